@@ -0,0 +1,131 @@
+/*
+MIT License
+
+Copyright (c) 2021 StorageOS
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Important: Run "make" to regenerate code after modifying this file
+
+const (
+	// PolicyResourceAll grants access to all resource types in the namespace.
+	PolicyResourceAll PolicyResourceType = "*"
+
+	// PolicyResourceVolume grants access to volumes in the namespace.
+	PolicyResourceVolume PolicyResourceType = "volume"
+
+	// PolicyResourcePolicy grants access to policies in the namespace.
+	PolicyResourcePolicy PolicyResourceType = "policy"
+
+	// PolicyGroupReady is the condition type set when the StorageOS policy
+	// group matches the spec.
+	PolicyGroupReady = "Ready"
+)
+
+// PolicyResourceType is the StorageOS resource type that a policy rule grants
+// access to.
+// +kubebuilder:validation:Enum="*";volume;policy
+type PolicyResourceType string
+
+// StoragePolicyRule grants access to StorageOS resources in a namespace.
+type StoragePolicyRule struct {
+	// Namespace is the name of the namespace that access is granted to.  The
+	// namespace must exist in StorageOS.
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// ResourceType is the type of resource that access is granted to.
+	// Defaults to all resource types.
+	// +optional
+	ResourceType PolicyResourceType `json:"resourceType,omitempty"`
+
+	// ReadOnly disallows requests that would modify the resources.
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+// StoragePolicyGroupSpec defines the desired state of StoragePolicyGroup.
+type StoragePolicyGroupSpec struct {
+	// Rules grant the members of the policy group access to namespaces.
+	// +optional
+	Rules []StoragePolicyRule `json:"rules,omitempty"`
+
+	// Users is the list of StorageOS usernames that are members of the policy
+	// group.  Users must already exist in StorageOS.
+	// +optional
+	Users []string `json:"users,omitempty"`
+}
+
+// StoragePolicyGroupStatus defines the observed state of StoragePolicyGroup.
+type StoragePolicyGroupStatus struct {
+	// PolicyGroupName is the name of the StorageOS policy group.
+	// +optional
+	PolicyGroupName string `json:"policyGroupName,omitempty"`
+
+	// PolicyGroupID is the ID of the StorageOS policy group.
+	// +optional
+	PolicyGroupID string `json:"policyGroupID,omitempty"`
+
+	// ObservedGeneration is the most recent generation applied to the
+	// StorageOS policy group.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the policy group sync.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Policy Group",type=string,JSONPath=`.status.policyGroupName`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// StoragePolicyGroup is the Schema for the storagepolicygroups API.  It
+// declares a StorageOS policy group, the namespace access it grants and its
+// member users.
+type StoragePolicyGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   StoragePolicyGroupSpec   `json:"spec,omitempty"`
+	Status StoragePolicyGroupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// StoragePolicyGroupList contains a list of StoragePolicyGroup.
+type StoragePolicyGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []StoragePolicyGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&StoragePolicyGroup{}, &StoragePolicyGroupList{})
+}
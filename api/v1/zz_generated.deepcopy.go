@@ -29,6 +29,7 @@ SOFTWARE.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -136,3 +137,124 @@ func (in *NodeStatus) DeepCopy() *NodeStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StoragePolicyGroup) DeepCopyInto(out *StoragePolicyGroup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StoragePolicyGroup.
+func (in *StoragePolicyGroup) DeepCopy() *StoragePolicyGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(StoragePolicyGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *StoragePolicyGroup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StoragePolicyGroupList) DeepCopyInto(out *StoragePolicyGroupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]StoragePolicyGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StoragePolicyGroupList.
+func (in *StoragePolicyGroupList) DeepCopy() *StoragePolicyGroupList {
+	if in == nil {
+		return nil
+	}
+	out := new(StoragePolicyGroupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *StoragePolicyGroupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StoragePolicyGroupSpec) DeepCopyInto(out *StoragePolicyGroupSpec) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]StoragePolicyRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StoragePolicyGroupSpec.
+func (in *StoragePolicyGroupSpec) DeepCopy() *StoragePolicyGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(StoragePolicyGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StoragePolicyGroupStatus) DeepCopyInto(out *StoragePolicyGroupStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StoragePolicyGroupStatus.
+func (in *StoragePolicyGroupStatus) DeepCopy() *StoragePolicyGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StoragePolicyGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StoragePolicyRule) DeepCopyInto(out *StoragePolicyRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StoragePolicyRule.
+func (in *StoragePolicyRule) DeepCopy() *StoragePolicyRule {
+	if in == nil {
+		return nil
+	}
+	out := new(StoragePolicyRule)
+	in.DeepCopyInto(out)
+	return out
+}
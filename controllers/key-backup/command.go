@@ -0,0 +1,178 @@
+package keybackup
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption"
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption/keys"
+	"github.com/storageos/api-manager/internal/pkg/crypto"
+)
+
+const (
+	// BackupCommand is the api-manager subcommand that writes a key backup.
+	BackupCommand = "backup-keys"
+
+	// RestoreCommand is the api-manager subcommand that restores a key backup.
+	RestoreCommand = "restore-keys"
+
+	// stdio is the file name used to read from stdin or write to stdout.
+	stdio = "-"
+)
+
+// IsCommand returns true if name is a key backup subcommand.
+func IsCommand(name string) bool {
+	return name == BackupCommand || name == RestoreCommand
+}
+
+// RunCommand runs the named key backup subcommand with the command line
+// arguments.  Backups are read from stdin and written to stdout when the file
+// name is "-".  Progress messages are written to stderr.
+func RunCommand(ctx context.Context, k8s client.Client, name string, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	switch name {
+	case BackupCommand:
+		return runBackup(ctx, k8s, args, stdout, stderr)
+	case RestoreCommand:
+		return runRestore(ctx, k8s, args, stdin, stderr)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// runBackup exports the namespace and volume key secrets, encrypted to a
+// public key, to a file or secret.
+func runBackup(ctx context.Context, k8s client.Client, args []string, stdout io.Writer, stderr io.Writer) error {
+	var namespace string
+	var publicKeyPath string
+	var output string
+	var toSecret string
+
+	fs := flag.NewFlagSet(BackupCommand, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&namespace, "namespace", metav1.NamespaceAll, "Only back up keys in this namespace.  All namespaces are backed up if unset.")
+	fs.StringVar(&publicKeyPath, "public-key", "", "Path of the PEM-encoded RSA public key to encrypt the backup to.")
+	fs.StringVar(&output, "output", "", "Path of the file to write the backup to, or \"-\" for stdout.")
+	fs.StringVar(&toSecret, "to-secret", "", "Secret to write the backup to, as namespace/name.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if publicKeyPath == "" {
+		return errors.New("-public-key must be set")
+	}
+	if output == "" && toSecret == "" {
+		return errors.New("-output or -to-secret must be set")
+	}
+
+	pem, err := ioutil.ReadFile(publicKeyPath)
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.ParseRSAPublicKey(pem)
+	if err != nil {
+		return err
+	}
+	backup, err := keys.New(k8s, nil).Export(ctx, namespace, encryption.NamespaceSecretName)
+	if err != nil {
+		return err
+	}
+	sealed, err := keys.Seal(backup, pub)
+	if err != nil {
+		return err
+	}
+
+	switch output {
+	case "":
+	case stdio:
+		if _, err := stdout.Write(sealed); err != nil {
+			return err
+		}
+	default:
+		if err := WriteFile(output, sealed); err != nil {
+			return err
+		}
+	}
+	if toSecret != "" {
+		ref, err := parseObjectKey(toSecret)
+		if err != nil {
+			return err
+		}
+		if err := WriteSecret(ctx, k8s, ref, sealed, nil); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(stderr, "backed up %d secrets\n", len(backup.Secrets))
+	return nil
+}
+
+// runRestore decrypts a backup from a file or secret with a private key, and
+// creates any key secrets that don't already exist.
+func runRestore(ctx context.Context, k8s client.Client, args []string, stdin io.Reader, stderr io.Writer) error {
+	var privateKeyPath string
+	var input string
+	var fromSecret string
+
+	fs := flag.NewFlagSet(RestoreCommand, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&privateKeyPath, "private-key", "", "Path of the PEM-encoded RSA private key to decrypt the backup with.")
+	fs.StringVar(&input, "input", "", "Path of the file to read the backup from, or \"-\" for stdin.")
+	fs.StringVar(&fromSecret, "from-secret", "", "Secret to read the backup from, as namespace/name.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if privateKeyPath == "" {
+		return errors.New("-private-key must be set")
+	}
+	if (input == "") == (fromSecret == "") {
+		return errors.New("exactly one of -input or -from-secret must be set")
+	}
+
+	pem, err := ioutil.ReadFile(privateKeyPath)
+	if err != nil {
+		return err
+	}
+	priv, err := crypto.ParseRSAPrivateKey(pem)
+	if err != nil {
+		return err
+	}
+
+	var sealed []byte
+	switch {
+	case input == stdio:
+		sealed, err = ioutil.ReadAll(stdin)
+	case input != "":
+		sealed, err = ioutil.ReadFile(input)
+	default:
+		var ref client.ObjectKey
+		if ref, err = parseObjectKey(fromSecret); err == nil {
+			sealed, err = ReadSecret(ctx, k8s, ref)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	backup, err := keys.Open(sealed, priv)
+	if err != nil {
+		return err
+	}
+	restored, err := keys.New(k8s, nil).Restore(ctx, backup)
+	fmt.Fprintf(stderr, "restored %d of %d secrets from backup created at %s\n", restored, len(backup.Secrets), backup.CreatedAt)
+	return err
+}
+
+// parseObjectKey parses a namespace/name reference.
+func parseObjectKey(s string) (client.ObjectKey, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return client.ObjectKey{}, fmt.Errorf("invalid secret reference %q, must be namespace/name", s)
+	}
+	return client.ObjectKey{Namespace: parts[0], Name: parts[1]}, nil
+}
@@ -0,0 +1,217 @@
+package keybackup
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption"
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption/keys"
+	"github.com/storageos/api-manager/internal/pkg/crypto"
+)
+
+const (
+	// BackupDataKey is the secret data key used to store the sealed backup
+	// when backups are written to a secret.
+	BackupDataKey = "backup"
+
+	// DefaultSecretName is the default name of the secret that backups are
+	// written to.
+	DefaultSecretName = "storageos-key-backup"
+)
+
+// ErrNoBackupTarget is returned when neither a file path nor a secret has been
+// set to write backups to.
+var ErrNoBackupTarget = errors.New("no backup file or secret set")
+
+// KeyExporter exports namespace and volume key secrets.
+type KeyExporter interface {
+	Export(ctx context.Context, namespace string, nsKeyName string) (*keys.Backup, error)
+}
+
+// Exporter periodically writes an encrypted backup of all namespace and volume
+// key secrets to a file and/or a secret.
+type Exporter struct {
+	client.Client
+	log           logr.Logger
+	keys          KeyExporter
+	publicKeyPath string
+	path          string
+	secretRef     *client.ObjectKey
+	labels        map[string]string
+	interval      time.Duration
+}
+
+var _ manager.Runnable = &Exporter{}
+var _ manager.LeaderElectionRunnable = &Exporter{}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+
+// NewExporter returns a new key backup exporter.  Backups are encrypted to the
+// PEM-encoded RSA public key at publicKeyPath, which is read before each
+// backup so that it can be replaced without a restart.
+//
+// If path is set, backups are written to the file, replacing any previous
+// backup.  If secretRef is set, backups are written to the secret, which is
+// created with labels if needed.  At least one must be set.
+func NewExporter(k8s client.Client, keys KeyExporter, publicKeyPath string, path string, secretRef *client.ObjectKey, labels map[string]string, interval time.Duration) *Exporter {
+	return &Exporter{
+		Client:        k8s,
+		log:           ctrl.Log.WithName("key-backup"),
+		keys:          keys,
+		publicKeyPath: publicKeyPath,
+		path:          path,
+		secretRef:     secretRef,
+		labels:        labels,
+		interval:      interval,
+	}
+}
+
+// SetupWithManager registers the exporter with the controller manager.
+func (e *Exporter) SetupWithManager(mgr ctrl.Manager) error {
+	if e.path == "" && e.secretRef == nil {
+		return ErrNoBackupTarget
+	}
+	return mgr.Add(e)
+}
+
+// NeedLeaderElection returns true so that only the leader writes backups.
+func (e *Exporter) NeedLeaderElection() bool {
+	return true
+}
+
+// Start writes a backup immediately and then on each interval, until the
+// context is cancelled.  Failed backups are logged and retried on the next
+// interval.
+func (e *Exporter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		if err := e.Backup(ctx); err != nil {
+			e.log.Error(err, "failed to back up encryption keys")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Backup writes a single encrypted backup of all namespace and volume key
+// secrets.
+func (e *Exporter) Backup(ctx context.Context) error {
+	tr := otel.Tracer("key-backup")
+	ctx, span := tr.Start(ctx, "key backup")
+	defer span.End()
+
+	pem, err := ioutil.ReadFile(e.publicKeyPath)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	pub, err := crypto.ParseRSAPublicKey(pem)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	backup, err := e.keys.Export(ctx, metav1.NamespaceAll, encryption.NamespaceSecretName)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	sealed, err := keys.Seal(backup, pub)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	span.SetAttributes(label.Int("secrets", len(backup.Secrets)))
+
+	if e.path != "" {
+		if err := WriteFile(e.path, sealed); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+	if e.secretRef != nil {
+		if err := WriteSecret(ctx, e.Client, *e.secretRef, sealed, e.labels); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+	span.SetStatus(codes.Ok, "encryption keys backed up")
+	e.log.Info("encryption keys backed up", "secrets", len(backup.Secrets))
+	return nil
+}
+
+// WriteFile writes the sealed backup to the file at path.  The backup is
+// written to a temporary file first and renamed so that a partially written
+// backup never replaces a complete one.
+func WriteFile(path string, sealed []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(sealed); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// WriteSecret writes the sealed backup to the secret at ref, creating it with
+// labels if it doesn't exist.
+func WriteSecret(ctx context.Context, k8s client.Client, ref client.ObjectKey, sealed []byte, labels map[string]string) error {
+	secret := &corev1.Secret{}
+	err := k8s.Get(ctx, ref, secret)
+	if apierrors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ref.Name,
+				Namespace: ref.Namespace,
+				Labels:    labels,
+			},
+			Data: map[string][]byte{BackupDataKey: sealed},
+		}
+		return k8s.Create(ctx, secret)
+	}
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data[BackupDataKey] = sealed
+	return k8s.Update(ctx, secret)
+}
+
+// ReadSecret returns the sealed backup stored in the secret at ref.
+func ReadSecret(ctx context.Context, k8s client.Client, ref client.ObjectKey) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := k8s.Get(ctx, ref, secret); err != nil {
+		return nil, err
+	}
+	sealed, ok := secret.Data[BackupDataKey]
+	if !ok || len(sealed) == 0 {
+		return nil, errors.New("secret does not contain a key backup")
+	}
+	return sealed, nil
+}
@@ -0,0 +1,87 @@
+package keybackup
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption"
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption/keys"
+)
+
+func TestExporter_Backup(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPath := filepath.Join(dir, "backup.pub")
+	if err := ioutil.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme).Build()
+	m := keys.New(k8s, nil)
+	nsKeyRef := client.ObjectKey{Name: encryption.NamespaceSecretName, Namespace: "default"}
+	if _, err := m.Ensure(ctx, nsKeyRef, client.ObjectKey{Name: "vol-1", Namespace: "default"}, nil, nil); err != nil {
+		t.Fatalf("failed to create keys: %v", err)
+	}
+
+	path := filepath.Join(dir, "keys.backup")
+	secretRef := client.ObjectKey{Name: DefaultSecretName, Namespace: "backup"}
+	e := NewExporter(k8s, m, pubPath, path, &secretRef, map[string]string{"app": "test"}, time.Hour)
+
+	// Run twice to check that existing backups are replaced.
+	for i := 0; i < 2; i++ {
+		if err := e.Backup(ctx); err != nil {
+			t.Fatalf("Backup() unexpected error: %v", err)
+		}
+	}
+
+	fromFile, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromSecret, err := ReadSecret(ctx, k8s, secretRef)
+	if err != nil {
+		t.Fatalf("ReadSecret() unexpected error: %v", err)
+	}
+	for name, sealed := range map[string][]byte{"file": fromFile, "secret": fromSecret} {
+		backup, err := keys.Open(sealed, priv)
+		if err != nil {
+			t.Fatalf("%s: Open() unexpected error: %v", name, err)
+		}
+		// The namespace and volume keys, but not the backup secret.
+		if len(backup.Secrets) != 2 {
+			t.Errorf("%s: backup has %d secrets, want 2", name, len(backup.Secrets))
+		}
+	}
+
+	if err := NewExporter(k8s, m, pubPath, "", nil, nil, time.Hour).SetupWithManager(nil); err != ErrNoBackupTarget {
+		t.Errorf("SetupWithManager() error = %v, want %v", err, ErrNoBackupTarget)
+	}
+}
@@ -0,0 +1,32 @@
+package keyrotation
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption"
+	"github.com/storageos/api-manager/internal/pkg/predicate"
+)
+
+// Predicate filters events before enqueuing the keys.  Only namespace key
+// secrets are processed.  Create events are needed so that existing secrets
+// are evaluated on startup, and update events will trigger a reconcile when
+// the rotation annotation is added.
+type Predicate struct {
+	predicate.IgnoreFuncs
+}
+
+// Create determines whether an object create should trigger a reconcile.
+func (p Predicate) Create(e event.CreateEvent) bool {
+	return isNamespaceKey(e.Object)
+}
+
+// Update determines whether an object update should trigger a reconcile.
+func (p Predicate) Update(e event.UpdateEvent) bool {
+	return isNamespaceKey(e.ObjectNew)
+}
+
+// isNamespaceKey returns true if the object is a namespace key secret.
+func isNamespaceKey(obj client.Object) bool {
+	return obj.GetName() == encryption.NamespaceSecretName
+}
@@ -0,0 +1,197 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption"
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption/keys"
+)
+
+// KeyRotator rotates namespace encryption keys, and upgrades volume keys to the
+// current format.
+type KeyRotator interface {
+	Rotate(ctx context.Context, nsKeyRef client.ObjectKey) error
+	Upgrade(ctx context.Context, nsKeyRef client.ObjectKey) error
+}
+
+// Reconciler reconciles namespace key secrets by rotating the namespace key
+// when requested, or when the rotation interval has passed.  Volume keys are
+// upgraded to the current format when requested.
+type Reconciler struct {
+	client.Client
+	log      logr.Logger
+	keys     KeyRotator
+	interval time.Duration
+	secrets  toolscache.Store
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update
+
+// NewReconciler returns a new namespace key rotation reconciler.
+//
+// The interval determines how often namespace keys should be rotated.  Set to
+// zero to only rotate keys on request.
+func NewReconciler(k8s client.Client, keys KeyRotator, interval time.Duration) *Reconciler {
+	return &Reconciler{
+		Client:   k8s,
+		log:      ctrl.Log.WithName("key-rotation"),
+		keys:     keys,
+		interval: interval,
+	}
+}
+
+// SetupWithManager registers the controller with the controller manager.
+//
+// Namespace key secrets are watched with their own informer, selected by name,
+// so that the manager cache does not hold every secret in the cluster.
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, workers int) error {
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	informer := toolscache.NewSharedIndexInformer(namespaceKeyListWatch(clientset), &corev1.Secret{}, 0, toolscache.Indexers{})
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		informer.Run(ctx.Done())
+		return nil
+	})); err != nil {
+		return err
+	}
+	r.secrets = informer.GetStore()
+
+	c, err := controller.New("key-rotation", mgr, controller.Options{Reconciler: r, MaxConcurrentReconciles: workers})
+	if err != nil {
+		return err
+	}
+	return c.Watch(&source.Informer{Informer: informer}, &handler.EnqueueRequestForObject{}, Predicate{})
+}
+
+// namespaceKeyListWatch returns a ListWatch for namespace key secrets in all
+// namespaces.
+func namespaceKeyListWatch(clientset kubernetes.Interface) *toolscache.ListWatch {
+	return toolscache.NewFilteredListWatchFromClient(clientset.CoreV1().RESTClient(), "secrets", metav1.NamespaceAll, func(opts *metav1.ListOptions) {
+		opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", encryption.NamespaceSecretName).String()
+	})
+}
+
+// Reconcile rotates the namespace key if rotation has been requested, is
+// overdue or was interrupted.  Otherwise it requeues for when the next
+// scheduled rotation is due.
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	secret, err := r.getSecret(ctx, req.NamespacedName)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	due, wait := r.rotationDue(secret, time.Now())
+	if !due {
+		// Rotation also upgrades volume keys, so only upgrade separately
+		// when not rotating.
+		if _, ok := secret.GetAnnotations()[keys.UpgradeAnnotationKey]; ok {
+			if err := r.upgrade(ctx, req); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	tr := otel.Tracer("key-rotation")
+	ctx, span := tr.Start(ctx, "namespace key rotate")
+	span.SetAttributes(label.String("namespace", req.Namespace))
+	defer span.End()
+
+	if err := r.keys.Rotate(ctx, req.NamespacedName); err != nil {
+		span.RecordError(err)
+		return ctrl.Result{}, err
+	}
+	span.SetStatus(codes.Ok, "namespace key rotated")
+	r.log.Info("namespace key rotated", "namespace", req.Namespace)
+
+	return ctrl.Result{RequeueAfter: r.interval}, nil
+}
+
+// upgrade upgrades the volume keys in the namespace to the current format.
+func (r *Reconciler) upgrade(ctx context.Context, req ctrl.Request) error {
+	tr := otel.Tracer("key-rotation")
+	ctx, span := tr.Start(ctx, "volume key upgrade")
+	span.SetAttributes(label.String("namespace", req.Namespace))
+	defer span.End()
+
+	if err := r.keys.Upgrade(ctx, req.NamespacedName); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	span.SetStatus(codes.Ok, "volume keys upgraded")
+	r.log.Info("volume keys upgraded", "namespace", req.Namespace, "version", keys.CurrentFormatVersion)
+	return nil
+}
+
+// getSecret returns a copy of the namespace key secret from the namespace key
+// informer, or reads it from the client if the informer has not been set up.
+func (r *Reconciler) getSecret(ctx context.Context, key client.ObjectKey) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	if r.secrets == nil {
+		if err := r.Get(ctx, key, secret); err != nil {
+			return nil, err
+		}
+		return secret, nil
+	}
+	obj, ok, err := r.secrets.GetByKey(key.String())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, apierrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+	}
+	cached, ok := obj.(*corev1.Secret)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T in namespace key informer", obj)
+	}
+	return cached.DeepCopy(), nil
+}
+
+// rotationDue returns true if the namespace key should be rotated now.
+// Otherwise it returns the time to wait until the next scheduled rotation, or
+// zero if scheduled rotation is disabled.
+func (r *Reconciler) rotationDue(secret *corev1.Secret, now time.Time) (bool, time.Duration) {
+	// Rotate on request, or resume an interrupted rotation.
+	if _, ok := secret.GetAnnotations()[keys.RotateAnnotationKey]; ok {
+		return true, 0
+	}
+	if keys.RotationInProgress(secret) {
+		return true, 0
+	}
+	if r.interval <= 0 {
+		return false, 0
+	}
+
+	// Keys that have never been rotated are measured from creation.
+	last := secret.GetCreationTimestamp().Time
+	if v, ok := secret.GetAnnotations()[keys.RotatedAtAnnotationKey]; ok {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			last = t
+		}
+	}
+	if wait := last.Add(r.interval).Sub(now); wait > 0 {
+		return false, wait
+	}
+	return true, 0
+}
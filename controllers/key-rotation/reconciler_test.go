@@ -0,0 +1,115 @@
+package keyrotation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption"
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption/keys"
+)
+
+func TestReconciler_rotationDue(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	created := metav1.NewTime(now.Add(-48 * time.Hour))
+
+	tests := []struct {
+		name        string
+		interval    time.Duration
+		annotations map[string]string
+		data        map[string][]byte
+		wantDue     bool
+		wantWait    time.Duration
+	}{
+		{
+			name:     "scheduled rotation disabled",
+			interval: 0,
+		},
+		{
+			name:        "requested",
+			interval:    0,
+			annotations: map[string]string{keys.RotateAnnotationKey: "true"},
+			wantDue:     true,
+		},
+		{
+			name:     "interrupted",
+			interval: 0,
+			data:     map[string][]byte{"key": []byte("a"), "next-key": []byte("b")},
+			wantDue:  true,
+		},
+		{
+			name:     "never rotated, overdue since creation",
+			interval: 24 * time.Hour,
+			wantDue:  true,
+		},
+		{
+			name:        "recently rotated",
+			interval:    24 * time.Hour,
+			annotations: map[string]string{keys.RotatedAtAnnotationKey: now.Add(-time.Hour).UTC().Format(time.RFC3339)},
+			wantWait:    23 * time.Hour,
+		},
+		{
+			name:        "invalid rotated time falls back to creation",
+			interval:    24 * time.Hour,
+			annotations: map[string]string{keys.RotatedAtAnnotationKey: "yesterday"},
+			wantDue:     true,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reconciler{interval: tt.interval}
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					CreationTimestamp: created,
+					Annotations:       tt.annotations,
+				},
+				Data: tt.data,
+			}
+			due, wait := r.rotationDue(secret, now)
+			if due != tt.wantDue {
+				t.Errorf("rotationDue() due = %v, want %v", due, tt.wantDue)
+			}
+			// RFC3339 truncates to seconds.
+			if wait.Round(time.Minute) != tt.wantWait {
+				t.Errorf("rotationDue() wait = %v, want %v", wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestReconciler_getSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := toolscache.NewStore(toolscache.MetaNamespaceKeyFunc)
+	cached := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: encryption.NamespaceSecretName, Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("a")},
+	}
+	if err := store.Add(cached); err != nil {
+		t.Fatal(err)
+	}
+	r := &Reconciler{secrets: store}
+
+	got, err := r.getSecret(ctx, client.ObjectKeyFromObject(cached))
+	if err != nil {
+		t.Fatalf("getSecret() error = %v", err)
+	}
+	got.Data["key"] = []byte("b")
+	if string(cached.Data["key"]) != "a" {
+		t.Error("getSecret() returned the cached secret, want a copy")
+	}
+
+	if _, err := r.getSecret(ctx, client.ObjectKey{Name: encryption.NamespaceSecretName, Namespace: "other"}); !apierrors.IsNotFound(err) {
+		t.Errorf("getSecret() error = %v, want not found", err)
+	}
+}
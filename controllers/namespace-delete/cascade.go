@@ -0,0 +1,196 @@
+package nsdelete
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/label"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/labels"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+// CascadePolicy determines whether volumes without a Kubernetes PV are deleted
+// from StorageOS namespaces that have been removed from Kubernetes, so that the
+// namespace can then be deleted.
+type CascadePolicy struct {
+	// Enabled turns on cascade deletes.
+	Enabled bool
+
+	// Retention is how long the Kubernetes namespace must have been deleted
+	// before its volumes are deleted.
+	//
+	// The deletion time is not persisted.  It is when this process first
+	// found the namespace deleted, so the retention period restarts whenever
+	// the api-manager restarts or leadership changes.  This can only delay
+	// volume deletion, never bring it forward.
+	Retention time.Duration
+
+	// DryRun logs the volumes that would be deleted, without deleting them.
+	DryRun bool
+
+	// Protected namespaces never have their volumes deleted.
+	Protected []string
+}
+
+// ParseProtectedNamespaces parses a comma-separated list of namespaces, as
+// used by the CascadePolicy.
+func ParseProtectedNamespaces(s string) []string {
+	return labels.SplitList(s)
+}
+
+// IsProtected returns true if the namespace is in the protected list.
+func (p CascadePolicy) IsProtected(namespace string) bool {
+	for _, ns := range p.Protected {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// absentNamespaces tracks when Kubernetes namespaces were first found to be
+// deleted by this process.  It is only held in memory: the time a namespace
+// was deleted can't be read from Kubernetes once it has gone, and a persisted
+// time could outlive a re-created namespace while the api-manager is not
+// running, deleting its volumes early.  It is safe for concurrent use.
+type absentNamespaces struct {
+	mu    sync.Mutex
+	since map[string]time.Time
+}
+
+// newAbsentNamespaces returns an empty absent namespace tracker.
+func newAbsentNamespaces() *absentNamespaces {
+	return &absentNamespaces{since: make(map[string]time.Time)}
+}
+
+// absentFor records the namespace as deleted, and returns how long it has been
+// deleted for.
+func (a *absentNamespaces) absentFor(name string, now time.Time) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	since, ok := a.since[name]
+	if !ok {
+		since = now
+		a.since[name] = since
+	}
+	return now.Sub(since)
+}
+
+// forget removes the namespace from the tracker.
+func (a *absentNamespaces) forget(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.since, name)
+}
+
+// retain removes namespaces that are not in the set from the tracker.
+func (a *absentNamespaces) retain(names map[string]bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for name := range a.since {
+		if !names[name] {
+			delete(a.since, name)
+		}
+	}
+}
+
+// cascade deletes the volumes in the StorageOS namespace that do not have a
+// Kubernetes PV, once the Kubernetes namespace has been deleted for longer than
+// the retention period.  It returns true if any volumes were deleted, so that
+// the namespace delete can be retried.
+//
+// Volumes that still have a PV are never deleted.  The namespace delete will
+// continue to fail until they are removed.
+func (c Controller) cascade(ctx context.Context, obj client.Object) (bool, error) {
+	if !c.cascadePolicy.Enabled {
+		return false, nil
+	}
+	name := obj.GetName()
+	if c.cascadePolicy.IsProtected(name) {
+		c.log.Info("namespace protected, not deleting remaining volumes", "name", name)
+		return false, nil
+	}
+	if absent := c.absent.absentFor(name, time.Now()); absent < c.cascadePolicy.Retention {
+		// The clock starts when this process first finds the namespace
+		// deleted, not when it was deleted in Kubernetes.
+		c.log.Info("namespace in retention period, not deleting remaining volumes", "name", name, "seen deleted for", absent.Round(time.Second).String(), "retention", c.cascadePolicy.Retention.String())
+		return false, nil
+	}
+
+	tr := otel.Tracer("namespace-delete")
+	ctx, span := tr.Start(ctx, "namespace cascade delete")
+	span.SetAttributes(label.String("name", name), label.Bool("dry-run", c.cascadePolicy.DryRun))
+	defer span.End()
+
+	vols, err := c.api.NamespaceVolumes(ctx, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		span.RecordError(err)
+		return false, err
+	}
+	orphans, err := c.orphanedVolumes(ctx, vols)
+	if err != nil {
+		span.RecordError(err)
+		return false, err
+	}
+	span.SetAttributes(label.Int("volumes", len(vols)), label.Int("orphaned volumes", len(orphans)))
+
+	if c.cascadePolicy.DryRun {
+		for _, vol := range orphans {
+			c.log.Info("dry-run: would delete volume without pv from deleted namespace", "namespace", name, "volume", vol.GetName())
+		}
+		c.log.Info("dry-run: namespace cascade delete report", "name", name, "volumes", len(vols), "would delete", len(orphans), "retained with pv", len(vols)-len(orphans))
+		return false, nil
+	}
+
+	var errs *multierror.Error
+	var deleted int
+	for _, vol := range orphans {
+		key := client.ObjectKey{Name: vol.GetName(), Namespace: name}
+		if err := c.api.DeleteVolume(ctx, key); err != nil && err != storageos.ErrVolumeNotFound {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		deleted++
+		c.log.Info("deleted volume without pv from deleted namespace", "namespace", name, "volume", vol.GetName())
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		span.RecordError(err)
+		return deleted > 0, err
+	}
+	return deleted > 0, nil
+}
+
+// orphanedVolumes returns the volumes that do not have a Kubernetes PV.  The PV
+// name is set on volumes provisioned by the CSI driver, otherwise the volume
+// name is used.
+func (c Controller) orphanedVolumes(ctx context.Context, vols []storageos.Object) ([]storageos.Object, error) {
+	pvs := &corev1.PersistentVolumeList{}
+	if err := c.k8s.List(ctx, pvs); err != nil {
+		return nil, err
+	}
+	pvNames := make(map[string]bool)
+	for _, pv := range pvs.Items {
+		pvNames[pv.GetName()] = true
+	}
+
+	var orphans []storageos.Object
+	for _, vol := range vols {
+		pvName := vol.GetLabels()[storageos.ReservedLabelK8sPVName]
+		if pvName == "" {
+			pvName = vol.GetName()
+		}
+		if !pvNames[pvName] {
+			orphans = append(orphans, vol)
+		}
+	}
+	return orphans, nil
+}
@@ -18,25 +18,41 @@ import (
 // namespaces in StorageOS when they have been detected as deleted in
 // Kubernetes.
 type Controller struct {
-	api NamespaceDeleter
-	log logr.Logger
+	api           NamespaceDeleter
+	k8s           client.Client
+	cascadePolicy CascadePolicy
+	absent        *absentNamespaces
+	log           logr.Logger
 }
 
 var _ syncv1.Controller = &Controller{}
 
 // NewController returns a Controller that implements namespace garbage
-// collection in StorageOS.
-func NewController(api NamespaceDeleter, log logr.Logger) (*Controller, error) {
-	return &Controller{api: api, log: log}, nil
+// collection in StorageOS.  Volumes without a PV are deleted from namespaces
+// that are still in use if allowed by the cascade policy.
+func NewController(api NamespaceDeleter, k8s client.Client, cascadePolicy CascadePolicy, log logr.Logger) (*Controller, error) {
+	return &Controller{
+		api:           api,
+		k8s:           k8s,
+		cascadePolicy: cascadePolicy,
+		absent:        newAbsentNamespaces(),
+		log:           log,
+	}, nil
 }
 
-// Ensure is a no-op.  We only care about deletes.
+// Ensure is a no-op, other than resetting the cascade retention period of
+// namespaces that were re-created while a delete was being retried.  We only
+// care about deletes.
 func (c Controller) Ensure(ctx context.Context, obj client.Object) error {
+	c.absent.forget(obj.GetName())
 	return nil
 }
 
 // Delete receives a k8s object that's been deleted and calls the StorageOS api
 // to remove it from management.
+//
+// If the namespace still has volumes, they are deleted if allowed by the
+// cascade policy and the namespace delete is retried.
 func (c Controller) Delete(ctx context.Context, obj client.Object) error {
 	tr := otel.Tracer("namespace-delete")
 	ctx, span := tr.Start(ctx, "namespace delete")
@@ -47,10 +63,20 @@ func (c Controller) Delete(ctx context.Context, obj client.Object) error {
 	defer cancel()
 
 	err := c.api.DeleteNamespace(ctx, client.ObjectKeyFromObject(obj))
+	if err == storageos.ErrNamespaceInUse {
+		deleted, cascadeErr := c.cascade(ctx, obj)
+		if cascadeErr != nil {
+			c.log.Error(cascadeErr, "failed to delete volumes from deleted namespace", "name", obj.GetName())
+		}
+		if deleted {
+			err = c.api.DeleteNamespace(ctx, client.ObjectKeyFromObject(obj))
+		}
+	}
 	if err != nil && err != storageos.ErrNamespaceNotFound {
 		span.RecordError(err)
 		return err
 	}
+	c.absent.forget(obj.GetName())
 	span.SetStatus(codes.Ok, "namespace decommissioned in storageos")
 	c.log.Info("namespace decommissioned in storageos", "name", obj.GetName())
 	return nil
@@ -75,5 +101,13 @@ func (c Controller) List(ctx context.Context) ([]types.NamespacedName, error) {
 	}
 	span.SetAttributes(label.Int("count", len(objects)))
 	span.SetStatus(codes.Ok, "listed namespaces")
+
+	// Forget namespaces that have since been removed.
+	names := make(map[string]bool)
+	for _, obj := range objects {
+		names[obj.GetName()] = true
+	}
+	c.absent.retain(names)
+
 	return storageos.ObjectKeys(objects), nil
 }
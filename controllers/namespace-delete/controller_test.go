@@ -0,0 +1,206 @@
+package nsdelete
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+const testNamespace = "test-ns"
+
+func TestControllerDeleteCascade(t *testing.T) {
+	t.Parallel()
+
+	orphan := storageos.MockObject{Name: "orphan", Namespace: testNamespace, Labels: map[string]string{storageos.ReservedLabelK8sPVName: "pvc-orphan"}}
+	bound := storageos.MockObject{Name: "bound", Namespace: testNamespace, Labels: map[string]string{storageos.ReservedLabelK8sPVName: "pvc-bound"}}
+	unlabelled := storageos.MockObject{Name: "pvc-unlabelled", Namespace: testNamespace}
+
+	tests := []struct {
+		name          string
+		policy        CascadePolicy
+		deletedFor    time.Duration
+		namespaceErr  error
+		wantErr       bool
+		wantDeleted   []storageos.MockObject
+		wantRetained  []storageos.MockObject
+		wantNamespace bool
+	}{
+		{
+			name:         "namespace deleted",
+			policy:       CascadePolicy{Enabled: true},
+			wantRetained: []storageos.MockObject{orphan, bound, unlabelled},
+		},
+		{
+			name:          "cascade disabled",
+			namespaceErr:  storageos.ErrNamespaceInUse,
+			wantErr:       true,
+			wantRetained:  []storageos.MockObject{orphan, bound, unlabelled},
+			wantNamespace: true,
+		},
+		{
+			name:          "retention period not elapsed",
+			policy:        CascadePolicy{Enabled: true, Retention: time.Hour},
+			deletedFor:    time.Minute,
+			namespaceErr:  storageos.ErrNamespaceInUse,
+			wantErr:       true,
+			wantRetained:  []storageos.MockObject{orphan, bound, unlabelled},
+			wantNamespace: true,
+		},
+		{
+			name:          "protected namespace",
+			policy:        CascadePolicy{Enabled: true, Protected: []string{"other", testNamespace}},
+			namespaceErr:  storageos.ErrNamespaceInUse,
+			wantErr:       true,
+			wantRetained:  []storageos.MockObject{orphan, bound, unlabelled},
+			wantNamespace: true,
+		},
+		{
+			name:          "dry-run",
+			policy:        CascadePolicy{Enabled: true, DryRun: true},
+			namespaceErr:  storageos.ErrNamespaceInUse,
+			wantErr:       true,
+			wantRetained:  []storageos.MockObject{orphan, bound, unlabelled},
+			wantNamespace: true,
+		},
+		{
+			name:          "delete volumes without pv",
+			policy:        CascadePolicy{Enabled: true, Retention: time.Hour},
+			deletedFor:    2 * time.Hour,
+			namespaceErr:  storageos.ErrNamespaceInUse,
+			wantErr:       true,
+			wantDeleted:   []storageos.MockObject{orphan},
+			wantRetained:  []storageos.MockObject{bound, unlabelled},
+			wantNamespace: true,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}}
+			nsKey := client.ObjectKeyFromObject(ns)
+
+			k8s := fake.NewClientBuilder().WithScheme(kscheme.Scheme).WithObjects(
+				&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pvc-bound"}},
+				&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pvc-unlabelled"}},
+			).Build()
+
+			api := storageos.NewMockClient()
+			if err := api.AddNamespace(nsKey); err != nil {
+				t.Fatal(err)
+			}
+			for _, vol := range []storageos.MockObject{orphan, bound, unlabelled} {
+				if err := api.AddVolume(vol); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// The mock does not check for volumes when deleting namespaces.
+			api.DeleteNamespaceErr = tt.namespaceErr
+
+			c, err := NewController(api, k8s, tt.policy, ctrl.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.deletedFor > 0 {
+				c.absent.since[testNamespace] = time.Now().Add(-tt.deletedFor)
+			}
+
+			if err := c.Delete(ctx, ns); (err != nil) != tt.wantErr {
+				t.Errorf("Delete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, vol := range tt.wantDeleted {
+				if api.VolumeExists(storageos.ObjectKeyFromObject(vol)) {
+					t.Errorf("volume %q not deleted", vol.GetName())
+				}
+			}
+			for _, vol := range tt.wantRetained {
+				if !api.VolumeExists(storageos.ObjectKeyFromObject(vol)) {
+					t.Errorf("volume %q deleted", vol.GetName())
+				}
+			}
+			if got := api.NamespaceExists(nsKey); got != tt.wantNamespace {
+				t.Errorf("namespace exists = %t, want %t", got, tt.wantNamespace)
+			}
+			// The namespace delete is retried once volumes have been deleted.
+			wantCalls := 1
+			if len(tt.wantDeleted) > 0 {
+				wantCalls = 2
+			}
+			if got := api.DeleteNamespaceCallCount[nsKey]; got != wantCalls {
+				t.Errorf("DeleteNamespace() called %d times, want %d", got, wantCalls)
+			}
+		})
+	}
+}
+
+func TestControllerListForgetsDeletedNamespaces(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	k8s := fake.NewClientBuilder().WithScheme(kscheme.Scheme).Build()
+	api := storageos.NewMockClient()
+	if err := api.AddNamespace(client.ObjectKey{Name: testNamespace}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewController(api, k8s, CascadePolicy{Enabled: true}, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.absent.since[testNamespace] = time.Now()
+	c.absent.since["removed"] = time.Now()
+
+	if _, err := c.List(ctx); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if _, ok := c.absent.since[testNamespace]; !ok {
+		t.Error("namespace in storageos forgotten")
+	}
+	if _, ok := c.absent.since["removed"]; ok {
+		t.Error("namespace removed from storageos not forgotten")
+	}
+}
+
+func TestParseProtectedNamespaces(t *testing.T) {
+	t.Parallel()
+
+	p := CascadePolicy{Protected: ParseProtectedNamespaces("kube-system, default,,")}
+	for _, ns := range []string{"kube-system", "default"} {
+		if !p.IsProtected(ns) {
+			t.Errorf("IsProtected(%q) = false, want true", ns)
+		}
+	}
+	if p.IsProtected(testNamespace) {
+		t.Errorf("IsProtected(%q) = true, want false", testNamespace)
+	}
+}
+
+func TestPredicateCreate(t *testing.T) {
+	t.Parallel()
+
+	p := Predicate{absent: newAbsentNamespaces()}
+	p.absent.since[testNamespace] = time.Now()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}}
+	if p.Create(event.CreateEvent{Object: ns}) {
+		t.Error("Predicate.Create() = true, want false")
+	}
+	if _, ok := p.absent.since[testNamespace]; ok {
+		t.Error("re-created namespace not forgotten")
+	}
+	if !p.Delete(event.DeleteEvent{Object: ns}) {
+		t.Error("Predicate.Delete() = false, want true")
+	}
+}
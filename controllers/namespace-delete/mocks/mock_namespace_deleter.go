@@ -50,6 +50,20 @@ func (mr *MockNamespaceDeleterMockRecorder) DeleteNamespace(arg0, arg1 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteNamespace", reflect.TypeOf((*MockNamespaceDeleter)(nil).DeleteNamespace), arg0, arg1)
 }
 
+// DeleteVolume mocks base method.
+func (m *MockNamespaceDeleter) DeleteVolume(arg0 context.Context, arg1 types.NamespacedName) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteVolume", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteVolume indicates an expected call of DeleteVolume.
+func (mr *MockNamespaceDeleterMockRecorder) DeleteVolume(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteVolume", reflect.TypeOf((*MockNamespaceDeleter)(nil).DeleteVolume), arg0, arg1)
+}
+
 // ListNamespaces mocks base method.
 func (m *MockNamespaceDeleter) ListNamespaces(arg0 context.Context) ([]storageos.Object, error) {
 	m.ctrl.T.Helper()
@@ -64,3 +78,18 @@ func (mr *MockNamespaceDeleterMockRecorder) ListNamespaces(arg0 interface{}) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListNamespaces", reflect.TypeOf((*MockNamespaceDeleter)(nil).ListNamespaces), arg0)
 }
+
+// NamespaceVolumes mocks base method.
+func (m *MockNamespaceDeleter) NamespaceVolumes(arg0 context.Context, arg1 types.NamespacedName) ([]storageos.Object, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NamespaceVolumes", arg0, arg1)
+	ret0, _ := ret[0].([]storageos.Object)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NamespaceVolumes indicates an expected call of NamespaceVolumes.
+func (mr *MockNamespaceDeleterMockRecorder) NamespaceVolumes(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NamespaceVolumes", reflect.TypeOf((*MockNamespaceDeleter)(nil).NamespaceVolumes), arg0, arg1)
+}
@@ -8,8 +8,21 @@ import (
 
 // Predicate filters events before enqueuing the keys.  Ignore all but Delete
 // events.
+//
+// Namespaces that are created are removed from the absent namespace tracker,
+// so that the cascade retention period restarts if they are deleted again.
 type Predicate struct {
 	predicate.IgnoreFuncs
+	absent *absentNamespaces
+}
+
+// Create resets the cascade retention period for the namespace.  It never
+// triggers a reconcile.
+func (p Predicate) Create(e event.CreateEvent) bool {
+	if p.absent != nil {
+		p.absent.forget(e.Object.GetName())
+	}
+	return false
 }
 
 // Delete determines whether an object delete should trigger a reconcile.
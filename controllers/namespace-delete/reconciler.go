@@ -21,6 +21,8 @@ import (
 type NamespaceDeleter interface {
 	DeleteNamespace(ctx context.Context, key client.ObjectKey) error
 	ListNamespaces(ctx context.Context) ([]storageos.Object, error)
+	NamespaceVolumes(ctx context.Context, key client.ObjectKey) ([]storageos.Object, error)
+	DeleteVolume(ctx context.Context, key client.ObjectKey) error
 }
 
 // Reconciler reconciles a Namespace object by deleting the StorageOS namespace
@@ -31,29 +33,33 @@ type Reconciler struct {
 	api        NamespaceDeleter
 	gcDelay    time.Duration
 	gcInterval time.Duration
+	cascade    CascadePolicy
 
 	objectv1.Reconciler
 }
 
 // +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumes,verbs=get;list;watch
 
 // NewReconciler returns a new Namespace delete reconciler.
 //
 // The gcInterval determines how often the periodic resync operation should be
-// run.
-func NewReconciler(api NamespaceDeleter, k8s client.Client, gcDelay time.Duration, gcInterval time.Duration) *Reconciler {
+// run.  The cascade policy determines whether volumes are deleted from
+// namespaces that are still in use.
+func NewReconciler(api NamespaceDeleter, k8s client.Client, gcDelay time.Duration, gcInterval time.Duration, cascade CascadePolicy) *Reconciler {
 	return &Reconciler{
 		Client:     k8s,
 		log:        ctrl.Log,
 		api:        api,
 		gcDelay:    gcDelay,
 		gcInterval: gcInterval,
+		cascade:    cascade,
 	}
 }
 
 // SetupWithManager registers the controller with the controller manager.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, workers int) error {
-	c, err := NewController(r.api, r.log)
+	c, err := NewController(r.api, r.Client, r.cascade, r.log)
 	if err != nil {
 		return err
 	}
@@ -74,6 +80,6 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, workers int) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		WithOptions(controller.Options{MaxConcurrentReconciles: workers}).
 		For(&corev1.Namespace{}).
-		WithEventFilter(Predicate{}).
+		WithEventFilter(Predicate{absent: c.absent}).
 		Complete(r)
 }
@@ -0,0 +1,141 @@
+package nssync
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+
+	msyncv1 "github.com/darkowlzz/operator-toolkit/controller/metadata-sync/v1"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+// SyncAnnotationKey is the Kubernetes namespace annotation that enables sync to
+// a StorageOS namespace.  It must be set to "true".
+const SyncAnnotationKey = "storageos.com/namespace-sync"
+
+// Controller implements the Sync contoller interface, creating StorageOS
+// namespaces and applying namespace labels to them.
+type Controller struct {
+	api NamespaceSyncer
+	log logr.Logger
+}
+
+var _ msyncv1.Controller = &Controller{}
+
+// NewController returns a Controller that implements namespace sync in
+// StorageOS.
+func NewController(api NamespaceSyncer, log logr.Logger) (*Controller, error) {
+	return &Controller{api: api, log: log}, nil
+}
+
+// SyncEnabled returns true if the sync annotation is set on the namespace.
+func SyncEnabled(obj client.Object) bool {
+	enabled, _ := strconv.ParseBool(obj.GetAnnotations()[SyncAnnotationKey])
+	return enabled
+}
+
+// Ensure creates the StorageOS namespace if it doesn't exist, and applies the
+// labels set on the k8s namespace to it.  Namespaces without the sync
+// annotation are ignored.
+//
+// StorageOS reserved labels can't be set on namespaces and are not applied.
+//
+// Any errors will result in a requeue, with standard back-off retries.
+//
+// There is no label sync from StorageOS to Kubernetes.  This is intentional to
+// ensure a simple flow of desired state set by users in Kubernetes to actual
+// state set on the StorageOS namespace.
+func (c Controller) Ensure(ctx context.Context, obj client.Object) error {
+	if !SyncEnabled(obj) {
+		return nil
+	}
+
+	tr := otel.Tracer("namespace-sync")
+	ctx, span := tr.Start(ctx, "namespace sync ensure")
+	span.SetAttributes(label.String("name", obj.GetName()))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+
+	if err := c.api.EnsureNamespace(ctx, client.ObjectKey{Name: obj.GetName()}, desiredLabels(obj)); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	span.SetStatus(codes.Ok, "namespace synced to storageos")
+	c.log.Info("namespace synced to storageos", "name", obj.GetName())
+	return nil
+}
+
+// Diff takes a list of Kubernetes namespace objects and returns those with the
+// sync annotation that either don't exist in StorageOS or have different
+// labels.
+func (c Controller) Diff(ctx context.Context, objs []client.Object) ([]client.Object, error) {
+	tr := otel.Tracer("namespace-sync")
+	ctx, span := tr.Start(ctx, "namespace sync diff")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+
+	var apply []client.Object
+
+	namespaces, err := c.api.NamespaceObjects(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, obj := range objs {
+		if !SyncEnabled(obj) {
+			continue
+		}
+		ns, ok := namespaces[client.ObjectKey{Name: obj.GetName()}]
+		if !ok || ns == nil {
+			apply = append(apply, obj)
+			continue
+		}
+		if !labelsEqual(desiredLabels(obj), withoutReserved(ns.GetLabels())) {
+			apply = append(apply, obj)
+		}
+	}
+	span.SetAttributes(label.Int("stale namespaces", len(apply)))
+	span.SetStatus(codes.Ok, "compared namespaces")
+	return apply, nil
+}
+
+// Delete is a no-op.  The namespace-delete controller will handle deletes.
+func (c Controller) Delete(ctx context.Context, obj client.Object) error {
+	return nil
+}
+
+// desiredLabels returns the labels to apply to the StorageOS namespace.
+func desiredLabels(obj client.Object) map[string]string {
+	return withoutReserved(obj.GetLabels())
+}
+
+// withoutReserved returns a copy of the labels without StorageOS reserved
+// labels.
+func withoutReserved(labels map[string]string) map[string]string {
+	ret := make(map[string]string)
+	for k, v := range labels {
+		if !storageos.IsReservedLabel(k) {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+// labelsEqual returns true if the label sets are equal.  Nil and empty sets
+// are treated as equal.
+func labelsEqual(a, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,212 @@
+package nssync
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+const testNamespace = "test-ns"
+
+var syncAnnotation = map[string]string{SyncAnnotationKey: "true"}
+
+func genNamespace(name string, labels map[string]string, annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}
+}
+
+func TestControllerEnsure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		labels      map[string]string
+		existing    bool
+		wantCreated bool
+		wantLabels  map[string]string
+	}{
+		{
+			name: "sync not enabled",
+		},
+		{
+			name:        "sync disabled",
+			annotations: map[string]string{SyncAnnotationKey: "false"},
+		},
+		{
+			name:        "create namespace",
+			annotations: syncAnnotation,
+			labels:      map[string]string{"team": "a"},
+			wantCreated: true,
+			wantLabels:  map[string]string{"team": "a"},
+		},
+		{
+			name:        "update namespace",
+			annotations: syncAnnotation,
+			labels:      map[string]string{"team": "b"},
+			existing:    true,
+			wantCreated: true,
+			wantLabels:  map[string]string{"team": "b"},
+		},
+		{
+			name:        "reserved labels not applied",
+			annotations: syncAnnotation,
+			labels:      map[string]string{"team": "a", storageos.ReservedLabelReplicas: "1"},
+			wantCreated: true,
+			wantLabels:  map[string]string{"team": "a"},
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			key := client.ObjectKey{Name: testNamespace}
+
+			api := storageos.NewMockClient()
+			if tt.existing {
+				if err := api.AddNamespace(key); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c, err := NewController(api, ctrl.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Ensure(ctx, genNamespace(testNamespace, tt.labels, tt.annotations)); err != nil {
+				t.Fatalf("Ensure() error = %v", err)
+			}
+
+			namespaces, err := api.NamespaceObjects(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ns, ok := namespaces[key]
+			if ok != tt.wantCreated {
+				t.Fatalf("namespace exists = %t, want %t", ok, tt.wantCreated)
+			}
+			if ok && !reflect.DeepEqual(ns.GetLabels(), tt.wantLabels) {
+				t.Errorf("namespace labels = %v, want %v", ns.GetLabels(), tt.wantLabels)
+			}
+		})
+	}
+}
+
+func TestControllerDiff(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	api := storageos.NewMockClient()
+	for name, labels := range map[string]map[string]string{
+		"in-sync":     {"team": "a"},
+		"stale":       {"team": "a"},
+		"no-labels":   nil,
+		"not-enabled": nil,
+	} {
+		if err := api.EnsureNamespace(ctx, client.ObjectKey{Name: name}, labels); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objs := []client.Object{
+		genNamespace("in-sync", map[string]string{"team": "a", storageos.ReservedLabelReplicas: "1"}, syncAnnotation),
+		genNamespace("stale", map[string]string{"team": "b"}, syncAnnotation),
+		genNamespace("no-labels", map[string]string{}, syncAnnotation),
+		genNamespace("missing", nil, syncAnnotation),
+		genNamespace("not-enabled", map[string]string{"team": "a"}, nil),
+		genNamespace("not-enabled-missing", nil, nil),
+	}
+
+	c, err := NewController(api, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Diff(ctx, objs)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	var gotNames []string
+	for _, obj := range got {
+		gotNames = append(gotNames, obj.GetName())
+	}
+	if want := []string{"stale", "missing"}; !reflect.DeepEqual(gotNames, want) {
+		t.Errorf("Diff() = %v, want %v", gotNames, want)
+	}
+}
+
+func TestPredicate(t *testing.T) {
+	t.Parallel()
+
+	teamA := map[string]string{"team": "a"}
+	teamB := map[string]string{"team": "b"}
+
+	tests := []struct {
+		name   string
+		oldObj *corev1.Namespace
+		newObj *corev1.Namespace
+		want   bool
+	}{
+		{
+			name:   "not enabled",
+			oldObj: genNamespace(testNamespace, teamA, nil),
+			newObj: genNamespace(testNamespace, teamB, nil),
+		},
+		{
+			name:   "enabled",
+			oldObj: genNamespace(testNamespace, teamA, nil),
+			newObj: genNamespace(testNamespace, teamA, syncAnnotation),
+			want:   true,
+		},
+		{
+			name:   "disabled",
+			oldObj: genNamespace(testNamespace, teamA, syncAnnotation),
+			newObj: genNamespace(testNamespace, teamB, nil),
+		},
+		{
+			name:   "labels changed",
+			oldObj: genNamespace(testNamespace, teamA, syncAnnotation),
+			newObj: genNamespace(testNamespace, teamB, syncAnnotation),
+			want:   true,
+		},
+		{
+			name:   "labels unchanged",
+			oldObj: genNamespace(testNamespace, teamA, syncAnnotation),
+			newObj: genNamespace(testNamespace, teamA, map[string]string{SyncAnnotationKey: "true", "other": "annotation"}),
+		},
+		{
+			name:   "reserved label changed",
+			oldObj: genNamespace(testNamespace, teamA, syncAnnotation),
+			newObj: genNamespace(testNamespace, map[string]string{"team": "a", storageos.ReservedLabelReplicas: "1"}, syncAnnotation),
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := Predicate{}
+			if got := p.Update(event.UpdateEvent{ObjectOld: tt.oldObj, ObjectNew: tt.newObj}); got != tt.want {
+				t.Errorf("Predicate.Update() = %t, want %t", got, tt.want)
+			}
+			if got := p.Create(event.CreateEvent{Object: tt.newObj}); got != SyncEnabled(tt.newObj) {
+				t.Errorf("Predicate.Create() = %t, want %t", got, SyncEnabled(tt.newObj))
+			}
+			if p.Delete(event.DeleteEvent{Object: tt.newObj}) {
+				t.Error("Predicate.Delete() = true, want false")
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/storageos/api-manager/controllers/namespace-sync (interfaces: NamespaceSyncer)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	storageos "github.com/storageos/api-manager/internal/pkg/storageos"
+	types "k8s.io/apimachinery/pkg/types"
+)
+
+// MockNamespaceSyncer is a mock of NamespaceSyncer interface.
+type MockNamespaceSyncer struct {
+	ctrl     *gomock.Controller
+	recorder *MockNamespaceSyncerMockRecorder
+}
+
+// MockNamespaceSyncerMockRecorder is the mock recorder for MockNamespaceSyncer.
+type MockNamespaceSyncerMockRecorder struct {
+	mock *MockNamespaceSyncer
+}
+
+// NewMockNamespaceSyncer creates a new mock instance.
+func NewMockNamespaceSyncer(ctrl *gomock.Controller) *MockNamespaceSyncer {
+	mock := &MockNamespaceSyncer{ctrl: ctrl}
+	mock.recorder = &MockNamespaceSyncerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNamespaceSyncer) EXPECT() *MockNamespaceSyncerMockRecorder {
+	return m.recorder
+}
+
+// EnsureNamespace mocks base method.
+func (m *MockNamespaceSyncer) EnsureNamespace(arg0 context.Context, arg1 types.NamespacedName, arg2 map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureNamespace", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnsureNamespace indicates an expected call of EnsureNamespace.
+func (mr *MockNamespaceSyncerMockRecorder) EnsureNamespace(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureNamespace", reflect.TypeOf((*MockNamespaceSyncer)(nil).EnsureNamespace), arg0, arg1, arg2)
+}
+
+// NamespaceObjects mocks base method.
+func (m *MockNamespaceSyncer) NamespaceObjects(arg0 context.Context) (map[types.NamespacedName]storageos.Object, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NamespaceObjects", arg0)
+	ret0, _ := ret[0].(map[types.NamespacedName]storageos.Object)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NamespaceObjects indicates an expected call of NamespaceObjects.
+func (mr *MockNamespaceSyncerMockRecorder) NamespaceObjects(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NamespaceObjects", reflect.TypeOf((*MockNamespaceSyncer)(nil).NamespaceObjects), arg0)
+}
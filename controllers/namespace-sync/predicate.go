@@ -0,0 +1,39 @@
+package nssync
+
+import (
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/storageos/api-manager/internal/pkg/predicate"
+)
+
+// Predicate filters events before enqueuing the keys.  Ignore all but Create
+// and Update events, and then filter out events from namespaces without the
+// sync annotation.  Trigger a sync when the annotation has been added, or when
+// the labels have changed.
+//
+// Deletes are handled by the namespace-delete controller.
+type Predicate struct {
+	predicate.IgnoreFuncs
+}
+
+// Create determines whether an object create should trigger a reconcile.
+func (p Predicate) Create(e event.CreateEvent) bool {
+	return SyncEnabled(e.Object)
+}
+
+// Update determines whether an object update should trigger a reconcile.
+func (p Predicate) Update(e event.UpdateEvent) bool {
+	// Ignore namespaces that don't have sync enabled.
+	if !SyncEnabled(e.ObjectNew) {
+		return false
+	}
+
+	// Reconcile if sync was just enabled.
+	if !SyncEnabled(e.ObjectOld) {
+		return true
+	}
+
+	// Otherwise reconcile on label changes.
+	return !k8slabels.Equals(desiredLabels(e.ObjectOld), desiredLabels(e.ObjectNew))
+}
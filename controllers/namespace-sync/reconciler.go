@@ -0,0 +1,79 @@
+package nssync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	msyncv1 "github.com/darkowlzz/operator-toolkit/controller/metadata-sync/v1"
+	syncv1 "github.com/darkowlzz/operator-toolkit/controller/sync/v1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+// NamespaceSyncer provides access to create and update namespaces.
+//go:generate mockgen -build_flags=--mod=vendor -destination=mocks/mock_namespace_syncer.go -package=mocks . NamespaceSyncer
+type NamespaceSyncer interface {
+	EnsureNamespace(ctx context.Context, key client.ObjectKey, labels map[string]string) error
+	NamespaceObjects(ctx context.Context) (map[client.ObjectKey]storageos.Object, error)
+}
+
+// Reconciler reconciles a Namespace object by creating the StorageOS namespace
+// and applying labels from the Kubernetes namespace to it.
+type Reconciler struct {
+	client.Client
+	log            logr.Logger
+	api            NamespaceSyncer
+	resyncDelay    time.Duration
+	resyncInterval time.Duration
+
+	msyncv1.Reconciler
+}
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// NewReconciler returns a new Namespace sync reconciler.
+//
+// The resyncInterval determines how often the periodic resync operation should
+// be run.
+func NewReconciler(api NamespaceSyncer, k8s client.Client, resyncDelay time.Duration, resyncInterval time.Duration) *Reconciler {
+	return &Reconciler{
+		Client:         k8s,
+		log:            ctrl.Log,
+		api:            api,
+		resyncDelay:    resyncDelay,
+		resyncInterval: resyncInterval,
+	}
+}
+
+// SetupWithManager registers the controller with the controller manager.
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, workers int) error {
+	c, err := NewController(r.api, r.log)
+	if err != nil {
+		return err
+	}
+
+	// Set the resync interval.
+	r.Reconciler.SetStartupSyncDelay(r.resyncDelay)
+	r.Reconciler.SetResyncPeriod(r.resyncInterval)
+
+	// Initialize the reconciler.
+	err = r.Reconciler.Init(mgr, c, &corev1.Namespace{}, &corev1.NamespaceList{},
+		syncv1.WithName("namespace-sync"),
+		syncv1.WithLogger(r.log),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create new reconciler: %w", err)
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{MaxConcurrentReconciles: workers}).
+		For(&corev1.Namespace{}).
+		WithEventFilter(Predicate{}).
+		Complete(r)
+}
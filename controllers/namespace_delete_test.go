@@ -46,7 +46,7 @@ func SetupNamespaceDeleteTest(ctx context.Context, createK8sNamespace bool, gcEn
 			gcInterval = time.Hour
 		}
 
-		controller := nsdelete.NewReconciler(api, mgr.GetClient(), defaultSyncDelay, gcInterval)
+		controller := nsdelete.NewReconciler(api, mgr.GetClient(), defaultSyncDelay, gcInterval, nsdelete.CascadePolicy{})
 		err = controller.SetupWithManager(mgr, defaultWorkers)
 		Expect(err).NotTo(HaveOccurred(), "failed to setup controller")
 
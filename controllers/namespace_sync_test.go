@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nssync "github.com/storageos/api-manager/controllers/namespace-sync"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+// SetupNamespaceSyncTest will set up a testing environment.  It must be
+// called from each test.
+func SetupNamespaceSyncTest(ctx context.Context, annotations map[string]string, createLabels map[string]string) client.ObjectKey {
+	var ns *corev1.Namespace
+	var key = client.ObjectKey{Name: "testns-" + randStringRunes(5)}
+	var cancel func()
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(ctx)
+
+		api = storageos.NewMockClient()
+
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{MetricsBindAddress: "0"})
+		Expect(err).NotTo(HaveOccurred(), "failed to create manager")
+
+		controller := nssync.NewReconciler(api, mgr.GetClient(), defaultSyncDelay, defaultSyncInterval)
+		err = controller.SetupWithManager(mgr, defaultWorkers)
+		Expect(err).NotTo(HaveOccurred(), "failed to setup controller")
+
+		go func() {
+			err := mgr.Start(ctx)
+			Expect(err).NotTo(HaveOccurred(), "failed to start manager")
+		}()
+
+		// Wait for manager to be ready.
+		time.Sleep(managerWaitDuration)
+
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        key.Name,
+				Labels:      createLabels,
+				Annotations: annotations,
+			},
+		}
+		err = k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred(), "failed to create test namespace")
+	})
+
+	AfterEach(func() {
+		err := k8sClient.Delete(ctx, ns)
+		Expect(err).NotTo(HaveOccurred(), "failed to delete test namespace")
+		cancel()
+	})
+
+	return key
+}
+
+// storageosNamespaceLabels returns the labels of the StorageOS namespace, and
+// false if it does not exist.
+func storageosNamespaceLabels(ctx context.Context, key client.ObjectKey) (map[string]string, bool) {
+	namespaces, err := api.NamespaceObjects(ctx)
+	Expect(err).NotTo(HaveOccurred(), "failed to list storageos namespaces")
+	ns, ok := namespaces[key]
+	if !ok {
+		return nil, false
+	}
+	return ns.GetLabels(), true
+}
+
+var _ = Describe("Namespace Sync controller", func() {
+	// Define utility constants for object names and testing timeouts/durations
+	// and intervals.
+	const (
+		timeout  = time.Second * 10
+		duration = time.Second * 2
+		interval = time.Millisecond * 250
+	)
+
+	ctx := context.Background()
+
+	Context("When creating an annotated k8s Namespace", func() {
+		key := SetupNamespaceSyncTest(ctx, map[string]string{nssync.SyncAnnotationKey: "true"}, map[string]string{"team": "a"})
+		It("Should create the StorageOS Namespace with the labels", func() {
+			By("Expecting StorageOS Namespace to be created")
+			Eventually(func() string {
+				labels, _ := storageosNamespaceLabels(ctx, key)
+				return labels["team"]
+			}, timeout, interval).Should(Equal("a"))
+
+			By("By updating the k8s Namespace labels")
+			var ns corev1.Namespace
+			Expect(k8sClient.Get(ctx, key, &ns)).Should(Succeed())
+			ns.Labels["team"] = "b"
+			Expect(k8sClient.Update(ctx, &ns)).Should(Succeed())
+
+			By("Expecting StorageOS Namespace labels to be updated")
+			Eventually(func() string {
+				labels, _ := storageosNamespaceLabels(ctx, key)
+				return labels["team"]
+			}, timeout, interval).Should(Equal("b"))
+		})
+	})
+
+	Context("When creating a k8s Namespace without the annotation", func() {
+		key := SetupNamespaceSyncTest(ctx, nil, map[string]string{"team": "a"})
+		It("Should not create the StorageOS Namespace until annotated", func() {
+			By("Expecting StorageOS Namespace not to be created")
+			Consistently(func() bool {
+				_, ok := storageosNamespaceLabels(ctx, key)
+				return ok
+			}, duration, interval).Should(BeFalse())
+
+			By("By adding the annotation to the k8s Namespace")
+			var ns corev1.Namespace
+			Expect(k8sClient.Get(ctx, key, &ns)).Should(Succeed())
+			ns.Annotations = map[string]string{nssync.SyncAnnotationKey: "true"}
+			Expect(k8sClient.Update(ctx, &ns)).Should(Succeed())
+
+			By("Expecting StorageOS Namespace to be created")
+			Eventually(func() bool {
+				_, ok := storageosNamespaceLabels(ctx, key)
+				return ok
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+})
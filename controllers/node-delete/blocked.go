@@ -0,0 +1,165 @@
+package nodedelete
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+const (
+	// NodeDeleteBlockedReason is the event reason used when a node has been
+	// blocked from deletion for longer than the threshold.
+	NodeDeleteBlockedReason = "NodeDeleteBlocked"
+
+	// BlockedInUse is the blocked metric reason for nodes that still have
+	// volume deployments.
+	BlockedInUse = "in-use"
+
+	// BlockedLocked is the blocked metric reason for nodes whose lock has not
+	// yet expired.
+	BlockedLocked = "locked"
+
+	// maxBlockedVolumes is the maximum number of volumes listed in a blocked
+	// reason.
+	maxBlockedVolumes = 5
+)
+
+// blockedNode records why a node delete is blocked.
+type blockedNode struct {
+	since  time.Time
+	reason string
+}
+
+// blockedNodes tracks StorageOS nodes that can't be deleted yet.  It is safe
+// for concurrent use.
+type blockedNodes struct {
+	mu    sync.Mutex
+	nodes map[string]blockedNode
+}
+
+// newBlockedNodes returns an empty blocked node tracker.
+func newBlockedNodes() *blockedNodes {
+	return &blockedNodes{nodes: make(map[string]blockedNode)}
+}
+
+// block records the node as blocked for the reason, and returns how long it has
+// been blocked for.
+func (b *blockedNodes) block(name string, reason string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	node, ok := b.nodes[name]
+	if !ok {
+		node.since = now
+	}
+	node.reason = reason
+	b.nodes[name] = node
+	b.updateMetric()
+
+	return now.Sub(node.since)
+}
+
+// unblock removes the node from the tracker.
+func (b *blockedNodes) unblock(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.nodes, name)
+	b.updateMetric()
+}
+
+// retain removes nodes that are not in the set from the tracker.
+func (b *blockedNodes) retain(names map[string]bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for name := range b.nodes {
+		if !names[name] {
+			delete(b.nodes, name)
+		}
+	}
+	b.updateMetric()
+}
+
+// updateMetric sets the blocked metric for each reason.  The lock must be held.
+func (b *blockedNodes) updateMetric() {
+	counts := map[string]int{BlockedInUse: 0, BlockedLocked: 0}
+	for _, node := range b.nodes {
+		counts[node.reason]++
+	}
+	for reason, count := range counts {
+		BlockedNodes.Set(reason, count)
+	}
+}
+
+// blockedReason returns the metric reason for a DeleteNode error, and false if
+// the error does not block the delete.
+func blockedReason(err error) (string, bool) {
+	switch err {
+	case storageos.ErrNodeInUse:
+		return BlockedInUse, true
+	case storageos.ErrNodeHasLock:
+		return BlockedLocked, true
+	}
+	return "", false
+}
+
+// describeVolumes returns a description of the volumes with deployments on the
+// node.
+func describeVolumes(vols []storageos.NodeVolume) string {
+	if len(vols) == 0 {
+		return "no volume deployments found"
+	}
+	var desc []string
+	for i, vol := range vols {
+		if i == maxBlockedVolumes {
+			desc = append(desc, fmt.Sprintf("and %d more", len(vols)-maxBlockedVolumes))
+			break
+		}
+		deployment := "replica"
+		if vol.Master {
+			deployment = "master"
+		}
+		desc = append(desc, fmt.Sprintf("%s (%s)", vol.Key, deployment))
+	}
+	return "volume deployments on node: " + strings.Join(desc, ", ")
+}
+
+// recordBlocked records that the node delete was blocked by err, listing the
+// volumes still deployed on the node.  A warning event is recorded once the
+// node has been blocked for longer than the threshold.  The original error is
+// returned so that the delete is retried.
+func (c Controller) recordBlocked(ctx context.Context, obj client.Object, err error) error {
+	reason, ok := blockedReason(err)
+	if !ok {
+		return err
+	}
+
+	apiCtx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+
+	desc := err.Error()
+	if reason == BlockedInUse {
+		vols, verr := c.api.NodeVolumes(apiCtx, client.ObjectKeyFromObject(obj))
+		if verr != nil {
+			c.log.Error(verr, "failed to list volumes on blocked node", "name", obj.GetName())
+		} else {
+			desc = fmt.Sprintf("%s, %s", desc, describeVolumes(vols))
+		}
+	}
+
+	blockedFor := c.blocked.block(obj.GetName(), reason, time.Now())
+	c.log.Info("node delete blocked", "name", obj.GetName(), "reason", desc, "blocked", blockedFor.Round(time.Second).String())
+
+	if blockedFor >= c.blockedThreshold {
+		c.recordEvent(obj, corev1.EventTypeWarning, NodeDeleteBlockedReason, fmt.Sprintf("Node delete blocked for %s: %s", blockedFor.Round(time.Second), desc))
+	}
+	return err
+}
@@ -0,0 +1,227 @@
+package nodedelete
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+func TestControllerDeleteBlocked(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		deleteNodeErr error
+		threshold     time.Duration
+		wantErr       error
+		wantBlocked   string
+		wantEvent     string
+	}{
+		{
+			name: "deleted",
+		},
+		{
+			name:          "in use",
+			deleteNodeErr: storageos.ErrNodeInUse,
+			threshold:     time.Hour,
+			wantErr:       storageos.ErrNodeInUse,
+			wantBlocked:   BlockedInUse,
+		},
+		{
+			name:          "in use past threshold",
+			deleteNodeErr: storageos.ErrNodeInUse,
+			wantErr:       storageos.ErrNodeInUse,
+			wantBlocked:   BlockedInUse,
+			wantEvent:     "Warning " + NodeDeleteBlockedReason + " Node delete blocked for 0s: node still in use, volume deployments on node: default/pv-pvc1 (master)",
+		},
+		{
+			name:          "locked past threshold",
+			deleteNodeErr: storageos.ErrNodeHasLock,
+			wantErr:       storageos.ErrNodeHasLock,
+			wantBlocked:   BlockedLocked,
+			wantEvent:     "Warning " + NodeDeleteBlockedReason + " Node delete blocked for 0s: node lock has not yet expired",
+		},
+		{
+			name:          "other error",
+			deleteNodeErr: errors.New("boom"),
+			wantErr:       errors.New("boom"),
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			node := genNode(nil)
+			nodeKey := client.ObjectKeyFromObject(node)
+			k8s := fake.NewClientBuilder().WithScheme(kscheme.Scheme).Build()
+
+			api := storageos.NewMockClient()
+			if err := api.AddNode(storageos.MockObject{Name: testNode}); err != nil {
+				t.Fatal(err)
+			}
+			api.SetNodeVolumes(nodeKey, []storageos.NodeVolume{genNodeVolume("pvc1", true, 0, 0)})
+			api.DeleteNodeErr = tt.deleteNodeErr
+
+			recorder := record.NewFakeRecorder(10)
+			c, err := NewController(api, k8s, recorder, false, 0, tt.threshold, ctrl.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.Delete(ctx, node)
+			if fmt.Sprint(err) != fmt.Sprint(tt.wantErr) {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.wantErr)
+			}
+
+			got := c.blocked.nodes[testNode].reason
+			if got != tt.wantBlocked {
+				t.Errorf("blocked reason = %q, want %q", got, tt.wantBlocked)
+			}
+
+			select {
+			case got := <-recorder.Events:
+				if got != tt.wantEvent {
+					t.Errorf("got event %q, want %q", got, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected event %q, got none", tt.wantEvent)
+				}
+			}
+
+			// Once the node can be deleted, it is no longer blocked.
+			api.DeleteNodeErr = nil
+			if err := c.Delete(ctx, node); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, ok := c.blocked.nodes[testNode]; ok {
+				t.Error("node still blocked after delete")
+			}
+		})
+	}
+}
+
+func TestBlockedNodes(t *testing.T) {
+	t.Parallel()
+
+	b := newBlockedNodes()
+	start := time.Now()
+
+	if got := b.block("node1", BlockedLocked, start); got != 0 {
+		t.Errorf("first block() = %s, want 0s", got)
+	}
+	if got := b.block("node1", BlockedInUse, start.Add(time.Minute)); got != time.Minute {
+		t.Errorf("second block() = %s, want 1m0s", got)
+	}
+	if got := b.nodes["node1"].reason; got != BlockedInUse {
+		t.Errorf("reason = %q, want %q", got, BlockedInUse)
+	}
+
+	b.block("node2", BlockedInUse, start)
+	b.retain(map[string]bool{"node2": true})
+	if _, ok := b.nodes["node1"]; ok {
+		t.Error("node1 retained, want removed")
+	}
+	if _, ok := b.nodes["node2"]; !ok {
+		t.Error("node2 removed, want retained")
+	}
+}
+
+func TestDescribeVolumes(t *testing.T) {
+	t.Parallel()
+
+	var vols []storageos.NodeVolume
+	for i := 0; i < maxBlockedVolumes+2; i++ {
+		vols = append(vols, genNodeVolume(fmt.Sprintf("pvc%d", i), i == 0, 1, 1))
+	}
+
+	tests := []struct {
+		name string
+		vols []storageos.NodeVolume
+		want string
+	}{
+		{
+			name: "none",
+			want: "no volume deployments found",
+		},
+		{
+			name: "master and replica",
+			vols: vols[:2],
+			want: "volume deployments on node: default/pv-pvc0 (master), default/pv-pvc1 (replica)",
+		},
+		{
+			name: "truncated",
+			vols: vols,
+			want: "and 2 more",
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := describeVolumes(tt.vols); !strings.HasSuffix(got, tt.want) {
+				t.Errorf("describeVolumes() = %q, want suffix %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestControllerListOrphaned(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	k8sNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	k8s := fake.NewClientBuilder().WithScheme(kscheme.Scheme).WithObjects(k8sNode).Build()
+
+	api := storageos.NewMockClient()
+	for _, name := range []string{"node1", "node2", "node3"} {
+		if err := api.AddNode(storageos.MockObject{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := NewController(api, k8s, record.NewFakeRecorder(10), true, 0, time.Hour, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.blocked.block("node2", BlockedInUse, time.Now())
+	c.blocked.block("node4", BlockedInUse, time.Now())
+
+	keys, err := c.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	names := make(map[string]bool)
+	for _, key := range keys {
+		names[key.Name] = true
+	}
+
+	got, err := c.orphanedNodes(ctx, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 2 {
+		t.Errorf("orphanedNodes() = %d, want 2", got)
+	}
+	if _, ok := c.blocked.nodes["node4"]; ok {
+		t.Error("blocked node4 retained after it was removed from storageos")
+	}
+	if _, ok := c.blocked.nodes["node2"]; !ok {
+		t.Error("blocked node2 removed, want retained")
+	}
+}
@@ -2,41 +2,114 @@ package nodedelete
 
 import (
 	"context"
+	"time"
 
 	syncv1 "github.com/darkowlzz/operator-toolkit/controller/sync/v1"
 	"github.com/go-logr/logr"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/label"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/storageos/api-manager/internal/pkg/storageos"
 )
 
 // Controller implements the SyncReconciler contoller interface, deleting nodes
-// in StorageOS when they have been detected as deleted in Kubernetes.
+// in StorageOS when they have been detected as deleted in Kubernetes, or when
+// decommission has been requested.
 type Controller struct {
-	api NodeDeleter
-	log logr.Logger
+	api              NodeDeleter
+	k8s              client.Client
+	recorder         record.EventRecorder
+	evacuate         bool
+	gracePeriod      time.Duration
+	absent           *absentNodes
+	protected        *protectedNodes
+	blocked          *blockedNodes
+	blockedThreshold time.Duration
+	log              logr.Logger
 }
 
 var _ syncv1.Controller = &Controller{}
 
 // NewController returns a Controller that implements node garbage collection in
-// StorageOS.
-func NewController(api NodeDeleter, log logr.Logger) (*Controller, error) {
-	return &Controller{api: api, log: log}, nil
+// StorageOS.  If evacuate is set, volumes are moved off the node before it is
+// deleted.  Nodes are only deleted once the Kubernetes node has been absent for
+// the gracePeriod.  A warning event is recorded when a node delete has been
+// blocked for longer than blockedThreshold.
+func NewController(api NodeDeleter, k8s client.Client, recorder record.EventRecorder, evacuate bool, gracePeriod time.Duration, blockedThreshold time.Duration, log logr.Logger) (*Controller, error) {
+	// Register prometheus metrics.
+	RegisterMetrics()
+
+	return &Controller{
+		api:              api,
+		k8s:              k8s,
+		recorder:         recorder,
+		evacuate:         evacuate,
+		gracePeriod:      gracePeriod,
+		absent:           newAbsentNodes(),
+		protected:        newProtectedNodes(),
+		blocked:          newBlockedNodes(),
+		blockedThreshold: blockedThreshold,
+		log:              log,
+	}, nil
 }
 
-// Ensure is a no-op.  We only care about deletes.
+// Ensure decommissions the node in StorageOS if the decommission annotation is
+// set on the Kubernetes node.  If the annotation has been removed, any changes
+// made by a partial decommission are reverted.  Decommission of protected nodes
+// is paused until the protection is removed.
+//
+// The node's protection is recorded on the StorageOS node, so that it is kept
+// if the Kubernetes node is deleted.
 func (c Controller) Ensure(ctx context.Context, obj client.Object) error {
-	return nil
+	// The node exists, so it is no longer absent.
+	c.absent.forget(obj.GetName())
+
+	if err := c.ensureProtection(ctx, obj); err != nil {
+		return err
+	}
+	if !DecommissionRequested(obj) {
+		return c.cancelDecommission(ctx, obj)
+	}
+	if Protected(obj) {
+		c.recordEvent(obj, corev1.EventTypeWarning, DecommissionProtectedReason, "Decommission paused, remove the "+ProtectNodeKey+" label or annotation to continue")
+		c.log.Info("decommission paused for protected node", "name", obj.GetName())
+		return nil
+	}
+	return c.decommission(ctx, obj)
 }
 
 // Delete receives a k8s object that's been deleted and calls the StorageOS api
-// to remove it from management.
+// to remove it from management.  If evacuate is set, volumes are moved off the
+// node first.
+//
+// The node is not deleted if it was protected when the Kubernetes node was
+// deleted, or until the Kubernetes node has been absent for the grace period.
+//
+// If StorageOS does not allow the node to be deleted yet, the reason is
+// recorded and the delete is retried.
 func (c Controller) Delete(ctx context.Context, obj client.Object) error {
+	protected, err := c.isProtected(ctx, obj.GetName())
+	if err != nil {
+		return err
+	}
+	if protected {
+		c.log.Info("not removing protected node from storageos", "name", obj.GetName())
+		return nil
+	}
+	if absent := c.absent.absentFor(obj.GetName(), time.Now()); absent < c.gracePeriod {
+		c.log.Info("waiting for grace period before removing node from storageos", "name", obj.GetName(), "absent", absent.Round(time.Second).String(), "grace", c.gracePeriod.String())
+		return ErrGracePeriod
+	}
+
+	if c.evacuate {
+		return c.decommission(ctx, obj)
+	}
+
 	tr := otel.Tracer("node-delete")
 	ctx, span := tr.Start(ctx, "node delete")
 	span.SetAttributes(label.String("name", obj.GetName()))
@@ -45,20 +118,36 @@ func (c Controller) Delete(ctx context.Context, obj client.Object) error {
 	ctx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
 	defer cancel()
 
-	err := c.api.DeleteNode(ctx, client.ObjectKeyFromObject(obj))
+	err = c.api.DeleteNode(ctx, client.ObjectKeyFromObject(obj))
 	if err != nil && err != storageos.ErrNodeNotFound {
 		span.RecordError(err)
-		return err
+		return c.recordBlocked(ctx, obj, err)
 	}
+	c.forget(obj.GetName())
 	span.SetStatus(codes.Ok, "node decommissioned in storageos")
 	c.log.Info("node decommissioned in storageos", "name", obj.GetName())
 	return nil
 }
 
+// forget removes all state kept for a node that has been removed from
+// StorageOS.
+func (c Controller) forget(name string) {
+	c.blocked.unblock(name)
+	c.absent.forget(name)
+	c.protected.set(name, false)
+}
+
 // List returns a list of nodes known to StorageOS, as NamespacedNames. This is
 // used for garbage collection and can be expensive. The garbage collector is
 // run in a separate goroutine periodically, not affecting the main
 // reconciliation control-loop.
+//
+// StorageOS nodes that were protected when their Kubernetes node was deleted
+// are not returned, so they are not garbage collected.  The protection is
+// removed from StorageOS nodes that have a Kubernetes node without protection.
+//
+// The number of StorageOS nodes without a matching Kubernetes node is recorded
+// in the orphaned nodes metric.
 func (c Controller) List(ctx context.Context) ([]types.NamespacedName, error) {
 	tr := otel.Tracer("node-delete")
 	ctx, span := tr.Start(ctx, "node list")
@@ -75,9 +164,108 @@ func (c Controller) List(ctx context.Context) ([]types.NamespacedName, error) {
 	span.SetAttributes(label.Int("count", len(nodes)))
 	span.SetStatus(codes.Ok, "listed nodes")
 
+	k8sNodes, err := c.k8sNodes(ctx)
+	if err != nil {
+		// Not fatal, garbage collection can still continue.  Protected nodes
+		// are treated as absent, so they are kept.
+		c.log.Error(err, "failed to list kubernetes nodes")
+	}
+
 	keys := []types.NamespacedName{}
+	names := make(map[string]bool)
 	for _, n := range nodes {
+		names[n.GetName()] = true
+		if storageos.NodeProtected(n.GetLabels()) {
+			k8sNode, exists := k8sNodes[n.GetName()]
+			if !exists || Protected(k8sNode) {
+				c.protected.set(n.GetName(), true)
+				continue
+			}
+			if err := c.api.EnsureNodeProtected(ctx, client.ObjectKeyFromObject(n), false); err != nil {
+				c.log.Error(err, "failed to remove protection from storageos node", "name", n.GetName())
+			}
+		}
 		keys = append(keys, types.NamespacedName{Name: n.GetName()})
 	}
+
+	// Forget nodes that have since been removed.
+	c.blocked.retain(names)
+	c.absent.retain(names)
+	c.protected.retain(names)
+
+	orphaned, err := c.orphanedNodes(ctx, names)
+	if err != nil {
+		// Not fatal, garbage collection can still continue.
+		c.log.Error(err, "failed to count storageos nodes without a kubernetes node")
+	} else {
+		span.SetAttributes(label.Int("orphaned", orphaned))
+		OrphanedNodes.Set(orphaned)
+	}
+
 	return keys, nil
 }
+
+// orphanedNodes returns the number of StorageOS nodes without a matching
+// Kubernetes node.
+func (c Controller) orphanedNodes(ctx context.Context, names map[string]bool) (int, error) {
+	k8sNodes := &corev1.NodeList{}
+	if err := c.k8s.List(ctx, k8sNodes); err != nil {
+		return 0, err
+	}
+	orphaned := len(names)
+	for _, n := range k8sNodes.Items {
+		if names[n.GetName()] {
+			orphaned--
+		}
+	}
+	return orphaned, nil
+}
+
+// k8sNodes returns the Kubernetes nodes, indexed on name.
+func (c Controller) k8sNodes(ctx context.Context) (map[string]*corev1.Node, error) {
+	list := &corev1.NodeList{}
+	if err := c.k8s.List(ctx, list); err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]*corev1.Node, len(list.Items))
+	for i := range list.Items {
+		nodes[list.Items[i].GetName()] = &list.Items[i]
+	}
+	return nodes, nil
+}
+
+// ensureProtection records whether the Kubernetes node is protected on the
+// StorageOS node.
+func (c Controller) ensureProtection(ctx context.Context, obj client.Object) error {
+	ctx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+
+	err := c.api.EnsureNodeProtected(ctx, client.ObjectKeyFromObject(obj), Protected(obj))
+	if err != nil && err != storageos.ErrNodeNotFound {
+		return err
+	}
+	return nil
+}
+
+// isProtected returns true if the node was protected when the Kubernetes node
+// was deleted, either as recorded by the predicate or on the StorageOS node.
+func (c Controller) isProtected(ctx context.Context, name string) (bool, error) {
+	if c.protected.has(name) {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+
+	nodes, err := c.api.ListNodes(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, n := range nodes {
+		if n.GetName() == name && storageos.NodeProtected(n.GetLabels()) {
+			c.protected.set(name, true)
+			return true, nil
+		}
+	}
+	return false, nil
+}
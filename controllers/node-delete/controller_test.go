@@ -0,0 +1,511 @@
+package nodedelete
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	pvclabel "github.com/storageos/api-manager/controllers/pvc-label"
+	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+const testNode = "node1"
+
+func genNode(annotations map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        testNode,
+			Annotations: annotations,
+		},
+	}
+}
+
+func genPVC(name string, annotations map[string]string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: annotations,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName: "pv-" + name,
+		},
+	}
+}
+
+// genNodeVolume returns a volume with its master or a replica on the node.
+// Volumes with a replica on the node have a healthy master elsewhere.
+func genNodeVolume(pvc string, master bool, replicas int, readyElsewhere int) storageos.NodeVolume {
+	vol := storageos.NodeVolume{
+		Key:                    client.ObjectKey{Name: "pv-" + pvc, Namespace: "default"},
+		Master:                 master,
+		Replica:                !master,
+		Replicas:               replicas,
+		ReadyElsewhere:         readyElsewhere,
+		ReadyReplicasElsewhere: readyElsewhere,
+	}
+	if !master && readyElsewhere > 0 {
+		vol.ReadyReplicasElsewhere--
+	}
+	if pvc != "" {
+		vol.PVC = client.ObjectKey{Name: pvc, Namespace: "default"}
+	}
+	return vol
+}
+
+func raisedBy(node string, original string) map[string]string {
+	return map[string]string{
+		DecommissionNodeAnnotationKey:     node,
+		DecommissionReplicasAnnotationKey: original,
+	}
+}
+
+func attemptedBy(node string, attempts string) map[string]string {
+	return map[string]string{
+		DecommissionNodeAnnotationKey:     node,
+		DecommissionAttemptsAnnotationKey: attempts,
+	}
+}
+
+func TestControllerDecommission(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		nodeMissing   bool
+		volumes       []storageos.NodeVolume
+		pvcs          []*corev1.PersistentVolumeClaim
+		deleteNodeErr error
+		wantErr       error
+		wantDeleted   bool
+		wantReplicas  map[string]uint64
+		wantRaisedBy  map[string]string
+		wantAttempts  map[string]int
+		wantEvents    []string
+	}{
+		{
+			name:        "no volumes",
+			wantDeleted: true,
+			wantEvents:  []string{"Normal " + NodeDecommissionedReason},
+		},
+		{
+			name:        "node not in storageos",
+			nodeMissing: true,
+			wantDeleted: true,
+		},
+		{
+			name:         "add replica",
+			volumes:      []storageos.NodeVolume{genNodeVolume("pvc1", false, 1, 1)},
+			pvcs:         []*corev1.PersistentVolumeClaim{genPVC("pvc1", nil)},
+			wantErr:      ErrDecommissionInProgress,
+			wantReplicas: map[string]uint64{"pvc1": 2},
+			wantRaisedBy: map[string]string{"pvc1": testNode},
+			wantEvents:   []string{"Normal " + DecommissionEvacuatingReason},
+		},
+		{
+			name:         "wait for replica sync",
+			volumes:      []storageos.NodeVolume{genNodeVolume("pvc1", false, 2, 1)},
+			pvcs:         []*corev1.PersistentVolumeClaim{genPVC("pvc1", raisedBy(testNode, "1"))},
+			wantErr:      ErrDecommissionInProgress,
+			wantRaisedBy: map[string]string{"pvc1": testNode},
+			wantEvents:   []string{"Normal " + DecommissionEvacuatingReason},
+		},
+		{
+			name:         "remove replica from node",
+			volumes:      []storageos.NodeVolume{genNodeVolume("pvc1", false, 2, 2)},
+			pvcs:         []*corev1.PersistentVolumeClaim{genPVC("pvc1", raisedBy(testNode, "1"))},
+			wantErr:      ErrDecommissionInProgress,
+			wantReplicas: map[string]uint64{"pvc1": 1},
+			wantAttempts: map[string]int{"pvc1": 1},
+			wantEvents:   []string{"Normal " + DecommissionEvacuatingReason},
+		},
+		{
+			name:         "wait for replicas elsewhere",
+			volumes:      []storageos.NodeVolume{genNodeVolume("pvc1", false, 3, 2)},
+			pvcs:         []*corev1.PersistentVolumeClaim{genPVC("pvc1", raisedBy(testNode, "2"))},
+			wantErr:      ErrDecommissionInProgress,
+			wantRaisedBy: map[string]string{"pvc1": testNode},
+			wantEvents:   []string{"Normal " + DecommissionEvacuatingReason},
+		},
+		{
+			name:         "retry replica still on node",
+			volumes:      []storageos.NodeVolume{genNodeVolume("pvc1", false, 1, 1)},
+			pvcs:         []*corev1.PersistentVolumeClaim{genPVC("pvc1", attemptedBy(testNode, "1"))},
+			wantErr:      ErrDecommissionInProgress,
+			wantReplicas: map[string]uint64{"pvc1": 2},
+			wantRaisedBy: map[string]string{"pvc1": testNode},
+			wantAttempts: map[string]int{"pvc1": 1},
+			wantEvents:   []string{"Normal " + DecommissionEvacuatingReason},
+		},
+		{
+			name:         "attempts exhausted",
+			volumes:      []storageos.NodeVolume{genNodeVolume("pvc1", false, 1, 1)},
+			pvcs:         []*corev1.PersistentVolumeClaim{genPVC("pvc1", attemptedBy(testNode, "3"))},
+			wantErr:      ErrDecommissionInProgress,
+			wantAttempts: map[string]int{"pvc1": DecommissionMaxAttempts},
+			wantEvents:   []string{"Warning " + DecommissionBlockedReason, "Normal " + DecommissionEvacuatingReason},
+		},
+		{
+			name:         "master blocked until node offline",
+			volumes:      []storageos.NodeVolume{genNodeVolume("pvc1", true, 2, 2)},
+			pvcs:         []*corev1.PersistentVolumeClaim{genPVC("pvc1", raisedBy(testNode, "1"))},
+			wantErr:      ErrDecommissionInProgress,
+			wantRaisedBy: map[string]string{"pvc1": testNode},
+			wantEvents:   []string{"Warning " + DecommissionBlockedReason, "Normal " + DecommissionEvacuatingReason},
+		},
+		{
+			name:         "restore replicas after move",
+			pvcs:         []*corev1.PersistentVolumeClaim{genPVC("pvc1", raisedBy(testNode, "1")), genPVC("pvc2", raisedBy("node2", "0")), genPVC("pvc3", attemptedBy(testNode, "1"))},
+			wantDeleted:  true,
+			wantReplicas: map[string]uint64{"pvc1": 1},
+			wantRaisedBy: map[string]string{"pvc2": "node2"},
+			wantEvents:   []string{"Normal " + NodeDecommissionedReason},
+		},
+		{
+			name:         "replica added by other node",
+			volumes:      []storageos.NodeVolume{genNodeVolume("pvc1", false, 2, 2)},
+			pvcs:         []*corev1.PersistentVolumeClaim{genPVC("pvc1", raisedBy("node2", "1"))},
+			wantErr:      ErrDecommissionInProgress,
+			wantRaisedBy: map[string]string{"pvc1": "node2"},
+			wantEvents:   []string{"Normal " + DecommissionEvacuatingReason},
+		},
+		{
+			name:       "max replicas",
+			volumes:    []storageos.NodeVolume{genNodeVolume("pvc1", false, storageos.MaxReplicas, storageos.MaxReplicas)},
+			pvcs:       []*corev1.PersistentVolumeClaim{genPVC("pvc1", nil)},
+			wantErr:    ErrDecommissionInProgress,
+			wantEvents: []string{"Warning " + DecommissionBlockedReason, "Normal " + DecommissionEvacuatingReason},
+		},
+		{
+			name:       "volume without pvc",
+			volumes:    []storageos.NodeVolume{genNodeVolume("", false, 1, 1)},
+			wantErr:    ErrDecommissionInProgress,
+			wantEvents: []string{"Warning " + DecommissionBlockedReason, "Normal " + DecommissionEvacuatingReason},
+		},
+		{
+			name:       "pvc not found",
+			volumes:    []storageos.NodeVolume{genNodeVolume("pvc1", false, 1, 1)},
+			wantErr:    ErrDecommissionInProgress,
+			wantEvents: []string{"Warning " + DecommissionBlockedReason, "Normal " + DecommissionEvacuatingReason},
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			node := genNode(map[string]string{DecommissionAnnotationKey: "true"})
+			nodeKey := client.ObjectKeyFromObject(node)
+
+			builder := fake.NewClientBuilder().WithScheme(kscheme.Scheme)
+			for _, pvc := range tt.pvcs {
+				builder = builder.WithObjects(pvc)
+			}
+			k8s := builder.Build()
+
+			api := storageos.NewMockClient()
+			if !tt.nodeMissing {
+				if err := api.AddNode(storageos.MockObject{Name: testNode}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			api.SetNodeVolumes(nodeKey, tt.volumes)
+			api.DeleteNodeErr = tt.deleteNodeErr
+
+			recorder := record.NewFakeRecorder(10)
+			c, err := NewController(api, k8s, recorder, true, 0, time.Hour, ctrl.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := c.Ensure(ctx, node); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Ensure() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !tt.nodeMissing {
+				if got := api.IsComputeOnly(nodeKey); got != true {
+					t.Errorf("compute-only = %t, want true", got)
+				}
+			}
+			if got := !api.NodeExists(nodeKey); got != tt.wantDeleted {
+				t.Errorf("node deleted = %t, want %t", got, tt.wantDeleted)
+			}
+
+			for _, pvc := range tt.pvcs {
+				volKey := client.ObjectKey{Name: pvc.Spec.VolumeName, Namespace: pvc.Namespace}
+				got, ok := api.GetReplicas(volKey)
+				want, wantOK := tt.wantReplicas[pvc.Name]
+				if ok != wantOK || got != want {
+					t.Errorf("%s replicas = %d (set %t), want %d (set %t)", pvc.Name, got, ok, want, wantOK)
+				}
+
+				current := &corev1.PersistentVolumeClaim{}
+				if err := k8s.Get(ctx, client.ObjectKeyFromObject(pvc), current); err != nil {
+					t.Fatal(err)
+				}
+				gotNode, _, _ := decommissionState(current)
+				if gotNode != tt.wantRaisedBy[pvc.Name] {
+					t.Errorf("%s decommission node = %q, want %q", pvc.Name, gotNode, tt.wantRaisedBy[pvc.Name])
+				}
+				if got := decommissionAttempts(current, testNode); got != tt.wantAttempts[pvc.Name] {
+					t.Errorf("%s decommission attempts = %d, want %d", pvc.Name, got, tt.wantAttempts[pvc.Name])
+				}
+			}
+
+			var gotEvents []string
+			for len(recorder.Events) > 0 {
+				gotEvents = append(gotEvents, <-recorder.Events)
+			}
+			if len(gotEvents) != len(tt.wantEvents) {
+				t.Fatalf("got events %q, want %q", gotEvents, tt.wantEvents)
+			}
+			for i, want := range tt.wantEvents {
+				if !strings.HasPrefix(gotEvents[i], want) {
+					t.Errorf("got event %q, want %q", gotEvents[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestControllerEnsureCancel(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	node := genNode(nil)
+	nodeKey := client.ObjectKeyFromObject(node)
+	pvc := genPVC("pvc1", raisedBy(testNode, "1"))
+	volKey := client.ObjectKey{Name: pvc.Spec.VolumeName, Namespace: pvc.Namespace}
+	k8s := fake.NewClientBuilder().WithScheme(kscheme.Scheme).WithObjects(pvc).Build()
+
+	api := storageos.NewMockClient()
+	if err := api.AddNode(storageos.MockObject{Name: testNode}); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.EnsureComputeOnly(ctx, nodeKey, true); err != nil {
+		t.Fatal(err)
+	}
+	api.SetNodeVolumes(nodeKey, []storageos.NodeVolume{genNodeVolume("pvc1", true, 2, 2)})
+
+	c, err := NewController(api, k8s, record.NewFakeRecorder(10), true, 0, time.Hour, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ensure(ctx, node); err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+
+	if !api.NodeExists(nodeKey) {
+		t.Error("node deleted, want not deleted")
+	}
+	if !api.IsComputeOnly(nodeKey) {
+		t.Error("compute-only = false, want unchanged")
+	}
+	if got, _ := api.GetReplicas(volKey); got != 1 {
+		t.Errorf("replicas = %d, want 1", got)
+	}
+	current := &corev1.PersistentVolumeClaim{}
+	if err := k8s.Get(ctx, client.ObjectKeyFromObject(pvc), current); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, raised := decommissionState(current); raised {
+		t.Errorf("decommission state not cleared: %v", current.GetAnnotations())
+	}
+}
+
+// TestControllerDecommissionWithLabelSync checks that PVC label sync keeps the
+// extra replica added while a volume is moved off a decommissioned node, and
+// applies the original replica count once the move has completed.
+func TestControllerDecommissionWithLabelSync(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	node := genNode(map[string]string{DecommissionAnnotationKey: "true"})
+	nodeKey := client.ObjectKeyFromObject(node)
+
+	sc := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "stos", UID: "sc-uid"},
+		Provisioner: provisioner.DriverName,
+	}
+	pvc := genPVC("pvc1", map[string]string{
+		provisioner.PVCProvisionerAnnotationKey:   provisioner.DriverName,
+		provisioner.StorageClassUUIDAnnotationKey: string(sc.UID),
+	})
+	pvc.Labels = map[string]string{storageos.ReservedLabelReplicas: "1"}
+	pvc.Spec.StorageClassName = &sc.Name
+	pvcKey := client.ObjectKeyFromObject(pvc)
+	volKey := client.ObjectKey{Name: pvc.Spec.VolumeName, Namespace: pvc.Namespace}
+	k8s := fake.NewClientBuilder().WithScheme(kscheme.Scheme).WithObjects(sc, pvc).Build()
+
+	api := storageos.NewMockClient()
+	if err := api.AddNode(storageos.MockObject{Name: testNode}); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.AddVolume(storageos.MockObject{Name: volKey.Name, Namespace: volKey.Namespace, Labels: pvc.Labels}); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.EnsureReplicas(ctx, volKey, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	nodeDelete, err := NewController(api, k8s, record.NewFakeRecorder(10), true, 0, time.Hour, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labelSync, err := pvclabel.NewController(k8s, api, kscheme.Scheme, nil, nil, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// syncLabels runs PVC label sync on the current PVC, as on a resync.
+	syncLabels := func() {
+		current := &corev1.PersistentVolumeClaim{}
+		if err := k8s.Get(ctx, pvcKey, current); err != nil {
+			t.Fatal(err)
+		}
+		if err := labelSync.Ensure(ctx, current); err != nil {
+			t.Fatalf("label sync Ensure() error = %v", err)
+		}
+	}
+	wantReplicas := func(step string, want uint64) {
+		if got, _ := api.GetReplicas(volKey); got != want {
+			t.Errorf("%s: replicas = %d, want %d", step, got, want)
+		}
+	}
+
+	// The replica is added, and kept by label sync.
+	api.SetNodeVolumes(nodeKey, []storageos.NodeVolume{genNodeVolume("pvc1", false, 1, 1)})
+	if err := nodeDelete.Ensure(ctx, node); !errors.Is(err, ErrDecommissionInProgress) {
+		t.Fatalf("Ensure() error = %v, want %v", err, ErrDecommissionInProgress)
+	}
+	wantReplicas("replica added", 2)
+	syncLabels()
+	wantReplicas("label sync while raised", 2)
+
+	// Once the extra replica is in sync, the original count is restored, and
+	// kept by label sync.
+	api.SetNodeVolumes(nodeKey, []storageos.NodeVolume{genNodeVolume("pvc1", false, 2, 2)})
+	if err := nodeDelete.Ensure(ctx, node); !errors.Is(err, ErrDecommissionInProgress) {
+		t.Fatalf("Ensure() error = %v, want %v", err, ErrDecommissionInProgress)
+	}
+	wantReplicas("replica removed", 1)
+	syncLabels()
+	wantReplicas("label sync after move", 1)
+
+	// The volume is no longer on the node, so the node is deleted.
+	api.SetNodeVolumes(nodeKey, nil)
+	if err := nodeDelete.Ensure(ctx, node); err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if api.NodeExists(nodeKey) {
+		t.Error("node not deleted")
+	}
+}
+
+func TestControllerDeleteNoEvacuate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	node := genNode(nil)
+	nodeKey := client.ObjectKeyFromObject(node)
+	k8s := fake.NewClientBuilder().WithScheme(kscheme.Scheme).Build()
+
+	api := storageos.NewMockClient()
+	if err := api.AddNode(storageos.MockObject{Name: testNode}); err != nil {
+		t.Fatal(err)
+	}
+	api.SetNodeVolumes(nodeKey, []storageos.NodeVolume{genNodeVolume("pvc1", true, 1, 0)})
+
+	c, err := NewController(api, k8s, record.NewFakeRecorder(10), false, 0, time.Hour, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, node); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if api.NodeExists(nodeKey) {
+		t.Error("node not deleted")
+	}
+	if api.IsComputeOnly(nodeKey) {
+		t.Error("compute-only set without evacuate")
+	}
+}
+
+func TestPredicate(t *testing.T) {
+	t.Parallel()
+
+	storageosNode := func(decommission string) *corev1.Node {
+		annotations := map[string]string{
+			provisioner.NodeDriverAnnotationKey: `{"` + provisioner.DriverName + `":"` + testNode + `"}`,
+		}
+		if decommission != "" {
+			annotations[DecommissionAnnotationKey] = decommission
+		}
+		return genNode(annotations)
+	}
+
+	tests := []struct {
+		name       string
+		create     *corev1.Node
+		old        *corev1.Node
+		new        *corev1.Node
+		wantCreate bool
+		wantUpdate bool
+	}{
+		{
+			name:       "decommission requested",
+			create:     storageosNode("true"),
+			old:        storageosNode(""),
+			new:        storageosNode("true"),
+			wantCreate: true,
+			wantUpdate: true,
+		},
+		{
+			name:       "decommission cancelled",
+			create:     storageosNode("false"),
+			old:        storageosNode("true"),
+			new:        storageosNode("false"),
+			wantUpdate: true,
+		},
+		{
+			name:   "no change",
+			create: storageosNode(""),
+			old:    storageosNode("true"),
+			new:    storageosNode("true"),
+		},
+		{
+			name:   "not a storageos node",
+			create: genNode(map[string]string{DecommissionAnnotationKey: "true"}),
+			old:    genNode(nil),
+			new:    genNode(map[string]string{DecommissionAnnotationKey: "true"}),
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := Predicate{log: ctrl.Log}
+			if got := p.Create(event.CreateEvent{Object: tt.create}); got != tt.wantCreate {
+				t.Errorf("Predicate.Create() = %t, want %t", got, tt.wantCreate)
+			}
+			if got := p.Update(event.UpdateEvent{ObjectOld: tt.old, ObjectNew: tt.new}); got != tt.wantUpdate {
+				t.Errorf("Predicate.Update() = %t, want %t", got, tt.wantUpdate)
+			}
+		})
+	}
+}
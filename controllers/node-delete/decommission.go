@@ -0,0 +1,369 @@
+package nodedelete
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/go-multierror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+const (
+	// DecommissionAnnotationKey is the Kubernetes node annotation that requests
+	// the node to be decommissioned in StorageOS while the Kubernetes node
+	// still exists.  It must be set to "true".
+	DecommissionAnnotationKey = "storageos.com/decommission"
+
+	// DecommissionNodeAnnotationKey is the PVC annotation set to the name of
+	// the node being decommissioned while the volume has an extra replica.
+	DecommissionNodeAnnotationKey = storageos.DecommissionNodeAnnotationKey
+
+	// DecommissionReplicasAnnotationKey is the PVC annotation set to the
+	// number of replicas the volume had before an extra replica was added.
+	// PVC label sync applies the extra replica while it is set, so that it
+	// is not removed by a resync.
+	DecommissionReplicasAnnotationKey = storageos.DecommissionReplicasAnnotationKey
+
+	// DecommissionAttemptsAnnotationKey is the PVC annotation set to the
+	// number of times the extra replica has been removed from the volume
+	// while the node set in DecommissionNodeAnnotationKey was decommissioned.
+	DecommissionAttemptsAnnotationKey = "storageos.com/decommission-attempts"
+
+	// DecommissionMaxAttempts is the number of times an extra replica is added
+	// and removed to move a volume replica off the node.  StorageOS chooses
+	// which replica to remove, so it may not be the one on the node.  Once
+	// reached, the volume is reported as blocked.
+	DecommissionMaxAttempts = 3
+
+	// DecommissionEvacuatingReason is the event reason used while volumes are
+	// being moved off the node.
+	DecommissionEvacuatingReason = "DecommissionEvacuating"
+
+	// DecommissionWaitingReason is the event reason used when all volumes
+	// have been moved, but StorageOS does not yet allow the node to be
+	// deleted.
+	DecommissionWaitingReason = "DecommissionWaiting"
+
+	// DecommissionBlockedReason is the event reason used when a volume can't
+	// be moved off the node.
+	DecommissionBlockedReason = "DecommissionBlocked"
+
+	// NodeDecommissionedReason is the event reason used when the node has
+	// been removed from StorageOS.
+	NodeDecommissionedReason = "NodeDecommissioned"
+)
+
+// ErrDecommissionInProgress is returned while volumes are being moved off the
+// node, so that the decommission is requeued.
+var ErrDecommissionInProgress = errors.New("node decommission in progress")
+
+// DecommissionRequested returns true if the node has the decommission
+// annotation set.
+func DecommissionRequested(obj client.Object) bool {
+	requested, _ := strconv.ParseBool(obj.GetAnnotations()[DecommissionAnnotationKey])
+	return requested
+}
+
+// decommission moves all volume masters and replicas off the node and then
+// deletes it from StorageOS.  It is called repeatedly until it returns nil:
+//
+//  1. The node is marked compute-only so no new deployments are placed on it.
+//  2. Each volume with a deployment on the node gets an extra replica.  The
+//     original replica count is recorded on the PVC before the replica is
+//     added, so that PVC label sync keeps the extra replica.
+//  3. Once the extra replica is in sync, and the original number of replicas
+//     are in sync on other nodes, volumes that only have a replica on the
+//     node are returned to their original replica count.  StorageOS should
+//     remove the replica from the compute-only node, but if the volume is
+//     still on the node on the next pass, the extra replica is added again.
+//     After DecommissionMaxAttempts the volume is reported as blocked.
+//  4. Volumes with their master on the node can't be moved while the node is
+//     online.  They keep the extra replica and are reported as blocked until
+//     the master fails over when the node goes offline.
+//  5. Once no volumes remain on the node, the original replica count is
+//     restored on any volumes that still have an extra replica, and the node
+//     is deleted.
+//
+// Progress is recorded as events on the Kubernetes node.
+func (c Controller) decommission(ctx context.Context, obj client.Object) error {
+	tr := otel.Tracer("node-delete")
+	ctx, span := tr.Start(ctx, "node decommission")
+	span.SetAttributes(label.String("name", obj.GetName()))
+	defer span.End()
+
+	observeErr := func(err error) error {
+		span.RecordError(err)
+		return err
+	}
+
+	key := client.ObjectKeyFromObject(obj)
+
+	apiCtx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+	if err := c.api.EnsureComputeOnly(apiCtx, key, true); err != nil {
+		if err == storageos.ErrNodeNotFound {
+			c.forget(obj.GetName())
+			span.SetStatus(codes.Ok, "node already removed from storageos")
+			return nil
+		}
+		// Compute-only only stops new deployments on the node, so continue.
+		c.log.Error(err, "failed to set node compute-only for decommission", "name", obj.GetName())
+	}
+
+	vols, err := c.api.NodeVolumes(apiCtx, key)
+	if err != nil {
+		if err == storageos.ErrNodeNotFound {
+			c.forget(obj.GetName())
+			span.SetStatus(codes.Ok, "node already removed from storageos")
+			return nil
+		}
+		return observeErr(err)
+	}
+
+	var errs *multierror.Error
+	var pending int
+	for _, vol := range vols {
+		done, err := c.evacuateVolume(ctx, obj, vol)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if !done {
+			pending++
+		}
+	}
+	span.SetAttributes(label.Int("volumes", len(vols)), label.Int("pending volumes", pending))
+	if err := errs.ErrorOrNil(); err != nil {
+		return observeErr(err)
+	}
+	if pending > 0 {
+		c.recordEvent(obj, corev1.EventTypeNormal, DecommissionEvacuatingReason, fmt.Sprintf("Moving %d of %d volumes off node", pending, len(vols)))
+		return observeErr(ErrDecommissionInProgress)
+	}
+
+	if err := c.restoreReplicas(ctx, obj.GetName(), vols); err != nil {
+		return observeErr(err)
+	}
+
+	apiCtx, cancel = context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+	switch err := c.api.DeleteNode(apiCtx, key); err {
+	case nil, storageos.ErrNodeNotFound:
+	case storageos.ErrNodeInUse, storageos.ErrNodeHasLock:
+		c.recordEvent(obj, corev1.EventTypeNormal, DecommissionWaitingReason, fmt.Sprintf("Volumes replicated to other nodes, waiting for node to go offline: %v", err))
+		return observeErr(c.recordBlocked(ctx, obj, err))
+	default:
+		return observeErr(err)
+	}
+	c.forget(obj.GetName())
+
+	c.recordEvent(obj, corev1.EventTypeNormal, NodeDecommissionedReason, "Node removed from StorageOS")
+	span.SetStatus(codes.Ok, "node decommissioned in storageos")
+	c.log.Info("node decommissioned in storageos", "name", obj.GetName())
+	return nil
+}
+
+// evacuateVolume moves the volume off the node, returning true once the volume
+// no longer needs to be moved.  Volumes that can't be moved have a
+// DecommissionBlocked event recorded and are never reported as moved.
+func (c Controller) evacuateVolume(ctx context.Context, node client.Object, vol storageos.NodeVolume) (bool, error) {
+	if vol.PVC.Name == "" {
+		c.recordEvent(node, corev1.EventTypeWarning, DecommissionBlockedReason, fmt.Sprintf("Volume %s can't be moved, it was not provisioned for a PVC", vol.Key))
+		return false, nil
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := c.k8s.Get(ctx, vol.PVC, pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			c.recordEvent(node, corev1.EventTypeWarning, DecommissionBlockedReason, fmt.Sprintf("Volume %s can't be moved, PVC %s not found", vol.Key, vol.PVC))
+			return false, nil
+		}
+		return false, err
+	}
+
+	apiCtx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+
+	raisedBy, original, raised := decommissionState(pvc)
+	switch {
+	case raised && raisedBy != node.GetName():
+		// Another node being decommissioned has added a replica.  Wait for
+		// it to finish.
+		return false, nil
+	case !raised:
+		if attempts := decommissionAttempts(pvc, node.GetName()); attempts >= DecommissionMaxAttempts {
+			c.recordEvent(node, corev1.EventTypeWarning, DecommissionBlockedReason, fmt.Sprintf("Volume %s is still on the node after %d attempts to move its replica", vol.Key, attempts))
+			return false, nil
+		}
+		if vol.Replicas >= storageos.MaxReplicas {
+			c.recordEvent(node, corev1.EventTypeWarning, DecommissionBlockedReason, fmt.Sprintf("Volume %s can't be moved, it already has the maximum number of replicas", vol.Key))
+			return false, nil
+		}
+		if err := c.setDecommissionState(ctx, pvc, node.GetName(), vol.Replicas); err != nil {
+			return false, err
+		}
+		if err := c.api.EnsureReplicas(apiCtx, vol.Key, uint64(vol.Replicas+1)); err != nil {
+			return false, err
+		}
+		c.log.Info("added replica to move volume off decommissioned node", "node", node.GetName(), "volume", vol.Key.String())
+		return false, nil
+	case vol.Master:
+		// StorageOS can't move the master of a volume while its node is
+		// online.  Keep the extra replica so that the master can fail over
+		// without reducing redundancy when the node goes offline.
+		c.recordEvent(node, corev1.EventTypeWarning, DecommissionBlockedReason, fmt.Sprintf("Volume %s can't be moved, its master is on the node and will only fail over when the node goes offline", vol.Key))
+		return false, nil
+	case !vol.Replica || vol.ReadyReplicasElsewhere < original:
+		// Wait for the extra replica to sync.  Only lower the replica count
+		// once the original number of replicas are in sync on other nodes,
+		// so that the replica on this node is the only one StorageOS can
+		// remove without losing a healthy copy.
+		return false, nil
+	}
+
+	// Only a replica remains on the node and the volume is fully replicated
+	// on other nodes.  Remove the extra replica, and check on the next pass
+	// that the replica on this node was the one removed.
+	if err := c.api.EnsureReplicas(apiCtx, vol.Key, uint64(original)); err != nil {
+		return false, err
+	}
+	if err := c.setDecommissionAttempt(ctx, pvc); err != nil {
+		return false, err
+	}
+	c.log.Info("removed extra replica from volume moved off decommissioned node", "node", node.GetName(), "volume", vol.Key.String())
+	return false, nil
+}
+
+// restoreReplicas returns volumes that had an extra replica added while the
+// node was decommissioned to their original replica count, and removes the
+// decommission state from their PVCs.  Volumes that still have a deployment on
+// the node are not changed.
+func (c Controller) restoreReplicas(ctx context.Context, nodeName string, skip []storageos.NodeVolume) error {
+	onNode := make(map[client.ObjectKey]bool)
+	for _, vol := range skip {
+		onNode[vol.PVC] = true
+	}
+
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := c.k8s.List(ctx, pvcs); err != nil {
+		return err
+	}
+
+	apiCtx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+
+	var errs *multierror.Error
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if pvc.GetAnnotations()[DecommissionNodeAnnotationKey] != nodeName || onNode[client.ObjectKeyFromObject(pvc)] {
+			continue
+		}
+		if _, original, raised := decommissionState(pvc); raised {
+			key := client.ObjectKey{Name: pvc.Spec.VolumeName, Namespace: pvc.GetNamespace()}
+			if err := c.api.EnsureReplicas(apiCtx, key, uint64(original)); err != nil && err != storageos.ErrVolumeNotFound {
+				errs = multierror.Append(errs, err)
+				continue
+			}
+		}
+		if err := c.clearDecommissionState(ctx, pvc); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// cancelDecommission restores the original replica count on volumes that had
+// an extra replica added for the node.
+//
+// Compute-only is not changed.  It is owned by node label sync, which removes
+// it once the decommission annotation has been removed, unless the node still
+// matches the cordon policy.
+func (c Controller) cancelDecommission(ctx context.Context, obj client.Object) error {
+	c.blocked.unblock(obj.GetName())
+
+	return c.restoreReplicas(ctx, obj.GetName(), nil)
+}
+
+// decommissionState returns the name of the node that added an extra replica
+// to the PVC's volume and the original replica count, if set.
+func decommissionState(pvc *corev1.PersistentVolumeClaim) (string, int, bool) {
+	return storageos.DecommissionState(pvc)
+}
+
+// decommissionAttempts returns the number of times the extra replica has been
+// removed from the PVC's volume while the node was decommissioned.
+func decommissionAttempts(pvc *corev1.PersistentVolumeClaim, node string) int {
+	if pvc.GetAnnotations()[DecommissionNodeAnnotationKey] != node {
+		return 0
+	}
+	attempts, _ := strconv.Atoi(pvc.GetAnnotations()[DecommissionAttemptsAnnotationKey])
+	return attempts
+}
+
+// setDecommissionState records the node and original replica count on the
+// PVC.  Attempts recorded by another node are removed.
+func (c Controller) setDecommissionState(ctx context.Context, pvc *corev1.PersistentVolumeClaim, node string, original int) error {
+	patch := client.MergeFrom(pvc.DeepCopy())
+	annotations := pvc.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if annotations[DecommissionNodeAnnotationKey] != node {
+		delete(annotations, DecommissionAttemptsAnnotationKey)
+	}
+	annotations[DecommissionNodeAnnotationKey] = node
+	annotations[DecommissionReplicasAnnotationKey] = strconv.Itoa(original)
+	pvc.SetAnnotations(annotations)
+
+	if err := c.k8s.Patch(ctx, pvc, patch); err != nil {
+		return fmt.Errorf("failed to set pvc decommission state: %w", err)
+	}
+	return nil
+}
+
+// setDecommissionAttempt records that the extra replica has been removed from
+// the PVC's volume.  The node is kept, so that the attempt is counted if the
+// volume is still on the node.
+func (c Controller) setDecommissionAttempt(ctx context.Context, pvc *corev1.PersistentVolumeClaim) error {
+	patch := client.MergeFrom(pvc.DeepCopy())
+	annotations := pvc.GetAnnotations()
+	attempts, _ := strconv.Atoi(annotations[DecommissionAttemptsAnnotationKey])
+	annotations[DecommissionAttemptsAnnotationKey] = strconv.Itoa(attempts + 1)
+	delete(annotations, DecommissionReplicasAnnotationKey)
+	pvc.SetAnnotations(annotations)
+
+	if err := c.k8s.Patch(ctx, pvc, patch); err != nil {
+		return fmt.Errorf("failed to set pvc decommission attempt: %w", err)
+	}
+	return nil
+}
+
+// clearDecommissionState removes the decommission state from the PVC.
+func (c Controller) clearDecommissionState(ctx context.Context, pvc *corev1.PersistentVolumeClaim) error {
+	patch := client.MergeFrom(pvc.DeepCopy())
+	annotations := pvc.GetAnnotations()
+	delete(annotations, DecommissionNodeAnnotationKey)
+	delete(annotations, DecommissionReplicasAnnotationKey)
+	delete(annotations, DecommissionAttemptsAnnotationKey)
+	pvc.SetAnnotations(annotations)
+
+	if err := c.k8s.Patch(ctx, pvc, patch); err != nil {
+		return fmt.Errorf("failed to clear pvc decommission state: %w", err)
+	}
+	return nil
+}
+
+// recordEvent records an event on the node, if a recorder is set.
+func (c Controller) recordEvent(obj client.Object, eventtype, reason, message string) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.Event(obj, eventtype, reason, message)
+}
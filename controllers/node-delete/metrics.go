@@ -0,0 +1,72 @@
+package nodedelete
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// GaugeMetric sets the value of a gauge.
+type GaugeMetric interface {
+	Set(value int)
+}
+
+// BlockedMetric sets the number of blocked nodes for a reason.
+type BlockedMetric interface {
+	Set(reason string, value int)
+}
+
+var (
+	// OrphanedNodes is the number of StorageOS nodes with no matching
+	// Kubernetes node, as found by the last garbage collection.
+	OrphanedNodes GaugeMetric = &gaugeAdapter{m: orphanedNodesGauge}
+
+	// BlockedNodes is the number of StorageOS nodes that can't be deleted yet,
+	// by reason.
+	BlockedNodes BlockedMetric = &blockedAdapter{m: blockedNodesGauge}
+
+	// registerMetricsOnce keeps track of metrics registration.
+	registerMetricsOnce sync.Once
+)
+
+var (
+	orphanedNodesGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "storageos_orphaned_nodes",
+			Help: "Number of StorageOS nodes with no matching Kubernetes node.",
+		},
+	)
+
+	blockedNodesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "storageos_node_delete_blocked_nodes",
+			Help: "Number of StorageOS nodes that can't be deleted yet, partitioned by reason.",
+		},
+		[]string{"reason"},
+	)
+)
+
+// RegisterMetrics ensures that the package metrics are registered.
+func RegisterMetrics() {
+	registerMetricsOnce.Do(func() {
+		metrics.Registry.MustRegister(orphanedNodesGauge)
+		metrics.Registry.MustRegister(blockedNodesGauge)
+	})
+}
+
+type gaugeAdapter struct {
+	m prometheus.Gauge
+}
+
+func (g *gaugeAdapter) Set(value int) {
+	g.m.Set(float64(value))
+}
+
+type blockedAdapter struct {
+	m *prometheus.GaugeVec
+}
+
+func (b *blockedAdapter) Set(reason string, value int) {
+	b.m.WithLabelValues(reason).Set(float64(value))
+}
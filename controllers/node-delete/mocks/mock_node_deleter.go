@@ -9,6 +9,7 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	storageos "github.com/storageos/api-manager/internal/pkg/storageos"
 	types "k8s.io/apimachinery/pkg/types"
 	client "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -50,6 +51,48 @@ func (mr *MockNodeDeleterMockRecorder) DeleteNode(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteNode", reflect.TypeOf((*MockNodeDeleter)(nil).DeleteNode), arg0, arg1)
 }
 
+// EnsureComputeOnly mocks base method.
+func (m *MockNodeDeleter) EnsureComputeOnly(arg0 context.Context, arg1 types.NamespacedName, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureComputeOnly", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnsureComputeOnly indicates an expected call of EnsureComputeOnly.
+func (mr *MockNodeDeleterMockRecorder) EnsureComputeOnly(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureComputeOnly", reflect.TypeOf((*MockNodeDeleter)(nil).EnsureComputeOnly), arg0, arg1, arg2)
+}
+
+// EnsureNodeProtected mocks base method.
+func (m *MockNodeDeleter) EnsureNodeProtected(arg0 context.Context, arg1 types.NamespacedName, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureNodeProtected", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnsureNodeProtected indicates an expected call of EnsureNodeProtected.
+func (mr *MockNodeDeleterMockRecorder) EnsureNodeProtected(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureNodeProtected", reflect.TypeOf((*MockNodeDeleter)(nil).EnsureNodeProtected), arg0, arg1, arg2)
+}
+
+// EnsureReplicas mocks base method.
+func (m *MockNodeDeleter) EnsureReplicas(arg0 context.Context, arg1 types.NamespacedName, arg2 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureReplicas", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnsureReplicas indicates an expected call of EnsureReplicas.
+func (mr *MockNodeDeleterMockRecorder) EnsureReplicas(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureReplicas", reflect.TypeOf((*MockNodeDeleter)(nil).EnsureReplicas), arg0, arg1, arg2)
+}
+
 // ListNodes mocks base method.
 func (m *MockNodeDeleter) ListNodes(arg0 context.Context) ([]client.Object, error) {
 	m.ctrl.T.Helper()
@@ -64,3 +107,18 @@ func (mr *MockNodeDeleterMockRecorder) ListNodes(arg0 interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListNodes", reflect.TypeOf((*MockNodeDeleter)(nil).ListNodes), arg0)
 }
+
+// NodeVolumes mocks base method.
+func (m *MockNodeDeleter) NodeVolumes(arg0 context.Context, arg1 types.NamespacedName) ([]storageos.NodeVolume, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NodeVolumes", arg0, arg1)
+	ret0, _ := ret[0].([]storageos.NodeVolume)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NodeVolumes indicates an expected call of NodeVolumes.
+func (mr *MockNodeDeleterMockRecorder) NodeVolumes(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NodeVolumes", reflect.TypeOf((*MockNodeDeleter)(nil).NodeVolumes), arg0, arg1)
+}
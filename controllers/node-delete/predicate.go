@@ -2,6 +2,7 @@ package nodedelete
 
 import (
 	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	"github.com/storageos/api-manager/internal/pkg/predicate"
@@ -9,18 +10,55 @@ import (
 )
 
 // Predicate filters events before enqueuing the keys.  Ignore all but Delete
-// events, and then filter out events from non-StorageOS nodes.
+// events and changes to the decommission annotation, and then filter out events
+// from non-StorageOS nodes.
+//
+// Nodes that were protected when deleted are recorded, so that they are not
+// removed from StorageOS by the delete or by garbage collection.
 type Predicate struct {
 	predicate.IgnoreFuncs
-	log logr.Logger
+	protected *protectedNodes
+	log       logr.Logger
+}
+
+// Create determines whether an object create should trigger a reconcile.  Only
+// nodes requesting decommission or protected nodes are reconciled.
+func (p Predicate) Create(e event.CreateEvent) bool {
+	return (DecommissionRequested(e.Object) || Protected(e.Object)) && p.isStorageOSNode(e.Object)
+}
+
+// Update determines whether an object update should trigger a reconcile.  Only
+// changes to the decommission annotation or to protection are reconciled.
+func (p Predicate) Update(e event.UpdateEvent) bool {
+	if DecommissionRequested(e.ObjectOld) == DecommissionRequested(e.ObjectNew) && Protected(e.ObjectOld) == Protected(e.ObjectNew) {
+		return false
+	}
+	return p.isStorageOSNode(e.ObjectNew)
 }
 
 // Delete determines whether an object delete should trigger a reconcile.
+// Protected nodes are recorded and not reconciled.
 func (p Predicate) Delete(e event.DeleteEvent) bool {
-	// Ignore objects without the StorageOS CSI driver annotation.
-	found, err := provisioner.IsStorageOSNode(e.Object)
+	if !p.isStorageOSNode(e.Object) {
+		return false
+	}
+	protected := Protected(e.Object)
+	if p.protected != nil {
+		p.protected.set(e.Object.GetName(), protected)
+	}
+	if protected {
+		p.log.Info("protected node deleted, not removing from storageos", "name", e.Object.GetName())
+		return false
+	}
+	return true
+}
+
+// isStorageOSNode returns true if the node has the StorageOS CSI driver
+// annotation.
+func (p Predicate) isStorageOSNode(obj client.Object) bool {
+	found, err := provisioner.IsStorageOSNode(obj)
 	if err != nil {
-		p.log.Error(err, "failed to process node annotations", "node", e.Object.GetName())
+		p.log.Error(err, "failed to process node annotations", "node", obj.GetName())
 	}
 	return found
 }
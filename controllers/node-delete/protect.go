@@ -0,0 +1,130 @@
+package nodedelete
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+const (
+	// ProtectNodeKey is the Kubernetes node label or annotation that stops the
+	// StorageOS node from being decommissioned until it is removed.  It must be
+	// set to "true".
+	ProtectNodeKey = storageos.ReservedLabelProtectNode
+
+	// DecommissionProtectedReason is the event reason used when decommission
+	// has been requested for a protected node.
+	DecommissionProtectedReason = "DecommissionProtected"
+)
+
+// ErrGracePeriod is returned when the Kubernetes node has not been absent for
+// long enough for the StorageOS node to be deleted, so that the delete is
+// requeued.
+var ErrGracePeriod = errors.New("node absent for less than grace period")
+
+// Protected returns true if the node has the protect label or annotation set.
+func Protected(obj client.Object) bool {
+	for _, m := range []map[string]string{obj.GetLabels(), obj.GetAnnotations()} {
+		if protected, _ := strconv.ParseBool(m[ProtectNodeKey]); protected {
+			return true
+		}
+	}
+	return false
+}
+
+// protectedNodes tracks Kubernetes nodes that were protected when they were
+// deleted.  The protection can no longer be read from the deleted node, so it
+// is kept until the node is removed from StorageOS or is deleted again without
+// protection.  It is safe for concurrent use.
+type protectedNodes struct {
+	mu    sync.RWMutex
+	nodes map[string]bool
+}
+
+// newProtectedNodes returns an empty protected node tracker.
+func newProtectedNodes() *protectedNodes {
+	return &protectedNodes{nodes: make(map[string]bool)}
+}
+
+// set records whether the node is protected.
+func (p *protectedNodes) set(name string, protected bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if protected {
+		p.nodes[name] = true
+		return
+	}
+	delete(p.nodes, name)
+}
+
+// has returns true if the node is protected.
+func (p *protectedNodes) has(name string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.nodes[name]
+}
+
+// retain removes nodes that are not in the set from the tracker.
+func (p *protectedNodes) retain(names map[string]bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for name := range p.nodes {
+		if !names[name] {
+			delete(p.nodes, name)
+		}
+	}
+}
+
+// absentNodes tracks when Kubernetes nodes were first found to be absent.  It
+// is safe for concurrent use.
+type absentNodes struct {
+	mu    sync.Mutex
+	since map[string]time.Time
+}
+
+// newAbsentNodes returns an empty absent node tracker.
+func newAbsentNodes() *absentNodes {
+	return &absentNodes{since: make(map[string]time.Time)}
+}
+
+// absentFor records the node as absent, and returns how long it has been
+// absent for.
+func (a *absentNodes) absentFor(name string, now time.Time) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	since, ok := a.since[name]
+	if !ok {
+		since = now
+		a.since[name] = since
+	}
+	return now.Sub(since)
+}
+
+// forget removes the node from the tracker.
+func (a *absentNodes) forget(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.since, name)
+}
+
+// retain removes nodes that are not in the set from the tracker.
+func (a *absentNodes) retain(names map[string]bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for name := range a.since {
+		if !names[name] {
+			delete(a.since, name)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package nodedelete
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+func TestProtected(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:   "label",
+			labels: map[string]string{ProtectNodeKey: "true"},
+			want:   true,
+		},
+		{
+			name:        "annotation",
+			annotations: map[string]string{ProtectNodeKey: "true"},
+			want:        true,
+		},
+		{
+			name:        "false",
+			labels:      map[string]string{ProtectNodeKey: "false"},
+			annotations: map[string]string{ProtectNodeKey: "false"},
+		},
+		{
+			name:   "invalid",
+			labels: map[string]string{ProtectNodeKey: "yes"},
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNode, Labels: tt.labels, Annotations: tt.annotations}}
+			if got := Protected(node); got != tt.want {
+				t.Errorf("Protected() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestControllerDeleteGracePeriod(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	node := genNode(nil)
+	nodeKey := client.ObjectKeyFromObject(node)
+	k8s := fake.NewClientBuilder().WithScheme(kscheme.Scheme).Build()
+
+	api := storageos.NewMockClient()
+	if err := api.AddNode(storageos.MockObject{Name: testNode}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewController(api, k8s, record.NewFakeRecorder(10), false, time.Minute, time.Hour, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Delete(ctx, node); err != ErrGracePeriod {
+		t.Fatalf("Delete() error = %v, want %v", err, ErrGracePeriod)
+	}
+	if !api.NodeExists(nodeKey) {
+		t.Fatal("node deleted within grace period")
+	}
+
+	// The node reappearing resets the grace period.
+	c.absent.since[testNode] = time.Now().Add(-2 * time.Minute)
+	if err := c.Ensure(ctx, node); err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if err := c.Delete(ctx, node); err != ErrGracePeriod {
+		t.Fatalf("Delete() after node reappeared error = %v, want %v", err, ErrGracePeriod)
+	}
+
+	c.absent.since[testNode] = time.Now().Add(-2 * time.Minute)
+	if err := c.Delete(ctx, node); err != nil {
+		t.Fatalf("Delete() after grace period error = %v", err)
+	}
+	if api.NodeExists(nodeKey) {
+		t.Error("node not deleted after grace period")
+	}
+	if _, ok := c.absent.since[testNode]; ok {
+		t.Error("absent node not forgotten after delete")
+	}
+}
+
+func TestControllerDeleteProtected(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	node := genNode(nil)
+	nodeKey := client.ObjectKeyFromObject(node)
+	k8s := fake.NewClientBuilder().WithScheme(kscheme.Scheme).Build()
+
+	api := storageos.NewMockClient()
+	if err := api.AddNode(storageos.MockObject{Name: testNode}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewController(api, k8s, record.NewFakeRecorder(10), true, 0, time.Hour, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.protected.set(testNode, true)
+
+	if err := c.Delete(ctx, node); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if !api.NodeExists(nodeKey) {
+		t.Error("protected node deleted")
+	}
+	if api.IsComputeOnly(nodeKey) {
+		t.Error("protected node decommissioned")
+	}
+
+	// Garbage collection keeps protection while the node is in StorageOS.
+	if _, err := c.List(ctx); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if !c.protected.has(testNode) {
+		t.Error("protection forgotten while node in storageos")
+	}
+}
+
+func TestControllerEnsureProtected(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	node := genNode(map[string]string{DecommissionAnnotationKey: "true", ProtectNodeKey: "true"})
+	nodeKey := client.ObjectKeyFromObject(node)
+	k8s := fake.NewClientBuilder().WithScheme(kscheme.Scheme).Build()
+
+	api := storageos.NewMockClient()
+	if err := api.AddNode(storageos.MockObject{Name: testNode}); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	c, err := NewController(api, k8s, recorder, true, 0, time.Hour, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ensure(ctx, node); err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if !api.NodeExists(nodeKey) {
+		t.Error("protected node deleted")
+	}
+	if api.IsComputeOnly(nodeKey) {
+		t.Error("protected node decommissioned")
+	}
+
+	want := "Warning " + DecommissionProtectedReason
+	select {
+	case got := <-recorder.Events:
+		if !strings.HasPrefix(got, want) {
+			t.Errorf("got event %q, want %q", got, want)
+		}
+	default:
+		t.Errorf("expected event %q, got none", want)
+	}
+}
+
+func TestPredicateProtected(t *testing.T) {
+	t.Parallel()
+
+	storageosNode := func(annotations map[string]string) *corev1.Node {
+		node := genNode(map[string]string{
+			provisioner.NodeDriverAnnotationKey: `{"` + provisioner.DriverName + `":"` + testNode + `"}`,
+		})
+		for k, v := range annotations {
+			node.Annotations[k] = v
+		}
+		return node
+	}
+	decommission := map[string]string{DecommissionAnnotationKey: "true"}
+	protectedDecommission := map[string]string{DecommissionAnnotationKey: "true", ProtectNodeKey: "true"}
+
+	p := Predicate{protected: newProtectedNodes(), log: ctrl.Log}
+
+	if p.Delete(event.DeleteEvent{Object: storageosNode(map[string]string{ProtectNodeKey: "true"})}) {
+		t.Error("Predicate.Delete() = true for protected node, want false")
+	}
+	if !p.protected.has(testNode) {
+		t.Error("protected node not recorded")
+	}
+	if !p.Delete(event.DeleteEvent{Object: storageosNode(nil)}) {
+		t.Error("Predicate.Delete() = false for unprotected node, want true")
+	}
+	if p.protected.has(testNode) {
+		t.Error("protection not cleared for unprotected node")
+	}
+
+	if !p.Update(event.UpdateEvent{ObjectOld: storageosNode(protectedDecommission), ObjectNew: storageosNode(decommission)}) {
+		t.Error("Predicate.Update() = false when protection removed during decommission, want true")
+	}
+	if !p.Update(event.UpdateEvent{ObjectOld: storageosNode(map[string]string{ProtectNodeKey: "true"}), ObjectNew: storageosNode(nil)}) {
+		t.Error("Predicate.Update() = false when protection removed without decommission, want true")
+	}
+	if p.Update(event.UpdateEvent{ObjectOld: storageosNode(nil), ObjectNew: storageosNode(nil)}) {
+		t.Error("Predicate.Update() = true for unchanged node, want false")
+	}
+	if !p.Create(event.CreateEvent{Object: storageosNode(map[string]string{ProtectNodeKey: "true"})}) {
+		t.Error("Predicate.Create() = false for protected node, want true")
+	}
+	if p.Create(event.CreateEvent{Object: storageosNode(nil)}) {
+		t.Error("Predicate.Create() = true for unprotected node, want false")
+	}
+}
+
+// TestControllerProtectionPersisted checks that protection recorded on the
+// StorageOS node is kept after the Kubernetes node has been deleted and the
+// controller restarted, and is removed once the node is back without it.
+func TestControllerProtectionPersisted(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	node := genNode(map[string]string{ProtectNodeKey: "true"})
+	nodeKey := client.ObjectKeyFromObject(node)
+
+	api := storageos.NewMockClient()
+	if err := api.AddNode(storageos.MockObject{Name: testNode}); err != nil {
+		t.Fatal(err)
+	}
+	protectedInStorageOS := func() bool {
+		nodes, err := api.ListNodes(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range nodes {
+			if n.GetName() == testNode {
+				return storageos.NodeProtected(n.GetLabels())
+			}
+		}
+		return false
+	}
+
+	c, err := NewController(api, fake.NewClientBuilder().WithScheme(kscheme.Scheme).WithObjects(node).Build(), record.NewFakeRecorder(10), true, 0, time.Hour, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ensure(ctx, node); err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if !protectedInStorageOS() {
+		t.Fatal("protection not recorded on storageos node")
+	}
+
+	// The Kubernetes node is deleted while the api-manager is restarted, so
+	// the protection is only known from StorageOS.
+	c, err = NewController(api, fake.NewClientBuilder().WithScheme(kscheme.Scheme).Build(), record.NewFakeRecorder(10), true, 0, time.Hour, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, node); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if !api.NodeExists(nodeKey) {
+		t.Error("protected node deleted")
+	}
+	keys, err := c.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("List() = %v, want protected node excluded", keys)
+	}
+
+	// The node is re-added without protection, so garbage collection removes
+	// the protection from StorageOS.
+	c, err = NewController(api, fake.NewClientBuilder().WithScheme(kscheme.Scheme).WithObjects(genNode(nil)).Build(), record.NewFakeRecorder(10), true, 0, time.Hour, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err = c.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("List() = %v, want node included", keys)
+	}
+	if protectedInStorageOS() {
+		t.Error("protection not removed from storageos node")
+	}
+}
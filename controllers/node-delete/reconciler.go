@@ -9,9 +9,12 @@ import (
 	syncv1 "github.com/darkowlzz/operator-toolkit/controller/sync/v1"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos"
 )
 
 //NodeDeleter provides access to removing nodes from StorageOS.
@@ -19,39 +22,58 @@ import (
 type NodeDeleter interface {
 	DeleteNode(ctx context.Context, key client.ObjectKey) error
 	ListNodes(ctx context.Context) ([]client.Object, error)
+	EnsureComputeOnly(ctx context.Context, key client.ObjectKey, enabled bool) error
+	EnsureNodeProtected(ctx context.Context, key client.ObjectKey, protected bool) error
+	NodeVolumes(ctx context.Context, key client.ObjectKey) ([]storageos.NodeVolume, error)
+	EnsureReplicas(ctx context.Context, key client.ObjectKey, desired uint64) error
 }
 
 // Reconciler reconciles a Node object by deleting the StorageOS node object
-// when the corresponding Kubernetes node is deleted.
+// when the corresponding Kubernetes node is deleted, or decommission has been
+// requested.
 type Reconciler struct {
 	client.Client
 	log        logr.Logger
 	api        NodeDeleter
 	gcDelay    time.Duration
 	gcInterval time.Duration
+	recorder   record.EventRecorder
+	evacuate   bool
+	grace      time.Duration
+	threshold  time.Duration
 
 	objectv1.Reconciler
 }
 
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // NewReconciler returns a new Node delete reconciler.
 //
 // The gcInterval determines how often the periodic resync operation should be
-// run.
-func NewReconciler(api NodeDeleter, k8s client.Client, gcDelay time.Duration, gcInterval time.Duration) *Reconciler {
+// run.  If evacuate is set, volumes are moved off nodes before they are
+// deleted.  Nodes are only deleted once the Kubernetes node has been absent for
+// the gracePeriod.  Decommission progress is recorded as events on the node,
+// and a warning event is recorded when a delete has been blocked for longer
+// than blockedThreshold.
+func NewReconciler(api NodeDeleter, k8s client.Client, gcDelay time.Duration, gcInterval time.Duration, recorder record.EventRecorder, evacuate bool, gracePeriod time.Duration, blockedThreshold time.Duration) *Reconciler {
 	return &Reconciler{
 		Client:     k8s,
 		log:        ctrl.Log,
 		api:        api,
 		gcDelay:    gcDelay,
 		gcInterval: gcInterval,
+		recorder:   recorder,
+		evacuate:   evacuate,
+		grace:      gracePeriod,
+		threshold:  blockedThreshold,
 	}
 }
 
 // SetupWithManager registers the controller with the controller manager.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, workers int) error {
-	c, err := NewController(r.api, r.log)
+	c, err := NewController(r.api, r.Client, r.recorder, r.evacuate, r.grace, r.threshold, r.log)
 	if err != nil {
 		return err
 	}
@@ -72,6 +94,6 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, workers int) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		WithOptions(controller.Options{MaxConcurrentReconciles: workers}).
 		For(&corev1.Node{}).
-		WithEventFilter(Predicate{log: r.log}).
+		WithEventFilter(Predicate{protected: c.protected, log: r.log}).
 		Complete(r)
 }
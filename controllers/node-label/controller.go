@@ -11,22 +11,26 @@ import (
 	"go.opentelemetry.io/otel/label"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/storageos/api-manager/internal/pkg/labels"
 	"github.com/storageos/api-manager/internal/pkg/storageos"
 )
 
 // Controller implements the Sync contoller interface, applying node labels to
 // StorageOS nodes.
 type Controller struct {
-	api NodeLabeller
-	log logr.Logger
+	api    NodeLabeller
+	cordon CordonPolicy
+	filter *labels.Filter
+	log    logr.Logger
 }
 
 var _ msyncv1.Controller = &Controller{}
 
 // NewController returns a Controller that implements node label sync in
-// StorageOS.
-func NewController(api NodeLabeller, log logr.Logger) (*Controller, error) {
-	return &Controller{api: api, log: log}, nil
+// StorageOS.  Nodes matching the cordon policy are marked as compute-only.
+// Only labels selected by the filter are applied, or all labels if nil.
+func NewController(api NodeLabeller, cordon CordonPolicy, filter *labels.Filter, log logr.Logger) (*Controller, error) {
+	return &Controller{api: api, cordon: cordon, filter: filter, log: log}, nil
 }
 
 // Ensure applies labels set on the k8s node to the StorageOS node.
@@ -34,11 +38,16 @@ func NewController(api NodeLabeller, log logr.Logger) (*Controller, error) {
 // StorageOS reserved labels are validated and applied first, then the remaining
 // unreserved labels are applied.
 //
+// If the node matches the cordon policy, for example when it has been cordoned
+// for maintenance, the StorageOS node is marked as compute-only.  This is
+// reverted once the node no longer matches.
+//
 // Any errors will result in a requeue, with standard back-off retries.
 //
 // There is no label sync from StorageOS to Kubernetes.  This is intentional to
 // ensure a simple flow of desired state set by users in Kubernetes to actual
-// state set on the StorageOS node.
+// state set on the StorageOS node.  Labels set by the optional node status
+// sync, prefixed with `status.storageos.com/`, are not applied.
 func (c Controller) Ensure(ctx context.Context, obj client.Object) error {
 	tr := otel.Tracer("node-label")
 	ctx, span := tr.Start(ctx, "node label ensure")
@@ -48,7 +57,13 @@ func (c Controller) Ensure(ctx context.Context, obj client.Object) error {
 	ctx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
 	defer cancel()
 
-	if err := c.api.EnsureNodeLabels(ctx, client.ObjectKeyFromObject(obj), obj.GetLabels()); err != nil {
+	desired := c.desiredLabels(obj)
+	if _, ok := desired[storageos.ReservedLabelComputeOnly]; ok && obj.GetLabels()[storageos.ReservedLabelComputeOnly] == "" {
+		span.SetAttributes(label.Bool("cordoned", true))
+		c.log.Info("node cordoned, applying compute-only to storageos node", "name", obj.GetName())
+	}
+
+	if err := c.api.EnsureNodeLabels(ctx, client.ObjectKeyFromObject(obj), desired); err != nil {
 		span.RecordError(err)
 		return err
 	}
@@ -58,7 +73,9 @@ func (c Controller) Ensure(ctx context.Context, obj client.Object) error {
 }
 
 // Diff takes a list of Kubernets node objects and returns them if they exist
-// within StorageOS but the labels are different.
+// within StorageOS but the labels are different.  Compute-only set by the
+// cordon policy is included in the comparison.  Node protection is managed by
+// the node delete controller and is excluded.
 func (c Controller) Diff(ctx context.Context, objs []client.Object) ([]client.Object, error) {
 	tr := otel.Tracer("node-label")
 	ctx, span := tr.Start(ctx, "node label diff")
@@ -81,7 +98,7 @@ func (c Controller) Diff(ctx context.Context, objs []client.Object) ([]client.Ob
 			continue
 		}
 		// If labels don't match, return original object.
-		if !reflect.DeepEqual(obj.GetLabels(), node.GetLabels()) {
+		if !reflect.DeepEqual(withoutProtection(c.desiredLabels(obj)), withoutProtection(node.GetLabels())) {
 			apply = append(apply, obj)
 		}
 	}
@@ -90,6 +107,42 @@ func (c Controller) Diff(ctx context.Context, objs []client.Object) ([]client.Ob
 	return apply, nil
 }
 
+// desiredLabels returns the labels to apply to the StorageOS node.  Status
+// labels set by the node status sync and labels not selected by the filter are
+// not applied.  StorageOS reserved labels are never filtered, and the failure
+// domain label is always set from the node's topology labels.
+func (c Controller) desiredLabels(obj client.Object) map[string]string {
+	return withFailureDomain(c.filter.Apply(withoutStatus(c.cordon.DesiredLabels(obj)), storageos.IsReservedLabel), obj.GetLabels())
+}
+
+// withoutStatus returns a copy of the labels without the status labels set by
+// the node status sync.
+func withoutStatus(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	ret := make(map[string]string)
+	for k, v := range labels {
+		if !IsStatusKey(k) {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+// withoutProtection returns a copy of the labels without the node protection
+// labels.  The Kubernetes protection label is not applied to the StorageOS
+// node, which has its own protection label set by the node delete controller.
+func withoutProtection(labels map[string]string) map[string]string {
+	ret := make(map[string]string)
+	for k, v := range labels {
+		if k != storageos.ReservedLabelProtectNode && k != storageos.LabelNodeProtected {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
 // Delete is a no-op.  The node-delete controller will handle deletes.
 func (c Controller) Delete(ctx context.Context, obj client.Object) error {
 	return nil
@@ -0,0 +1,68 @@
+package nodelabel
+
+import (
+	"context"
+	"testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+func TestControllerDiffProtection(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		nodeLabels    map[string]string
+		storageLabels map[string]string
+		wantStale     bool
+	}{
+		{
+			name:          "unchanged",
+			nodeLabels:    map[string]string{"foo": "bar"},
+			storageLabels: map[string]string{"foo": "bar"},
+		},
+		{
+			name:          "changed",
+			nodeLabels:    map[string]string{"foo": "baz"},
+			storageLabels: map[string]string{"foo": "bar"},
+			wantStale:     true,
+		},
+		{
+			name:          "protected node",
+			nodeLabels:    map[string]string{"foo": "bar", storageos.ReservedLabelProtectNode: "true"},
+			storageLabels: map[string]string{"foo": "bar", storageos.LabelNodeProtected: "true"},
+		},
+		{
+			name:          "protection not yet recorded",
+			nodeLabels:    map[string]string{"foo": "bar", storageos.ReservedLabelProtectNode: "true"},
+			storageLabels: map[string]string{"foo": "bar"},
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			node := genNode(false, tt.nodeLabels)
+			api := storageos.NewMockClient()
+			if err := api.AddNode(storageos.MockObject{Name: node.GetName(), Labels: tt.storageLabels}); err != nil {
+				t.Fatal(err)
+			}
+			c, err := NewController(api, CordonPolicy{}, nil, ctrl.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stale, err := c.Diff(context.Background(), []client.Object{node})
+			if err != nil {
+				t.Fatalf("Diff() unexpected error: %v", err)
+			}
+			if got := len(stale) == 1; got != tt.wantStale {
+				t.Errorf("Diff() stale = %t, want %t", got, tt.wantStale)
+			}
+		})
+	}
+}
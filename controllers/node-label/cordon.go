@@ -0,0 +1,86 @@
+package nodelabel
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nodedelete "github.com/storageos/api-manager/controllers/node-delete"
+	"github.com/storageos/api-manager/internal/pkg/labels"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+// CordonPolicy determines which Kubernetes node states cause the StorageOS node
+// to be marked as compute-only, so that no new volume masters or replicas are
+// placed on it.
+//
+// Nodes with the node-delete decommission annotation always match the policy.
+//
+// Compute-only is removed once the node no longer matches the policy, unless
+// the `storageos.com/computeonly=true` label is set on the node.
+type CordonPolicy struct {
+	// Unschedulable marks cordoned nodes as compute-only.
+	Unschedulable bool
+
+	// Taints mark nodes with any of the taints as compute-only.  Each taint is
+	// given as `key` or `key:Effect`.
+	Taints []string
+}
+
+// ParseCordonTaints parses a comma-separated list of taints, as used by the
+// CordonPolicy.
+func ParseCordonTaints(s string) []string {
+	return labels.SplitList(s)
+}
+
+// Enabled returns true if the policy can mark nodes as compute-only.
+func (p CordonPolicy) Enabled() bool {
+	return p.Unschedulable || len(p.Taints) > 0
+}
+
+// Cordoned returns true if the node matches the policy, or is being
+// decommissioned.
+func (p CordonPolicy) Cordoned(node *corev1.Node) bool {
+	if nodedelete.DecommissionRequested(node) {
+		return true
+	}
+	if p.Unschedulable && node.Spec.Unschedulable {
+		return true
+	}
+	for _, taint := range node.Spec.Taints {
+		if p.matchTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTaint returns true if the taint matches any of the policy taints.
+func (p CordonPolicy) matchTaint(taint corev1.Taint) bool {
+	for _, t := range p.Taints {
+		key, effect := t, ""
+		if i := strings.LastIndex(t, ":"); i >= 0 {
+			key, effect = t[:i], t[i+1:]
+		}
+		if key == taint.Key && (effect == "" || effect == string(taint.Effect)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DesiredLabels returns the labels to apply to the StorageOS node: the node
+// labels, with compute-only set if the node matches the policy.
+func (p CordonPolicy) DesiredLabels(obj client.Object) map[string]string {
+	node, ok := obj.(*corev1.Node)
+	if !ok || !p.Cordoned(node) {
+		return obj.GetLabels()
+	}
+	ret := make(map[string]string)
+	for k, v := range obj.GetLabels() {
+		ret[k] = v
+	}
+	ret[storageos.ReservedLabelComputeOnly] = "true"
+	return ret
+}
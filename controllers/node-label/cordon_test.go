@@ -0,0 +1,290 @@
+package nodelabel
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	nodedelete "github.com/storageos/api-manager/controllers/node-delete"
+	"github.com/storageos/api-manager/internal/pkg/labels"
+	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+func genNode(unschedulable bool, nodeLabels map[string]string, taints ...corev1.Taint) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node1",
+			Labels:      nodeLabels,
+			Annotations: map[string]string{provisioner.NodeDriverAnnotationKey: `{"csi.storageos.com":"node1-id"}`},
+		},
+		Spec: corev1.NodeSpec{
+			Unschedulable: unschedulable,
+			Taints:        taints,
+		},
+	}
+}
+
+func genDecommissionNode(nodeLabels map[string]string) *corev1.Node {
+	node := genNode(false, nodeLabels)
+	node.Annotations[nodedelete.DecommissionAnnotationKey] = "true"
+	return node
+}
+
+func TestParseCordonTaints(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{in: "", want: nil},
+		{in: "maintenance", want: []string{"maintenance"}},
+		{in: " maintenance:NoSchedule, example.com/drain ,", want: []string{"maintenance:NoSchedule", "example.com/drain"}},
+	}
+	for _, tt := range tests {
+		if got := ParseCordonTaints(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCordonTaints(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCordonPolicyDesiredLabels(t *testing.T) {
+	t.Parallel()
+
+	computeOnly := map[string]string{"foo": "bar", storageos.ReservedLabelComputeOnly: "true"}
+
+	tests := []struct {
+		name   string
+		policy CordonPolicy
+		node   *corev1.Node
+		want   map[string]string
+	}{
+		{
+			name:   "disabled",
+			policy: CordonPolicy{},
+			node:   genNode(true, map[string]string{"foo": "bar"}),
+			want:   map[string]string{"foo": "bar"},
+		},
+		{
+			name:   "cordoned",
+			policy: CordonPolicy{Unschedulable: true},
+			node:   genNode(true, map[string]string{"foo": "bar"}),
+			want:   computeOnly,
+		},
+		{
+			name:   "not cordoned",
+			policy: CordonPolicy{Unschedulable: true},
+			node:   genNode(false, map[string]string{"foo": "bar"}),
+			want:   map[string]string{"foo": "bar"},
+		},
+		{
+			name:   "cordon overrides label",
+			policy: CordonPolicy{Unschedulable: true},
+			node:   genNode(true, map[string]string{"foo": "bar", storageos.ReservedLabelComputeOnly: "false"}),
+			want:   computeOnly,
+		},
+		{
+			name:   "taint key",
+			policy: CordonPolicy{Taints: []string{"maintenance"}},
+			node:   genNode(false, map[string]string{"foo": "bar"}, corev1.Taint{Key: "maintenance", Effect: corev1.TaintEffectNoExecute}),
+			want:   computeOnly,
+		},
+		{
+			name:   "taint key and effect",
+			policy: CordonPolicy{Taints: []string{"maintenance:NoSchedule"}},
+			node:   genNode(false, map[string]string{"foo": "bar"}, corev1.Taint{Key: "maintenance", Effect: corev1.TaintEffectNoSchedule}),
+			want:   computeOnly,
+		},
+		{
+			name:   "taint effect mismatch",
+			policy: CordonPolicy{Taints: []string{"maintenance:NoSchedule"}},
+			node:   genNode(false, map[string]string{"foo": "bar"}, corev1.Taint{Key: "maintenance", Effect: corev1.TaintEffectNoExecute}),
+			want:   map[string]string{"foo": "bar"},
+		},
+		{
+			name:   "other taint",
+			policy: CordonPolicy{Taints: []string{"maintenance"}},
+			node:   genNode(false, map[string]string{"foo": "bar"}, corev1.Taint{Key: "gpu", Effect: corev1.TaintEffectNoSchedule}),
+			want:   map[string]string{"foo": "bar"},
+		},
+		{
+			name:   "decommission with policy disabled",
+			policy: CordonPolicy{},
+			node:   genDecommissionNode(map[string]string{"foo": "bar"}),
+			want:   computeOnly,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.policy.DesiredLabels(tt.node); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CordonPolicy.DesiredLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestControllerEnsureCordon(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := storageos.NewMockClient()
+	c, err := NewController(api, CordonPolicy{Unschedulable: true}, nil, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := client.ObjectKey{Name: "node1"}
+
+	// Cordon, then uncordon.
+	steps := []struct {
+		node *corev1.Node
+		want map[string]string
+	}{
+		{node: genNode(true, map[string]string{"foo": "bar"}), want: map[string]string{"foo": "bar", storageos.ReservedLabelComputeOnly: "true"}},
+		{node: genNode(false, map[string]string{"foo": "bar"}), want: map[string]string{"foo": "bar"}},
+	}
+	for i, step := range steps {
+		if err := c.Ensure(ctx, step.node); err != nil {
+			t.Fatalf("step %d: Ensure() unexpected error: %v", i, err)
+		}
+		got, err := api.GetNodeLabels(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("step %d: storageos node labels = %v, want %v", i, got, step.want)
+		}
+	}
+}
+
+func TestPredicateUpdate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy CordonPolicy
+		filter *labels.Filter
+		oldObj *corev1.Node
+		newObj *corev1.Node
+		want   bool
+	}{
+		{
+			name:   "unchanged",
+			policy: CordonPolicy{Unschedulable: true},
+			oldObj: genNode(false, nil),
+			newObj: genNode(false, nil),
+		},
+		{
+			name:   "label change",
+			oldObj: genNode(false, nil),
+			newObj: genNode(false, map[string]string{"foo": "bar"}),
+			want:   true,
+		},
+		{
+			name:   "status label change",
+			oldObj: genNode(false, map[string]string{"foo": "bar"}),
+			newObj: genNode(false, map[string]string{"foo": "bar", StatusHealthLabel: "online"}),
+		},
+		{
+			name:   "filtered label change",
+			filter: &labels.Filter{ExcludePrefixes: []string{"example.com/"}},
+			oldObj: genNode(false, map[string]string{"foo": "bar"}),
+			newObj: genNode(false, map[string]string{"foo": "bar", "example.com/build": "1"}),
+		},
+		{
+			name:   "filtered zone change",
+			filter: &labels.Filter{ExcludePrefixes: []string{"topology."}},
+			oldObj: genNode(false, map[string]string{corev1.LabelTopologyZone: "a"}),
+			newObj: genNode(false, map[string]string{corev1.LabelTopologyZone: "b"}),
+			want:   true,
+		},
+		{
+			name:   "reserved label change with filter",
+			filter: &labels.Filter{IncludePrefixes: []string{"example.com/"}},
+			oldObj: genNode(false, nil),
+			newObj: genNode(false, map[string]string{storageos.ReservedLabelComputeOnly: "true"}),
+			want:   true,
+		},
+		{
+			name:   "cordoned",
+			policy: CordonPolicy{Unschedulable: true},
+			oldObj: genNode(false, nil),
+			newObj: genNode(true, nil),
+			want:   true,
+		},
+		{
+			name:   "uncordoned",
+			policy: CordonPolicy{Unschedulable: true},
+			oldObj: genNode(true, nil),
+			newObj: genNode(false, nil),
+			want:   true,
+		},
+		{
+			name:   "cordoned with policy disabled",
+			oldObj: genNode(false, nil),
+			newObj: genNode(true, nil),
+		},
+		{
+			name:   "taint added",
+			policy: CordonPolicy{Taints: []string{"maintenance"}},
+			oldObj: genNode(false, nil),
+			newObj: genNode(false, nil, corev1.Taint{Key: "maintenance", Effect: corev1.TaintEffectNoSchedule}),
+			want:   true,
+		},
+		{
+			name:   "other taint added",
+			policy: CordonPolicy{Taints: []string{"maintenance"}},
+			oldObj: genNode(false, nil),
+			newObj: genNode(false, nil, corev1.Taint{Key: "gpu", Effect: corev1.TaintEffectNoSchedule}),
+		},
+		{
+			name:   "decommission requested with policy disabled",
+			oldObj: genNode(false, nil),
+			newObj: genDecommissionNode(nil),
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := Predicate{cordon: tt.policy, filter: tt.filter, log: ctrl.Log}
+			if got := p.Update(event.UpdateEvent{ObjectOld: tt.oldObj, ObjectNew: tt.newObj}); got != tt.want {
+				t.Errorf("Predicate.Update() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestControllerEnsureFilter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := storageos.NewMockClient()
+	filter := &labels.Filter{ExcludePrefixes: []string{"example.com/"}}
+	c, err := NewController(api, CordonPolicy{Unschedulable: true}, filter, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := genNode(true, map[string]string{"foo": "bar", "example.com/build": "1"})
+	if err := c.Ensure(ctx, node); err != nil {
+		t.Fatalf("Ensure() unexpected error: %v", err)
+	}
+	got, err := api.GetNodeLabels(client.ObjectKeyFromObject(node))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"foo": "bar", storageos.ReservedLabelComputeOnly: "true"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("storageos node labels = %v, want %v", got, want)
+	}
+}
@@ -1,18 +1,22 @@
 package nodelabel
 
 import (
-	"reflect"
-
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 
+	"github.com/storageos/api-manager/internal/pkg/labels"
 	"github.com/storageos/api-manager/internal/pkg/predicate"
 	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
 )
 
 // Predicate filters events before enqueuing the keys.  Ignore all but Update
 // events, and then filter out events from non-StorageOS nodes.  Trigger a
-// resync when labels have changed.
+// resync when labels selected by the filter have changed, or when the node
+// starts or stops matching the cordon policy.
 //
 // Nodes added to the cluster will not immediately be added to StorageOS, so we
 // can't react to node create events.  Instead, trigger a resync when the
@@ -20,7 +24,9 @@ import (
 // known to StorageOS and can receive label updates.
 type Predicate struct {
 	predicate.IgnoreFuncs
-	log logr.Logger
+	cordon CordonPolicy
+	filter *labels.Filter
+	log    logr.Logger
 }
 
 // Update determines whether an object update should trigger a reconcile.
@@ -43,9 +49,21 @@ func (p Predicate) Update(e event.UpdateEvent) bool {
 		return true
 	}
 
-	// Otherwise reconcile on label changes.
-	if !reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()) {
+	// Otherwise reconcile on label changes, ignoring status labels set by the
+	// node status sync and labels not selected by the filter.
+	if !k8slabels.Equals(p.syncedLabels(e.ObjectOld), p.syncedLabels(e.ObjectNew)) {
 		return true
 	}
-	return false
+
+	// Or when the node has been cordoned or uncordoned, including by the
+	// decommission annotation.
+	oldNode, okOld := e.ObjectOld.(*corev1.Node)
+	newNode, okNew := e.ObjectNew.(*corev1.Node)
+	return okOld && okNew && p.cordon.Cordoned(oldNode) != p.cordon.Cordoned(newNode)
+}
+
+// syncedLabels returns the node labels that are synced to StorageOS, including
+// the failure domain.
+func (p Predicate) syncedLabels(obj client.Object) map[string]string {
+	return withFailureDomain(p.filter.Apply(withoutStatus(obj.GetLabels()), storageos.IsReservedLabel), obj.GetLabels())
 }
@@ -13,6 +13,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 
+	"github.com/storageos/api-manager/internal/pkg/labels"
 	"github.com/storageos/api-manager/internal/pkg/storageos"
 )
 
@@ -29,6 +30,8 @@ type Reconciler struct {
 	client.Client
 	log            logr.Logger
 	api            NodeLabeller
+	cordon         CordonPolicy
+	filter         *labels.Filter
 	resyncDelay    time.Duration
 	resyncInterval time.Duration
 
@@ -40,12 +43,15 @@ type Reconciler struct {
 // NewReconciler returns a new Node label reconciler.
 //
 // The resyncInterval determines how often the periodic resync operation should
-// be run.
-func NewReconciler(api NodeLabeller, k8s client.Client, resyncDelay time.Duration, resyncInterval time.Duration) *Reconciler {
+// be run.  The cordon policy determines which nodes are marked as compute-only.
+// Only labels selected by the filter are synced, or all labels if nil.
+func NewReconciler(api NodeLabeller, k8s client.Client, resyncDelay time.Duration, resyncInterval time.Duration, cordon CordonPolicy, filter *labels.Filter) *Reconciler {
 	return &Reconciler{
 		Client:         k8s,
 		log:            ctrl.Log,
 		api:            api,
+		cordon:         cordon,
+		filter:         filter,
 		resyncDelay:    resyncDelay,
 		resyncInterval: resyncInterval,
 	}
@@ -53,7 +59,7 @@ func NewReconciler(api NodeLabeller, k8s client.Client, resyncDelay time.Duratio
 
 // SetupWithManager registers the controller with the controller manager.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, workers int) error {
-	c, err := NewController(r.api, r.log)
+	c, err := NewController(r.api, r.cordon, r.filter, r.log)
 	if err != nil {
 		return err
 	}
@@ -74,6 +80,6 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, workers int) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		WithOptions(controller.Options{MaxConcurrentReconciles: workers}).
 		For(&corev1.Node{}).
-		WithEventFilter(Predicate{log: r.log}).
+		WithEventFilter(Predicate{cordon: r.cordon, filter: r.filter, log: r.log}).
 		Complete(r)
 }
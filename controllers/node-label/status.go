@@ -0,0 +1,229 @@
+package nodelabel
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/go-multierror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	storageosv1 "github.com/storageos/api-manager/api/v1"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+const (
+	// StatusPrefix is the prefix of the Kubernetes node labels and annotations
+	// maintained by the node status sync.  Labels with this prefix are not
+	// synced to StorageOS.
+	StatusPrefix = "status.storageos.com/"
+
+	// StatusHealthLabel is the node label set to the StorageOS node health.
+	StatusHealthLabel = StatusPrefix + "health"
+
+	// StatusComputeOnlyLabel is the node label set to true if the StorageOS
+	// node is compute-only.
+	StatusComputeOnlyLabel = StatusPrefix + "computeonly"
+
+	// StatusCapacityTotalAnnotation is the node annotation set to the total
+	// StorageOS capacity, in bytes.
+	StatusCapacityTotalAnnotation = StatusPrefix + "capacity-total"
+
+	// StatusCapacityFreeAnnotation is the node annotation set to the free
+	// StorageOS capacity, in bytes.
+	StatusCapacityFreeAnnotation = StatusPrefix + "capacity-free"
+
+	// StatusCapacityAvailableAnnotation is the node annotation set to the
+	// StorageOS capacity available for new volumes, in bytes.
+	StatusCapacityAvailableAnnotation = StatusPrefix + "capacity-available"
+)
+
+// IsStatusKey returns true if the label or annotation key is maintained by the
+// node status sync.
+func IsStatusKey(key string) bool {
+	return strings.HasPrefix(key, StatusPrefix)
+}
+
+// NodeLister provides access to list StorageOS nodes.
+type NodeLister interface {
+	ListNodes(ctx context.Context) ([]client.Object, error)
+}
+
+// StatusSyncer periodically sets StorageOS node health, capacity and
+// compute-only status as labels and annotations on the Kubernetes nodes.
+//
+// Only labels and annotations with the StatusPrefix are changed, and labels
+// with the prefix are ignored by the node label sync, so the two don't
+// conflict.
+type StatusSyncer struct {
+	client.Client
+	log      logr.Logger
+	api      NodeLister
+	interval time.Duration
+}
+
+var _ manager.Runnable = &StatusSyncer{}
+var _ manager.LeaderElectionRunnable = &StatusSyncer{}
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch
+
+// NewStatusSyncer returns a new node status syncer, that syncs on each
+// interval.
+func NewStatusSyncer(api NodeLister, k8s client.Client, interval time.Duration) *StatusSyncer {
+	return &StatusSyncer{
+		Client:   k8s,
+		log:      ctrl.Log.WithName("node-status-sync"),
+		api:      api,
+		interval: interval,
+	}
+}
+
+// SetupWithManager registers the syncer with the controller manager.
+func (s *StatusSyncer) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(s)
+}
+
+// NeedLeaderElection returns true so that only the leader updates nodes.
+func (s *StatusSyncer) NeedLeaderElection() bool {
+	return true
+}
+
+// Start syncs immediately and then on each interval, until the context is
+// cancelled.  Failures are logged and retried on the next interval.
+func (s *StatusSyncer) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Sync(ctx); err != nil {
+			s.log.Error(err, "failed to sync storageos node status")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync sets the StorageOS status labels and annotations on all Kubernetes
+// nodes.  Status is removed from nodes that are not known to StorageOS.
+func (s *StatusSyncer) Sync(ctx context.Context) error {
+	tr := otel.Tracer("node-label")
+	ctx, span := tr.Start(ctx, "node status sync")
+	defer span.End()
+
+	observeErr := func(err error) error {
+		span.RecordError(err)
+		return err
+	}
+
+	apiCtx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+
+	stosNodes, err := s.api.ListNodes(apiCtx)
+	if err != nil {
+		return observeErr(err)
+	}
+	status := make(map[string]*storageosv1.Node)
+	for _, obj := range stosNodes {
+		if n, ok := obj.(*storageosv1.Node); ok {
+			status[n.GetName()] = n
+		}
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := s.List(ctx, nodes); err != nil {
+		return observeErr(err)
+	}
+
+	var errs *multierror.Error
+	var updated int
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		labels, annotations := desiredStatus(status[node.GetName()])
+		changed, err := s.setStatus(ctx, node, labels, annotations)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if changed {
+			updated++
+		}
+	}
+	span.SetAttributes(label.Int("updated nodes", updated))
+	if err := errs.ErrorOrNil(); err != nil {
+		return observeErr(err)
+	}
+	span.SetStatus(codes.Ok, "node status synced")
+	return nil
+}
+
+// setStatus replaces the status labels and annotations on the node, if
+// changed.  Other labels and annotations are not modified.
+func (s *StatusSyncer) setStatus(ctx context.Context, node *corev1.Node, labels map[string]string, annotations map[string]string) (bool, error) {
+	patch := client.MergeFrom(node.DeepCopy())
+
+	newLabels, labelsChanged := replaceStatus(node.GetLabels(), labels)
+	newAnnotations, annotationsChanged := replaceStatus(node.GetAnnotations(), annotations)
+	if !labelsChanged && !annotationsChanged {
+		return false, nil
+	}
+	node.SetLabels(newLabels)
+	node.SetAnnotations(newAnnotations)
+
+	if err := s.Patch(ctx, node, patch); err != nil {
+		return false, err
+	}
+	s.log.V(4).Info("updated storageos node status", "name", node.GetName())
+	return true, nil
+}
+
+// desiredStatus returns the status labels and annotations for the StorageOS
+// node.  Both are empty if the node is nil.
+func desiredStatus(n *storageosv1.Node) (map[string]string, map[string]string) {
+	labels := make(map[string]string)
+	annotations := make(map[string]string)
+	if n == nil {
+		return labels, annotations
+	}
+
+	computeOnly, _ := strconv.ParseBool(n.GetLabels()[storageos.ReservedLabelComputeOnly])
+
+	labels[StatusHealthLabel] = string(n.Status.Health)
+	labels[StatusComputeOnlyLabel] = strconv.FormatBool(computeOnly)
+	annotations[StatusCapacityTotalAnnotation] = strconv.FormatUint(n.Status.Capacity.Total, 10)
+	annotations[StatusCapacityFreeAnnotation] = strconv.FormatUint(n.Status.Capacity.Free, 10)
+	annotations[StatusCapacityAvailableAnnotation] = strconv.FormatUint(n.Status.Capacity.Available, 10)
+	return labels, annotations
+}
+
+// replaceStatus returns the current map with all status keys replaced by the
+// desired status, and whether it changed.
+func replaceStatus(current map[string]string, desired map[string]string) (map[string]string, bool) {
+	ret := make(map[string]string)
+	changed := false
+	for k, v := range current {
+		if !IsStatusKey(k) {
+			ret[k] = v
+			continue
+		}
+		if dv, ok := desired[k]; !ok || dv != v {
+			changed = true
+		}
+	}
+	for k, v := range desired {
+		if cv, ok := current[k]; !ok || cv != v {
+			changed = true
+		}
+		ret[k] = v
+	}
+	return ret, changed
+}
@@ -0,0 +1,169 @@
+package nodelabel
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storageosv1 "github.com/storageos/api-manager/api/v1"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+type fakeNodeLister []client.Object
+
+func (l fakeNodeLister) ListNodes(ctx context.Context) ([]client.Object, error) {
+	return l, nil
+}
+
+func TestStatusSyncerSync(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	stosNode := &storageosv1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node1",
+			Labels: map[string]string{storageos.ReservedLabelComputeOnly: "true"},
+		},
+		Status: storageosv1.NodeStatus{
+			Health: storageosv1.NodeHealthOnline,
+			Capacity: storageosv1.CapacityStats{
+				Total:     100,
+				Free:      60,
+				Available: 50,
+			},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		labels          map[string]string
+		annotations     map[string]string
+		stosNodes       fakeNodeLister
+		wantLabels      map[string]string
+		wantAnnotations map[string]string
+	}{
+		{
+			name:        "status added",
+			labels:      map[string]string{"foo": "bar"},
+			annotations: map[string]string{"baz": "qux"},
+			stosNodes:   fakeNodeLister{stosNode},
+			wantLabels: map[string]string{
+				"foo":                  "bar",
+				StatusHealthLabel:      "online",
+				StatusComputeOnlyLabel: "true",
+			},
+			wantAnnotations: map[string]string{
+				"baz":                             "qux",
+				StatusCapacityTotalAnnotation:     "100",
+				StatusCapacityFreeAnnotation:      "60",
+				StatusCapacityAvailableAnnotation: "50",
+			},
+		},
+		{
+			name: "status updated",
+			labels: map[string]string{
+				"foo":                  "bar",
+				StatusHealthLabel:      "offline",
+				StatusComputeOnlyLabel: "false",
+				StatusPrefix + "stale": "true",
+			},
+			stosNodes: fakeNodeLister{stosNode},
+			wantLabels: map[string]string{
+				"foo":                  "bar",
+				StatusHealthLabel:      "online",
+				StatusComputeOnlyLabel: "true",
+			},
+			wantAnnotations: map[string]string{
+				StatusCapacityTotalAnnotation:     "100",
+				StatusCapacityFreeAnnotation:      "60",
+				StatusCapacityAvailableAnnotation: "50",
+			},
+		},
+		{
+			name: "status removed from nodes not in storageos",
+			labels: map[string]string{
+				"foo":             "bar",
+				StatusHealthLabel: "online",
+			},
+			annotations: map[string]string{
+				"baz":                         "qux",
+				StatusCapacityTotalAnnotation: "100",
+			},
+			wantLabels:      map[string]string{"foo": "bar"},
+			wantAnnotations: map[string]string{"baz": "qux"},
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "node1",
+					Labels:      tt.labels,
+					Annotations: tt.annotations,
+				},
+			}
+			k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(node).Build()
+
+			s := NewStatusSyncer(tt.stosNodes, k8s, time.Minute)
+			if err := s.Sync(ctx); err != nil {
+				t.Fatalf("Sync() unexpected error: %v", err)
+			}
+
+			got := &corev1.Node{}
+			if err := k8s.Get(ctx, client.ObjectKeyFromObject(node), got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.GetLabels()) > 0 || len(tt.wantLabels) > 0 {
+				if !reflect.DeepEqual(got.GetLabels(), tt.wantLabels) {
+					t.Errorf("node labels = %v, want %v", got.GetLabels(), tt.wantLabels)
+				}
+			}
+			if len(got.GetAnnotations()) > 0 || len(tt.wantAnnotations) > 0 {
+				if !reflect.DeepEqual(got.GetAnnotations(), tt.wantAnnotations) {
+					t.Errorf("node annotations = %v, want %v", got.GetAnnotations(), tt.wantAnnotations)
+				}
+			}
+		})
+	}
+}
+
+func TestControllerEnsureIgnoresStatusLabels(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	api := storageos.NewMockClient()
+	c, err := NewController(api, CordonPolicy{}, nil, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := genNode(false, map[string]string{"foo": "bar", StatusHealthLabel: "online"})
+	if err := c.Ensure(ctx, node); err != nil {
+		t.Fatalf("Ensure() unexpected error: %v", err)
+	}
+	got, err := api.GetNodeLabels(client.ObjectKeyFromObject(node))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"foo": "bar"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("storageos node labels = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package nodelabel
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+// FailureDomain returns the StorageOS failure domain for a node with the given
+// Kubernetes labels.  The zone is used if set, otherwise the region.  Zone
+// names are unique across regions, so the region is not needed to distinguish
+// them.  The deprecated beta topology labels are used if the standard labels
+// are not set.
+//
+// An empty string is returned if the node has no topology labels.
+func FailureDomain(nodeLabels map[string]string) string {
+	for _, key := range []string{
+		corev1.LabelTopologyZone,
+		corev1.LabelFailureDomainBetaZone,
+		corev1.LabelTopologyRegion,
+		corev1.LabelFailureDomainBetaRegion,
+	} {
+		if v := nodeLabels[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// withFailureDomain returns the labels with the StorageOS failure domain label
+// set from the node's topology labels.  A failure domain label set on the
+// Kubernetes node takes precedence.
+func withFailureDomain(labels map[string]string, nodeLabels map[string]string) map[string]string {
+	domain, ok := nodeLabels[storageos.LabelFailureDomain]
+	if !ok {
+		domain = FailureDomain(nodeLabels)
+	}
+	if domain == "" {
+		return labels
+	}
+	ret := make(map[string]string)
+	for k, v := range labels {
+		ret[k] = v
+	}
+	ret[storageos.LabelFailureDomain] = domain
+	return ret
+}
@@ -0,0 +1,133 @@
+package nodelabel
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/labels"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+func TestFailureDomain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   string
+	}{
+		{
+			name: "no topology labels",
+		},
+		{
+			name: "zone and region",
+			labels: map[string]string{
+				corev1.LabelTopologyZone:   "eu-west-1a",
+				corev1.LabelTopologyRegion: "eu-west-1",
+			},
+			want: "eu-west-1a",
+		},
+		{
+			name:   "region only",
+			labels: map[string]string{corev1.LabelTopologyRegion: "eu-west-1"},
+			want:   "eu-west-1",
+		},
+		{
+			name:   "beta zone",
+			labels: map[string]string{corev1.LabelFailureDomainBetaZone: "eu-west-1b"},
+			want:   "eu-west-1b",
+		},
+		{
+			name: "standard zone preferred",
+			labels: map[string]string{
+				corev1.LabelTopologyZone:          "eu-west-1a",
+				corev1.LabelFailureDomainBetaZone: "eu-west-1b",
+			},
+			want: "eu-west-1a",
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := FailureDomain(tt.labels); got != tt.want {
+				t.Errorf("FailureDomain() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestControllerEnsureFailureDomain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		filter *labels.Filter
+		labels map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "zone",
+			labels: map[string]string{corev1.LabelTopologyZone: "a"},
+			want: map[string]string{
+				corev1.LabelTopologyZone:     "a",
+				storageos.LabelFailureDomain: "a",
+			},
+		},
+		{
+			name:   "zone filtered",
+			filter: &labels.Filter{ExcludePrefixes: []string{"topology."}},
+			labels: map[string]string{corev1.LabelTopologyZone: "a", "foo": "bar"},
+			want: map[string]string{
+				"foo":                        "bar",
+				storageos.LabelFailureDomain: "a",
+			},
+		},
+		{
+			name: "node override",
+			labels: map[string]string{
+				corev1.LabelTopologyZone:     "a",
+				storageos.LabelFailureDomain: "rack1",
+			},
+			want: map[string]string{
+				corev1.LabelTopologyZone:     "a",
+				storageos.LabelFailureDomain: "rack1",
+			},
+		},
+		{
+			name:   "no topology",
+			labels: map[string]string{"foo": "bar"},
+			want:   map[string]string{"foo": "bar"},
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			api := storageos.NewMockClient()
+			c, err := NewController(api, CordonPolicy{}, tt.filter, ctrl.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node := genNode(false, tt.labels)
+			if err := c.Ensure(ctx, node); err != nil {
+				t.Fatalf("Ensure() unexpected error: %v", err)
+			}
+			got, err := api.GetNodeLabels(client.ObjectKeyFromObject(node))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("storageos node labels = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -53,7 +53,7 @@ func SetupNodeDeleteTest(ctx context.Context, createK8sNode bool, isStorageOS bo
 			gcInterval = time.Hour
 		}
 
-		controller := nodedelete.NewReconciler(api, mgr.GetClient(), defaultSyncDelay, gcInterval)
+		controller := nodedelete.NewReconciler(api, mgr.GetClient(), defaultSyncDelay, gcInterval, mgr.GetEventRecorderFor("storageos-api-manager"), true, 0, time.Hour)
 		err = controller.SetupWithManager(mgr, defaultWorkers)
 		Expect(err).NotTo(HaveOccurred(), "failed to setup controller")
 
@@ -58,7 +58,7 @@ func SetupNodeLabelSyncTest(ctx context.Context, isStorageOS bool, createLabels
 			gcInterval = time.Hour
 		}
 
-		controller := nodelabel.NewReconciler(api, mgr.GetClient(), defaultSyncDelay, gcInterval)
+		controller := nodelabel.NewReconciler(api, mgr.GetClient(), defaultSyncDelay, gcInterval, nodelabel.CordonPolicy{}, nil)
 		err = controller.SetupWithManager(mgr, defaultWorkers)
 		Expect(err).NotTo(HaveOccurred(), "failed to setup controller")
 
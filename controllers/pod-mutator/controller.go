@@ -6,13 +6,19 @@ import (
 	"net/http"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/go-logr/logr"
+
+	"github.com/storageos/api-manager/internal/pkg/mutation"
 )
 
+// WebhookName is the webhook label value of the mutator decision metrics.
+const WebhookName = "pod-mutator"
+
 type Controller struct {
 	client.Client
 	mutators []Mutator
@@ -20,7 +26,10 @@ type Controller struct {
 	log      logr.Logger
 }
 
+// Mutator is implemented by Pod mutators.  Name is used to identify the
+// mutator in its decision metrics.
 type Mutator interface {
+	Name() string
 	MutatePod(ctx context.Context, pod *corev1.Pod, namespace string) error
 }
 
@@ -31,6 +40,7 @@ var _ admission.Handler = &Controller{}
 
 // NewController returns a new Pod mutating admission controller.
 func NewController(k8s client.Client, decoder *admission.Decoder, mutators []Mutator) *Controller {
+	mutation.RegisterMetrics()
 	return &Controller{
 		mutators: mutators,
 		Client:   k8s,
@@ -40,6 +50,7 @@ func NewController(k8s client.Client, decoder *admission.Decoder, mutators []Mut
 }
 
 // Handle handles an admission request and mutates a pod object in the request.
+// The decision of each mutator is recorded in the mutator decision metrics.
 func (c *Controller) Handle(ctx context.Context, req admission.Request) admission.Response {
 	pod := &corev1.Pod{}
 
@@ -54,7 +65,11 @@ func (c *Controller) Handle(ctx context.Context, req admission.Request) admissio
 
 	// Run the mutators on the Pod object.
 	for _, m := range c.mutators {
-		if err := m.MutatePod(ctx, pod, namespace); err != nil {
+		before := pod.DeepCopy()
+		err := m.MutatePod(ctx, pod, namespace)
+		changed := !equality.Semantic.DeepEqual(before, pod)
+		mutation.Decisions.Increment(WebhookName, m.Name(), mutation.Decision(changed, err))
+		if err != nil {
 			c.log.Error(err, "failed to mutate pod")
 			return admission.Errored(http.StatusInternalServerError, err)
 		}
@@ -50,6 +50,11 @@ func NewPodSchedulerSetter(k8s client.Client, scheduler string) *PodSchedulerSet
 	}
 }
 
+// Name returns the name of the mutator.
+func (p *PodSchedulerSetter) Name() string {
+	return "scheduler"
+}
+
 // MutatePod mutates a given pod with a configured scheduler name if the pod is
 // associated with volumes managed by the configured provisioners.
 //
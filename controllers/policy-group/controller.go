@@ -0,0 +1,194 @@
+package policygroup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	syncv1 "github.com/darkowlzz/operator-toolkit/controller/sync/v1"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	storageosv1 "github.com/storageos/api-manager/api/v1"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+const (
+	// GroupNamePrefix is prepended to the StoragePolicyGroup name to give the
+	// StorageOS policy group name.  Only policy groups with the prefix are
+	// managed, so that policy groups created by other means are never garbage
+	// collected.
+	GroupNamePrefix = "k8s-"
+
+	// SyncedReason is the Ready condition reason set when the StorageOS policy
+	// group matches the spec.
+	SyncedReason = "PolicyGroupSynced"
+
+	// NamespaceNotFoundReason is the Ready condition reason set when a
+	// namespace in the rules does not exist in StorageOS.
+	NamespaceNotFoundReason = "NamespaceNotFound"
+
+	// UserNotFoundReason is the Ready condition reason set when a member user
+	// does not exist in StorageOS.
+	UserNotFoundReason = "UserNotFound"
+
+	// SyncFailedReason is the Ready condition reason set when the StorageOS
+	// policy group could not be updated.
+	SyncFailedReason = "PolicyGroupSyncFailed"
+)
+
+// Controller implements the SyncReconciler contoller interface, syncing
+// StoragePolicyGroups to StorageOS policy groups.
+type Controller struct {
+	api PolicyGroupSyncer
+	k8s client.Client
+	log logr.Logger
+}
+
+var _ syncv1.Controller = &Controller{}
+
+// NewController returns a Controller that implements policy group sync in
+// StorageOS.
+func NewController(api PolicyGroupSyncer, k8s client.Client, log logr.Logger) (*Controller, error) {
+	return &Controller{api: api, k8s: k8s, log: log}, nil
+}
+
+// GroupName returns the name of the StorageOS policy group for the
+// StoragePolicyGroup.
+func GroupName(name string) string {
+	return GroupNamePrefix + name
+}
+
+// Ensure creates or updates the StorageOS policy group so that it grants the
+// access declared in the StoragePolicyGroup rules to the member users.  The
+// result is recorded in the Ready status condition.
+//
+// Any errors will result in a requeue, with standard back-off retries.  This
+// includes namespaces or users that don't exist yet in StorageOS.
+func (c Controller) Ensure(ctx context.Context, obj client.Object) error {
+	spg, ok := obj.(*storageosv1.StoragePolicyGroup)
+	if !ok {
+		return fmt.Errorf("unexpected object type %T", obj)
+	}
+
+	tr := otel.Tracer("policy-group")
+	ctx, span := tr.Start(ctx, "policy group ensure")
+	span.SetAttributes(label.String("name", obj.GetName()))
+	defer span.End()
+
+	apiCtx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+
+	id, err := c.api.EnsurePolicyGroup(apiCtx, GroupName(spg.GetName()), policyRules(spg.Spec.Rules), spg.Spec.Users)
+	if statusErr := c.updateStatus(ctx, spg, id, err); statusErr != nil {
+		c.log.Error(statusErr, "failed to update policy group status", "name", obj.GetName())
+	}
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	span.SetStatus(codes.Ok, "policy group synced to storageos")
+	c.log.Info("policy group synced to storageos", "name", obj.GetName())
+	return nil
+}
+
+// Delete receives a k8s object that's been deleted and calls the StorageOS api
+// to remove the policy group.
+func (c Controller) Delete(ctx context.Context, obj client.Object) error {
+	tr := otel.Tracer("policy-group")
+	ctx, span := tr.Start(ctx, "policy group delete")
+	span.SetAttributes(label.String("name", obj.GetName()))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+
+	if err := c.api.DeletePolicyGroup(ctx, GroupName(obj.GetName())); err != nil && err != storageos.ErrPolicyGroupNotFound {
+		span.RecordError(err)
+		return err
+	}
+	span.SetStatus(codes.Ok, "policy group deleted from storageos")
+	c.log.Info("policy group deleted from storageos", "name", obj.GetName())
+	return nil
+}
+
+// List returns the StoragePolicyGroups that have policy groups in StorageOS,
+// as NamespacedNames.  Policy groups without the name prefix are ignored.
+// This is used for garbage collection and can be expensive. The garbage
+// collector is run in a separate goroutine periodically, not affecting the main
+// reconciliation control-loop.
+func (c Controller) List(ctx context.Context) ([]types.NamespacedName, error) {
+	tr := otel.Tracer("policy-group")
+	ctx, span := tr.Start(ctx, "policy group list")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, storageos.DefaultRequestTimeout)
+	defer cancel()
+
+	names, err := c.api.PolicyGroupNames(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	keys := []types.NamespacedName{}
+	for _, name := range names {
+		if strings.HasPrefix(name, GroupNamePrefix) {
+			keys = append(keys, types.NamespacedName{Name: strings.TrimPrefix(name, GroupNamePrefix)})
+		}
+	}
+	span.SetAttributes(label.Int("count", len(keys)))
+	span.SetStatus(codes.Ok, "listed policy groups")
+	return keys, nil
+}
+
+// updateStatus records the result of the policy group sync on the
+// StoragePolicyGroup status.
+func (c Controller) updateStatus(ctx context.Context, spg *storageosv1.StoragePolicyGroup, id string, syncErr error) error {
+	patch := client.MergeFrom(spg.DeepCopy())
+
+	spg.Status.PolicyGroupName = GroupName(spg.GetName())
+	if id != "" {
+		spg.Status.PolicyGroupID = id
+	}
+
+	cond := metav1.Condition{
+		Type:               storageosv1.PolicyGroupReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             SyncedReason,
+		Message:            "Policy group synced to StorageOS",
+		ObservedGeneration: spg.GetGeneration(),
+	}
+	switch {
+	case syncErr == nil:
+		spg.Status.ObservedGeneration = spg.GetGeneration()
+	case errors.Is(syncErr, storageos.ErrNamespaceNotFound):
+		cond.Status, cond.Reason, cond.Message = metav1.ConditionFalse, NamespaceNotFoundReason, syncErr.Error()
+	case errors.Is(syncErr, storageos.ErrUserNotFound):
+		cond.Status, cond.Reason, cond.Message = metav1.ConditionFalse, UserNotFoundReason, syncErr.Error()
+	default:
+		cond.Status, cond.Reason, cond.Message = metav1.ConditionFalse, SyncFailedReason, syncErr.Error()
+	}
+	meta.SetStatusCondition(&spg.Status.Conditions, cond)
+
+	return c.k8s.Status().Patch(ctx, spg, patch)
+}
+
+// policyRules converts the StoragePolicyGroup rules to StorageOS policy rules.
+func policyRules(rules []storageosv1.StoragePolicyRule) []storageos.PolicyRule {
+	var ret []storageos.PolicyRule
+	for _, rule := range rules {
+		ret = append(ret, storageos.PolicyRule{
+			Namespace:    rule.Namespace,
+			ResourceType: string(rule.ResourceType),
+			ReadOnly:     rule.ReadOnly,
+		})
+	}
+	return ret
+}
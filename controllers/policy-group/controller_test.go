@@ -0,0 +1,213 @@
+package policygroup
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storageosv1 "github.com/storageos/api-manager/api/v1"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+const testGroup = "team-a"
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := storageosv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func TestControllerEnsure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		rules      []storageosv1.StoragePolicyRule
+		users      []string
+		ensureErr  error
+		wantErr    bool
+		wantStatus metav1.ConditionStatus
+		wantReason string
+		wantRules  []storageos.PolicyRule
+		wantUsers  []string
+	}{
+		{
+			name: "synced",
+			rules: []storageosv1.StoragePolicyRule{
+				{Namespace: "ns1"},
+				{Namespace: "ns2", ResourceType: storageosv1.PolicyResourceVolume, ReadOnly: true},
+			},
+			users:      []string{"alice"},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: SyncedReason,
+			wantRules: []storageos.PolicyRule{
+				{Namespace: "ns1"},
+				{Namespace: "ns2", ResourceType: "volume", ReadOnly: true},
+			},
+			wantUsers: []string{"alice"},
+		},
+		{
+			name:       "namespace not found",
+			rules:      []storageosv1.StoragePolicyRule{{Namespace: "missing"}},
+			wantErr:    true,
+			wantStatus: metav1.ConditionFalse,
+			wantReason: NamespaceNotFoundReason,
+		},
+		{
+			name:       "user not found",
+			rules:      []storageosv1.StoragePolicyRule{{Namespace: "ns1"}},
+			users:      []string{"alice", "carol"},
+			wantErr:    true,
+			wantStatus: metav1.ConditionFalse,
+			wantReason: UserNotFoundReason,
+			wantRules:  []storageos.PolicyRule{{Namespace: "ns1"}},
+			wantUsers:  []string{"alice"},
+		},
+		{
+			name:       "sync failed",
+			ensureErr:  errors.New("api failed"),
+			wantErr:    true,
+			wantStatus: metav1.ConditionFalse,
+			wantReason: SyncFailedReason,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			spg := &storageosv1.StoragePolicyGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: testGroup, Generation: 2},
+				Spec:       storageosv1.StoragePolicyGroupSpec{Rules: tt.rules, Users: tt.users},
+			}
+			k8s := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(spg).Build()
+
+			api := storageos.NewMockClient()
+			for _, ns := range []string{"ns1", "ns2"} {
+				if err := api.AddNamespace(client.ObjectKey{Name: ns}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			api.AddUser("alice")
+			api.EnsurePolicyGroupErr = tt.ensureErr
+
+			c, err := NewController(api, k8s, ctrl.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Ensure(ctx, spg); (err != nil) != tt.wantErr {
+				t.Errorf("Ensure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got := &storageosv1.StoragePolicyGroup{}
+			if err := k8s.Get(ctx, client.ObjectKeyFromObject(spg), got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status.PolicyGroupName != GroupName(testGroup) {
+				t.Errorf("status policy group name = %q, want %q", got.Status.PolicyGroupName, GroupName(testGroup))
+			}
+			cond := meta.FindStatusCondition(got.Status.Conditions, storageosv1.PolicyGroupReady)
+			if cond == nil {
+				t.Fatal("ready condition not set")
+			}
+			if cond.Status != tt.wantStatus || cond.Reason != tt.wantReason {
+				t.Errorf("ready condition = %s/%s, want %s/%s", cond.Status, cond.Reason, tt.wantStatus, tt.wantReason)
+			}
+			if cond.ObservedGeneration != 2 {
+				t.Errorf("ready condition observed generation = %d, want 2", cond.ObservedGeneration)
+			}
+			// The observed generation is only set once the spec has been applied.
+			wantGen := int64(2)
+			if tt.wantErr {
+				wantGen = 0
+			}
+			if got.Status.ObservedGeneration != wantGen {
+				t.Errorf("status observed generation = %d, want %d", got.Status.ObservedGeneration, wantGen)
+			}
+
+			group, ok := api.GetPolicyGroup(GroupName(testGroup))
+			if ok != (tt.wantRules != nil) {
+				t.Fatalf("policy group exists = %t, want %t", ok, tt.wantRules != nil)
+			}
+			if !ok {
+				return
+			}
+			if got.Status.PolicyGroupID != group.ID {
+				t.Errorf("status policy group id = %q, want %q", got.Status.PolicyGroupID, group.ID)
+			}
+			if !reflect.DeepEqual(group.Rules, tt.wantRules) {
+				t.Errorf("policy group rules = %v, want %v", group.Rules, tt.wantRules)
+			}
+			if !reflect.DeepEqual(group.Users, tt.wantUsers) {
+				t.Errorf("policy group users = %v, want %v", group.Users, tt.wantUsers)
+			}
+		})
+	}
+}
+
+func TestControllerDelete(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	api := storageos.NewMockClient()
+	if _, err := api.EnsurePolicyGroup(ctx, GroupName(testGroup), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewController(api, fake.NewClientBuilder().WithScheme(newScheme(t)).Build(), ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spg := &storageosv1.StoragePolicyGroup{ObjectMeta: metav1.ObjectMeta{Name: testGroup}}
+	if err := c.Delete(ctx, spg); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok := api.GetPolicyGroup(GroupName(testGroup)); ok {
+		t.Error("policy group not deleted")
+	}
+
+	// Already deleted.
+	if err := c.Delete(ctx, spg); err != nil {
+		t.Errorf("Delete() of deleted policy group error = %v", err)
+	}
+}
+
+func TestControllerList(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	api := storageos.NewMockClient()
+	for _, name := range []string{GroupName(testGroup), "manual"} {
+		if _, err := api.EnsurePolicyGroup(ctx, name, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := NewController(api, fake.NewClientBuilder().WithScheme(newScheme(t)).Build(), ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if want := []types.NamespacedName{{Name: testGroup}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/storageos/api-manager/controllers/policy-group (interfaces: PolicyGroupSyncer)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	storageos "github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+// MockPolicyGroupSyncer is a mock of PolicyGroupSyncer interface.
+type MockPolicyGroupSyncer struct {
+	ctrl     *gomock.Controller
+	recorder *MockPolicyGroupSyncerMockRecorder
+}
+
+// MockPolicyGroupSyncerMockRecorder is the mock recorder for MockPolicyGroupSyncer.
+type MockPolicyGroupSyncerMockRecorder struct {
+	mock *MockPolicyGroupSyncer
+}
+
+// NewMockPolicyGroupSyncer creates a new mock instance.
+func NewMockPolicyGroupSyncer(ctrl *gomock.Controller) *MockPolicyGroupSyncer {
+	mock := &MockPolicyGroupSyncer{ctrl: ctrl}
+	mock.recorder = &MockPolicyGroupSyncerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPolicyGroupSyncer) EXPECT() *MockPolicyGroupSyncerMockRecorder {
+	return m.recorder
+}
+
+// DeletePolicyGroup mocks base method.
+func (m *MockPolicyGroupSyncer) DeletePolicyGroup(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePolicyGroup", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePolicyGroup indicates an expected call of DeletePolicyGroup.
+func (mr *MockPolicyGroupSyncerMockRecorder) DeletePolicyGroup(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePolicyGroup", reflect.TypeOf((*MockPolicyGroupSyncer)(nil).DeletePolicyGroup), arg0, arg1)
+}
+
+// EnsurePolicyGroup mocks base method.
+func (m *MockPolicyGroupSyncer) EnsurePolicyGroup(arg0 context.Context, arg1 string, arg2 []storageos.PolicyRule, arg3 []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsurePolicyGroup", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnsurePolicyGroup indicates an expected call of EnsurePolicyGroup.
+func (mr *MockPolicyGroupSyncerMockRecorder) EnsurePolicyGroup(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsurePolicyGroup", reflect.TypeOf((*MockPolicyGroupSyncer)(nil).EnsurePolicyGroup), arg0, arg1, arg2, arg3)
+}
+
+// PolicyGroupNames mocks base method.
+func (m *MockPolicyGroupSyncer) PolicyGroupNames(arg0 context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PolicyGroupNames", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PolicyGroupNames indicates an expected call of PolicyGroupNames.
+func (mr *MockPolicyGroupSyncerMockRecorder) PolicyGroupNames(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PolicyGroupNames", reflect.TypeOf((*MockPolicyGroupSyncer)(nil).PolicyGroupNames), arg0)
+}
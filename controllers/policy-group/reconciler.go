@@ -0,0 +1,84 @@
+package policygroup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	objectv1 "github.com/darkowlzz/operator-toolkit/controller/external-object-sync/v1"
+	syncv1 "github.com/darkowlzz/operator-toolkit/controller/sync/v1"
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	storageosv1 "github.com/storageos/api-manager/api/v1"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+// PolicyGroupSyncer provides access to manage StorageOS policy groups.
+//go:generate mockgen -build_flags=--mod=vendor -destination=mocks/mock_policy_group_syncer.go -package=mocks . PolicyGroupSyncer
+type PolicyGroupSyncer interface {
+	PolicyGroupNames(ctx context.Context) ([]string, error)
+	EnsurePolicyGroup(ctx context.Context, name string, rules []storageos.PolicyRule, users []string) (string, error)
+	DeletePolicyGroup(ctx context.Context, name string) error
+}
+
+// Reconciler reconciles a StoragePolicyGroup object by creating or updating the
+// corresponding StorageOS policy group, and deleting it when the
+// StoragePolicyGroup is deleted.
+type Reconciler struct {
+	client.Client
+	log        logr.Logger
+	api        PolicyGroupSyncer
+	gcDelay    time.Duration
+	gcInterval time.Duration
+
+	objectv1.Reconciler
+}
+
+// +kubebuilder:rbac:groups=storageos.com,resources=storagepolicygroups,verbs=get;list;watch
+// +kubebuilder:rbac:groups=storageos.com,resources=storagepolicygroups/status,verbs=get;update;patch
+
+// NewReconciler returns a new StoragePolicyGroup reconciler.
+//
+// The gcInterval determines how often the periodic garbage collection of
+// policy groups no longer declared by a StoragePolicyGroup should be run.
+func NewReconciler(api PolicyGroupSyncer, k8s client.Client, gcDelay time.Duration, gcInterval time.Duration) *Reconciler {
+	return &Reconciler{
+		Client:     k8s,
+		log:        ctrl.Log,
+		api:        api,
+		gcDelay:    gcDelay,
+		gcInterval: gcInterval,
+	}
+}
+
+// SetupWithManager registers the controller with the controller manager.
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, workers int) error {
+	c, err := NewController(r.api, r.Client, r.log)
+	if err != nil {
+		return err
+	}
+
+	// Set the garbage collection interval.
+	r.Reconciler.SetStartupGarbageCollectionDelay(r.gcDelay)
+	r.Reconciler.SetGarbageCollectionPeriod(r.gcInterval)
+
+	// Initialize the reconciler.
+	err = r.Reconciler.Init(mgr, c, &storageosv1.StoragePolicyGroup{}, &storageosv1.StoragePolicyGroupList{},
+		syncv1.WithName("policy-group"),
+		syncv1.WithLogger(r.log),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create new reconciler: %w", err)
+	}
+
+	// Status updates don't change the generation, so they are ignored.
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{MaxConcurrentReconciles: workers}).
+		For(&storageosv1.StoragePolicyGroup{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(r)
+}
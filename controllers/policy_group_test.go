@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	storageosv1 "github.com/storageos/api-manager/api/v1"
+	policygroup "github.com/storageos/api-manager/controllers/policy-group"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+// SetupPolicyGroupTest will set up a testing environment.  It must be called
+// from each test.  The returned client must be used for StoragePolicyGroups,
+// as the suite client does not have them registered.
+func SetupPolicyGroupTest(ctx context.Context, spec storageosv1.StoragePolicyGroupSpec) (client.ObjectKey, *client.Client) {
+	var spg *storageosv1.StoragePolicyGroup
+	var key = client.ObjectKey{Name: "testspg-" + randStringRunes(5)}
+	var mgrClient client.Client
+	var cancel func()
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(ctx)
+
+		api = storageos.NewMockClient()
+		err := api.AddNamespace(client.ObjectKey{Name: "team-a"})
+		Expect(err).NotTo(HaveOccurred(), "failed to create storageos namespace")
+		api.AddUser("alice")
+
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{MetricsBindAddress: "0"})
+		Expect(err).NotTo(HaveOccurred(), "failed to create manager")
+
+		err = storageosv1.AddToScheme(mgr.GetScheme())
+		Expect(err).NotTo(HaveOccurred(), "failed to add storageos scheme")
+
+		controller := policygroup.NewReconciler(api, mgr.GetClient(), defaultSyncDelay, defaultSyncInterval)
+		err = controller.SetupWithManager(mgr, defaultWorkers)
+		Expect(err).NotTo(HaveOccurred(), "failed to setup controller")
+
+		go func() {
+			err := mgr.Start(ctx)
+			Expect(err).NotTo(HaveOccurred(), "failed to start manager")
+		}()
+
+		// Wait for manager to be ready.
+		time.Sleep(managerWaitDuration)
+
+		mgrClient, err = client.New(cfg, client.Options{Scheme: mgr.GetScheme()})
+		Expect(err).NotTo(HaveOccurred(), "failed to create client")
+
+		spg = &storageosv1.StoragePolicyGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name},
+			Spec:       spec,
+		}
+		err = mgrClient.Create(ctx, spg)
+		Expect(err).NotTo(HaveOccurred(), "failed to create test storage policy group")
+	})
+
+	AfterEach(func() {
+		// The test may have already deleted the StoragePolicyGroup.
+		err := client.IgnoreNotFound(mgrClient.Delete(ctx, spg))
+		Expect(err).NotTo(HaveOccurred(), "failed to delete test storage policy group")
+		cancel()
+	})
+
+	return key, &mgrClient
+}
+
+// policyGroupReason returns the reason of the StoragePolicyGroup Ready
+// condition, or an empty string if it is not set.
+func policyGroupReason(ctx context.Context, c client.Client, key client.ObjectKey) string {
+	var spg storageosv1.StoragePolicyGroup
+	if err := c.Get(ctx, key, &spg); err != nil {
+		return ""
+	}
+	cond := meta.FindStatusCondition(spg.Status.Conditions, storageosv1.PolicyGroupReady)
+	if cond == nil {
+		return ""
+	}
+	return cond.Reason
+}
+
+var _ = Describe("Policy Group controller", func() {
+	// Define utility constants for object names and testing timeouts/durations
+	// and intervals.
+	const (
+		timeout  = time.Second * 10
+		duration = time.Second * 2
+		interval = time.Millisecond * 250
+	)
+
+	ctx := context.Background()
+
+	Context("When creating a StoragePolicyGroup", func() {
+		key, c := SetupPolicyGroupTest(ctx, storageosv1.StoragePolicyGroupSpec{
+			Rules: []storageosv1.StoragePolicyRule{{Namespace: "team-a"}},
+			Users: []string{"alice"},
+		})
+		It("Should create the StorageOS policy group", func() {
+			By("Expecting StorageOS policy group to be created")
+			Eventually(func() []string {
+				group, _ := api.GetPolicyGroup(policygroup.GroupName(key.Name))
+				return group.Users
+			}, timeout, interval).Should(Equal([]string{"alice"}))
+
+			By("Expecting the Ready condition to be set")
+			Eventually(func() string {
+				return policyGroupReason(ctx, *c, key)
+			}, timeout, interval).Should(Equal(policygroup.SyncedReason))
+
+			By("By deleting the StoragePolicyGroup")
+			var spg storageosv1.StoragePolicyGroup
+			Expect((*c).Get(ctx, key, &spg)).Should(Succeed())
+			Expect((*c).Delete(ctx, &spg)).Should(Succeed())
+
+			By("Expecting StorageOS policy group to be deleted")
+			Eventually(func() bool {
+				_, ok := api.GetPolicyGroup(policygroup.GroupName(key.Name))
+				return ok
+			}, timeout, interval).Should(BeFalse())
+		})
+	})
+
+	Context("When creating a StoragePolicyGroup for a missing namespace", func() {
+		key, c := SetupPolicyGroupTest(ctx, storageosv1.StoragePolicyGroupSpec{
+			Rules: []storageosv1.StoragePolicyRule{{Namespace: "missing"}},
+		})
+		It("Should not create the StorageOS policy group", func() {
+			By("Expecting the Ready condition to report the missing namespace")
+			Eventually(func() string {
+				return policyGroupReason(ctx, *c, key)
+			}, timeout, interval).Should(Equal(policygroup.NamespaceNotFoundReason))
+
+			By("Expecting StorageOS policy group not to be created")
+			Consistently(func() bool {
+				_, ok := api.GetPolicyGroup(policygroup.GroupName(key.Name))
+				return ok
+			}, duration, interval).Should(BeFalse())
+		})
+	})
+})
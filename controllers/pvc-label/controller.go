@@ -2,8 +2,12 @@ package pvclabel
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"reflect"
+	"strconv"
+	"sync"
+	"time"
 
 	msyncv1 "github.com/darkowlzz/operator-toolkit/controller/metadata-sync/v1"
 	"github.com/darkowlzz/operator-toolkit/object"
@@ -11,37 +15,165 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/label"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/storageos/api-manager/internal/pkg/labels"
 	"github.com/storageos/api-manager/internal/pkg/provisioner"
 	"github.com/storageos/api-manager/internal/pkg/storageos"
 )
 
+const (
+	// VolumeResizeCondition is the PVC condition type used to report the
+	// result of resizing the StorageOS volume.
+	VolumeResizeCondition corev1.PersistentVolumeClaimConditionType = "StorageOSVolumeResize"
+
+	// ResizedReason is the condition and event reason set when the StorageOS
+	// volume size matches the PVC storage request.
+	ResizedReason = "VolumeResized"
+
+	// ShrinkRefusedReason is the condition and event reason set when the PVC
+	// storage request is smaller than the capacity of the PVC.
+	ShrinkRefusedReason = "VolumeShrinkRefused"
+
+	// ResizeFailedReason is the condition and event reason set when the
+	// StorageOS volume could not be resized.
+	ResizeFailedReason = "VolumeResizeFailed"
+
+	// LabelSyncAnnotationKey is the PVC annotation used to record the result
+	// of the last label sync, as a JSON encoded LabelSyncStatus.
+	LabelSyncAnnotationKey = "storageos.com/label-sync"
+
+	// InvalidLabelReason is the event reason set when a PVC has a reserved
+	// label that can't be applied to the StorageOS volume.
+	InvalidLabelReason = "InvalidVolumeLabel"
+
+	// ReplicaSyncAnnotationKey is the PVC annotation used to report the
+	// progress of the volume replicas syncing, as a JSON encoded
+	// ReplicaSyncStatus.
+	ReplicaSyncAnnotationKey = "storageos.com/replica-sync"
+
+	// ReplicasSyncingReason is the event reason set when the volume replicas
+	// are not in sync.
+	ReplicasSyncingReason = "ReplicasSyncing"
+
+	// ReplicasReadyReason is the event reason set when all of the volume
+	// replicas are in sync.
+	ReplicasReadyReason = "ReplicasReady"
+
+	// ReplicaFailedReason is the event reason set when a volume replica has
+	// failed.
+	ReplicaFailedReason = "ReplicaFailed"
+
+	// ReplicaSyncRequeueInterval is how often the replica sync progress is
+	// checked while the volume replicas are syncing.
+	ReplicaSyncRequeueInterval = 10 * time.Second
+)
+
+// LabelSyncStatus is the result of the last label sync, recorded on the PVC so
+// that users editing labels can see whether they were applied.
+type LabelSyncStatus struct {
+	// ObservedGeneration is the PVC generation that was last synced.
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// LastSyncTime is when the labels were last synced.
+	LastSyncTime metav1.Time `json:"lastSyncTime"`
+
+	// Error is the error returned by the last sync, if any.
+	Error string `json:"error,omitempty"`
+
+	// AppliedLabels are the labels set on the StorageOS volume after the
+	// last sync.
+	AppliedLabels map[string]string `json:"appliedLabels,omitempty"`
+}
+
 // Controller implements the Sync contoller interface, applying PVC labels to
 // StorageOS volumes.
 type Controller struct {
 	client.Client
-	api    VolumeLabeller
-	scheme *runtime.Scheme
-	log    logr.Logger
+	api      VolumeLabeller
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+	filter   *labels.Filter
+	syncing  *syncingSet
+	log      logr.Logger
 }
 
 var _ msyncv1.Controller = &Controller{}
 
 // NewController returns a Controller that implements PVC label sync in
-// StorageOS.
-func NewController(k8s client.Client, api VolumeLabeller, scheme *runtime.Scheme, log logr.Logger) (*Controller, error) {
-	return &Controller{Client: k8s, api: api, scheme: scheme, log: log}, nil
+// StorageOS.  Only PVC labels selected by the filter are applied, or all labels
+// if nil.
+func NewController(k8s client.Client, api VolumeLabeller, scheme *runtime.Scheme, recorder record.EventRecorder, filter *labels.Filter, log logr.Logger) (*Controller, error) {
+	return &Controller{Client: k8s, api: api, scheme: scheme, recorder: recorder, filter: filter, syncing: newSyncingSet(), log: log}, nil
+}
+
+// ReplicasSyncing returns true if the volume replicas of the PVC were syncing
+// after a change to the replica count when it was last ensured.
+func (c Controller) ReplicasSyncing(key client.ObjectKey) bool {
+	return c.syncing.has(key)
 }
 
-// Ensure applies labels set on the k8s PVC to the StorageOS volume.
+// syncingSet is the set of PVCs with volume replicas syncing.
+type syncingSet struct {
+	mu   sync.Mutex
+	keys map[client.ObjectKey]bool
+}
+
+func newSyncingSet() *syncingSet {
+	return &syncingSet{keys: make(map[client.ObjectKey]bool)}
+}
+
+func (s *syncingSet) set(key client.ObjectKey, syncing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if syncing {
+		s.keys[key] = true
+		return
+	}
+	delete(s.keys, key)
+}
+
+func (s *syncingSet) has(key client.ObjectKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[key]
+}
+
+// ReplicaSyncStatus is the health and sync progress of the volume replicas,
+// recorded on the PVC while the replicas are syncing.
+type ReplicaSyncStatus struct {
+	storageos.ReplicaStatus
+
+	// ReadyReplicas is the number of replicas that are in sync.
+	ReadyReplicas int `json:"ready"`
+
+	// InSync is true once the desired number of replicas are in sync.
+	InSync bool `json:"inSync"`
+
+	// LastUpdateTime is when the status was last updated.
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+}
+
+// Ensure applies labels set on the k8s PVC to the StorageOS volume, and resizes
+// the volume if the PVC storage request has increased.
 //
 // StorageOS reserved labels are validated and applied first, then the remaining
 // unreserved labels are applied.
 //
-// Any errors will result in a requeue, with standard back-off retries.
+// Any errors will result in a requeue, with standard back-off retries.  The
+// result of the label sync is recorded in the `storageos.com/label-sync` PVC
+// annotation, and a warning event is emitted for each invalid reserved label.
+//
+// If the labels changed the replica count, the replica sync progress is
+// recorded in the `storageos.com/replica-sync` PVC annotation until the volume
+// replicas are in sync.  ReplicasSyncing reports whether the PVC should be
+// checked again.
 //
 // There is no label sync from StorageOS to Kubernetes.  This is intentional to
 // ensure a simple flow of desired state set by users in Kubernetes to actual
@@ -65,6 +197,46 @@ func (c Controller) Ensure(ctx context.Context, obj client.Object) error {
 		return observeErr(err)
 	}
 
+	// The PV name is required, as this will be the name of the StorageOS
+	// volume.  We can get this without re-fetching the PVC by converting to an
+	// unstructured object, and then reading from the spec.
+	pvName, ok, err := unstructured.NestedString(u.Object, []string{"spec", "volumeName"}...)
+	if err != nil {
+		return observeErr(fmt.Errorf("failed to get pv name from pvc: %w", err))
+	}
+	if !ok {
+		return observeErr(fmt.Errorf("pv for pvc not yet provisioned"))
+	}
+
+	// Use the PV name, and the PVC namespace for the StorageOS volume lookup.
+	key := client.ObjectKey{Name: pvName, Namespace: obj.GetNamespace()}
+
+	// Resize the volume before applying labels.  The size does not depend on
+	// the StorageClass, so resizing is not blocked if labels can't be synced.
+	// A failed resize is recorded in the resize condition and does not block
+	// label sync.  It is returned once labels have been applied, so that the
+	// resize is retried.
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, pvc); err != nil {
+		return observeErr(fmt.Errorf("failed to convert pvc: %w", err))
+	}
+	sizeErr := c.ensureSize(ctx, pvc, key)
+	if sizeErr != nil {
+		c.log.Error(sizeErr, "failed to ensure volume size, continuing with label sync", "name", obj.GetName())
+	}
+
+	// Read the current volume labels.  They are used to detect changes to the
+	// replica count and, if the sync makes no changes, as the applied labels
+	// in the sync status.
+	var currentLabels map[string]string
+	vol, err := c.api.GetVolume(ctx, key)
+	switch {
+	case err == nil:
+		currentLabels = vol.GetLabels()
+	case !errors.Is(err, storageos.ErrVolumeNotFound):
+		c.log.Error(err, "failed to get volume labels", "name", obj.GetName())
+	}
+
 	// The StorageClass is required, as defaults may be set there that were
 	// applied during volume creation.
 	scName, _, err := unstructured.NestedString(u.Object, []string{"spec", "storageClassName"}...)
@@ -81,46 +253,352 @@ func (c Controller) Ensure(ctx context.Context, obj client.Object) error {
 	// don't want to risk applying new default params automatically.  Instead,
 	// the user should manually remove the `storageos.com/storageclass=<uid>`
 	// annotation from the PVC to re-enable label sync for the volume.
-	ok, err := provisioner.ValidateOrSetStorageClassUID(ctx, c.Client, sc, obj)
+	ok, err = provisioner.ValidateOrSetStorageClassUID(ctx, c.Client, sc, obj)
 	if err != nil {
 		return observeErr(fmt.Errorf("failed to set storageclass annotation on the pvc: %w", err))
 	}
 	if !ok {
 		// Don't requeue if the StorageClass doesn't match, it's not transient.
 		c.log.Error(err, "current storageclass does not match, skipping label sync")
+		syncErr := fmt.Errorf("storageclass %q does not match the one used to provision the volume, remove the %s annotation to re-enable label sync", scName, provisioner.StorageClassUUIDAnnotationKey)
+		if err := c.setSyncStatus(ctx, pvc, currentLabels, syncErr); err != nil {
+			c.log.Error(err, "failed to set pvc label sync status", "name", obj.GetName())
+		}
+		if sizeErr != nil {
+			return observeErr(sizeErr)
+		}
 		return nil
 	}
 
-	// Overlay the PVC labels on top of the StorageClass default parameters
-	// before applying to the volume.
-	ensureLabels := provisioner.StorageClassReservedParams(sc)
-	for k, v := range obj.GetLabels() {
-		ensureLabels[k] = v
+	ensureLabels := c.desiredLabels(sc, obj)
+	replicasLabel, _ := storageos.LookupVolumeLabel(storageos.ReservedLabelReplicas)
+	replicasChanged := vol != nil && !replicasLabel.Equal(currentLabels[storageos.ReservedLabelReplicas], ensureLabels[storageos.ReservedLabelReplicas])
+
+	for k, v := range ensureLabels {
+		if err := storageos.ValidateVolumeLabel(k, v); err != nil {
+			c.event(pvc, corev1.EventTypeWarning, InvalidLabelReason, fmt.Sprintf("Label %s=%q can't be applied to the StorageOS volume: %v", k, v, err))
+		}
 	}
 
-	// The PV name is required, as this will be the name of the StorageOS
-	// volume.  We can get this without re-fetching the PVC by converting to an
-	// unstructured object, and then reading from the spec.
-	pvName, ok, err := unstructured.NestedString(u.Object, []string{"spec", "volumeName"}...)
-	if err != nil {
-		return observeErr(fmt.Errorf("failed to get pv name from pvc: %w", err))
+	syncErr := c.api.EnsureVolumeLabels(ctx, key, ensureLabels)
+	appliedLabels := currentLabels
+	if vol != nil && !storageos.VolumeLabelsInSync(currentLabels, ensureLabels) {
+		// Read back the labels that were applied, as a failed sync may have
+		// applied some labels but not others.
+		appliedLabels = c.appliedLabels(ctx, pvc, key)
 	}
-	if !ok {
-		return observeErr(fmt.Errorf("pv for pvc not yet provisioned"))
+	if err := c.setSyncStatus(ctx, pvc, appliedLabels, syncErr); err != nil {
+		c.log.Error(err, "failed to set pvc label sync status", "name", obj.GetName())
 	}
+	if syncErr != nil {
+		return observeErr(syncErr)
+	}
+	c.log.Info("pvc labels applied to storageos", "name", obj.GetName())
 
-	// Use the PV name, and the PVC namespace for the StorageOS volume lookup.
-	key := client.ObjectKey{Name: pvName, Namespace: obj.GetNamespace()}
-	if err := c.api.EnsureVolumeLabels(ctx, key, ensureLabels); err != nil {
+	syncing, err := c.ensureReplicaSync(ctx, pvc, key, replicasChanged)
+	c.syncing.set(client.ObjectKeyFromObject(obj), syncing)
+	if err != nil {
 		return observeErr(err)
 	}
+	if sizeErr != nil {
+		return observeErr(sizeErr)
+	}
 	span.SetStatus(codes.Ok, "pvc labels applied to storageos")
-	c.log.Info("pvc labels applied to storageos", "name", obj.GetName())
 	return nil
 }
 
-// Diff takes a list of Kubernets PVC objects and returns them if they exist
-// as volumes within StorageOS but the labels are different.
+// ensureReplicaSync records the replica health and sync progress on the pvc
+// after the replica count has changed, returning true until all of the volume
+// replicas are in sync.  Replicas are not checked if the replica count has
+// not changed and the previous sync has completed, so volumes with a failed
+// replica are not checked on every sync.
+//
+// Events are emitted when replicas start syncing, when a replica fails and
+// when all replicas are in sync.  Volumes that have never had replicas out of
+// sync are not annotated.
+func (c Controller) ensureReplicaSync(ctx context.Context, pvc *corev1.PersistentVolumeClaim, key client.ObjectKey, changed bool) (bool, error) {
+	prev := replicaSyncStatus(pvc)
+	if !changed && (prev == nil || prev.InSync) {
+		return false, nil
+	}
+
+	status, err := c.api.GetReplicaStatus(ctx, key)
+	if errors.Is(err, storageos.ErrVolumeNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get volume replica status: %w", err)
+	}
+
+	if status.InSync() {
+		if prev == nil {
+			return false, nil
+		}
+		if prev.InSync {
+			// Keep the recorded status current after a replicas change,
+			// without repeating the ready event.
+			if !changed {
+				return false, nil
+			}
+			return false, c.setReplicaSyncStatus(ctx, pvc, status)
+		}
+		c.event(pvc, corev1.EventTypeNormal, ReplicasReadyReason, fmt.Sprintf("All %d StorageOS volume replicas are in sync", status.Desired))
+		return false, c.setReplicaSyncStatus(ctx, pvc, status)
+	}
+
+	if prev == nil || prev.InSync {
+		c.event(pvc, corev1.EventTypeNormal, ReplicasSyncingReason, fmt.Sprintf("Waiting for StorageOS volume replicas to sync, %d of %d ready", status.Ready(), status.Desired))
+	}
+	for _, r := range status.Replicas {
+		if r.Health == storageos.ReplicaHealthFailed && !replicaFailed(prev, r.ID) {
+			c.event(pvc, corev1.EventTypeWarning, ReplicaFailedReason, fmt.Sprintf("StorageOS volume replica %s on node %s has failed", r.ID, r.NodeID))
+		}
+	}
+	c.log.V(4).Info("volume replicas syncing", "name", pvc.GetName(), "ready", status.Ready(), "desired", status.Desired)
+	return true, c.setReplicaSyncStatus(ctx, pvc, status)
+}
+
+// setReplicaSyncStatus records the replica status in the pvc replica sync
+// annotation.
+func (c Controller) setReplicaSyncStatus(ctx context.Context, pvc *corev1.PersistentVolumeClaim, status *storageos.ReplicaStatus) error {
+	b, err := json.Marshal(ReplicaSyncStatus{
+		ReplicaStatus:  *status,
+		ReadyReplicas:  status.Ready(),
+		InSync:         status.InSync(),
+		LastUpdateTime: metav1.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode pvc replica sync status: %w", err)
+	}
+
+	patch := client.MergeFrom(pvc.DeepCopy())
+	annotations := pvc.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[ReplicaSyncAnnotationKey] = string(b)
+	pvc.SetAnnotations(annotations)
+
+	if err := c.Patch(ctx, pvc, patch); err != nil {
+		return fmt.Errorf("failed to set pvc replica sync status: %w", err)
+	}
+	return nil
+}
+
+// replicaSyncStatus returns the replica status recorded on the pvc, or nil if
+// not set or invalid.
+func replicaSyncStatus(pvc *corev1.PersistentVolumeClaim) *ReplicaSyncStatus {
+	v, ok := pvc.GetAnnotations()[ReplicaSyncAnnotationKey]
+	if !ok {
+		return nil
+	}
+	status := &ReplicaSyncStatus{}
+	if err := json.Unmarshal([]byte(v), status); err != nil {
+		return nil
+	}
+	return status
+}
+
+// replicaFailed returns true if the replica was recorded as failed in the
+// replica status.
+func replicaFailed(status *ReplicaSyncStatus, id string) bool {
+	if status == nil {
+		return false
+	}
+	for _, r := range status.Replicas {
+		if r.ID == id {
+			return r.Health == storageos.ReplicaHealthFailed
+		}
+	}
+	return false
+}
+
+// ensureSize resizes the StorageOS volume to match the PVC storage request,
+// recording the result as a PVC condition and event.
+//
+// Requests below the PVC `status.capacity` are refused and not retried, as
+// volumes can't be shrunk.  The event is only recorded when the request is
+// first refused.  Volumes that are already larger than the request, for
+// example when the size was rounded up at provisioning, are not changed.
+// Other errors are returned so that the resize is retried.
+func (c Controller) ensureSize(ctx context.Context, pvc *corev1.PersistentVolumeClaim, key client.ObjectKey) error {
+	request, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if !ok || request.Sign() <= 0 {
+		return nil
+	}
+	desired := uint64(request.Value())
+
+	if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok && request.Cmp(capacity) < 0 {
+		msg := fmt.Sprintf("StorageOS volume can't be shrunk to %s, PVC capacity is %s", request.String(), capacity.String())
+		if cond := resizeCondition(pvc); cond == nil || cond.Reason != ShrinkRefusedReason || cond.Message != msg {
+			c.log.Info("refusing to shrink volume", "name", pvc.GetName(), "request", request.String(), "capacity", capacity.String())
+			c.event(pvc, corev1.EventTypeWarning, ShrinkRefusedReason, msg)
+		}
+		return c.setResizeCondition(ctx, pvc, corev1.ConditionFalse, ShrinkRefusedReason, msg)
+	}
+
+	resized, err := c.api.EnsureVolumeSize(ctx, key, desired)
+	switch {
+	case errors.Is(err, storageos.ErrVolumeNotFound):
+		// Nothing to resize.
+		return nil
+	case err != nil:
+		msg := fmt.Sprintf("Failed to resize StorageOS volume to %s: %v", request.String(), err)
+		c.event(pvc, corev1.EventTypeWarning, ResizeFailedReason, msg)
+		if condErr := c.setResizeCondition(ctx, pvc, corev1.ConditionFalse, ResizeFailedReason, msg); condErr != nil {
+			c.log.Error(condErr, "failed to set pvc resize condition", "name", pvc.GetName())
+		}
+		return fmt.Errorf("failed to resize volume: %w", err)
+	case resized:
+		msg := fmt.Sprintf("Resized StorageOS volume to %s", request.String())
+		c.log.Info("resized volume", "name", pvc.GetName(), "size", request.String())
+		c.event(pvc, corev1.EventTypeNormal, ResizedReason, msg)
+		return c.setResizeCondition(ctx, pvc, corev1.ConditionTrue, ResizedReason, msg)
+	}
+
+	// The volume is already at least the requested size.  Only clear a
+	// previous failure, don't add the condition to PVCs that have never been
+	// resized.
+	if cond := resizeCondition(pvc); cond != nil && cond.Status != corev1.ConditionTrue {
+		return c.setResizeCondition(ctx, pvc, corev1.ConditionTrue, ResizedReason, fmt.Sprintf("StorageOS volume is %s", request.String()))
+	}
+	return nil
+}
+
+// setResizeCondition sets the resize condition on the pvc status, if changed.
+// The transition time is only updated when the status changes.
+//
+// A strategic merge patch is used so that only the resize condition is sent,
+// merged on the condition type.  A JSON merge patch would replace the whole
+// conditions list, removing conditions set by the external-resizer or kubelet
+// since the pvc was read.
+func (c Controller) setResizeCondition(ctx context.Context, pvc *corev1.PersistentVolumeClaim, status corev1.ConditionStatus, reason string, msg string) error {
+	now := metav1.Now()
+	patch := client.StrategicMergeFrom(pvc.DeepCopy())
+
+	cond := resizeCondition(pvc)
+	if cond == nil {
+		pvc.Status.Conditions = append(pvc.Status.Conditions, corev1.PersistentVolumeClaimCondition{Type: VolumeResizeCondition})
+		cond = &pvc.Status.Conditions[len(pvc.Status.Conditions)-1]
+	}
+	if cond.Status == status && cond.Reason == reason && cond.Message == msg {
+		return nil
+	}
+	if cond.Status != status {
+		cond.LastTransitionTime = now
+	}
+	cond.Status = status
+	cond.Reason = reason
+	cond.Message = msg
+	cond.LastProbeTime = now
+
+	if err := c.Status().Patch(ctx, pvc, patch); err != nil {
+		return fmt.Errorf("failed to set pvc resize condition: %w", err)
+	}
+	return nil
+}
+
+// resizeCondition returns the resize condition from the pvc status, or nil if
+// not set.
+func resizeCondition(pvc *corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaimCondition {
+	for i := range pvc.Status.Conditions {
+		if pvc.Status.Conditions[i].Type == VolumeResizeCondition {
+			return &pvc.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// appliedLabels returns the labels on the StorageOS volume after a sync, or
+// nil if they can't be read.
+func (c Controller) appliedLabels(ctx context.Context, pvc *corev1.PersistentVolumeClaim, key client.ObjectKey) map[string]string {
+	vol, err := c.api.GetVolume(ctx, key)
+	if err != nil {
+		c.log.Error(err, "failed to get volume labels for pvc label sync status", "name", pvc.GetName())
+		return nil
+	}
+	return vol.GetLabels()
+}
+
+// setSyncStatus records the result of the label sync in the pvc label sync
+// annotation.  The pvc is not patched if the result is unchanged since the
+// last sync, so LastSyncTime is the time of the last sync that changed the
+// status.
+func (c Controller) setSyncStatus(ctx context.Context, pvc *corev1.PersistentVolumeClaim, appliedLabels map[string]string, syncErr error) error {
+	status := LabelSyncStatus{
+		ObservedGeneration: pvc.GetGeneration(),
+		LastSyncTime:       metav1.Now(),
+		AppliedLabels:      appliedLabels,
+	}
+	if syncErr != nil {
+		status.Error = syncErr.Error()
+	}
+	if prev := labelSyncStatus(pvc); prev != nil && prev.equal(status) {
+		return nil
+	}
+
+	b, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to encode pvc label sync status: %w", err)
+	}
+
+	patch := client.MergeFrom(pvc.DeepCopy())
+	annotations := pvc.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[LabelSyncAnnotationKey] = string(b)
+	pvc.SetAnnotations(annotations)
+
+	if err := c.Patch(ctx, pvc, patch); err != nil {
+		return fmt.Errorf("failed to set pvc label sync status: %w", err)
+	}
+	return nil
+}
+
+// labelSyncStatus returns the label sync status recorded on the pvc, or nil if
+// not set or invalid.
+func labelSyncStatus(pvc *corev1.PersistentVolumeClaim) *LabelSyncStatus {
+	v, ok := pvc.GetAnnotations()[LabelSyncAnnotationKey]
+	if !ok {
+		return nil
+	}
+	status := &LabelSyncStatus{}
+	if err := json.Unmarshal([]byte(v), status); err != nil {
+		return nil
+	}
+	return status
+}
+
+// equal returns true if the sync statuses match, ignoring LastSyncTime.
+func (s LabelSyncStatus) equal(other LabelSyncStatus) bool {
+	if s.ObservedGeneration != other.ObservedGeneration || s.Error != other.Error || len(s.AppliedLabels) != len(other.AppliedLabels) {
+		return false
+	}
+	for k, v := range s.AppliedLabels {
+		if ov, ok := other.AppliedLabels[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+// event records an event on the pvc, if an event recorder has been set.
+func (c Controller) event(pvc *corev1.PersistentVolumeClaim, eventType string, reason string, msg string) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.Event(pvc, eventType, reason, msg)
+}
+
+// Diff takes a list of Kubernetes PVC objects and returns the ones whose
+// StorageOS volume labels differ from the labels that Ensure would apply.
+//
+// The desired labels are computed as in Ensure, with the StorageClass
+// parameters overlaid by the PVC labels, and only the labels managed by label
+// sync are compared.  PVCs without a volume in StorageOS, or whose
+// StorageClass no longer matches, are skipped since Ensure would not change
+// them.
 func (c Controller) Diff(ctx context.Context, objs []client.Object) ([]client.Object, error) {
 	tr := otel.Tracer("pvc-label")
 	ctx, span := tr.Start(ctx, "pvc label diff")
@@ -136,14 +614,48 @@ func (c Controller) Diff(ctx context.Context, objs []client.Object) ([]client.Ob
 		span.RecordError(err)
 		return nil, err
 	}
+
+	// Most PVCs share a few StorageClasses, so only fetch each once.
+	scs := make(map[string]*storagev1.StorageClass)
+
 	for _, obj := range objs {
-		vol, ok := volumes[client.ObjectKeyFromObject(obj)]
-		if !ok {
+		u, err := object.GetUnstructuredObject(c.scheme, obj)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		pvName, _, _ := unstructured.NestedString(u.Object, []string{"spec", "volumeName"}...)
+		scName, _, _ := unstructured.NestedString(u.Object, []string{"spec", "storageClassName"}...)
+
+		// The volume is named after the PV, in the PVC namespace.
+		vol, ok := volumes[client.ObjectKey{Name: pvName, Namespace: obj.GetNamespace()}]
+		if pvName == "" || !ok {
 			// Ignore PVCs without volumes in StorageOS.
 			continue
 		}
-		// If labels don't match, return original object.
-		if !reflect.DeepEqual(obj.GetLabels(), vol.GetLabels()) {
+
+		sc, ok := scs[scName]
+		if !ok {
+			sc, err = provisioner.StorageClass(c.Client, scName)
+			if err != nil {
+				// Let Ensure report the error.
+				c.log.V(4).Info("failed to get storageclass for pvc", "name", obj.GetName(), "error", err)
+				sc = nil
+			}
+			scs[scName] = sc
+		}
+		if sc == nil {
+			apply = append(apply, obj)
+			continue
+		}
+
+		// Ensure won't sync labels if the StorageClass has changed since the
+		// volume was provisioned.
+		if uid, ok := obj.GetAnnotations()[provisioner.StorageClassUUIDAnnotationKey]; ok && uid != string(sc.GetUID()) {
+			continue
+		}
+
+		if !storageos.VolumeLabelsInSync(vol.GetLabels(), c.desiredLabels(sc, obj)) {
 			apply = append(apply, obj)
 		}
 	}
@@ -152,6 +664,23 @@ func (c Controller) Diff(ctx context.Context, objs []client.Object) ([]client.Ob
 	return apply, nil
 }
 
+// desiredLabels returns the labels to apply to the volume: the PVC labels
+// selected by the filter, overlaid on top of the StorageClass default
+// parameters.  StorageOS reserved labels are never filtered.
+//
+// While a volume is being moved off a decommissioned node, it keeps the extra
+// replica added by the node delete controller, as recorded on the PVC.
+func (c Controller) desiredLabels(sc *storagev1.StorageClass, obj client.Object) map[string]string {
+	ret := provisioner.StorageClassReservedParams(sc)
+	for k, v := range c.filter.Apply(obj.GetLabels(), storageos.IsReservedLabel) {
+		ret[k] = v
+	}
+	if _, original, ok := storageos.DecommissionState(obj); ok {
+		ret[storageos.ReservedLabelReplicas] = strconv.Itoa(original + 1)
+	}
+	return ret
+}
+
 // Delete is a no-op.  Volume removal is handled via CSI.
 func (c Controller) Delete(ctx context.Context, obj client.Object) error {
 	return nil
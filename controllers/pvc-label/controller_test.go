@@ -0,0 +1,794 @@
+package pvclabel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/storageos/api-manager/internal/pkg/labels"
+	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+func TestControllerEnsureSize(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		currentSize   string
+		request       string
+		capacity      string
+		resizeErr     error
+		wantErr       bool
+		wantSize      string
+		wantEvent     string
+		wantCondition corev1.ConditionStatus
+		wantReason    string
+	}{
+		{
+			name:        "unchanged",
+			currentSize: "1Gi",
+			request:     "1Gi",
+			wantSize:    "1Gi",
+		},
+		{
+			name:          "expand",
+			currentSize:   "1Gi",
+			request:       "2Gi",
+			wantSize:      "2Gi",
+			wantEvent:     "Normal " + ResizedReason,
+			wantCondition: corev1.ConditionTrue,
+			wantReason:    ResizedReason,
+		},
+		{
+			name:        "volume larger than request",
+			currentSize: "1Gi",
+			request:     "1G",
+			capacity:    "1G",
+			wantSize:    "1Gi",
+		},
+		{
+			name:          "shrink",
+			currentSize:   "2Gi",
+			request:       "1Gi",
+			capacity:      "2Gi",
+			wantSize:      "2Gi",
+			wantEvent:     "Warning " + ShrinkRefusedReason,
+			wantCondition: corev1.ConditionFalse,
+			wantReason:    ShrinkRefusedReason,
+		},
+		{
+			name:          "api error",
+			currentSize:   "1Gi",
+			request:       "2Gi",
+			resizeErr:     errors.New("insufficient capacity"),
+			wantErr:       true,
+			wantSize:      "1Gi",
+			wantEvent:     "Warning " + ResizeFailedReason,
+			wantCondition: corev1.ConditionFalse,
+			wantReason:    ResizeFailedReason,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			sc := &storagev1.StorageClass{
+				ObjectMeta:  metav1.ObjectMeta{Name: "stos", UID: "sc-uid"},
+				Provisioner: provisioner.DriverName,
+			}
+			scName := sc.Name
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pvc1",
+					Namespace: "default",
+					Labels:    map[string]string{"foo": "bar"},
+					Annotations: map[string]string{
+						provisioner.PVCProvisionerAnnotationKey:   provisioner.DriverName,
+						provisioner.StorageClassUUIDAnnotationKey: string(sc.UID),
+					},
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &scName,
+					VolumeName:       "pv1",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceStorage: resource.MustParse(tt.request),
+						},
+					},
+				},
+			}
+			if tt.capacity != "" {
+				pvc.Status.Capacity = corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(tt.capacity),
+				}
+			}
+			k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sc, pvc).Build()
+
+			volKey := client.ObjectKey{Name: "pv1", Namespace: "default"}
+			api := storageos.NewMockClient()
+			if err := api.AddVolume(storageos.MockObject{Name: volKey.Name, Namespace: volKey.Namespace}); err != nil {
+				t.Fatal(err)
+			}
+			current := resource.MustParse(tt.currentSize)
+			if _, err := api.EnsureVolumeSize(ctx, volKey, uint64(current.Value())); err != nil {
+				t.Fatal(err)
+			}
+			api.EnsureVolumeSizeErr = tt.resizeErr
+
+			recorder := record.NewFakeRecorder(10)
+			c, err := NewController(k8s, api, scheme, recorder, nil, ctrl.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.Ensure(ctx, pvc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ensure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			size, err := api.GetVolumeSize(volKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := resource.MustParse(tt.wantSize); size != uint64(want.Value()) {
+				t.Errorf("volume size = %d, want %d", size, want.Value())
+			}
+
+			// Labels are applied whether or not the resize succeeded.
+			vol, err := api.GetVolume(ctx, volKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := vol.GetLabels()["foo"]; got != "bar" {
+				t.Errorf("volume label foo = %q, want %q", got, "bar")
+			}
+
+			select {
+			case got := <-recorder.Events:
+				if tt.wantEvent == "" || !strings.HasPrefix(got, tt.wantEvent) {
+					t.Errorf("got event %q, want %q", got, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected event %q, got none", tt.wantEvent)
+				}
+			}
+
+			got := &corev1.PersistentVolumeClaim{}
+			if err := k8s.Get(ctx, client.ObjectKeyFromObject(pvc), got); err != nil {
+				t.Fatal(err)
+			}
+			cond := resizeCondition(got)
+			if tt.wantCondition == "" {
+				if cond != nil {
+					t.Errorf("expected no resize condition, got %v", cond)
+				}
+				return
+			}
+			if cond == nil {
+				t.Fatal("expected resize condition, got none")
+			}
+			if cond.Status != tt.wantCondition || cond.Reason != tt.wantReason {
+				t.Errorf("resize condition = %s/%s, want %s/%s", cond.Status, cond.Reason, tt.wantCondition, tt.wantReason)
+			}
+		})
+	}
+}
+
+// TestSetResizeConditionKeepsConditions checks that setting the resize
+// condition from a stale copy of the pvc does not remove conditions added
+// since it was read.
+func TestSetResizeConditionKeepsConditions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc1", Namespace: "default"},
+	}
+	k8s := fake.NewClientBuilder().WithScheme(kscheme.Scheme).WithObjects(pvc).Build()
+
+	stale := &corev1.PersistentVolumeClaim{}
+	if err := k8s.Get(ctx, client.ObjectKeyFromObject(pvc), stale); err != nil {
+		t.Fatal(err)
+	}
+
+	// The kubelet adds a condition after the pvc was read.
+	current := stale.DeepCopy()
+	current.Status.Conditions = append(current.Status.Conditions, corev1.PersistentVolumeClaimCondition{
+		Type:   corev1.PersistentVolumeClaimFileSystemResizePending,
+		Status: corev1.ConditionTrue,
+	})
+	if err := k8s.Status().Update(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewController(k8s, storageos.NewMockClient(), kscheme.Scheme, record.NewFakeRecorder(10), nil, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.setResizeCondition(ctx, stale, corev1.ConditionTrue, ResizedReason, "resized"); err != nil {
+		t.Fatalf("setResizeCondition() error = %v", err)
+	}
+
+	got := &corev1.PersistentVolumeClaim{}
+	if err := k8s.Get(ctx, client.ObjectKeyFromObject(pvc), got); err != nil {
+		t.Fatal(err)
+	}
+	types := make(map[corev1.PersistentVolumeClaimConditionType]bool)
+	for _, cond := range got.Status.Conditions {
+		types[cond.Type] = true
+	}
+	if !types[VolumeResizeCondition] || !types[corev1.PersistentVolumeClaimFileSystemResizePending] {
+		t.Errorf("conditions = %v, want resize and filesystem resize pending", got.Status.Conditions)
+	}
+}
+
+func TestControllerEnsureLabelSyncStatus(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		syncErr     error
+		wantErr     bool
+		wantApplied map[string]string
+		wantEvents  []string
+	}{
+		{
+			name:        "labels applied",
+			labels:      map[string]string{"foo": "bar", storageos.ReservedLabelReplicas: "1"},
+			wantApplied: map[string]string{"foo": "bar", storageos.ReservedLabelReplicas: "1"},
+		},
+		{
+			name:        "unknown reserved label",
+			labels:      map[string]string{"foo": "bar", storageos.ReservedLabelPrefix + "bogus": "true"},
+			wantErr:     true,
+			wantApplied: map[string]string{"foo": "bar"},
+			wantEvents:  []string{"Warning " + InvalidLabelReason},
+		},
+		{
+			name:       "invalid reserved label value",
+			labels:     map[string]string{storageos.ReservedLabelFailureMode: "sometimes"},
+			wantEvents: []string{"Warning " + InvalidLabelReason},
+			// The mock client doesn't validate values, so the label is
+			// still applied.
+			wantApplied: map[string]string{storageos.ReservedLabelFailureMode: "sometimes"},
+		},
+		{
+			name:    "api error",
+			labels:  map[string]string{"foo": "bar"},
+			syncErr: errors.New("replicas update rejected"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			sc := &storagev1.StorageClass{
+				ObjectMeta:  metav1.ObjectMeta{Name: "stos", UID: "sc-uid"},
+				Provisioner: provisioner.DriverName,
+			}
+			scName := sc.Name
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "pvc1",
+					Namespace:  "default",
+					Generation: 3,
+					Labels:     tt.labels,
+					Annotations: map[string]string{
+						provisioner.PVCProvisionerAnnotationKey:   provisioner.DriverName,
+						provisioner.StorageClassUUIDAnnotationKey: string(sc.UID),
+					},
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &scName,
+					VolumeName:       "pv1",
+				},
+			}
+			k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sc, pvc).Build()
+
+			volKey := client.ObjectKey{Name: "pv1", Namespace: "default"}
+			api := storageos.NewMockClient()
+			if err := api.AddVolume(storageos.MockObject{Name: volKey.Name, Namespace: volKey.Namespace}); err != nil {
+				t.Fatal(err)
+			}
+			api.EnsureVolumeLabelsErr = tt.syncErr
+
+			recorder := record.NewFakeRecorder(10)
+			c, err := NewController(k8s, api, scheme, recorder, nil, ctrl.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.Ensure(ctx, pvc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ensure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, want := range tt.wantEvents {
+				select {
+				case got := <-recorder.Events:
+					if !strings.HasPrefix(got, want) {
+						t.Errorf("got event %q, want %q", got, want)
+					}
+				default:
+					t.Errorf("expected event %q, got none", want)
+				}
+			}
+			select {
+			case got := <-recorder.Events:
+				t.Errorf("unexpected event %q", got)
+			default:
+			}
+
+			got := &corev1.PersistentVolumeClaim{}
+			if err := k8s.Get(ctx, client.ObjectKeyFromObject(pvc), got); err != nil {
+				t.Fatal(err)
+			}
+			annotation, ok := got.GetAnnotations()[LabelSyncAnnotationKey]
+			if !ok {
+				t.Fatal("expected label sync annotation, got none")
+			}
+			status := LabelSyncStatus{}
+			if err := json.Unmarshal([]byte(annotation), &status); err != nil {
+				t.Fatalf("failed to decode label sync status %q: %v", annotation, err)
+			}
+			if status.ObservedGeneration != pvc.GetGeneration() {
+				t.Errorf("observed generation = %d, want %d", status.ObservedGeneration, pvc.GetGeneration())
+			}
+			if (status.Error != "") != tt.wantErr {
+				t.Errorf("status error = %q, wantErr %v", status.Error, tt.wantErr)
+			}
+			if len(status.AppliedLabels) > 0 || len(tt.wantApplied) > 0 {
+				if !reflect.DeepEqual(status.AppliedLabels, tt.wantApplied) {
+					t.Errorf("applied labels = %v, want %v", status.AppliedLabels, tt.wantApplied)
+				}
+			}
+
+			// A repeat sync with the same result should not update the pvc.
+			rv := got.GetResourceVersion()
+			if err := c.Ensure(ctx, got); (err != nil) != tt.wantErr {
+				t.Fatalf("repeat Ensure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for len(recorder.Events) > 0 {
+				<-recorder.Events
+			}
+			if err := k8s.Get(ctx, client.ObjectKeyFromObject(pvc), got); err != nil {
+				t.Fatal(err)
+			}
+			if got.GetResourceVersion() != rv {
+				t.Errorf("repeat sync updated pvc, resource version %s, want %s", got.GetResourceVersion(), rv)
+			}
+			if got.GetAnnotations()[LabelSyncAnnotationKey] != annotation {
+				t.Errorf("repeat sync changed label sync status to %q, want %q", got.GetAnnotations()[LabelSyncAnnotationKey], annotation)
+			}
+		})
+	}
+}
+
+func TestControllerEnsureReplicaSync(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	ready := storageos.ReplicaProgress{ID: "r1", NodeID: "n1", Health: storageos.ReplicaHealthReady}
+
+	// Steps are run in order against the same PVC, as events depend on the
+	// previously recorded status.  If replicas is set, the PVC replicas label
+	// is changed before the step.
+	steps := []struct {
+		name        string
+		replicas    string
+		status      *storageos.ReplicaStatus
+		wantSyncing bool
+		wantEvents  []string
+		wantStatus  *ReplicaSyncStatus
+	}{
+		{
+			name:   "in sync without previous status",
+			status: &storageos.ReplicaStatus{Desired: 1, Replicas: []storageos.ReplicaProgress{ready}},
+		},
+		{
+			name: "failed replica without replicas change",
+			status: &storageos.ReplicaStatus{Desired: 2, Replicas: []storageos.ReplicaProgress{
+				ready,
+				{ID: "r0", NodeID: "n0", Health: storageos.ReplicaHealthFailed},
+			}},
+		},
+		{
+			name:     "replica syncing",
+			replicas: "2",
+			status: &storageos.ReplicaStatus{Desired: 2, Replicas: []storageos.ReplicaProgress{
+				ready,
+				{ID: "r2", NodeID: "n2", Health: "syncing", BytesRemaining: 1024, ThroughputBytes: 512, EstimatedSecondsRemaining: 2},
+			}},
+			wantSyncing: true,
+			wantEvents:  []string{"Normal " + ReplicasSyncingReason},
+			wantStatus:  &ReplicaSyncStatus{ReadyReplicas: 1},
+		},
+		{
+			name: "replica still syncing",
+			status: &storageos.ReplicaStatus{Desired: 2, Replicas: []storageos.ReplicaProgress{
+				ready,
+				{ID: "r2", NodeID: "n2", Health: "syncing", BytesRemaining: 512, ThroughputBytes: 512, EstimatedSecondsRemaining: 1},
+			}},
+			wantSyncing: true,
+			wantStatus:  &ReplicaSyncStatus{ReadyReplicas: 1},
+		},
+		{
+			name: "replica failed",
+			status: &storageos.ReplicaStatus{Desired: 2, Replicas: []storageos.ReplicaProgress{
+				ready,
+				{ID: "r2", NodeID: "n2", Health: storageos.ReplicaHealthFailed},
+			}},
+			wantSyncing: true,
+			wantEvents:  []string{"Warning " + ReplicaFailedReason},
+			wantStatus:  &ReplicaSyncStatus{ReadyReplicas: 1},
+		},
+		{
+			name: "replicas ready",
+			status: &storageos.ReplicaStatus{Desired: 2, Replicas: []storageos.ReplicaProgress{
+				ready,
+				{ID: "r3", NodeID: "n3", Health: storageos.ReplicaHealthReady},
+			}},
+			wantEvents: []string{"Normal " + ReplicasReadyReason},
+			wantStatus: &ReplicaSyncStatus{ReadyReplicas: 2, InSync: true},
+		},
+		{
+			name: "still ready",
+			status: &storageos.ReplicaStatus{Desired: 2, Replicas: []storageos.ReplicaProgress{
+				ready,
+				{ID: "r3", NodeID: "n3", Health: storageos.ReplicaHealthReady},
+			}},
+			wantStatus: &ReplicaSyncStatus{ReadyReplicas: 2, InSync: true},
+		},
+		{
+			name:       "replicas reduced",
+			replicas:   "1",
+			status:     &storageos.ReplicaStatus{Desired: 1, Replicas: []storageos.ReplicaProgress{ready}},
+			wantStatus: &ReplicaSyncStatus{ReadyReplicas: 1, InSync: true},
+		},
+	}
+
+	ctx := context.Background()
+
+	sc := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "stos", UID: "sc-uid"},
+		Provisioner: provisioner.DriverName,
+	}
+	scName := sc.Name
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pvc1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				provisioner.PVCProvisionerAnnotationKey:   provisioner.DriverName,
+				provisioner.StorageClassUUIDAnnotationKey: string(sc.UID),
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &scName,
+			VolumeName:       "pv1",
+		},
+	}
+	k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sc, pvc).Build()
+
+	volKey := client.ObjectKey{Name: "pv1", Namespace: "default"}
+	api := storageos.NewMockClient()
+	if err := api.AddVolume(storageos.MockObject{Name: volKey.Name, Namespace: volKey.Namespace}); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	c, err := NewController(k8s, api, scheme, recorder, nil, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range steps {
+		api.SetReplicaStatus(volKey, step.status)
+
+		got := &corev1.PersistentVolumeClaim{}
+		if err := k8s.Get(ctx, client.ObjectKeyFromObject(pvc), got); err != nil {
+			t.Fatal(err)
+		}
+		if step.replicas != "" {
+			got.SetLabels(map[string]string{storageos.ReservedLabelReplicas: step.replicas})
+			if err := k8s.Update(ctx, got); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.Ensure(ctx, got); err != nil {
+			t.Fatalf("%s: Ensure() error = %v", step.name, err)
+		}
+		if syncing := c.ReplicasSyncing(client.ObjectKeyFromObject(pvc)); syncing != step.wantSyncing {
+			t.Errorf("%s: ReplicasSyncing() = %t, want %t", step.name, syncing, step.wantSyncing)
+		}
+
+		for _, want := range step.wantEvents {
+			select {
+			case got := <-recorder.Events:
+				if !strings.HasPrefix(got, want) {
+					t.Errorf("%s: got event %q, want %q", step.name, got, want)
+				}
+			default:
+				t.Errorf("%s: expected event %q, got none", step.name, want)
+			}
+		}
+		select {
+		case got := <-recorder.Events:
+			t.Errorf("%s: unexpected event %q", step.name, got)
+		default:
+		}
+
+		if err := k8s.Get(ctx, client.ObjectKeyFromObject(pvc), got); err != nil {
+			t.Fatal(err)
+		}
+		status := replicaSyncStatus(got)
+		if step.wantStatus == nil {
+			if status != nil {
+				t.Errorf("%s: expected no replica sync status, got %+v", step.name, status)
+			}
+			continue
+		}
+		if status == nil {
+			t.Fatalf("%s: expected replica sync status, got none", step.name)
+		}
+		if status.ReadyReplicas != step.wantStatus.ReadyReplicas || status.InSync != step.wantStatus.InSync {
+			t.Errorf("%s: replica sync status ready/inSync = %d/%t, want %d/%t", step.name, status.ReadyReplicas, status.InSync, step.wantStatus.ReadyReplicas, step.wantStatus.InSync)
+		}
+		if !reflect.DeepEqual(status.Replicas, step.status.Replicas) {
+			t.Errorf("%s: replica sync status replicas = %+v, want %+v", step.name, status.Replicas, step.status.Replicas)
+		}
+	}
+}
+
+func TestControllerDiff(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	provisionerLabels := map[string]string{
+		storageos.ReservedLabelK8sPVCName:      "pvc1",
+		storageos.ReservedLabelK8sPVCNamespace: "default",
+		storageos.ReservedLabelK8sPVName:       "pv1",
+	}
+	withProvisionerLabels := func(labels map[string]string) map[string]string {
+		ret := make(map[string]string)
+		for k, v := range provisionerLabels {
+			ret[k] = v
+		}
+		for k, v := range labels {
+			ret[k] = v
+		}
+		return ret
+	}
+
+	tests := []struct {
+		name      string
+		scParams  map[string]string
+		scUID     string
+		pvcLabels map[string]string
+		volLabels map[string]string
+		noVolume  bool
+		wantStale bool
+	}{
+		{
+			name:      "in sync",
+			pvcLabels: map[string]string{"foo": "bar"},
+			volLabels: withProvisionerLabels(map[string]string{"foo": "bar"}),
+		},
+		{
+			name:      "storageclass defaults applied",
+			scParams:  map[string]string{storageos.ReservedLabelReplicas: "1"},
+			pvcLabels: map[string]string{"foo": "bar"},
+			volLabels: withProvisionerLabels(map[string]string{"foo": "bar", storageos.ReservedLabelReplicas: "1"}),
+		},
+		{
+			name:      "pvc overrides storageclass default",
+			scParams:  map[string]string{storageos.ReservedLabelReplicas: "1"},
+			pvcLabels: map[string]string{storageos.ReservedLabelReplicas: "2"},
+			volLabels: withProvisionerLabels(map[string]string{storageos.ReservedLabelReplicas: "1"}),
+			wantStale: true,
+		},
+		{
+			name:      "unreserved label changed",
+			pvcLabels: map[string]string{"foo": "baz"},
+			volLabels: withProvisionerLabels(map[string]string{"foo": "bar"}),
+			wantStale: true,
+		},
+		{
+			name:      "immutable label not compared",
+			scParams:  map[string]string{storageos.ReservedLabelNoCache: "true"},
+			volLabels: withProvisionerLabels(nil),
+		},
+		{
+			name:      "storageclass changed",
+			scUID:     "old-sc-uid",
+			pvcLabels: map[string]string{"foo": "baz"},
+			volLabels: withProvisionerLabels(map[string]string{"foo": "bar"}),
+		},
+		{
+			name:      "no volume",
+			pvcLabels: map[string]string{"foo": "bar"},
+			noVolume:  true,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			sc := &storagev1.StorageClass{
+				ObjectMeta:  metav1.ObjectMeta{Name: "stos", UID: "sc-uid"},
+				Provisioner: provisioner.DriverName,
+				Parameters:  tt.scParams,
+			}
+			scUID := string(sc.UID)
+			if tt.scUID != "" {
+				scUID = tt.scUID
+			}
+			scName := sc.Name
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pvc1",
+					Namespace: "default",
+					Labels:    tt.pvcLabels,
+					Annotations: map[string]string{
+						provisioner.PVCProvisionerAnnotationKey:   provisioner.DriverName,
+						provisioner.StorageClassUUIDAnnotationKey: scUID,
+					},
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &scName,
+					VolumeName:       "pv1",
+				},
+			}
+			k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sc).Build()
+
+			api := storageos.NewMockClient()
+			if !tt.noVolume {
+				if err := api.AddVolume(storageos.MockObject{Name: "pv1", Namespace: "default", Labels: tt.volLabels}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c, err := NewController(k8s, api, scheme, nil, nil, ctrl.Log)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stale, err := c.Diff(ctx, []client.Object{pvc})
+			if err != nil {
+				t.Fatalf("Diff() unexpected error: %v", err)
+			}
+			if got := len(stale) == 1; got != tt.wantStale {
+				t.Errorf("Diff() stale = %t, want %t", got, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestPredicateUpdate(t *testing.T) {
+	t.Parallel()
+
+	genPVC := func(request string, pvcLabels map[string]string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pvc1",
+				Namespace:   "default",
+				Labels:      pvcLabels,
+				Annotations: map[string]string{provisioner.PVCProvisionerAnnotationKey: provisioner.DriverName},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse(request),
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter *labels.Filter
+		oldObj *corev1.PersistentVolumeClaim
+		newObj *corev1.PersistentVolumeClaim
+		want   bool
+	}{
+		{
+			name:   "unchanged",
+			oldObj: genPVC("1Gi", nil),
+			newObj: genPVC("1Gi", nil),
+		},
+		{
+			name:   "label change",
+			oldObj: genPVC("1Gi", nil),
+			newObj: genPVC("1Gi", map[string]string{"foo": "bar"}),
+			want:   true,
+		},
+		{
+			name:   "filtered label change",
+			filter: &labels.Filter{IncludePrefixes: []string{"example.com/"}},
+			oldObj: genPVC("1Gi", nil),
+			newObj: genPVC("1Gi", map[string]string{"foo": "bar"}),
+		},
+		{
+			name:   "reserved label change with filter",
+			filter: &labels.Filter{IncludePrefixes: []string{"example.com/"}},
+			oldObj: genPVC("1Gi", nil),
+			newObj: genPVC("1Gi", map[string]string{storageos.ReservedLabelReplicas: "1"}),
+			want:   true,
+		},
+		{
+			name:   "storage request increase",
+			oldObj: genPVC("1Gi", nil),
+			newObj: genPVC("2Gi", nil),
+			want:   true,
+		},
+		{
+			name:   "storage request decrease",
+			oldObj: genPVC("2Gi", nil),
+			newObj: genPVC("1Gi", nil),
+			want:   true,
+		},
+		{
+			name:   "equivalent storage request",
+			oldObj: genPVC("1Gi", nil),
+			newObj: genPVC("1024Mi", nil),
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := Predicate{filter: tt.filter, log: ctrl.Log}
+			if got := p.Update(event.UpdateEvent{ObjectOld: tt.oldObj, ObjectNew: tt.newObj}); got != tt.want {
+				t.Errorf("Predicate.Update() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
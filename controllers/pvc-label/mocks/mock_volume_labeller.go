@@ -50,6 +50,51 @@ func (mr *MockVolumeLabellerMockRecorder) EnsureVolumeLabels(arg0, arg1, arg2 in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureVolumeLabels", reflect.TypeOf((*MockVolumeLabeller)(nil).EnsureVolumeLabels), arg0, arg1, arg2)
 }
 
+// EnsureVolumeSize mocks base method.
+func (m *MockVolumeLabeller) EnsureVolumeSize(arg0 context.Context, arg1 types.NamespacedName, arg2 uint64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureVolumeSize", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnsureVolumeSize indicates an expected call of EnsureVolumeSize.
+func (mr *MockVolumeLabellerMockRecorder) EnsureVolumeSize(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureVolumeSize", reflect.TypeOf((*MockVolumeLabeller)(nil).EnsureVolumeSize), arg0, arg1, arg2)
+}
+
+// GetReplicaStatus mocks base method.
+func (m *MockVolumeLabeller) GetReplicaStatus(arg0 context.Context, arg1 types.NamespacedName) (*storageos.ReplicaStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReplicaStatus", arg0, arg1)
+	ret0, _ := ret[0].(*storageos.ReplicaStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReplicaStatus indicates an expected call of GetReplicaStatus.
+func (mr *MockVolumeLabellerMockRecorder) GetReplicaStatus(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReplicaStatus", reflect.TypeOf((*MockVolumeLabeller)(nil).GetReplicaStatus), arg0, arg1)
+}
+
+// GetVolume mocks base method.
+func (m *MockVolumeLabeller) GetVolume(arg0 context.Context, arg1 types.NamespacedName) (storageos.Object, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVolume", arg0, arg1)
+	ret0, _ := ret[0].(storageos.Object)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVolume indicates an expected call of GetVolume.
+func (mr *MockVolumeLabellerMockRecorder) GetVolume(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVolume", reflect.TypeOf((*MockVolumeLabeller)(nil).GetVolume), arg0, arg1)
+}
+
 // VolumeObjects mocks base method.
 func (m *MockVolumeLabeller) VolumeObjects(arg0 context.Context) (map[types.NamespacedName]storageos.Object, error) {
 	m.ctrl.T.Helper()
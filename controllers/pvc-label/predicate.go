@@ -1,25 +1,29 @@
 package pvclabel
 
 import (
-	"reflect"
-
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 
+	"github.com/storageos/api-manager/internal/pkg/labels"
 	"github.com/storageos/api-manager/internal/pkg/predicate"
 	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
 )
 
 // Predicate filters events before enqueuing the keys.  Ignore all but Update
 // events, and then filter out events from non-StorageOS PVCs.  Trigger a
-// resync when labels have changed.
+// resync when labels selected by the filter or the storage request have
+// changed.
 //
 // We don't need to react to PVC create events as PVC labels will be set in the
 // CSI create volume request as params.  This is a customization made to the CSI
 // Provisioner.
 type Predicate struct {
 	predicate.IgnoreFuncs
-	log logr.Logger
+	filter *labels.Filter
+	log    logr.Logger
 }
 
 // Update determines whether an object update should trigger a reconcile.
@@ -29,9 +33,27 @@ func (p Predicate) Update(e event.UpdateEvent) bool {
 		return false
 	}
 
-	// Otherwise reconcile on label changes.
-	if !reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()) {
+	// Otherwise reconcile on changes to labels selected by the filter.
+	if !k8slabels.Equals(p.filter.Apply(e.ObjectOld.GetLabels(), storageos.IsReservedLabel), p.filter.Apply(e.ObjectNew.GetLabels(), storageos.IsReservedLabel)) {
 		return true
 	}
-	return false
+
+	// Or storage request changes, to resize the volume.
+	return storageRequestChanged(e.ObjectOld, e.ObjectNew)
+}
+
+// storageRequestChanged returns true if both objects are PVCs and the storage
+// request differs.
+func storageRequestChanged(oldObj, newObj interface{}) bool {
+	oldPVC, ok := oldObj.(*corev1.PersistentVolumeClaim)
+	if !ok {
+		return false
+	}
+	newPVC, ok := newObj.(*corev1.PersistentVolumeClaim)
+	if !ok {
+		return false
+	}
+	oldReq := oldPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+	newReq := newPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+	return oldReq.Cmp(newReq) != 0
 }
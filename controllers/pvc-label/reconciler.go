@@ -9,17 +9,23 @@ import (
 	syncv1 "github.com/darkowlzz/operator-toolkit/controller/sync/v1"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 
+	"github.com/storageos/api-manager/internal/pkg/labels"
 	"github.com/storageos/api-manager/internal/pkg/storageos"
 )
 
-// VolumeLabeller provides access to update volume labels.
+// VolumeLabeller provides access to read volumes and replica status, and update
+// volume labels and size.
 //go:generate mockgen -build_flags=--mod=vendor -destination=mocks/mock_volume_labeller.go -package=mocks . VolumeLabeller
 type VolumeLabeller interface {
 	EnsureVolumeLabels(ctx context.Context, key client.ObjectKey, labels map[string]string) error
+	EnsureVolumeSize(ctx context.Context, key client.ObjectKey, desired uint64) (bool, error)
+	GetVolume(ctx context.Context, key client.ObjectKey) (storageos.Object, error)
+	GetReplicaStatus(ctx context.Context, key client.ObjectKey) (*storageos.ReplicaStatus, error)
 	VolumeObjects(ctx context.Context) (map[client.ObjectKey]storageos.Object, error)
 }
 
@@ -29,23 +35,31 @@ type Reconciler struct {
 	client.Client
 	log            logr.Logger
 	api            VolumeLabeller
+	recorder       record.EventRecorder
+	filter         *labels.Filter
 	resyncDelay    time.Duration
 	resyncInterval time.Duration
+	labeller       *Controller
 
 	msyncv1.Reconciler
 }
 
-// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // NewReconciler returns a new PVC label reconciler.
 //
 // The resyncInterval determines how often the periodic resync operation should
-// be run.
-func NewReconciler(api VolumeLabeller, k8s client.Client, resyncDelay time.Duration, resyncInterval time.Duration) *Reconciler {
+// be run.  Resize results are recorded as events with the recorder.  Only PVC
+// labels selected by the filter are synced, or all labels if nil.
+func NewReconciler(api VolumeLabeller, k8s client.Client, resyncDelay time.Duration, resyncInterval time.Duration, recorder record.EventRecorder, filter *labels.Filter) *Reconciler {
 	return &Reconciler{
 		Client:         k8s,
 		log:            ctrl.Log,
 		api:            api,
+		recorder:       recorder,
+		filter:         filter,
 		resyncDelay:    resyncDelay,
 		resyncInterval: resyncInterval,
 	}
@@ -53,10 +67,11 @@ func NewReconciler(api VolumeLabeller, k8s client.Client, resyncDelay time.Durat
 
 // SetupWithManager registers the controller with the controller manager.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, workers int) error {
-	c, err := NewController(r.Client, r.api, mgr.GetScheme(), r.log)
+	c, err := NewController(r.Client, r.api, mgr.GetScheme(), r.recorder, r.filter, r.log)
 	if err != nil {
 		return err
 	}
+	r.labeller = c
 
 	// Set the resync interval.
 	r.Reconciler.SetStartupSyncDelay(r.resyncDelay)
@@ -74,6 +89,20 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, workers int) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		WithOptions(controller.Options{MaxConcurrentReconciles: workers}).
 		For(&corev1.PersistentVolumeClaim{}).
-		WithEventFilter(Predicate{log: r.log}).
+		WithEventFilter(Predicate{filter: r.filter, log: r.log}).
 		Complete(r)
 }
+
+// Reconcile applies the PVC labels to the StorageOS volume.  If the replica
+// count was changed, the PVC is requeued until the volume replicas are in
+// sync.
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	result, err := r.Reconciler.Reconcile(ctx, req)
+	if err != nil || r.labeller == nil {
+		return result, err
+	}
+	if r.labeller.ReplicasSyncing(req.NamespacedName) {
+		return ctrl.Result{RequeueAfter: ReplicaSyncRequeueInterval}, nil
+	}
+	return result, nil
+}
@@ -3,15 +3,35 @@ package pvcmutator
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/go-logr/logr"
+	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption"
+	"github.com/storageos/api-manager/controllers/pvc-mutator/storageclass"
+	"github.com/storageos/api-manager/internal/pkg/mutation"
+	"github.com/storageos/api-manager/internal/pkg/request"
 )
 
+// WebhookName is the webhook label value of the mutator decision metrics.
+const WebhookName = "pvc-mutator"
+
+// deniedErrors are returned by mutators when a request is rejected by policy,
+// rather than because the mutation failed.  They are returned to the user as
+// a denied request instead of an internal error.
+var deniedErrors = []error{
+	encryption.ErrEncryptionRequired,
+	encryption.ErrEncryptionChanged,
+	storageclass.ErrStorageClassChanged,
+}
+
 type Controller struct {
 	client.Client
 	mutators []Mutator
@@ -19,17 +39,32 @@ type Controller struct {
 	log      logr.Logger
 }
 
+// Mutator is implemented by PVC mutators.  Name is used to identify the
+// mutator in its decision metrics.
 type Mutator interface {
+	Name() string
 	MutatePVC(ctx context.Context, pvc *corev1.PersistentVolumeClaim, namespace string) error
 }
 
+// UpdateMutator is implemented by Mutators that also handle PVC updates.
+// Mutators that don't implement it are only run when PVCs are created.
+//
+// MutatePVCUpdate is passed the PVC before the update as old, and may mutate
+// the updated pvc.  Returning an error rejects the update, so it should be
+// used for changes that can't be honoured, such as changing settings that are
+// only read when the volume is provisioned.
+type UpdateMutator interface {
+	MutatePVCUpdate(ctx context.Context, old *corev1.PersistentVolumeClaim, pvc *corev1.PersistentVolumeClaim, namespace string) error
+}
+
 // Check if the Handler interface is implemented.
 var _ admission.Handler = &Controller{}
 
-// +kubebuilder:webhook:path=/mutate-pvcs,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=persistentvolumeclaims,verbs=create,versions=v1,name=pvc-mutator.storageos.com,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/mutate-pvcs,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=persistentvolumeclaims,verbs=create;update,versions=v1,name=pvc-mutator.storageos.com,admissionReviewVersions=v1
 
 // NewController returns a new PVC mutating admission controller.
 func NewController(k8s client.Client, decoder *admission.Decoder, mutators []Mutator) *Controller {
+	mutation.RegisterMetrics()
 	return &Controller{
 		mutators: mutators,
 		Client:   k8s,
@@ -39,6 +74,9 @@ func NewController(k8s client.Client, decoder *admission.Decoder, mutators []Mut
 }
 
 // Handle handles an admission request and mutates a pvc object in the request.
+// All mutators are run when the pvc is created, and only UpdateMutators when
+// it is updated.  The decision of each mutator that was run is recorded in
+// the mutator decision metrics.
 func (c *Controller) Handle(ctx context.Context, req admission.Request) admission.Response {
 	pvc := &corev1.PersistentVolumeClaim{}
 
@@ -51,12 +89,48 @@ func (c *Controller) Handle(ctx context.Context, req admission.Request) admissio
 	// pvc object lacks namespace info.
 	namespace := req.AdmissionRequest.Namespace
 
-	// Run the mutators on the PVC object.
-	for _, m := range c.mutators {
-		if err := m.MutatePVC(ctx, pvc, namespace); err != nil {
-			c.log.Error(err, "failed to mutate pvc")
-			return admission.Errored(http.StatusInternalServerError, err)
+	// Make the request UID available to mutators so that it can be recorded
+	// on any objects they create.
+	ctx = request.WithUID(ctx, req.UID)
+
+	switch req.Operation {
+	case admissionv1.Create:
+		// Run the mutators on the PVC object.
+		for _, m := range c.mutators {
+			before := pvc.DeepCopy()
+			err := m.MutatePVC(ctx, pvc, namespace)
+			observe(m.Name(), before, pvc, err)
+			if err != nil {
+				c.log.Error(err, "failed to mutate pvc")
+				return errorResponse(err)
+			}
+		}
+	case admissionv1.Update:
+		// Don't block updates of PVCs that are being deleted, such as
+		// finalizer removal.
+		if pvc.GetDeletionTimestamp() != nil {
+			return admission.Allowed("")
 		}
+		old := &corev1.PersistentVolumeClaim{}
+		if err := c.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// Run the mutators that support updates on the PVC object.
+		for _, m := range c.mutators {
+			um, ok := m.(UpdateMutator)
+			if !ok {
+				continue
+			}
+			before := pvc.DeepCopy()
+			err := um.MutatePVCUpdate(ctx, old, pvc, namespace)
+			observe(m.Name(), before, pvc, err)
+			if err != nil {
+				c.log.Error(err, "failed to mutate pvc update")
+				return errorResponse(err)
+			}
+		}
+	default:
+		return admission.Allowed("")
 	}
 
 	marshaledPVC, err := json.Marshal(pvc)
@@ -66,3 +140,22 @@ func (c *Controller) Handle(ctx context.Context, req admission.Request) admissio
 
 	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPVC)
 }
+
+// observe records the decision of the named mutator, given the pvc before and
+// after it was run.
+func observe(name string, before, after *corev1.PersistentVolumeClaim, err error) {
+	changed := !equality.Semantic.DeepEqual(before, after)
+	mutation.Decisions.Increment(WebhookName, name, mutation.Decision(changed, err))
+}
+
+// errorResponse returns the admission response for a mutator error.  Requests
+// rejected by policy are denied, and other errors are returned as internal
+// errors.
+func errorResponse(err error) admission.Response {
+	for _, denied := range deniedErrors {
+		if errors.Is(err, denied) {
+			return admission.Denied(err.Error())
+		}
+	}
+	return admission.Errored(http.StatusInternalServerError, err)
+}
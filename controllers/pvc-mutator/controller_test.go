@@ -0,0 +1,173 @@
+package pvcmutator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption"
+	"github.com/storageos/api-manager/controllers/pvc-mutator/storageclass"
+)
+
+// createMutator records the operations it was called for, and returns err on
+// create.
+type createMutator struct {
+	calls []string
+	err   error
+}
+
+func (m *createMutator) Name() string {
+	return "create"
+}
+
+func (m *createMutator) MutatePVC(ctx context.Context, pvc *corev1.PersistentVolumeClaim, namespace string) error {
+	m.calls = append(m.calls, "create")
+	return m.err
+}
+
+// updateMutator records the operations it was called for, and returns err on
+// update.
+type updateMutator struct {
+	createMutator
+	err error
+}
+
+func (m *updateMutator) MutatePVCUpdate(ctx context.Context, old *corev1.PersistentVolumeClaim, pvc *corev1.PersistentVolumeClaim, namespace string) error {
+	m.calls = append(m.calls, "update:"+old.Labels["version"])
+	return m.err
+}
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genPVC := func(version string, deleted bool) runtime.RawExtension {
+		pvc := &corev1.PersistentVolumeClaim{
+			TypeMeta:   metav1.TypeMeta{Kind: "PersistentVolumeClaim", APIVersion: "v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc1", Namespace: "default", Labels: map[string]string{"version": version}},
+		}
+		if deleted {
+			now := metav1.Now()
+			pvc.DeletionTimestamp = &now
+		}
+		raw, err := json.Marshal(pvc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return runtime.RawExtension{Raw: raw}
+	}
+
+	tests := []struct {
+		name            string
+		operation       admissionv1.Operation
+		deleted         bool
+		createErr       error
+		updateErr       error
+		wantCreateCalls []string
+		wantUpdateCalls []string
+		wantAllowed     bool
+		wantCode        int32
+	}{
+		{
+			name:            "create runs all mutators",
+			operation:       admissionv1.Create,
+			wantCreateCalls: []string{"create"},
+			wantUpdateCalls: []string{"create"},
+			wantAllowed:     true,
+		},
+		{
+			name:            "create denied by encryption policy",
+			operation:       admissionv1.Create,
+			createErr:       pkgerrors.Wrap(encryption.ErrEncryptionRequired, "pvc must set encryption"),
+			wantCreateCalls: []string{"create"},
+			wantCode:        http.StatusForbidden,
+		},
+		{
+			name:            "update runs update mutators",
+			operation:       admissionv1.Update,
+			wantUpdateCalls: []string{"update:1"},
+			wantAllowed:     true,
+		},
+		{
+			name:            "update failed",
+			operation:       admissionv1.Update,
+			updateErr:       errors.New("rejected"),
+			wantUpdateCalls: []string{"update:1"},
+			wantCode:        http.StatusInternalServerError,
+		},
+		{
+			name:            "update denied by encryption policy",
+			operation:       admissionv1.Update,
+			updateErr:       pkgerrors.Wrap(encryption.ErrEncryptionChanged, "pvc label changed"),
+			wantUpdateCalls: []string{"update:1"},
+			wantCode:        http.StatusForbidden,
+		},
+		{
+			name:            "update denied by storageclass policy",
+			operation:       admissionv1.Update,
+			updateErr:       pkgerrors.Wrap(storageclass.ErrStorageClassChanged, "pvc storageclass changed"),
+			wantUpdateCalls: []string{"update:1"},
+			wantCode:        http.StatusForbidden,
+		},
+		{
+			name:        "update of deleted pvc skipped",
+			operation:   admissionv1.Update,
+			deleted:     true,
+			updateErr:   errors.New("rejected"),
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cm := &createMutator{err: tt.createErr}
+			um := &updateMutator{err: tt.updateErr}
+			c := NewController(fake.NewClientBuilder().WithScheme(scheme).Build(), decoder, []Mutator{cm, um})
+
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: tt.operation,
+				Namespace: "default",
+				Object:    genPVC("2", tt.deleted),
+			}}
+			if tt.operation == admissionv1.Update {
+				req.OldObject = genPVC("1", false)
+			}
+
+			resp := c.Handle(context.Background(), req)
+			if resp.Allowed != tt.wantAllowed {
+				t.Errorf("Handle() allowed = %t, want %t (%v)", resp.Allowed, tt.wantAllowed, resp.Result)
+			}
+			if tt.wantCode != 0 && (resp.Result == nil || resp.Result.Code != tt.wantCode) {
+				t.Errorf("Handle() result = %v, want code %d", resp.Result, tt.wantCode)
+			}
+			if !reflect.DeepEqual(cm.calls, tt.wantCreateCalls) {
+				t.Errorf("create mutator calls = %v, want %v", cm.calls, tt.wantCreateCalls)
+			}
+			if !reflect.DeepEqual(um.calls, tt.wantUpdateCalls) {
+				t.Errorf("update mutator calls = %v, want %v", um.calls, tt.wantUpdateCalls)
+			}
+		})
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/storageos/api-manager/internal/pkg/provisioner"
 	"github.com/storageos/api-manager/internal/pkg/storageos"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -36,20 +38,63 @@ const (
 	// NamespaceSecretName is the name of the secret containing the user key in
 	// each namespace with encrypted volumes.
 	NamespaceSecretName = "storageos-namespace-key"
+
+	// NamespacePolicyAnnotationKey is the namespace annotation that sets the
+	// encryption policy for StorageOS PVCs in the namespace.
+	NamespacePolicyAnnotationKey = "storageos.com/encryption-policy"
+
+	// PolicyOptional encrypts PVCs only when requested by the PVC label or
+	// StorageClass parameter.  This is the default when no policy is set.
+	PolicyOptional = "optional"
+
+	// PolicyDefault encrypts PVCs unless encryption is explicitly disabled by
+	// the PVC label or StorageClass parameter.
+	PolicyDefault = "default"
+
+	// PolicyMandatory rejects PVCs that do not enable encryption with the PVC
+	// label or StorageClass parameter.  Encryption is not enabled on the PVC's
+	// behalf, so that it is visible in the PVC or StorageClass.
+	PolicyMandatory = "mandatory"
+
+	// NamespaceKeyGeneratedReason is the event reason used when a namespace
+	// key is generated.
+	NamespaceKeyGeneratedReason = "NamespaceKeyGenerated"
+
+	// VolumeKeyGeneratedReason is the event reason used when a volume key is
+	// generated for a pvc.
+	VolumeKeyGeneratedReason = "VolumeKeyGenerated"
+
+	// VolumeKeyReusedReason is the event reason used when a pvc references an
+	// existing volume key.
+	VolumeKeyReusedReason = "VolumeKeyReused"
 )
 
 var (
 	// ErrCrossNamespace is returned if a encryption key secret is requested
 	// that is not it the PVC namespace.
 	ErrCrossNamespace = errors.New("encryption key secret namespace must match pvc namespace")
+
+	// ErrEncryptionRequired is returned if a pvc disables encryption in a
+	// namespace where the encryption policy makes it mandatory.
+	ErrEncryptionRequired = errors.New("encryption is mandatory in this namespace")
+
+	// ErrInvalidPolicy is returned if the namespace encryption policy
+	// annotation is set to an unknown value.
+	ErrInvalidPolicy = errors.New("invalid namespace encryption policy")
+
+	// ErrEncryptionChanged is returned if a pvc update enables or disables
+	// encryption after the volume has been provisioned.
+	ErrEncryptionChanged = errors.New("encryption can't be enabled or disabled after the volume has been provisioned")
 )
 
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // KeyManager is the encrption key manager, responsible for creating and
 // retrieving secrets that contain the keys required for volume encryption.
 type KeyManager interface {
-	Ensure(ctx context.Context, userKeyRef client.ObjectKey, volKeyRef client.ObjectKey, nsSecretLabels map[string]string, volSecretLabels map[string]string) error
+	Ensure(ctx context.Context, userKeyRef client.ObjectKey, volKeyRef client.ObjectKey, nsSecretLabels map[string]string, volSecretLabels map[string]string) (keys.EnsureResult, error)
 }
 
 // EncryptionKeySetter is responsible for generating and setting pvc encryption
@@ -67,31 +112,47 @@ type EncryptionKeySetter struct {
 	// namespace of the secret containing the encryption key.
 	secretNamespaceAnnotationKey string
 
+	// policyAnnotationKey is the namespace annotation that sets the encryption
+	// policy for the namespace.
+	policyAnnotationKey string
+
 	// labels that should be applied to any kubernetes resources created by the
 	// key manager.
 	labels map[string]string
 
 	client.Client
-	keys KeyManager
-	log  logr.Logger
+	keys     KeyManager
+	recorder record.EventRecorder
+	log      logr.Logger
 }
 
 // NewKeySetter returns a new PVC encryption key mutating admission
 // controller that generates volume encryption keys and sets references to their
 // location as PVC annotations.
-func NewKeySetter(k8s client.Client, labels map[string]string) *EncryptionKeySetter {
+//
+// If kek is set, namespace keys are stored wrapped by the key encryption key
+// provider.  Events are recorded on the PVC and its namespace when keys are
+// generated or reused.
+func NewKeySetter(k8s client.Client, kek keys.KEKProvider, recorder record.EventRecorder, labels map[string]string) *EncryptionKeySetter {
 	return &EncryptionKeySetter{
 		enabledLabel:                 storageos.ReservedLabelEncryption,
 		secretNameAnnotationKey:      SecretNameAnnotationKey,
 		secretNamespaceAnnotationKey: SecretNamespaceAnnotationKey,
+		policyAnnotationKey:          NamespacePolicyAnnotationKey,
 
-		Client: k8s,
-		keys:   keys.New(k8s),
-		labels: labels,
-		log:    ctrl.Log.WithName("keygen"),
+		Client:   k8s,
+		keys:     keys.New(k8s, kek),
+		recorder: recorder,
+		labels:   labels,
+		log:      ctrl.Log.WithName("keygen"),
 	}
 }
 
+// Name returns the name of the mutator.
+func (s *EncryptionKeySetter) Name() string {
+	return "encryption"
+}
+
 // MutatePVC mutates a given pvc with annotations containing its encryption key,
 // if the pvc has encryption enabled.
 //
@@ -114,14 +175,39 @@ func (s *EncryptionKeySetter) MutatePVC(ctx context.Context, pvc *corev1.Persist
 		return nil
 	}
 
+	// The namespace encryption policy may enable encryption by default, or
+	// make it mandatory.
+	ns, err := s.namespace(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	policy, err := s.namespacePolicy(ns)
+	if err != nil {
+		return err
+	}
+
 	// Skip mutation if the PVC does not have encryption enabled.
 	// The encryption label should be added to StorageOS PVCs
 	// or inherited from StorageOS StorageClass.
 	// Invalid value of encryption must block PVC creation.
-	enabled, err := s.isEnabled(pvc.GetLabels(), storageClass.Parameters)
+	enabled, set, err := s.isEnabled(pvc.GetLabels(), storageClass.Parameters)
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("failed to parse boolean value for %q pvc label or storageclass parameter", s.enabledLabel))
 	}
+	switch {
+	case set && !enabled && policy == PolicyMandatory:
+		return errors.Wrapf(ErrEncryptionRequired, "pvc must not set %q to false, or use a storageclass that does", s.enabledLabel)
+	case !set && policy == PolicyMandatory:
+		return errors.Wrapf(ErrEncryptionRequired, "pvc must set %q to true, or use a storageclass that does", s.enabledLabel)
+	case !set && policy == PolicyDefault:
+		// Set the label so that the volume is provisioned with encryption.
+		if pvc.Labels == nil {
+			pvc.Labels = make(map[string]string)
+		}
+		pvc.Labels[s.enabledLabel] = "true"
+		enabled = true
+		log.Info("enabled encryption by namespace policy", "policy", policy)
+	}
 	if !enabled {
 		log.V(4).Info("pvc does not have encryption enabled, skipping")
 		return nil
@@ -147,9 +233,11 @@ func (s *EncryptionKeySetter) MutatePVC(ctx context.Context, pvc *corev1.Persist
 
 	// Ensure that the encryption keys exist where expected, creating them if
 	// needed.
-	if err := s.keys.Ensure(ctx, nsKeyRef, volKeyRef, s.labels, volSecretLabels); err != nil {
+	result, err := s.keys.Ensure(ctx, nsKeyRef, volKeyRef, s.labels, volSecretLabels)
+	if err != nil {
 		return errors.Wrap(err, "failed to ensure encryption key present for pvc")
 	}
+	s.recordEvents(pvc, ns, volKeyRef, result)
 
 	// Set annotations on the PVC pointing to the volume key secret.  The
 	// namespace key secret does not need to be passed to the control plane.
@@ -163,6 +251,53 @@ func (s *EncryptionKeySetter) MutatePVC(ctx context.Context, pvc *corev1.Persist
 	return nil
 }
 
+// MutatePVCUpdate handles changes to the encryption label of an existing pvc.
+//
+// Encryption is set when the volume is provisioned, so enabling or disabling
+// it once the pvc is bound is rejected.  Before then, the update is handled as
+// if the pvc was being created, generating keys if encryption is now enabled.
+// If an update removes or changes the volume key annotations, they are
+// restored.
+//
+// Errors returned here will block the update of the PVC.
+func (s *EncryptionKeySetter) MutatePVCUpdate(ctx context.Context, old *corev1.PersistentVolumeClaim, pvc *corev1.PersistentVolumeClaim, namespace string) error {
+	log := s.log.WithValues("pvc", client.ObjectKeyFromObject(pvc).String())
+	log.V(4).Info("received pvc update for mutation")
+
+	// Don't block updates if the StorageClass can't be found, as the pvc
+	// can't be provisioned until it exists.
+	isStorageOS, err := provisioner.IsStorageOSPVC(s.Client, old)
+	if err != nil {
+		log.V(4).Info("unable to determine pvc provisioner, skipping", "error", err.Error())
+		return nil
+	}
+	if !isStorageOS {
+		log.V(4).Info("pvc not provisioned by StorageOS, skipping")
+		return nil
+	}
+
+	label, _ := storageos.LookupVolumeLabel(s.enabledLabel)
+	if !label.Equal(old.GetLabels()[s.enabledLabel], pvc.GetLabels()[s.enabledLabel]) {
+		if provisioner.IsBound(old) {
+			return errors.Wrapf(ErrEncryptionChanged, "pvc %q label changed from %q to %q", s.enabledLabel, old.GetLabels()[s.enabledLabel], pvc.GetLabels()[s.enabledLabel])
+		}
+		return s.MutatePVC(ctx, pvc, namespace)
+	}
+
+	for _, key := range []string{s.secretNameAnnotationKey, s.secretNamespaceAnnotationKey} {
+		val, ok := old.GetAnnotations()[key]
+		if !ok || pvc.GetAnnotations()[key] == val {
+			continue
+		}
+		if pvc.Annotations == nil {
+			pvc.Annotations = make(map[string]string)
+		}
+		pvc.Annotations[key] = val
+		log.Info("restored volume encryption key annotation", "annotation", key)
+	}
+	return nil
+}
+
 // VolumeSecretLabels returns the labels that should be set on the volume key
 // secret.
 func (s *EncryptionKeySetter) VolumeSecretLabels(pvcName string) map[string]string {
@@ -220,14 +355,69 @@ func GenerateVolumeSecretName() string {
 	return fmt.Sprintf("%s-%s", VolumeSecretNamePrefix, uuid.New().String())
 }
 
-// isEnabled iterates on the given maps and looks for encryption key. First occurrence wins.
-func (s *EncryptionKeySetter) isEnabled(hayStacks ...map[string]string) (bool, error) {
+// isEnabled iterates on the given maps and looks for encryption key. First
+// occurrence wins.  set is false if the key was not found in any map.
+func (s *EncryptionKeySetter) isEnabled(hayStacks ...map[string]string) (enabled bool, set bool, err error) {
 	for _, hayStack := range hayStacks {
 		val, exists := hayStack[s.enabledLabel]
 		if exists {
-			return strconv.ParseBool(val)
+			enabled, err = strconv.ParseBool(val)
+			return enabled, true, err
 		}
 	}
 
-	return false, nil
+	return false, false, nil
+}
+
+// namespace returns the namespace of the pvc, or nil if it does not exist.
+func (s *EncryptionKeySetter) namespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	ns := &corev1.Namespace{}
+	if err := s.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to retrieve namespace of pvc")
+	}
+	return ns, nil
+}
+
+// namespacePolicy returns the encryption policy set on the namespace, or
+// PolicyOptional if not set or the namespace is nil.
+func (s *EncryptionKeySetter) namespacePolicy(ns *corev1.Namespace) (string, error) {
+	if ns == nil {
+		return PolicyOptional, nil
+	}
+	policy, ok := ns.GetAnnotations()[s.policyAnnotationKey]
+	if !ok || policy == "" {
+		return PolicyOptional, nil
+	}
+	switch policy {
+	case PolicyOptional, PolicyDefault, PolicyMandatory:
+		return policy, nil
+	default:
+		return "", errors.Wrapf(ErrInvalidPolicy, "namespace %s has %s=%q, must be one of %q, %q or %q", ns.GetName(), s.policyAnnotationKey, policy, PolicyOptional, PolicyDefault, PolicyMandatory)
+	}
+}
+
+// recordEvents records events on the pvc and its namespace describing the keys
+// that were generated or reused.  Namespace events are skipped if ns is nil.
+//
+// The pvc has not been created yet when the events are recorded, so the event
+// will reference the pvc by name only.
+func (s *EncryptionKeySetter) recordEvents(pvc *corev1.PersistentVolumeClaim, ns *corev1.Namespace, volKeyRef client.ObjectKey, result keys.EnsureResult) {
+	if s.recorder == nil {
+		return
+	}
+	if result.NamespaceKeyCreated && ns != nil {
+		s.recorder.Event(ns, corev1.EventTypeNormal, NamespaceKeyGeneratedReason, fmt.Sprintf("Generated namespace encryption key in secret %s", NamespaceSecretName))
+	}
+
+	reason, msg := VolumeKeyReusedReason, "Using existing volume encryption key"
+	if result.VolumeKeyCreated {
+		reason, msg = VolumeKeyGeneratedReason, "Generated volume encryption key"
+	}
+	s.recorder.Event(pvc, corev1.EventTypeNormal, reason, fmt.Sprintf("%s in secret %s", msg, volKeyRef.Name))
+	if ns != nil {
+		s.recorder.Event(ns, corev1.EventTypeNormal, reason, fmt.Sprintf("%s in secret %s for pvc %s", msg, volKeyRef.Name, pvc.GetName()))
+	}
 }
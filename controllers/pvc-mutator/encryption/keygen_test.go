@@ -2,6 +2,7 @@ package encryption
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"strings"
 	"testing"
@@ -15,6 +16,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -59,8 +61,39 @@ func TestMutatePVC(t *testing.T) {
 		Provisioner: "foo-provisioner",
 	}
 
+	// StorageOS StorageClass with encryption disabled.
+	stosSCUnencrypted := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "stos-unenc",
+		},
+		Parameters: map[string]string{
+			storageos.ReservedLabelEncryption: "false",
+		},
+		Provisioner: storageosProvisioner,
+	}
+
 	testNamespace := "default"
 
+	// Namespaces with encryption policies.
+	defaultPolicyNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "policy-default",
+			Annotations: map[string]string{NamespacePolicyAnnotationKey: PolicyDefault},
+		},
+	}
+	mandatoryPolicyNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "policy-mandatory",
+			Annotations: map[string]string{NamespacePolicyAnnotationKey: PolicyMandatory},
+		},
+	}
+	invalidPolicyNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "policy-invalid",
+			Annotations: map[string]string{NamespacePolicyAnnotationKey: "always"},
+		},
+	}
+
 	testcases := []struct {
 		name                              string
 		namespace                         string
@@ -71,7 +104,9 @@ func TestMutatePVC(t *testing.T) {
 		wantSecretNameAnnotationGenerated bool
 		wantSecretNameAnnotation          string
 		wantSecretNamespaceAnnotation     string
+		wantEncryptionLabel               string
 		wantErr                           bool
+		wantErrIs                         error
 	}{
 		{
 			name:         "foreign pvc",
@@ -169,6 +204,92 @@ func TestMutatePVC(t *testing.T) {
 			wantSecretNameAnnotation:      "",
 			wantSecretNamespaceAnnotation: "",
 		},
+		{
+			name:                              "default policy enables encryption",
+			namespace:                         defaultPolicyNamespace.Name,
+			storageClass:                      stosSC,
+			wantSecretNameAnnotationGenerated: true,
+			wantSecretNamespaceAnnotation:     defaultPolicyNamespace.Name,
+			wantEncryptionLabel:               "true",
+		},
+		{
+			name:      "default policy with encryption disabled by pvc",
+			namespace: defaultPolicyNamespace.Name,
+			labels: map[string]string{
+				storageos.ReservedLabelEncryption: "false",
+			},
+			storageClass:        stosSC,
+			wantEncryptionLabel: "false",
+		},
+		{
+			name:         "default policy with encryption disabled by storageclass",
+			namespace:    defaultPolicyNamespace.Name,
+			storageClass: stosSCUnencrypted,
+		},
+		{
+			name:         "default policy ignores non-stos pvc",
+			namespace:    defaultPolicyNamespace.Name,
+			storageClass: notStosSC,
+		},
+		{
+			name:         "mandatory policy without encryption",
+			namespace:    mandatoryPolicyNamespace.Name,
+			storageClass: stosSC,
+			wantErr:      true,
+			wantErrIs:    ErrEncryptionRequired,
+		},
+		{
+			name:      "mandatory policy with encryption enabled by pvc",
+			namespace: mandatoryPolicyNamespace.Name,
+			labels: map[string]string{
+				storageos.ReservedLabelEncryption: "true",
+			},
+			storageClass:                      stosSC,
+			wantSecretNameAnnotationGenerated: true,
+			wantSecretNamespaceAnnotation:     mandatoryPolicyNamespace.Name,
+			wantEncryptionLabel:               "true",
+		},
+		{
+			name:                              "mandatory policy with encryption enabled by storageclass",
+			namespace:                         mandatoryPolicyNamespace.Name,
+			storageClass:                      stosSCEncrypted,
+			wantSecretNameAnnotationGenerated: true,
+			wantSecretNamespaceAnnotation:     mandatoryPolicyNamespace.Name,
+		},
+		{
+			name:      "mandatory policy with encryption disabled by pvc",
+			namespace: mandatoryPolicyNamespace.Name,
+			labels: map[string]string{
+				storageos.ReservedLabelEncryption: "false",
+			},
+			storageClass: stosSC,
+			wantErr:      true,
+			wantErrIs:    ErrEncryptionRequired,
+		},
+		{
+			name:         "mandatory policy with encryption disabled by storageclass",
+			namespace:    mandatoryPolicyNamespace.Name,
+			storageClass: stosSCUnencrypted,
+			wantErr:      true,
+			wantErrIs:    ErrEncryptionRequired,
+		},
+		{
+			name:      "mandatory policy with encryption enabled by pvc overriding storageclass",
+			namespace: mandatoryPolicyNamespace.Name,
+			labels: map[string]string{
+				storageos.ReservedLabelEncryption: "true",
+			},
+			storageClass:                      stosSCUnencrypted,
+			wantSecretNameAnnotationGenerated: true,
+			wantSecretNamespaceAnnotation:     mandatoryPolicyNamespace.Name,
+			wantEncryptionLabel:               "true",
+		},
+		{
+			name:         "invalid policy",
+			namespace:    invalidPolicyNamespace.Name,
+			storageClass: stosSC,
+			wantErr:      true,
+		},
 		{
 			name:      "pvc with encryption beta annotation",
 			namespace: testNamespace,
@@ -186,16 +307,17 @@ func TestMutatePVC(t *testing.T) {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			// Create all the above resources and get a k8s client.
-			k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(stosSC, stosSCEncrypted, notStosSC).Build()
+			k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(stosSC, stosSCEncrypted, stosSCUnencrypted, notStosSC, defaultPolicyNamespace, mandatoryPolicyNamespace, invalidPolicyNamespace).Build()
 
 			// Create a EncryptionKeySetter instance with the fake client.
 			encryptionKeySetter := EncryptionKeySetter{
 				enabledLabel:                 storageos.ReservedLabelEncryption,
 				secretNameAnnotationKey:      SecretNameAnnotationKey,
 				secretNamespaceAnnotationKey: SecretNamespaceAnnotationKey,
+				policyAnnotationKey:          NamespacePolicyAnnotationKey,
 
 				Client: k8s,
-				keys:   keys.New(k8s),
+				keys:   keys.New(k8s, nil),
 				log:    ctrl.Log,
 			}
 
@@ -206,8 +328,24 @@ func TestMutatePVC(t *testing.T) {
 				if !tc.wantErr {
 					t.Errorf("got unexpected error: %v", err)
 				}
+				if tc.wantErrIs != nil && !errors.Is(err, tc.wantErrIs) {
+					t.Errorf("got error %v, want %v", err, tc.wantErrIs)
+				}
 				return
 			}
+			if tc.wantErr {
+				t.Fatal("expected error, got none")
+			}
+
+			// Check encryption label.  Unless expected to be set, it should
+			// be unchanged.
+			wantLabel := tc.wantEncryptionLabel
+			if wantLabel == "" {
+				wantLabel = tc.labels[storageos.ReservedLabelEncryption]
+			}
+			if got := pvc.GetLabels()[storageos.ReservedLabelEncryption]; got != wantLabel {
+				t.Errorf("expected %s label to be %q, got %q", storageos.ReservedLabelEncryption, wantLabel, got)
+			}
 
 			// Check name ref annotation.
 			annotations := pvc.GetAnnotations()
@@ -275,6 +413,180 @@ func TestMutatePVC(t *testing.T) {
 	}
 }
 
+func TestMutatePVCEvents(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	sc := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "stos"},
+		Provisioner: provisioner.DriverName,
+	}
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+	}
+	encrypted := map[string]string{storageos.ReservedLabelEncryption: "true"}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sc, ns).Build()
+	recorder := record.NewFakeRecorder(10)
+	s := NewKeySetter(k8s, nil, recorder, nil)
+
+	// The first pvc generates both keys.
+	pvc := createPVC("pvc1", ns.Name, sc.Name, false, encrypted, nil)
+	if err := s.MutatePVC(context.Background(), pvc, ns.Name); err != nil {
+		t.Fatalf("MutatePVC() unexpected error: %v", err)
+	}
+	secretName := pvc.GetAnnotations()[SecretNameAnnotationKey]
+	wantEvents := []string{
+		"Normal NamespaceKeyGenerated Generated namespace encryption key in secret " + NamespaceSecretName,
+		"Normal VolumeKeyGenerated Generated volume encryption key in secret " + secretName,
+		"Normal VolumeKeyGenerated Generated volume encryption key in secret " + secretName + " for pvc pvc1",
+	}
+	for _, want := range wantEvents {
+		if got := <-recorder.Events; got != want {
+			t.Errorf("got event %q, want %q", got, want)
+		}
+	}
+
+	// A pvc referencing the existing volume key reuses it.
+	pvc = createPVC("pvc2", ns.Name, sc.Name, false, encrypted, map[string]string{SecretNameAnnotationKey: secretName})
+	if err := s.MutatePVC(context.Background(), pvc, ns.Name); err != nil {
+		t.Fatalf("MutatePVC() unexpected error: %v", err)
+	}
+	wantEvents = []string{
+		"Normal VolumeKeyReused Using existing volume encryption key in secret " + secretName,
+		"Normal VolumeKeyReused Using existing volume encryption key in secret " + secretName + " for pvc pvc2",
+	}
+	for _, want := range wantEvents {
+		if got := <-recorder.Events; got != want {
+			t.Errorf("got event %q, want %q", got, want)
+		}
+	}
+	if n := len(recorder.Events); n != 0 {
+		t.Errorf("got %d unexpected events", n)
+	}
+}
+
+func TestMutatePVCUpdate(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	sc := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "stos"},
+		Provisioner: provisioner.DriverName,
+	}
+	notStosSC := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "non-stos"},
+		Provisioner: "foo-provisioner",
+	}
+	encrypted := map[string]string{storageos.ReservedLabelEncryption: "true"}
+	unencrypted := map[string]string{storageos.ReservedLabelEncryption: "false"}
+	keyAnnotations := map[string]string{SecretNameAnnotationKey: "vol-key", SecretNamespaceAnnotationKey: "default"}
+
+	testcases := []struct {
+		name                    string
+		storageClass            string
+		bound                   bool
+		oldLabels               map[string]string
+		oldAnnotations          map[string]string
+		labels                  map[string]string
+		annotations             map[string]string
+		wantErr                 error
+		wantSecretNameGenerated bool
+		wantAnnotations         map[string]string
+	}{
+		{
+			name:                    "enable encryption before provisioning",
+			storageClass:            sc.Name,
+			labels:                  encrypted,
+			wantSecretNameGenerated: true,
+		},
+		{
+			name:         "enable encryption after provisioning",
+			storageClass: sc.Name,
+			bound:        true,
+			labels:       encrypted,
+			wantErr:      ErrEncryptionChanged,
+		},
+		{
+			name:           "disable encryption after provisioning",
+			storageClass:   sc.Name,
+			bound:          true,
+			oldLabels:      encrypted,
+			oldAnnotations: keyAnnotations,
+			labels:         unencrypted,
+			annotations:    keyAnnotations,
+			wantErr:        ErrEncryptionChanged,
+		},
+		{
+			name:         "set default value after provisioning",
+			storageClass: sc.Name,
+			bound:        true,
+			labels:       unencrypted,
+		},
+		{
+			name:            "restore removed key annotations",
+			storageClass:    sc.Name,
+			bound:           true,
+			oldLabels:       encrypted,
+			oldAnnotations:  keyAnnotations,
+			labels:          encrypted,
+			annotations:     map[string]string{SecretNameAnnotationKey: "other-key"},
+			wantAnnotations: keyAnnotations,
+		},
+		{
+			name:         "non-storageos pvc",
+			storageClass: notStosSC.Name,
+			bound:        true,
+			labels:       encrypted,
+		},
+		{
+			name:         "missing storageclass",
+			storageClass: "missing",
+			labels:       encrypted,
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sc, notStosSC).Build()
+			s := NewKeySetter(k8s, nil, nil, nil)
+
+			old := createPVC("pvc1", "default", tc.storageClass, false, tc.oldLabels, tc.oldAnnotations)
+			pvc := createPVC("pvc1", "default", tc.storageClass, false, tc.labels, tc.annotations)
+			if tc.bound {
+				old.Spec.VolumeName = "pv1"
+				pvc.Spec.VolumeName = "pv1"
+			}
+
+			err := s.MutatePVCUpdate(context.Background(), old, pvc, "default")
+			if !errors.Is(err, tc.wantErr) || (err != nil && tc.wantErr == nil) {
+				t.Fatalf("MutatePVCUpdate() error = %v, want %v", err, tc.wantErr)
+			}
+
+			nameRef := pvc.GetAnnotations()[SecretNameAnnotationKey]
+			if tc.wantSecretNameGenerated && !strings.HasPrefix(nameRef, VolumeSecretNamePrefix) {
+				t.Errorf("expected %s annotation to be generated, got %s", SecretNameAnnotationKey, nameRef)
+			}
+			for k, v := range tc.wantAnnotations {
+				if got := pvc.GetAnnotations()[k]; got != v {
+					t.Errorf("expected %s annotation to be %q, got %q", k, v, got)
+				}
+			}
+		})
+	}
+}
+
 // createPVC creates and returns a PVC object.
 func createPVC(name, namespace, storageClassName string, betaAnnotation bool, labels map[string]string, annotations map[string]string) *corev1.PersistentVolumeClaim {
 	scAnnotationKey := "volume.beta.kubernetes.io/storage-class"
@@ -323,6 +635,7 @@ func Test_isEnabled(t *testing.T) {
 		key     string
 		kv      map[string]string
 		want    bool
+		wantSet bool
 		wantErr bool
 	}{
 		{
@@ -347,7 +660,8 @@ func Test_isEnabled(t *testing.T) {
 			kv: map[string]string{
 				"foo": "true",
 			},
-			want: true,
+			want:    true,
+			wantSet: true,
 		},
 		{
 			name: "disabled",
@@ -355,7 +669,8 @@ func Test_isEnabled(t *testing.T) {
 			kv: map[string]string{
 				"foo": "false",
 			},
-			want: false,
+			want:    false,
+			wantSet: true,
 		},
 		{
 			name: "empty map",
@@ -375,7 +690,7 @@ func Test_isEnabled(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			eks := EncryptionKeySetter{enabledLabel: tt.key}
 
-			got, err := eks.isEnabled(tt.kv)
+			got, set, err := eks.isEnabled(tt.kv)
 			if err != nil {
 				if !tt.wantErr {
 					t.Errorf("got unexpected error: %v", err)
@@ -385,6 +700,9 @@ func Test_isEnabled(t *testing.T) {
 			if got != tt.want {
 				t.Errorf("isEnabled() = %v, want %v", got, tt.want)
 			}
+			if set != tt.wantSet {
+				t.Errorf("isEnabled() set = %v, want %v", set, tt.wantSet)
+			}
 		})
 	}
 }
@@ -445,7 +763,7 @@ func TestEncryptionKeySetter_VolumeSecretLabels(t *testing.T) {
 				secretNamespaceAnnotationKey: SecretNamespaceAnnotationKey,
 				labels:                       tt.labels,
 				Client:                       nil,
-				keys:                         keys.New(nil),
+				keys:                         keys.New(nil, nil),
 				log:                          ctrl.Log,
 			}
 			if got := s.VolumeSecretLabels(tt.pvcName); !reflect.DeepEqual(got, tt.want) {
@@ -0,0 +1,208 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/crypto"
+)
+
+const (
+	// BackupFormatVersion is the version of the backup format written by
+	// Export.
+	BackupFormatVersion = 1
+
+	// BackupAlgorithm describes how sealed backups are encrypted: a random
+	// AES-256 GCM data key encrypts the backup, and the data key is encrypted
+	// to the operator's RSA public key with RSA-OAEP and SHA-256.
+	BackupAlgorithm = "RSA-OAEP-SHA256+A256GCM"
+
+	// backupLabel is used as the RSA-OAEP label and the GCM additional data
+	// when sealing backups.
+	backupLabel = "storageos-key-backup"
+)
+
+var (
+	// ErrUnsupportedBackup is returned when a backup has a format version or
+	// algorithm that is not known.
+	ErrUnsupportedBackup = errors.New("unsupported key backup format")
+
+	// ErrRestoreConflict is returned when a secret in the backup already
+	// exists with different contents.
+	ErrRestoreConflict = errors.New("secret exists with different keys")
+)
+
+// Backup is a copy of the namespace and volume key secrets.  Secret data is
+// copied as stored by the KeyManager, so namespace keys that were wrapped by a
+// key encryption key provider remain wrapped.
+type Backup struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Secrets   []BackupSecret `json:"secrets"`
+}
+
+// BackupSecret is a namespace or volume key secret in a backup.
+type BackupSecret struct {
+	Namespace   string            `json:"namespace"`
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Data        map[string][]byte `json:"data"`
+}
+
+// SealedBackup is a Backup encrypted to an RSA public key.  Byte fields are
+// base64-encoded when marshalled to JSON.
+type SealedBackup struct {
+	Version      int    `json:"version"`
+	Algorithm    string `json:"algorithm"`
+	EncryptedKey []byte `json:"encryptedKey"`
+	Ciphertext   []byte `json:"ciphertext"`
+}
+
+// Export returns a backup of the namespace key secrets named nsKeyName and all
+// volume key secrets.  If namespace is set, only secrets in that namespace are
+// included.
+func (m *KeyManager) Export(ctx context.Context, namespace string, nsKeyName string) (*Backup, error) {
+	secrets := &corev1.SecretList{}
+	if err := m.client.List(ctx, secrets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	backup := &Backup{
+		Version:   BackupFormatVersion,
+		CreatedAt: time.Now().UTC(),
+		Secrets:   []BackupSecret{},
+	}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if secret.GetName() != nsKeyName && !isVolumeKeySecret(secret) {
+			continue
+		}
+		backup.Secrets = append(backup.Secrets, BackupSecret{
+			Namespace:   secret.GetNamespace(),
+			Name:        secret.GetName(),
+			Labels:      secret.GetLabels(),
+			Annotations: secret.GetAnnotations(),
+			Data:        secret.Data,
+		})
+	}
+	sort.Slice(backup.Secrets, func(i, j int) bool {
+		a, b := backup.Secrets[i], backup.Secrets[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return backup, nil
+}
+
+// Restore creates the secrets in the backup that don't already exist, and
+// returns the number of secrets created.  Existing secrets with the same data
+// are skipped.  Existing secrets with different data are not modified, and
+// their names are returned in an error wrapping ErrRestoreConflict once all
+// other secrets have been restored.
+func (m *KeyManager) Restore(ctx context.Context, backup *Backup) (int, error) {
+	if backup.Version != BackupFormatVersion {
+		return 0, fmt.Errorf("%w: version %d", ErrUnsupportedBackup, backup.Version)
+	}
+
+	var restored int
+	var conflicts []string
+	for _, s := range backup.Secrets {
+		ref := client.ObjectKey{Name: s.Name, Namespace: s.Namespace}
+		existing := &corev1.Secret{}
+		err := m.client.Get(ctx, ref, existing)
+		if err == nil {
+			if !equalData(existing.Data, s.Data) {
+				conflicts = append(conflicts, ref.String())
+			}
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return restored, err
+		}
+		if err := m.client.Create(ctx, m.secret(ref, s.Data, s.Labels, s.Annotations)); err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", ref, err)
+		}
+		restored++
+	}
+	if len(conflicts) > 0 {
+		return restored, fmt.Errorf("%w: %s", ErrRestoreConflict, strings.Join(conflicts, ", "))
+	}
+	return restored, nil
+}
+
+// Seal encrypts the backup to the RSA public key, returning the JSON-encoded
+// SealedBackup.  Only the holder of the matching private key can open it.
+func Seal(backup *Backup, pub *rsa.PublicKey) ([]byte, error) {
+	plaintext, err := json.Marshal(backup)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := crypto.GenerateUserKey()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := crypto.EncryptGCM(plaintext, dataKey, []byte(backupLabel))
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := crypto.EncryptOAEP(dataKey, pub, []byte(backupLabel))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(SealedBackup{
+		Version:      BackupFormatVersion,
+		Algorithm:    BackupAlgorithm,
+		EncryptedKey: encryptedKey,
+		Ciphertext:   ciphertext,
+	})
+}
+
+// Open decrypts a JSON-encoded SealedBackup with the RSA private key.
+func Open(data []byte, priv *rsa.PrivateKey) (*Backup, error) {
+	sealed := SealedBackup{}
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, err
+	}
+	if sealed.Version != BackupFormatVersion || sealed.Algorithm != BackupAlgorithm {
+		return nil, fmt.Errorf("%w: version %d, algorithm %q", ErrUnsupportedBackup, sealed.Version, sealed.Algorithm)
+	}
+	dataKey, err := crypto.DecryptOAEP(sealed.EncryptedKey, priv, []byte(backupLabel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt backup key: %w", err)
+	}
+	plaintext, err := crypto.DecryptGCM(sealed.Ciphertext, dataKey, []byte(backupLabel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt backup: %w", err)
+	}
+	backup := &Backup{}
+	if err := json.Unmarshal(plaintext, backup); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// equalData returns true if the secret data maps have the same contents.
+func equalData(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if !bytes.Equal(v, b[k]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestKeyManager_ExportRestore(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	const nsKeyName = "storageos-namespace-key"
+	refs := []client.ObjectKey{
+		{Name: "vol-1", Namespace: "ns-a"},
+		{Name: "vol-2", Namespace: "ns-a"},
+		{Name: "vol-3", Namespace: "ns-b"},
+	}
+
+	// Unrelated secrets must not be exported.
+	other := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns-a"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+	src := fake.NewClientBuilder().WithScheme(scheme).WithObjects(other).Build()
+	m := New(src, nil)
+	for _, ref := range refs {
+		nsKeyRef := client.ObjectKey{Name: nsKeyName, Namespace: ref.Namespace}
+		if _, err := m.Ensure(ctx, nsKeyRef, ref, nil, map[string]string{"app": "test"}); err != nil {
+			t.Fatalf("failed to create keys: %v", err)
+		}
+	}
+
+	backup, err := m.Export(ctx, "", nsKeyName)
+	if err != nil {
+		t.Fatalf("Export() unexpected error: %v", err)
+	}
+	// 2 namespace keys and 3 volume keys.
+	if len(backup.Secrets) != 5 {
+		t.Fatalf("Export() returned %d secrets, want 5", len(backup.Secrets))
+	}
+
+	nsBackup, err := m.Export(ctx, "ns-b", nsKeyName)
+	if err != nil {
+		t.Fatalf("Export() unexpected error: %v", err)
+	}
+	if len(nsBackup.Secrets) != 2 {
+		t.Errorf("Export() for namespace returned %d secrets, want 2", len(nsBackup.Secrets))
+	}
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := Seal(backup, &priv.PublicKey)
+	if err != nil {
+		t.Fatalf("Seal() unexpected error: %v", err)
+	}
+	wrongKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(sealed, wrongKey); err == nil {
+		t.Error("expected error opening backup with a different private key")
+	}
+	opened, err := Open(sealed, priv)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+
+	// Restore into an empty cluster.
+	dst := fake.NewClientBuilder().WithScheme(scheme).Build()
+	restorer := New(dst, nil)
+	n, err := restorer.Restore(ctx, opened)
+	if err != nil {
+		t.Fatalf("Restore() unexpected error: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("Restore() restored %d secrets, want 5", n)
+	}
+	for _, ref := range refs {
+		want := &corev1.Secret{}
+		if err := src.Get(ctx, ref, want); err != nil {
+			t.Fatal(err)
+		}
+		got := &corev1.Secret{}
+		if err := dst.Get(ctx, ref, got); err != nil {
+			t.Fatalf("secret %s not restored: %v", ref, err)
+		}
+		if !equalData(got.Data, want.Data) {
+			t.Errorf("secret %s data does not match", ref)
+		}
+		if got.Labels["app"] != "test" {
+			t.Errorf("secret %s labels not restored", ref)
+		}
+		nsKeyRef := client.ObjectKey{Name: nsKeyName, Namespace: ref.Namespace}
+		if err := restorer.Validate(ctx, nsKeyRef, got); err != nil {
+			t.Errorf("restored secret %s invalid: %v", ref, err)
+		}
+	}
+
+	// Restoring again is a no-op.
+	if n, err := restorer.Restore(ctx, opened); err != nil || n != 0 {
+		t.Errorf("Restore() again = %d, %v, want 0, nil", n, err)
+	}
+
+	// Secrets that have changed are not overwritten.
+	changed := &corev1.Secret{}
+	if err := dst.Get(ctx, refs[0], changed); err != nil {
+		t.Fatal(err)
+	}
+	changed.Data["key"] = []byte("changed")
+	if err := dst.Update(ctx, changed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restorer.Restore(ctx, opened); !errors.Is(err, ErrRestoreConflict) {
+		t.Errorf("Restore() error = %v, want %v", err, ErrRestoreConflict)
+	}
+}
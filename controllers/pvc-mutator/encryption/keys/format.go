@@ -0,0 +1,234 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/crypto"
+)
+
+const (
+	// FormatVersionCBC is the original volume key format.  The volume master
+	// key is wrapped with AES-256 CBC, with no authentication of the wrapped
+	// key.  Volume key secrets without a version use this format.
+	FormatVersionCBC = "1"
+
+	// FormatVersionGCM wraps the volume master key with AES-256 GCM, using the
+	// iv as additional authenticated data.
+	FormatVersionGCM = "2"
+
+	// CurrentFormatVersion is the format used for new volume keys, and that
+	// existing volume keys are upgraded to.
+	CurrentFormatVersion = FormatVersionGCM
+
+	// UpgradeAnnotationKey can be set on a namespace key secret to request
+	// that all volume keys in the namespace are upgraded to the current
+	// format.  It is removed once the upgrade completes.
+	UpgradeAnnotationKey = "storageos.com/upgrade-key-format"
+
+	// versionDataKey is the volume key secret data key used to store the
+	// format version.
+	versionDataKey = "version"
+
+	// gcmAdditionalDataPrefix is prepended to the iv to form the additional
+	// authenticated data for FormatVersionGCM.
+	gcmAdditionalDataPrefix = "storageos-vuk-v2:"
+)
+
+// ErrUnsupportedFormatVersion is returned when a volume key secret has a format
+// version that is not known.
+var ErrUnsupportedFormatVersion = errors.New("unsupported volume key format version")
+
+// formatVersion returns the format version of the volume key secret.
+func formatVersion(secret *corev1.Secret) (string, error) {
+	version := string(secret.Data[versionDataKey])
+	switch version {
+	case "":
+		return FormatVersionCBC, nil
+	case FormatVersionCBC, FormatVersionGCM:
+		return version, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedFormatVersion, version)
+	}
+}
+
+// vukSize returns the size in bytes of a volume user key in the format
+// version.
+func vukSize(version string) int {
+	if version == FormatVersionGCM {
+		// Nonce, wrapped key and authentication tag.
+		return 12 + volumeKeySize + 16
+	}
+	// CBC IV and wrapped key.
+	return 16 + volumeKeySize
+}
+
+// wrapVMK wraps the volume master key with an intermediate key derived from
+// the namespace key and iv, returning the volume user key in the format
+// version.
+func wrapVMK(version string, vmk []byte, nsKey []byte, iv []byte) ([]byte, error) {
+	ik, err := crypto.CreateIK(nsKey, iv)
+	if err != nil {
+		return nil, err
+	}
+	switch version {
+	case FormatVersionCBC:
+		return crypto.Encrypt(vmk, ik)
+	case FormatVersionGCM:
+		return crypto.EncryptGCM(vmk, ik, gcmAdditionalData(iv))
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormatVersion, version)
+	}
+}
+
+// unwrapVMK returns the volume master key from a volume user key in the format
+// version.
+func unwrapVMK(version string, vuk []byte, nsKey []byte, iv []byte) ([]byte, error) {
+	ik, err := crypto.CreateIK(nsKey, iv)
+	if err != nil {
+		return nil, err
+	}
+	switch version {
+	case FormatVersionCBC:
+		return crypto.Decrypt(vuk, ik)
+	case FormatVersionGCM:
+		return crypto.DecryptGCM(vuk, ik, gcmAdditionalData(iv))
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormatVersion, version)
+	}
+}
+
+// gcmAdditionalData returns the additional authenticated data used when
+// wrapping with FormatVersionGCM.  Binding the iv means the volume user key
+// can't be paired with a different iv.
+func gcmAdditionalData(iv []byte) []byte {
+	return append([]byte(gcmAdditionalDataPrefix), iv...)
+}
+
+// setVolumeKey sets the volume user key and HMAC in the volume key secret
+// data, wrapping the volume master key with the namespace key in the current
+// format version, and records the format version as an annotation.  The secret
+// is not persisted.
+func setVolumeKey(secret *corev1.Secret, vmk []byte, nsKey []byte, iv []byte) error {
+	vuk, err := wrapVMK(CurrentFormatVersion, vmk, nsKey, iv)
+	if err != nil {
+		return err
+	}
+	hmac, err := crypto.CreateHMAC(vmk, iv)
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data["key"] = vmk
+	secret.Data["iv"] = iv
+	secret.Data["vuk"] = vuk
+	secret.Data["hmac"] = hmac
+	secret.Data[versionDataKey] = []byte(CurrentFormatVersion)
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[FormatVersionAnnotationKey] = CurrentFormatVersion
+	return nil
+}
+
+// Upgrade re-wraps each volume key in the namespace that is not in the current
+// format version, using the namespace key it was wrapped with.  The volume
+// master keys are not changed.  The upgrade annotation is removed from the
+// namespace key secret once all volume keys have been upgraded.
+//
+// Each volume key secret is updated atomically, so an interrupted upgrade can
+// be run again.
+func (m *KeyManager) Upgrade(ctx context.Context, nsKeyRef client.ObjectKey) error {
+	nsKeys, err := m.availableNamespaceKeys(ctx, nsKeyRef)
+	if err != nil {
+		return err
+	}
+	if len(nsKeys) == 0 {
+		return ErrNoKeyInSecret
+	}
+
+	secrets := &corev1.SecretList{}
+	if err := m.client.List(ctx, secrets, client.InNamespace(nsKeyRef.Namespace)); err != nil {
+		return err
+	}
+
+	var failed []string
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if !isVolumeKeySecret(secret) {
+			continue
+		}
+		if err := m.upgrade(ctx, secret, nsKeys); err != nil {
+			if errors.Is(err, ErrVolumeKeyNotWrapped) {
+				failed = append(failed, secret.GetName())
+				continue
+			}
+			return fmt.Errorf("failed to upgrade volume key %s: %w", secret.GetName(), err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", ErrVolumeKeyNotWrapped, strings.Join(failed, ", "))
+	}
+
+	nsSecret := &corev1.Secret{}
+	if err := m.client.Get(ctx, nsKeyRef, nsSecret); err != nil {
+		return err
+	}
+	if _, ok := nsSecret.GetAnnotations()[UpgradeAnnotationKey]; !ok {
+		return nil
+	}
+	delete(nsSecret.Annotations, UpgradeAnnotationKey)
+	return m.client.Update(ctx, nsSecret)
+}
+
+// upgrade re-wraps the volume key secret in the current format version if
+// needed, using whichever of the namespace keys it was wrapped with.
+func (m *KeyManager) upgrade(ctx context.Context, secret *corev1.Secret, nsKeys [][]byte) error {
+	version, err := formatVersion(secret)
+	if err != nil {
+		return err
+	}
+	if version == CurrentFormatVersion {
+		return nil
+	}
+	vmk, iv, vuk := secret.Data["key"], secret.Data["iv"], secret.Data["vuk"]
+	for _, nsKey := range nsKeys {
+		ok, err := unwrapsTo(version, vuk, vmk, nsKey, iv)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := setVolumeKey(secret, vmk, nsKey, iv); err != nil {
+			return err
+		}
+		// The update will fail if the secret was modified since it was read.
+		return m.client.Update(ctx, secret)
+	}
+	return ErrVolumeKeyNotWrapped
+}
+
+// unwrapsTo returns true if the vuk, in the format version, decrypts to the
+// vmk when using an intermediate key derived from the namespace key and iv.
+func unwrapsTo(version string, vuk []byte, vmk []byte, nsKey []byte, iv []byte) (bool, error) {
+	if version == "" {
+		version = FormatVersionCBC
+	}
+	plaintext, err := unwrapVMK(version, vuk, nsKey, iv)
+	if errors.Is(err, ErrUnsupportedFormatVersion) {
+		return false, err
+	}
+	if err != nil {
+		return false, nil
+	}
+	return bytes.Equal(plaintext, vmk), nil
+}
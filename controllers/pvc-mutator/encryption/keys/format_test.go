@@ -0,0 +1,145 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/storageos/api-manager/internal/pkg/crypto"
+)
+
+func TestWrapVMK(t *testing.T) {
+	t.Parallel()
+
+	vmk, err := crypto.GenerateVMK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv, err := crypto.GenerateIV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nsKey, err := crypto.GenerateUserKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []string{FormatVersionCBC, FormatVersionGCM} {
+		vuk, err := wrapVMK(version, vmk, nsKey, iv)
+		if err != nil {
+			t.Fatalf("version %s: wrapVMK() unexpected error: %v", version, err)
+		}
+		if len(vuk) != vukSize(version) {
+			t.Errorf("version %s: vuk is %d bytes, want %d", version, len(vuk), vukSize(version))
+		}
+		got, err := unwrapVMK(version, vuk, nsKey, iv)
+		if err != nil {
+			t.Fatalf("version %s: unwrapVMK() unexpected error: %v", version, err)
+		}
+		if !bytes.Equal(got, vmk) {
+			t.Errorf("version %s: unwrapped key does not match", version)
+		}
+	}
+
+	// Tampering must be detected with authenticated encryption.
+	vuk, err := wrapVMK(FormatVersionGCM, vmk, nsKey, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vuk[len(vuk)-1] ^= 0xff
+	if _, err := unwrapVMK(FormatVersionGCM, vuk, nsKey, iv); err == nil {
+		t.Error("expected error unwrapping modified vuk")
+	}
+
+	if _, err := wrapVMK("99", vmk, nsKey, iv); !errors.Is(err, ErrUnsupportedFormatVersion) {
+		t.Errorf("wrapVMK() error = %v, want %v", err, ErrUnsupportedFormatVersion)
+	}
+}
+
+func TestKeyManager_Upgrade(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	const namespace = "default"
+	nsKeyRef := client.ObjectKey{Name: "storageos-namespace-key", Namespace: namespace}
+	volKeyRef := client.ObjectKey{Name: "vol-1", Namespace: namespace}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme).Build()
+	m := New(k8s, nil)
+	if _, err := m.Ensure(ctx, nsKeyRef, volKeyRef, nil, nil); err != nil {
+		t.Fatalf("failed to create keys: %v", err)
+	}
+
+	nsSecret := &corev1.Secret{}
+	if err := k8s.Get(ctx, nsKeyRef, nsSecret); err != nil {
+		t.Fatal(err)
+	}
+	nsKey := nsSecret.Data[keyDataKey]
+
+	// Convert the volume key to the original format, without a version.
+	volSecret := &corev1.Secret{}
+	if err := k8s.Get(ctx, volKeyRef, volSecret); err != nil {
+		t.Fatal(err)
+	}
+	vmk := volSecret.Data["key"]
+	legacy, err := wrapVMK(FormatVersionCBC, vmk, nsKey, volSecret.Data["iv"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	volSecret.Data["vuk"] = legacy
+	delete(volSecret.Data, versionDataKey)
+	if err := k8s.Update(ctx, volSecret); err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing keys remain valid.
+	if _, err := m.Ensure(ctx, nsKeyRef, volKeyRef, nil, nil); err != nil {
+		t.Fatalf("Ensure() with legacy key unexpected error: %v", err)
+	}
+
+	nsSecret.Annotations = map[string]string{UpgradeAnnotationKey: ""}
+	if err := k8s.Update(ctx, nsSecret); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Upgrade(ctx, nsKeyRef); err != nil {
+		t.Fatalf("Upgrade() unexpected error: %v", err)
+	}
+
+	if err := k8s.Get(ctx, volKeyRef, volSecret); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(volSecret.Data[versionDataKey]); got != CurrentFormatVersion {
+		t.Errorf("version = %q, want %q", got, CurrentFormatVersion)
+	}
+	if !bytes.Equal(volSecret.Data["key"], vmk) {
+		t.Error("volume master key changed during upgrade")
+	}
+	if err := m.Validate(ctx, nsKeyRef, volSecret); err != nil {
+		t.Errorf("Validate() after upgrade unexpected error: %v", err)
+	}
+	upgraded := &corev1.Secret{}
+	if err := k8s.Get(ctx, nsKeyRef, upgraded); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := upgraded.Annotations[UpgradeAnnotationKey]; ok {
+		t.Errorf("expected %s annotation to be removed", UpgradeAnnotationKey)
+	}
+
+	// Unknown versions are rejected.
+	volSecret.Data[versionDataKey] = []byte("99")
+	if err := m.Validate(ctx, nsKeyRef, volSecret); !errors.Is(err, ErrInvalidKeySecret) {
+		t.Errorf("Validate() error = %v, want %v", err, ErrInvalidKeySecret)
+	}
+}
@@ -0,0 +1,230 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/storageos/api-manager/internal/pkg/crypto"
+	"github.com/storageos/api-manager/internal/pkg/secret"
+)
+
+const (
+	// LocalKEKProviderName is the name of the local file-based key encryption
+	// key provider.
+	LocalKEKProviderName = "local"
+
+	// TransitKEKProviderName is the name of the transit-style HTTP key
+	// encryption key provider.
+	TransitKEKProviderName = "transit"
+
+	// DefaultTransitMount is the default mount path of the transit secrets
+	// engine.
+	DefaultTransitMount = "transit"
+
+	// kekKeySize is the size of the local key encryption key in bytes.
+	kekKeySize = 32
+)
+
+var (
+	// ErrKEKProviderMismatch is returned when a namespace key was wrapped by a
+	// different key encryption key provider than the one configured.
+	ErrKEKProviderMismatch = errors.New("namespace key wrapped by a different key encryption key provider")
+
+	// ErrInvalidKEK is returned when the local key encryption key is not a
+	// hex-encoded 32 byte key.
+	ErrInvalidKEK = errors.New("key encryption key must be 32 bytes, hex-encoded")
+)
+
+// KEKProvider wraps and unwraps namespace keys using a key encryption key that
+// is held outside of Kubernetes.
+type KEKProvider interface {
+	// Name identifies the provider.  It is stored with each wrapped key so
+	// that keys wrapped by a different provider can be detected.
+	Name() string
+
+	// Wrap encrypts the plaintext key.
+	Wrap(ctx context.Context, plaintext []byte) ([]byte, error)
+
+	// Unwrap decrypts a key previously encrypted with Wrap.
+	Unwrap(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// LocalKEKProvider wraps keys with a key encryption key read from a local file.
+// It is intended for testing, or where the file is mounted from an external
+// secret store.
+type LocalKEKProvider struct {
+	path string
+}
+
+var _ KEKProvider = &LocalKEKProvider{}
+
+// NewLocalKEKProvider returns a key encryption key provider that uses the
+// hex-encoded 32 byte key in the file at path.  The file is read on each
+// request so that the key can be updated without a restart, as long as keys
+// wrapped with the previous key have been re-wrapped.
+func NewLocalKEKProvider(path string) *LocalKEKProvider {
+	return &LocalKEKProvider{path: path}
+}
+
+// Name returns the provider name.
+func (p *LocalKEKProvider) Name() string {
+	return LocalKEKProviderName
+}
+
+// Wrap encrypts the plaintext key with AES-256 GCM.
+func (p *LocalKEKProvider) Wrap(ctx context.Context, plaintext []byte) ([]byte, error) {
+	kek, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.EncryptGCM(plaintext, kek, []byte(LocalKEKProviderName))
+}
+
+// Unwrap decrypts a key previously encrypted with Wrap.
+func (p *LocalKEKProvider) Unwrap(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	kek, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.DecryptGCM(ciphertext, kek, []byte(LocalKEKProviderName))
+}
+
+// key reads the key encryption key from the file.
+func (p *LocalKEKProvider) key() ([]byte, error) {
+	val, err := secret.Read(p.path)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := hex.DecodeString(val)
+	if err != nil || len(kek) != kekKeySize {
+		return nil, ErrInvalidKEK
+	}
+	return kek, nil
+}
+
+// TransitKEKProvider wraps keys using a remote encryption service with a
+// HashiCorp Vault transit-compatible HTTP API.  The key encryption key never
+// leaves the remote service.
+type TransitKEKProvider struct {
+	address   string
+	mount     string
+	keyName   string
+	tokenPath string
+	client    *http.Client
+}
+
+var _ KEKProvider = &TransitKEKProvider{}
+
+// NewTransitKEKProvider returns a key encryption key provider that calls the
+// transit API at address, using the named key in the transit engine mounted at
+// mount.  The API token is read from tokenPath on each request so that it can
+// be refreshed without a restart.
+func NewTransitKEKProvider(address, mount, keyName, tokenPath string, timeout time.Duration) *TransitKEKProvider {
+	if mount == "" {
+		mount = DefaultTransitMount
+	}
+	return &TransitKEKProvider{
+		address:   strings.TrimSuffix(address, "/"),
+		mount:     mount,
+		keyName:   keyName,
+		tokenPath: tokenPath,
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the provider name.
+func (p *TransitKEKProvider) Name() string {
+	return TransitKEKProviderName
+}
+
+// transitRequest is the request body for transit encrypt and decrypt calls.
+type transitRequest struct {
+	Plaintext  string `json:"plaintext,omitempty"`
+	Ciphertext string `json:"ciphertext,omitempty"`
+}
+
+// transitResponse is the response body for transit encrypt and decrypt calls.
+type transitResponse struct {
+	Data struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// Wrap encrypts the plaintext key using the transit encrypt endpoint.  The
+// returned ciphertext is the opaque ciphertext string returned by the service.
+func (p *TransitKEKProvider) Wrap(ctx context.Context, plaintext []byte) ([]byte, error) {
+	resp, err := p.do(ctx, "encrypt", transitRequest{Plaintext: base64.StdEncoding.EncodeToString(plaintext)})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data.Ciphertext == "" {
+		return nil, errors.New("transit encrypt returned no ciphertext")
+	}
+	return []byte(resp.Data.Ciphertext), nil
+}
+
+// Unwrap decrypts a key previously encrypted with Wrap using the transit
+// decrypt endpoint.
+func (p *TransitKEKProvider) Unwrap(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	resp, err := p.do(ctx, "decrypt", transitRequest{Ciphertext: string(ciphertext)})
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
+
+// do calls the transit operation endpoint with the request body.
+func (p *TransitKEKProvider) do(ctx context.Context, op string, body transitRequest) (*transitResponse, error) {
+	token, err := secret.Read(p.tokenPath)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(p.address)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, "v1", p.mount, op, p.keyName)
+
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	out := &transitResponse{}
+	if err := json.Unmarshal(respBody, out); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transit %s failed with status %d: %s", op, resp.StatusCode, strings.Join(out.Errors, ", "))
+	}
+	return out, nil
+}
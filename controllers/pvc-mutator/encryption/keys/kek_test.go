@@ -0,0 +1,238 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/storageos/api-manager/internal/pkg/crypto"
+)
+
+// writeFile writes content to a new file in a temporary directory and returns
+// its path.
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newLocalKEK returns a local key encryption key provider with a random key.
+func newLocalKEK(t *testing.T) *LocalKEKProvider {
+	t.Helper()
+	kek, err := crypto.GenerateUserKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewLocalKEKProvider(writeFile(t, "kek", hex.EncodeToString(kek)+"\n"))
+}
+
+func TestLocalKEKProvider(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	key, err := crypto.GenerateUserKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := newLocalKEK(t)
+	wrapped, err := p.Wrap(ctx, key)
+	if err != nil {
+		t.Fatalf("Wrap() unexpected error: %v", err)
+	}
+	if bytes.Contains(wrapped, key) {
+		t.Error("wrapped key contains plaintext key")
+	}
+	unwrapped, err := p.Unwrap(ctx, wrapped)
+	if err != nil {
+		t.Fatalf("Unwrap() unexpected error: %v", err)
+	}
+	if !bytes.Equal(unwrapped, key) {
+		t.Error("unwrapped key does not match original")
+	}
+
+	// A different key encryption key must not unwrap the key.
+	if _, err := newLocalKEK(t).Unwrap(ctx, wrapped); err == nil {
+		t.Error("expected error unwrapping with a different key encryption key")
+	}
+
+	// Invalid key encryption keys must be rejected.
+	for _, content := range []string{"not hex", "abcd"} {
+		p := NewLocalKEKProvider(writeFile(t, "kek", content))
+		if _, err := p.Wrap(ctx, key); err != ErrInvalidKEK {
+			t.Errorf("Wrap() with key %q error = %v, want %v", content, err, ErrInvalidKEK)
+		}
+	}
+}
+
+func TestTransitKEKProvider(t *testing.T) {
+	t.Parallel()
+
+	const token = "s.token"
+	const keyName = "storageos"
+
+	// Fake transit service that "encrypts" by reversing the base64 plaintext.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		req := transitRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := transitResponse{}
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/" + keyName:
+			resp.Data.Ciphertext = "vault:v1:" + reverse(req.Plaintext)
+		case "/v1/transit/decrypt/" + keyName:
+			resp.Data.Plaintext = reverse(strings.TrimPrefix(req.Ciphertext, "vault:v1:"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	p := NewTransitKEKProvider(srv.URL, "", keyName, writeFile(t, "token", token), time.Second)
+	wrapped, err := p.Wrap(ctx, key)
+	if err != nil {
+		t.Fatalf("Wrap() unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(string(wrapped), "vault:v1:") {
+		t.Errorf("Wrap() = %s, want transit ciphertext", wrapped)
+	}
+	unwrapped, err := p.Unwrap(ctx, wrapped)
+	if err != nil {
+		t.Fatalf("Unwrap() unexpected error: %v", err)
+	}
+	if !bytes.Equal(unwrapped, key) {
+		t.Errorf("Unwrap() = %s, want %s", unwrapped, key)
+	}
+
+	// Bad credentials must return the service error.
+	p = NewTransitKEKProvider(srv.URL, "", keyName, writeFile(t, "token", "wrong"), time.Second)
+	if _, err := p.Wrap(ctx, key); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Wrap() error = %v, want permission denied", err)
+	}
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+func TestKeyManager_KEK(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	nsKeyRef := client.ObjectKey{Name: "storageos-namespace-key", Namespace: "default"}
+	volKeyRef := client.ObjectKey{Name: "vol-1", Namespace: "default"}
+
+	t.Run("namespace key stored wrapped", func(t *testing.T) {
+		k8s := fake.NewClientBuilder().WithScheme(scheme).Build()
+		kek := newLocalKEK(t)
+		m := New(k8s, kek)
+
+		if _, err := m.Ensure(ctx, nsKeyRef, volKeyRef, nil, nil); err != nil {
+			t.Fatalf("Ensure() unexpected error: %v", err)
+		}
+		nsSecret := &corev1.Secret{}
+		if err := k8s.Get(ctx, nsKeyRef, nsSecret); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := nsSecret.Data[keyDataKey]; ok {
+			t.Error("namespace key stored unwrapped")
+		}
+		if got := string(nsSecret.Data[kekProviderDataKey]); got != LocalKEKProviderName {
+			t.Errorf("kek provider = %q, want %q", got, LocalKEKProviderName)
+		}
+		nsKey, err := kek.Unwrap(ctx, nsSecret.Data[wrappedKeyDataKey])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The volume key must be wrapped by the unwrapped namespace key.
+		volSecret := &corev1.Secret{}
+		if err := k8s.Get(ctx, volKeyRef, volSecret); err != nil {
+			t.Fatal(err)
+		}
+		ok, err := unwrapsTo(string(volSecret.Data[versionDataKey]), volSecret.Data["vuk"], volSecret.Data["key"], nsKey, volSecret.Data["iv"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Error("volume key not wrapped by namespace key")
+		}
+
+		// Without the provider, the namespace key can't be used.
+		if _, err := New(k8s, nil).Ensure(ctx, nsKeyRef, client.ObjectKey{Name: "vol-2", Namespace: "default"}, nil, nil); err != ErrKEKProviderMismatch {
+			t.Errorf("Ensure() without provider error = %v, want %v", err, ErrKEKProviderMismatch)
+		}
+	})
+
+	t.Run("unwrapped namespace key migrated on rotation", func(t *testing.T) {
+		k8s := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		// Create keys before the provider was configured.
+		if _, err := New(k8s, nil).Ensure(ctx, nsKeyRef, volKeyRef, nil, nil); err != nil {
+			t.Fatalf("Ensure() unexpected error: %v", err)
+		}
+
+		kek := newLocalKEK(t)
+		m := New(k8s, kek)
+
+		// Unwrapped keys can still be used.
+		if _, err := m.Ensure(ctx, nsKeyRef, client.ObjectKey{Name: "vol-2", Namespace: "default"}, nil, nil); err != nil {
+			t.Fatalf("Ensure() with unwrapped namespace key unexpected error: %v", err)
+		}
+
+		if err := m.Rotate(ctx, nsKeyRef); err != nil {
+			t.Fatalf("Rotate() unexpected error: %v", err)
+		}
+		nsSecret := &corev1.Secret{}
+		if err := k8s.Get(ctx, nsKeyRef, nsSecret); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := nsSecret.Data[keyDataKey]; ok {
+			t.Error("namespace key still stored unwrapped after rotation")
+		}
+		if RotationInProgress(nsSecret) {
+			t.Error("replacement key not removed after rotation")
+		}
+		if _, err := kek.Unwrap(ctx, nsSecret.Data[wrappedKeyDataKey]); err != nil {
+			t.Errorf("failed to unwrap rotated namespace key: %v", err)
+		}
+	})
+}
@@ -2,8 +2,8 @@ package keys
 
 import (
 	"context"
-	"encoding/hex"
 	"errors"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -11,6 +11,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/storageos/api-manager/internal/pkg/crypto"
+	"github.com/storageos/api-manager/internal/pkg/request"
+	"github.com/storageos/api-manager/internal/pkg/version"
 )
 
 var (
@@ -19,72 +21,133 @@ var (
 	ErrNoKeyInSecret = errors.New("secret does not contain encryption key")
 )
 
+const (
+	// keyDataKey is the secret data key used to store an unwrapped key.
+	keyDataKey = "key"
+
+	// wrappedKeyDataKey is the namespace key secret data key used to store
+	// the namespace key when wrapped by a key encryption key provider.
+	wrappedKeyDataKey = "wrapped-key"
+
+	// kekProviderDataKey is the namespace key secret data key used to store
+	// the name of the key encryption key provider that wrapped the key.
+	kekProviderDataKey = "kek-provider"
+
+	// CreatedAtAnnotationKey is set on generated key secrets to record when
+	// the key was generated, in RFC3339 format.
+	CreatedAtAnnotationKey = "storageos.com/key-created-at"
+
+	// CreatedByVersionAnnotationKey is set on generated key secrets to record
+	// the version of the api-manager that generated the key.
+	CreatedByVersionAnnotationKey = "storageos.com/key-created-by-version"
+
+	// RequestUIDAnnotationKey is set on generated key secrets to record the
+	// UID of the admission request that caused the key to be generated.
+	RequestUIDAnnotationKey = "storageos.com/key-admission-request-uid"
+
+	// FormatVersionAnnotationKey is set on volume key secrets to record the
+	// format version of the volume key.
+	FormatVersionAnnotationKey = "storageos.com/key-format-version"
+)
+
+// EnsureResult describes the keys generated by Ensure.
+type EnsureResult struct {
+	// NamespaceKeyCreated is true if a new namespace key was generated.
+	NamespaceKeyCreated bool
+
+	// VolumeKeyCreated is true if a new volume key was generated.  If false,
+	// an existing volume key was validated and reused.
+	VolumeKeyCreated bool
+}
+
 // KeyManager generates, stores and removes encryption keys.
 type KeyManager struct {
 	client client.Client
+	kek    KEKProvider
 }
 
 // New creates a new KeyManager that is responsible for generationg and storing
 // volume encryption keys.  The client should be uncached so that created
 // secrets can be read back immediately.
-func New(client client.Client) *KeyManager {
-	return &KeyManager{client: client}
+//
+// If kek is set, namespace keys are stored wrapped by the key encryption key
+// provider.  Otherwise they are stored unwrapped.
+func New(client client.Client, kek KEKProvider) *KeyManager {
+	RegisterMetrics()
+	return &KeyManager{client: client, kek: kek}
 }
 
 // Ensure that a secret exists at volKeyRef, creating it with valid keys if
-// needed.  If a secret already exists, it does not verify validity.
-func (m *KeyManager) Ensure(ctx context.Context, nsKeyRef client.ObjectKey, volKeyRef client.ObjectKey, nsSecretLabels map[string]string, volSecretLabels map[string]string) error {
-	// Return immediately if the volume key already exists, or an error if
+// needed.  If a secret already exists, it is validated and an error wrapping
+// ErrInvalidKeySecret returned if it does not contain a valid volume key.
+//
+// Generated key secrets are annotated with the time, the api-manager version
+// and, if set in the context, the admission request UID.
+func (m *KeyManager) Ensure(ctx context.Context, nsKeyRef client.ObjectKey, volKeyRef client.ObjectKey, nsSecretLabels map[string]string, volSecretLabels map[string]string) (EnsureResult, error) {
+	result := EnsureResult{}
+
+	// Validate the volume key if it already exists, or return an error if
 	// another error (e.g. permission denied).
-	err := m.client.Get(ctx, volKeyRef, &corev1.Secret{})
+	existing := &corev1.Secret{}
+	err := m.client.Get(ctx, volKeyRef, existing)
 	if err == nil {
-		return nil
+		return result, m.Validate(ctx, nsKeyRef, existing)
 	}
 	if !apierrors.IsNotFound(err) {
-		return err
+		return result, err
 	}
 
 	// We need to generate a new volume key.  Check if the namespace key exists,
 	// and create first if needed.
-	nsKey, err := m.ensureNamespaceKey(ctx, nsKeyRef, nsSecretLabels)
+	nsKey, created, err := m.ensureNamespaceKey(ctx, nsKeyRef, nsSecretLabels)
 	if err != nil {
-		return err
+		return result, err
 	}
-	return m.createVolumeKey(ctx, volKeyRef, nsKey, volSecretLabels)
+	result.NamespaceKeyCreated = created
+
+	if err := m.createVolumeKey(ctx, volKeyRef, nsKey, volSecretLabels); err != nil {
+		return result, err
+	}
+	result.VolumeKeyCreated = true
+	return result, nil
 }
 
-func (m *KeyManager) ensureNamespaceKey(ctx context.Context, nsKeyRef client.ObjectKey, labels map[string]string) (string, error) {
+// ensureNamespaceKey returns the namespace key that new volume keys should be
+// wrapped with, generating it first if needed.  created is true if the key was
+// generated.
+func (m *KeyManager) ensureNamespaceKey(ctx context.Context, nsKeyRef client.ObjectKey, labels map[string]string) (key []byte, created bool, err error) {
 	existing := &corev1.Secret{}
-	err := m.client.Get(ctx, nsKeyRef, existing)
+	err = m.client.Get(ctx, nsKeyRef, existing)
 	if err == nil {
 		// Key exists, use it or error if invalid.
-		return keyFromSecret(existing)
+		key, err = m.wrappingKeyFromSecret(ctx, existing)
+		return key, false, err
 	}
 	if !apierrors.IsNotFound(err) {
-		return "", err
+		return nil, false, err
 	}
 
-	key, err := crypto.GenerateUserKey()
+	key, err = crypto.GenerateUserKey()
 	if err != nil {
-		return "", err
+		return nil, false, err
 	}
 
-	data := map[string][]byte{
-		"key": key,
+	secret := m.secret(nsKeyRef, map[string][]byte{}, labels, creationAnnotations(ctx))
+	if err := m.setNamespaceKey(ctx, secret, keyDataKey, wrappedKeyDataKey, key); err != nil {
+		return nil, false, err
 	}
-
-	secret := m.secret(nsKeyRef, data, labels)
 	if err := m.client.Create(ctx, secret); err != nil {
-		return "", err
+		return nil, false, err
 	}
-	return keyFromSecret(secret)
+	KeysGenerated.Increment(nsKeyRef.Namespace, namespaceKeyType)
+	return key, true, nil
 }
 
 // createVolumeKey generates a new volume key and stores it in a secret at
 // volKeyRef.
 //
 // Will return an error if the secret already exists.
-func (m *KeyManager) createVolumeKey(ctx context.Context, volKeyRef client.ObjectKey, nsKey string, labels map[string]string) error {
+func (m *KeyManager) createVolumeKey(ctx context.Context, volKeyRef client.ObjectKey, nsKey []byte, labels map[string]string) error {
 	// Generate Initialization Vector.
 	iv, err := crypto.GenerateIV()
 	if err != nil {
@@ -97,63 +160,109 @@ func (m *KeyManager) createVolumeKey(ctx context.Context, volKeyRef client.Objec
 		return err
 	}
 
-	nskeyBytes, err := hex.DecodeString(nsKey)
-	if err != nil {
-		return err
-	}
-
-	ik, err := crypto.CreateIK(nskeyBytes, iv)
-	if err != nil {
-		return err
-	}
-
-	// Encrypt the VMK with ik to obtain a Volume User Key.
-	vuk, err := crypto.Encrypt(vmk, ik)
-	if err != nil {
+	// Wrap the VMK with a key derived from the namespace key to obtain a
+	// Volume User Key, and create a HMAC of the VMK.
+	secret := m.secret(volKeyRef, map[string][]byte{}, labels, creationAnnotations(ctx))
+	if err := setVolumeKey(secret, vmk, nsKey, iv); err != nil {
 		return err
 	}
 
-	// Create a HMAC of the VMK.
-	hmac, err := crypto.CreateHMAC(vmk, iv)
-	if err != nil {
+	if err := m.client.Create(ctx, secret); err != nil {
 		return err
 	}
-
-	// Secret contents.
-	data := map[string][]byte{
-		"key":  vmk,
-		"iv":   iv,
-		"vuk":  vuk,
-		"hmac": hmac,
-	}
-
-	return m.client.Create(ctx, m.secret(volKeyRef, data, labels))
+	KeysGenerated.Increment(volKeyRef.Namespace, volumeKeyType)
+	return nil
 }
 
 // secret returns a secret object.  The owner is not set as we don't want keys
 // to be deleted when the api-manager is upgraded.  In the future we can add
 // finalizer-based garbage collection.  For now, there is no garbage collection.
-func (m *KeyManager) secret(key client.ObjectKey, data map[string][]byte, labels map[string]string) *corev1.Secret {
+func (m *KeyManager) secret(key client.ObjectKey, data map[string][]byte, labels map[string]string, annotations map[string]string) *corev1.Secret {
 	return &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Secret",
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      key.Name,
-			Namespace: key.Namespace,
-			Labels:    labels,
+			Name:        key.Name,
+			Namespace:   key.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
 		},
 		Data: data,
 	}
 }
 
-func keyFromSecret(secret *corev1.Secret) (string, error) {
+// creationAnnotations returns the annotations recording how and when a key
+// secret was generated.
+func creationAnnotations(ctx context.Context) map[string]string {
+	annotations := map[string]string{
+		CreatedAtAnnotationKey:        time.Now().UTC().Format(time.RFC3339),
+		CreatedByVersionAnnotationKey: version.Version,
+	}
+	if uid := request.UID(ctx); uid != "" {
+		annotations[RequestUIDAnnotationKey] = string(uid)
+	}
+	return annotations
+}
+
+// wrappingKeyFromSecret returns the namespace key that new volume keys should
+// be wrapped with.  If a rotation is in progress, the replacement key is
+// returned so that the new volume key does not need to be re-wrapped.
+func (m *KeyManager) wrappingKeyFromSecret(ctx context.Context, secret *corev1.Secret) ([]byte, error) {
+	next, err := m.namespaceKey(ctx, secret, nextKeyDataKey, nextWrappedKeyDataKey)
+	if err == nil {
+		return next, nil
+	}
+	if err != ErrNoKeyInSecret {
+		return nil, err
+	}
+	return m.namespaceKey(ctx, secret, keyDataKey, wrappedKeyDataKey)
+}
+
+// namespaceKey returns the namespace key stored in the secret, unwrapping it
+// with the key encryption key provider if it was stored wrapped.  Unwrapped
+// keys are stored at plainKey and wrapped keys at wrappedKey.
+//
+// Unwrapped keys are always returned, even if a key encryption key provider is
+// configured.  This allows keys created before the provider was configured to
+// continue to be used until they are next rotated.
+func (m *KeyManager) namespaceKey(ctx context.Context, secret *corev1.Secret, plainKey string, wrappedKey string) ([]byte, error) {
+	if secret.Data == nil {
+		return nil, ErrNoKeyInSecret
+	}
+	if key, ok := secret.Data[plainKey]; ok && len(key) > 0 {
+		return key, nil
+	}
+	wrapped, ok := secret.Data[wrappedKey]
+	if !ok || len(wrapped) == 0 {
+		return nil, ErrNoKeyInSecret
+	}
+	if m.kek == nil || string(secret.Data[kekProviderDataKey]) != m.kek.Name() {
+		return nil, ErrKEKProviderMismatch
+	}
+	return m.kek.Unwrap(ctx, wrapped)
+}
+
+// setNamespaceKey stores the namespace key in the secret data, wrapping it
+// with the key encryption key provider if configured.  Unwrapped keys are
+// stored at plainKey and wrapped keys at wrappedKey.  The secret is not
+// persisted.
+func (m *KeyManager) setNamespaceKey(ctx context.Context, secret *corev1.Secret, plainKey string, wrappedKey string, key []byte) error {
 	if secret.Data == nil {
-		return "", ErrNoKeyInSecret
+		secret.Data = make(map[string][]byte)
 	}
-	if key, ok := secret.Data["key"]; ok && len(key) > 0 {
-		return hex.EncodeToString(key), nil
+	if m.kek == nil {
+		secret.Data[plainKey] = key
+		delete(secret.Data, wrappedKey)
+		return nil
+	}
+	wrapped, err := m.kek.Wrap(ctx, key)
+	if err != nil {
+		return err
 	}
-	return "", ErrNoKeyInSecret
+	secret.Data[wrappedKey] = wrapped
+	secret.Data[kekProviderDataKey] = []byte(m.kek.Name())
+	delete(secret.Data, plainKey)
+	return nil
 }
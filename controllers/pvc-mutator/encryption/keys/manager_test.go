@@ -0,0 +1,79 @@
+package keys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/storageos/api-manager/internal/pkg/request"
+	"github.com/storageos/api-manager/internal/pkg/version"
+)
+
+func TestKeyManager_Ensure(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := request.WithUID(context.Background(), "req-1")
+	nsKeyRef := client.ObjectKey{Name: "storageos-namespace-key", Namespace: "default"}
+	volKeyRef := client.ObjectKey{Name: "vol-1", Namespace: "default"}
+
+	k8s := fake.NewClientBuilder().WithScheme(scheme).Build()
+	m := New(k8s, nil)
+
+	result, err := m.Ensure(ctx, nsKeyRef, volKeyRef, nil, nil)
+	if err != nil {
+		t.Fatalf("Ensure() unexpected error: %v", err)
+	}
+	if want := (EnsureResult{NamespaceKeyCreated: true, VolumeKeyCreated: true}); result != want {
+		t.Errorf("Ensure() = %+v, want %+v", result, want)
+	}
+
+	for _, ref := range []client.ObjectKey{nsKeyRef, volKeyRef} {
+		secret := &corev1.Secret{}
+		if err := k8s.Get(ctx, ref, secret); err != nil {
+			t.Fatal(err)
+		}
+		annotations := secret.GetAnnotations()
+		if _, err := time.Parse(time.RFC3339, annotations[CreatedAtAnnotationKey]); err != nil {
+			t.Errorf("%s: invalid %s annotation: %v", ref, CreatedAtAnnotationKey, err)
+		}
+		if got := annotations[CreatedByVersionAnnotationKey]; got != version.Version {
+			t.Errorf("%s: %s = %q, want %q", ref, CreatedByVersionAnnotationKey, got, version.Version)
+		}
+		if got := annotations[RequestUIDAnnotationKey]; got != "req-1" {
+			t.Errorf("%s: %s = %q, want %q", ref, RequestUIDAnnotationKey, got, "req-1")
+		}
+		_, hasFormat := annotations[FormatVersionAnnotationKey]
+		if wantFormat := ref == volKeyRef; hasFormat != wantFormat {
+			t.Errorf("%s: %s annotation set = %t, want %t", ref, FormatVersionAnnotationKey, hasFormat, wantFormat)
+		}
+	}
+
+	// A second volume reuses the namespace key.
+	result, err = m.Ensure(ctx, nsKeyRef, client.ObjectKey{Name: "vol-2", Namespace: "default"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Ensure() unexpected error: %v", err)
+	}
+	if want := (EnsureResult{VolumeKeyCreated: true}); result != want {
+		t.Errorf("Ensure() = %+v, want %+v", result, want)
+	}
+
+	// Existing volume keys are validated and reused.
+	result, err = m.Ensure(ctx, nsKeyRef, volKeyRef, nil, nil)
+	if err != nil {
+		t.Fatalf("Ensure() unexpected error: %v", err)
+	}
+	if want := (EnsureResult{}); result != want {
+		t.Errorf("Ensure() = %+v, want %+v", result, want)
+	}
+}
@@ -0,0 +1,55 @@
+package keys
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// namespaceKeyType is the metric key type label value for namespace keys.
+	namespaceKeyType = "namespace"
+
+	// volumeKeyType is the metric key type label value for volume keys.
+	volumeKeyType = "volume"
+)
+
+// GeneratedMetric counts generated keys.
+type GeneratedMetric interface {
+	Increment(namespace string, keyType string)
+}
+
+var (
+	// KeysGenerated counts the encryption keys generated, by namespace and
+	// key type.
+	KeysGenerated GeneratedMetric = &generatedAdapter{m: keysGeneratedCounter}
+
+	// registerMetricsOnce keeps track of metrics registration.
+	registerMetricsOnce sync.Once
+)
+
+var (
+	keysGeneratedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "storageos_encryption_keys_generated_total",
+			Help: "Number of encryption keys generated, partitioned by namespace and key type.",
+		},
+		[]string{"namespace", "type"},
+	)
+)
+
+// RegisterMetrics ensures that the package metrics are registered.
+func RegisterMetrics() {
+	registerMetricsOnce.Do(func() {
+		metrics.Registry.MustRegister(keysGeneratedCounter)
+	})
+}
+
+type generatedAdapter struct {
+	m *prometheus.CounterVec
+}
+
+func (g *generatedAdapter) Increment(namespace string, keyType string) {
+	g.m.WithLabelValues(namespace, keyType).Inc()
+}
@@ -0,0 +1,181 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/crypto"
+)
+
+const (
+	// RotateAnnotationKey can be set on a namespace key secret to request that
+	// the namespace key is rotated.  It is removed once rotation completes.
+	RotateAnnotationKey = "storageos.com/rotate-key"
+
+	// RotatedAtAnnotationKey is set on the namespace key secret to record the
+	// time that the namespace key was last rotated, in RFC3339 format.
+	RotatedAtAnnotationKey = "storageos.com/key-rotated-at"
+
+	// nextKeyDataKey is the namespace key secret data key used to store the
+	// replacement namespace key while a rotation is in progress.
+	nextKeyDataKey = "next-key"
+
+	// nextWrappedKeyDataKey is the namespace key secret data key used to
+	// store the replacement namespace key while a rotation is in progress,
+	// when wrapped by a key encryption key provider.
+	nextWrappedKeyDataKey = "next-wrapped-key"
+)
+
+var (
+	// ErrVolumeKeyNotWrapped is returned when a volume key secret could not be
+	// unwrapped using either the current or the replacement namespace key.
+	ErrVolumeKeyNotWrapped = errors.New("volume key not wrapped by namespace key")
+)
+
+// Rotate replaces the namespace key stored at nsKeyRef, re-wrapping the volume
+// master key in each volume key secret in the namespace with the new key.  The
+// volume master keys themselves are not changed, so no volume data needs to be
+// re-encrypted.  Volume keys in an older format are upgraded to the current
+// format as they are re-wrapped.
+//
+// Rotation is resumable.  The replacement key is persisted in the namespace
+// key secret before any volume key secret is modified, and each volume key
+// secret is updated atomically.  If rotation is interrupted it can be called
+// again and will continue with the same replacement key, skipping volume key
+// secrets that have already been re-wrapped.  The replacement key is only
+// promoted once all volume key secrets have been re-wrapped.
+func (m *KeyManager) Rotate(ctx context.Context, nsKeyRef client.ObjectKey) error {
+	nsSecret := &corev1.Secret{}
+	if err := m.client.Get(ctx, nsKeyRef, nsSecret); err != nil {
+		return err
+	}
+	current, err := m.namespaceKey(ctx, nsSecret, keyDataKey, wrappedKeyDataKey)
+	if err != nil {
+		return err
+	}
+
+	// Persist the replacement key first so that an interrupted rotation can be
+	// resumed with the same key.
+	next, err := m.namespaceKey(ctx, nsSecret, nextKeyDataKey, nextWrappedKeyDataKey)
+	if err == ErrNoKeyInSecret {
+		next, err = crypto.GenerateUserKey()
+		if err != nil {
+			return err
+		}
+		if err := m.setNamespaceKey(ctx, nsSecret, nextKeyDataKey, nextWrappedKeyDataKey, next); err != nil {
+			return err
+		}
+		if err := m.client.Update(ctx, nsSecret); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	secrets := &corev1.SecretList{}
+	if err := m.client.List(ctx, secrets, client.InNamespace(nsKeyRef.Namespace)); err != nil {
+		return err
+	}
+
+	var failed []string
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if !isVolumeKeySecret(secret) {
+			continue
+		}
+		if err := m.rewrap(ctx, secret, current, next); err != nil {
+			if errors.Is(err, ErrVolumeKeyNotWrapped) {
+				failed = append(failed, secret.GetName())
+				continue
+			}
+			return fmt.Errorf("failed to re-wrap volume key %s: %w", secret.GetName(), err)
+		}
+	}
+
+	// Don't promote the replacement key if any volume key would be left
+	// unrecoverable.  Rotation will be retried once the secrets are fixed or
+	// removed.
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", ErrVolumeKeyNotWrapped, strings.Join(failed, ", "))
+	}
+
+	// Promote the replacement key and mark rotation complete.  Keys that were
+	// stored unwrapped before a key encryption key provider was configured are
+	// stored wrapped from now on.
+	if err := m.setNamespaceKey(ctx, nsSecret, keyDataKey, wrappedKeyDataKey, next); err != nil {
+		return err
+	}
+	delete(nsSecret.Data, nextKeyDataKey)
+	delete(nsSecret.Data, nextWrappedKeyDataKey)
+	if nsSecret.Annotations == nil {
+		nsSecret.Annotations = make(map[string]string)
+	}
+	delete(nsSecret.Annotations, RotateAnnotationKey)
+	delete(nsSecret.Annotations, UpgradeAnnotationKey)
+	nsSecret.Annotations[RotatedAtAnnotationKey] = time.Now().UTC().Format(time.RFC3339)
+
+	return m.client.Update(ctx, nsSecret)
+}
+
+// rewrap updates the volume key secret so that the vuk is wrapped with the
+// next namespace key, in the current format version.  Secrets that are already
+// wrapped with the next namespace key in the current format are left unchanged.
+func (m *KeyManager) rewrap(ctx context.Context, secret *corev1.Secret, current []byte, next []byte) error {
+	version, err := formatVersion(secret)
+	if err != nil {
+		return err
+	}
+	vmk := secret.Data["key"]
+	iv := secret.Data["iv"]
+	vuk := secret.Data["vuk"]
+
+	// Skip if already re-wrapped by a previous attempt.
+	if ok, err := unwrapsTo(version, vuk, vmk, next, iv); err != nil || ok && version == CurrentFormatVersion {
+		return err
+	}
+
+	// Only re-wrap keys that were wrapped by the current namespace key, or by
+	// the next key in an older format.  Anything else is foreign or corrupt.
+	ok, err := unwrapsTo(version, vuk, vmk, current, iv)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if ok, err = unwrapsTo(version, vuk, vmk, next, iv); err != nil {
+			return err
+		}
+	}
+	if !ok {
+		return ErrVolumeKeyNotWrapped
+	}
+
+	if err := setVolumeKey(secret, vmk, next, iv); err != nil {
+		return err
+	}
+
+	// The update will fail if the secret was modified since it was read.
+	return m.client.Update(ctx, secret)
+}
+
+// isVolumeKeySecret returns true if the secret has the fields of a volume key
+// secret.
+func isVolumeKeySecret(secret *corev1.Secret) bool {
+	for _, k := range []string{"key", "iv", "vuk"} {
+		if len(secret.Data[k]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// RotationInProgress returns true if the namespace key secret has a
+// replacement key that has not yet been promoted.
+func RotationInProgress(secret *corev1.Secret) bool {
+	return len(secret.Data[nextKeyDataKey]) > 0 || len(secret.Data[nextWrappedKeyDataKey]) > 0
+}
@@ -0,0 +1,188 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/storageos/api-manager/internal/pkg/crypto"
+)
+
+func TestKeyManager_Rotate(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	const namespace = "default"
+	nsKeyRef := client.ObjectKey{Name: "storageos-namespace-key", Namespace: namespace}
+	volKeyRefs := []client.ObjectKey{
+		{Name: "vol-1", Namespace: namespace},
+		{Name: "vol-2", Namespace: namespace},
+	}
+
+	tests := []struct {
+		name string
+		// prepare is run after the keys have been created.
+		prepare func(t *testing.T, k8s client.Client)
+		wantErr error
+	}{
+		{
+			name: "rotate",
+		},
+		{
+			name: "resume interrupted rotation",
+			prepare: func(t *testing.T, k8s client.Client) {
+				// Persist a replacement key and re-wrap one volume key, as if
+				// a previous attempt had stopped part-way through.
+				m := New(k8s, nil)
+				nsSecret := &corev1.Secret{}
+				if err := k8s.Get(context.Background(), nsKeyRef, nsSecret); err != nil {
+					t.Fatal(err)
+				}
+				next, err := crypto.GenerateUserKey()
+				if err != nil {
+					t.Fatal(err)
+				}
+				nsSecret.Data[nextKeyDataKey] = next
+				if err := k8s.Update(context.Background(), nsSecret); err != nil {
+					t.Fatal(err)
+				}
+				volSecret := &corev1.Secret{}
+				if err := k8s.Get(context.Background(), volKeyRefs[0], volSecret); err != nil {
+					t.Fatal(err)
+				}
+				if err := m.rewrap(context.Background(), volSecret, nsSecret.Data["key"], next); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "legacy format volume key upgraded",
+			prepare: func(t *testing.T, k8s client.Client) {
+				nsSecret := &corev1.Secret{}
+				if err := k8s.Get(context.Background(), nsKeyRef, nsSecret); err != nil {
+					t.Fatal(err)
+				}
+				volSecret := &corev1.Secret{}
+				if err := k8s.Get(context.Background(), volKeyRefs[0], volSecret); err != nil {
+					t.Fatal(err)
+				}
+				vuk, err := wrapVMK(FormatVersionCBC, volSecret.Data["key"], nsSecret.Data["key"], volSecret.Data["iv"])
+				if err != nil {
+					t.Fatal(err)
+				}
+				volSecret.Data["vuk"] = vuk
+				delete(volSecret.Data, versionDataKey)
+				if err := k8s.Update(context.Background(), volSecret); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "foreign volume key blocks promotion",
+			prepare: func(t *testing.T, k8s client.Client) {
+				volSecret := &corev1.Secret{}
+				if err := k8s.Get(context.Background(), volKeyRefs[1], volSecret); err != nil {
+					t.Fatal(err)
+				}
+				vuk, err := crypto.GenerateRandomBytes(len(volSecret.Data["vuk"]))
+				if err != nil {
+					t.Fatal(err)
+				}
+				volSecret.Data["vuk"] = vuk
+				if err := k8s.Update(context.Background(), volSecret); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrVolumeKeyNotWrapped,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			k8s := fake.NewClientBuilder().WithScheme(scheme).Build()
+			m := New(k8s, nil)
+
+			for _, ref := range volKeyRefs {
+				if _, err := m.Ensure(ctx, nsKeyRef, ref, nil, nil); err != nil {
+					t.Fatalf("failed to create keys: %v", err)
+				}
+			}
+			before := &corev1.Secret{}
+			if err := k8s.Get(ctx, nsKeyRef, before); err != nil {
+				t.Fatal(err)
+			}
+			if tt.prepare != nil {
+				tt.prepare(t, k8s)
+			}
+
+			err := m.Rotate(ctx, nsKeyRef)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Rotate() error = %v, want %v", err, tt.wantErr)
+				}
+				// The original key must still be current.
+				after := &corev1.Secret{}
+				if err := k8s.Get(ctx, nsKeyRef, after); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(after.Data["key"], before.Data["key"]) {
+					t.Error("namespace key promoted despite failure")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rotate() unexpected error: %v", err)
+			}
+
+			after := &corev1.Secret{}
+			if err := k8s.Get(ctx, nsKeyRef, after); err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(after.Data["key"], before.Data["key"]) {
+				t.Error("namespace key was not changed")
+			}
+			if _, ok := after.Data[nextKeyDataKey]; ok {
+				t.Error("replacement key not removed after rotation")
+			}
+			if after.Annotations[RotatedAtAnnotationKey] == "" {
+				t.Errorf("expected %s annotation to be set", RotatedAtAnnotationKey)
+			}
+
+			for _, ref := range volKeyRefs {
+				volSecret := &corev1.Secret{}
+				if err := k8s.Get(ctx, ref, volSecret); err != nil {
+					t.Fatal(err)
+				}
+				ok, err := unwrapsTo(string(volSecret.Data[versionDataKey]), volSecret.Data["vuk"], volSecret.Data["key"], after.Data["key"], volSecret.Data["iv"])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					t.Errorf("volume key %s not wrapped with new namespace key", ref.Name)
+				}
+				if got := string(volSecret.Data[versionDataKey]); got != CurrentFormatVersion {
+					t.Errorf("volume key %s version = %q, want %q", ref.Name, got, CurrentFormatVersion)
+				}
+				valid, err := crypto.CheckHMAC(volSecret.Data["key"], volSecret.Data["hmac"], volSecret.Data["iv"])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !valid {
+					t.Errorf("volume key %s has invalid hmac", ref.Name)
+				}
+			}
+		})
+	}
+}
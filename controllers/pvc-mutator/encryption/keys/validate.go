@@ -0,0 +1,120 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/crypto"
+)
+
+const (
+	// volumeKeySize is the size of the volume master key in bytes.
+	volumeKeySize = 64
+
+	// ivSize is the size of the initialization vector in bytes.
+	ivSize = 32
+
+	// hmacSize is the size of the volume master key HMAC in bytes.
+	hmacSize = 32
+)
+
+// ErrInvalidKeySecret is returned when an existing volume key secret does not
+// contain a valid volume key.
+var ErrInvalidKeySecret = errors.New("invalid volume encryption key secret")
+
+// Validate checks that the volume key secret contains a complete and
+// consistent volume key.  The format version, and the presence and size of
+// each field are checked, as is the HMAC of the volume master key.
+//
+// If the namespace key secret at nsKeyRef exists and the namespace key can be
+// read, the volume user key must also decrypt to the volume master key.  While
+// a rotation is in progress, either the current or replacement namespace key
+// is accepted.
+//
+// Validation failures are returned wrapping ErrInvalidKeySecret.
+func (m *KeyManager) Validate(ctx context.Context, nsKeyRef client.ObjectKey, secret *corev1.Secret) error {
+	invalid := func(format string, a ...interface{}) error {
+		return fmt.Errorf("%w %s/%s: %s", ErrInvalidKeySecret, secret.GetNamespace(), secret.GetName(), fmt.Sprintf(format, a...))
+	}
+
+	version, err := formatVersion(secret)
+	if err != nil {
+		return invalid("%v", err)
+	}
+
+	for _, field := range []struct {
+		name string
+		size int
+	}{
+		{name: "key", size: volumeKeySize},
+		{name: "iv", size: ivSize},
+		{name: "vuk", size: vukSize(version)},
+		{name: "hmac", size: hmacSize},
+	} {
+		val, ok := secret.Data[field.name]
+		if !ok || len(val) == 0 {
+			return invalid("missing %q", field.name)
+		}
+		if len(val) != field.size {
+			return invalid("%q must be %d bytes, got %d", field.name, field.size, len(val))
+		}
+	}
+
+	vmk, iv, vuk := secret.Data["key"], secret.Data["iv"], secret.Data["vuk"]
+
+	valid, err := crypto.CheckHMAC(vmk, secret.Data["hmac"], iv)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return invalid("hmac does not match key")
+	}
+
+	nsKeys, err := m.availableNamespaceKeys(ctx, nsKeyRef)
+	if err != nil {
+		return err
+	}
+	if len(nsKeys) == 0 {
+		return nil
+	}
+	for _, nsKey := range nsKeys {
+		ok, err := unwrapsTo(version, vuk, vmk, nsKey, iv)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return invalid("vuk was not created from key with the namespace key in %s", nsKeyRef.Name)
+}
+
+// availableNamespaceKeys returns the current and, if a rotation is in progress,
+// replacement namespace keys.  Keys that can't be read, for example because
+// they were wrapped by a different key encryption key provider, are omitted.
+// No keys are returned if the namespace key secret does not exist.
+func (m *KeyManager) availableNamespaceKeys(ctx context.Context, nsKeyRef client.ObjectKey) ([][]byte, error) {
+	nsSecret := &corev1.Secret{}
+	if err := m.client.Get(ctx, nsKeyRef, nsSecret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var nsKeys [][]byte
+	for _, k := range []struct{ plain, wrapped string }{
+		{plain: keyDataKey, wrapped: wrappedKeyDataKey},
+		{plain: nextKeyDataKey, wrapped: nextWrappedKeyDataKey},
+	} {
+		if nsKey, err := m.namespaceKey(ctx, nsSecret, k.plain, k.wrapped); err == nil {
+			nsKeys = append(nsKeys, nsKey)
+		}
+	}
+	return nsKeys, nil
+}
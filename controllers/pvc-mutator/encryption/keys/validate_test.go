@@ -0,0 +1,153 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/storageos/api-manager/internal/pkg/crypto"
+)
+
+func TestKeyManager_Validate(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	const namespace = "default"
+	nsKeyRef := client.ObjectKey{Name: "storageos-namespace-key", Namespace: namespace}
+	volKeyRef := client.ObjectKey{Name: "vol-1", Namespace: namespace}
+
+	randomBytes := func(t *testing.T, n int) []byte {
+		b, err := crypto.GenerateRandomBytes(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name string
+		// modify is run on the namespace and volume key secrets after they
+		// have been created, and before validation.
+		modify  func(t *testing.T, nsSecret, volSecret *corev1.Secret)
+		wantErr bool
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "missing key",
+			modify: func(t *testing.T, nsSecret, volSecret *corev1.Secret) {
+				delete(volSecret.Data, "key")
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing hmac",
+			modify: func(t *testing.T, nsSecret, volSecret *corev1.Secret) {
+				delete(volSecret.Data, "hmac")
+			},
+			wantErr: true,
+		},
+		{
+			name: "short iv",
+			modify: func(t *testing.T, nsSecret, volSecret *corev1.Secret) {
+				volSecret.Data["iv"] = volSecret.Data["iv"][:16]
+			},
+			wantErr: true,
+		},
+		{
+			name: "long vuk",
+			modify: func(t *testing.T, nsSecret, volSecret *corev1.Secret) {
+				volSecret.Data["vuk"] = append(volSecret.Data["vuk"], 0)
+			},
+			wantErr: true,
+		},
+		{
+			name: "hmac mismatch",
+			modify: func(t *testing.T, nsSecret, volSecret *corev1.Secret) {
+				volSecret.Data["hmac"] = randomBytes(t, hmacSize)
+			},
+			wantErr: true,
+		},
+		{
+			name: "vuk from foreign namespace key",
+			modify: func(t *testing.T, nsSecret, volSecret *corev1.Secret) {
+				nsSecret.Data[keyDataKey] = randomBytes(t, 32)
+			},
+			wantErr: true,
+		},
+		{
+			name: "vuk from replacement namespace key",
+			modify: func(t *testing.T, nsSecret, volSecret *corev1.Secret) {
+				next := randomBytes(t, 32)
+				vuk, err := wrapVMK(CurrentFormatVersion, volSecret.Data["key"], next, volSecret.Data["iv"])
+				if err != nil {
+					t.Fatal(err)
+				}
+				volSecret.Data["vuk"] = vuk
+				nsSecret.Data[nextKeyDataKey] = next
+			},
+		},
+		{
+			name: "namespace key unavailable",
+			modify: func(t *testing.T, nsSecret, volSecret *corev1.Secret) {
+				nsSecret.Data = map[string][]byte{
+					wrappedKeyDataKey:  randomBytes(t, 60),
+					kekProviderDataKey: []byte(TransitKEKProviderName),
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			k8s := fake.NewClientBuilder().WithScheme(scheme).Build()
+			m := New(k8s, nil)
+
+			if _, err := m.Ensure(ctx, nsKeyRef, volKeyRef, nil, nil); err != nil {
+				t.Fatalf("failed to create keys: %v", err)
+			}
+			nsSecret := &corev1.Secret{}
+			if err := k8s.Get(ctx, nsKeyRef, nsSecret); err != nil {
+				t.Fatal(err)
+			}
+			volSecret := &corev1.Secret{}
+			if err := k8s.Get(ctx, volKeyRef, volSecret); err != nil {
+				t.Fatal(err)
+			}
+			if tt.modify != nil {
+				tt.modify(t, nsSecret, volSecret)
+				if err := k8s.Update(ctx, nsSecret); err != nil {
+					t.Fatal(err)
+				}
+				if err := k8s.Update(ctx, volSecret); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := m.Ensure(ctx, nsKeyRef, volKeyRef, nil, nil)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidKeySecret) {
+					t.Errorf("Ensure() error = %v, want %v", err, ErrInvalidKeySecret)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Ensure() unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -11,6 +11,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ErrStorageClassChanged is returned if a pvc update changes the StorageClass
+// after the volume has been provisioned.
+var ErrStorageClassChanged = errors.New("storageclass can't be changed after the volume has been provisioned")
+
 // AnnotationSetter is responsible to add an annotation
 // to link the StorageClass with the PVC.
 type AnnotationSetter struct {
@@ -27,6 +31,11 @@ func NewAnnotationSetter(k8s client.Client) *AnnotationSetter {
 	}
 }
 
+// Name returns the name of the mutator.
+func (s *AnnotationSetter) Name() string {
+	return "storageclass"
+}
+
 // MutatePVC mutates a given pvc with a new annotation,
 // by attached StorageClass.
 //
@@ -57,3 +66,49 @@ func (s *AnnotationSetter) MutatePVC(ctx context.Context, pvc *corev1.Persistent
 	log.Info("set StorageClass UID as annotation", "pvc", pvc.Name, "uid", string(storageClass.UID))
 	return nil
 }
+
+// MutatePVCUpdate keeps the StorageClass UID annotation consistent when a pvc
+// is updated.
+//
+// The StorageClass can only be changed with the beta StorageClass annotation,
+// as the StorageClass name in the spec is immutable.  Changing it once the pvc
+// is bound is rejected.  Before then, the UID annotation is set from the new
+// StorageClass.  If an update removes or changes the UID annotation, it is
+// restored.
+//
+// Errors returned here will block the update of the PVC.
+func (s *AnnotationSetter) MutatePVCUpdate(ctx context.Context, old *corev1.PersistentVolumeClaim, pvc *corev1.PersistentVolumeClaim, namespace string) error {
+	log := s.log.WithValues("pvc", client.ObjectKeyFromObject(pvc).String())
+	log.V(4).Info("received pvc update for mutation")
+
+	// Don't block updates if the StorageClass can't be found, as the pvc
+	// can't be provisioned until it exists.
+	isStorageOS, err := provisioner.IsStorageOSPVC(s.Client, old)
+	if err != nil {
+		log.V(4).Info("unable to determine pvc provisioner, skipping", "error", err.Error())
+		return nil
+	}
+	if !isStorageOS {
+		log.V(4).Info("pvc not provisioned by StorageOS, skipping")
+		return nil
+	}
+
+	if oldName, newName := provisioner.PVCStorageClassName(old), provisioner.PVCStorageClassName(pvc); oldName != newName {
+		if provisioner.IsBound(old) {
+			return errors.Wrapf(ErrStorageClassChanged, "pvc storageclass changed from %q to %q", oldName, newName)
+		}
+		return s.MutatePVC(ctx, pvc, namespace)
+	}
+
+	uid, ok := old.GetAnnotations()[provisioner.StorageClassUUIDAnnotationKey]
+	if !ok || pvc.GetAnnotations()[provisioner.StorageClassUUIDAnnotationKey] == uid {
+		return nil
+	}
+	if pvc.Annotations == nil {
+		pvc.Annotations = make(map[string]string)
+	}
+	pvc.Annotations[provisioner.StorageClassUUIDAnnotationKey] = uid
+
+	log.Info("restored StorageClass UID annotation", "uid", uid)
+	return nil
+}
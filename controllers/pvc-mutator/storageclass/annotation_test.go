@@ -2,6 +2,7 @@ package storageclass
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/storageos/api-manager/internal/pkg/provisioner"
@@ -185,6 +186,98 @@ func TestMutatePVC(t *testing.T) {
 	}
 }
 
+func TestMutatePVCUpdate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	fastSC := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{UID: types.UID("fast-uid"), Name: "fast"},
+		Provisioner: provisioner.DriverName,
+	}
+	slowSC := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{UID: types.UID("slow-uid"), Name: "slow"},
+		Provisioner: provisioner.DriverName,
+	}
+	fooSC := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{UID: types.UID("foo-uid"), Name: "foo"},
+		Provisioner: "foo-provisioner",
+	}
+
+	// The beta annotation is the only way to change the StorageClass of an
+	// existing pvc.
+	const betaKey = "volume.beta.kubernetes.io/storage-class"
+
+	testcases := []struct {
+		name           string
+		bound          bool
+		oldAnnotations map[string]string
+		annotations    map[string]string
+		wantErr        error
+		wantUID        string
+	}{
+		{
+			name:           "storageclass changed before provisioning",
+			oldAnnotations: map[string]string{betaKey: "fast", provisioner.StorageClassUUIDAnnotationKey: "fast-uid"},
+			annotations:    map[string]string{betaKey: "slow", provisioner.StorageClassUUIDAnnotationKey: "fast-uid"},
+			wantUID:        "slow-uid",
+		},
+		{
+			name:           "storageclass changed after provisioning",
+			bound:          true,
+			oldAnnotations: map[string]string{betaKey: "fast", provisioner.StorageClassUUIDAnnotationKey: "fast-uid"},
+			annotations:    map[string]string{betaKey: "slow", provisioner.StorageClassUUIDAnnotationKey: "fast-uid"},
+			wantErr:        ErrStorageClassChanged,
+			wantUID:        "fast-uid",
+		},
+		{
+			name:           "uid annotation removed",
+			bound:          true,
+			oldAnnotations: map[string]string{betaKey: "fast", provisioner.StorageClassUUIDAnnotationKey: "fast-uid"},
+			annotations:    map[string]string{betaKey: "fast"},
+			wantUID:        "fast-uid",
+		},
+		{
+			name:           "uid annotation never set",
+			bound:          true,
+			oldAnnotations: map[string]string{betaKey: "fast"},
+			annotations:    map[string]string{betaKey: "fast"},
+		},
+		{
+			name:           "non-storageos pvc",
+			bound:          true,
+			oldAnnotations: map[string]string{betaKey: "foo"},
+			annotations:    map[string]string{betaKey: "fast"},
+		},
+	}
+
+	for _, tc := range testcases {
+		var tc = tc
+		t.Run(tc.name, func(t *testing.T) {
+			k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(fastSC, slowSC, fooSC).Build()
+			annotationSetter := NewAnnotationSetter(k8s)
+
+			old := createPVC("pvc1", "default", nil)
+			old.Annotations = tc.oldAnnotations
+			pvc := createPVC("pvc1", "default", nil)
+			pvc.Annotations = tc.annotations
+			if tc.bound {
+				old.Spec.VolumeName = "pv1"
+				pvc.Spec.VolumeName = "pv1"
+			}
+
+			err := annotationSetter.MutatePVCUpdate(context.Background(), old, pvc, "default")
+			if !errors.Is(err, tc.wantErr) || (err != nil && tc.wantErr == nil) {
+				t.Fatalf("MutatePVCUpdate() error = %v, want %v", err, tc.wantErr)
+			}
+			if got := pvc.GetAnnotations()[provisioner.StorageClassUUIDAnnotationKey]; got != tc.wantUID {
+				t.Errorf("expected uid annotation %q, got %q", tc.wantUID, got)
+			}
+		})
+	}
+}
+
 // createPVC creates and returns a PVC object.
 func createPVC(name, namespace string, storageClass *storagev1.StorageClass) *corev1.PersistentVolumeClaim {
 	pvc := &corev1.PersistentVolumeClaim{
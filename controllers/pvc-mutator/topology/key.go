@@ -0,0 +1,112 @@
+package topology
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// KeySetter sets the topology key on PVCs that request topology-aware
+// placement, so that replicas are spread across the failure domains set on
+// nodes by the node label sync.
+type KeySetter struct {
+	client.Client
+	log logr.Logger
+}
+
+// NewKeySetter returns a new PVC topology key mutating admission controller.
+func NewKeySetter(k8s client.Client) *KeySetter {
+	return &KeySetter{
+		Client: k8s,
+		log:    ctrl.Log.WithName("topology"),
+	}
+}
+
+// Name returns the name of the mutator.
+func (s *KeySetter) Name() string {
+	return "topology"
+}
+
+// MutatePVC sets the `storageos.com/topology-key` label to the failure domain
+// node label if topology-aware placement has been requested with the PVC label
+// or StorageClass parameter, and no topology key has been set in either.
+//
+// Errors returned here may block creation of the PVC, depending on the
+// FailurePolicy set in the webhook configuration.
+func (s *KeySetter) MutatePVC(ctx context.Context, pvc *corev1.PersistentVolumeClaim, namespace string) error {
+	log := s.log.WithValues("pvc", client.ObjectKeyFromObject(pvc).String())
+	log.V(4).Info("received pvc for mutation")
+
+	// Find StorageClass of PVC.
+	storageClass, err := provisioner.StorageClassForPVC(s.Client, pvc)
+	if err != nil {
+		return errors.Wrap(err, "failed to check pvc provisioner")
+	}
+
+	// Skip mutation if the PVC will not be provisioned by StorageOS.
+	if provisioned := provisioner.IsProvisionedStorageClass(storageClass, provisioner.DriverName); !provisioned {
+		log.V(4).Info("pvc will not be provisioned by StorageOS, skipping")
+		return nil
+	}
+
+	// The PVC label takes precedence over the StorageClass parameter.
+	enabled, err := topologyAware(pvc.GetLabels(), storageClass.Parameters)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse boolean value for %q pvc label or storageclass parameter", storageos.ReservedLabelTopologyAware)
+	}
+	if !enabled {
+		log.V(4).Info("pvc does not have topology-aware placement enabled, skipping")
+		return nil
+	}
+	if _, ok := pvc.GetLabels()[storageos.ReservedLabelTopologyKey]; ok {
+		return nil
+	}
+	if _, ok := storageClass.Parameters[storageos.ReservedLabelTopologyKey]; ok {
+		return nil
+	}
+
+	if pvc.Labels == nil {
+		pvc.Labels = make(map[string]string)
+	}
+	pvc.Labels[storageos.ReservedLabelTopologyKey] = storageos.LabelFailureDomain
+
+	log.Info("set topology key", "key", storageos.LabelFailureDomain)
+	return nil
+}
+
+// MutatePVCUpdate sets the topology key if topology-aware placement is enabled
+// by an update, before the pvc is bound.  Topology labels are only read when
+// the volume is provisioned, so later updates are ignored.
+//
+// Errors returned here will block the update of the PVC.
+func (s *KeySetter) MutatePVCUpdate(ctx context.Context, old *corev1.PersistentVolumeClaim, pvc *corev1.PersistentVolumeClaim, namespace string) error {
+	if provisioner.IsBound(old) {
+		return nil
+	}
+
+	// Don't block updates if the StorageClass can't be found, as the pvc
+	// can't be provisioned until it exists.
+	if _, err := provisioner.StorageClassForPVC(s.Client, pvc); err != nil {
+		s.log.V(4).Info("unable to determine pvc storageclass, skipping", "pvc", client.ObjectKeyFromObject(pvc).String(), "error", err.Error())
+		return nil
+	}
+	return s.MutatePVC(ctx, pvc, namespace)
+}
+
+// topologyAware returns the value of the topology-aware label in the first of
+// the maps that has it set, or false if none do.
+func topologyAware(hayStacks ...map[string]string) (bool, error) {
+	for _, hayStack := range hayStacks {
+		if val, ok := hayStack[storageos.ReservedLabelTopologyAware]; ok {
+			return strconv.ParseBool(val)
+		}
+	}
+	return false, nil
+}
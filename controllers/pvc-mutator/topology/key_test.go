@@ -0,0 +1,244 @@
+package topology
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMutatePVC(t *testing.T) {
+	t.Parallel()
+
+	// Create a new scheme and add all the types from different clientsets.
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	// StorageOS StorageClass.
+	stosSC := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "stos",
+		},
+		Provisioner: provisioner.DriverName,
+	}
+
+	// StorageOS StorageClass with topology-aware placement enabled.
+	topologySC := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "stos-topology",
+		},
+		Provisioner: provisioner.DriverName,
+		Parameters: map[string]string{
+			storageos.ReservedLabelTopologyAware: "true",
+		},
+	}
+
+	// StorageOS StorageClass with topology-aware placement and key set.
+	topologyKeySC := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "stos-topology-key",
+		},
+		Provisioner: provisioner.DriverName,
+		Parameters: map[string]string{
+			storageos.ReservedLabelTopologyAware: "true",
+			storageos.ReservedLabelTopologyKey:   corev1.LabelTopologyZone,
+		},
+	}
+
+	// Non-StorageOS StorageClass.
+	notStosSC := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "non-stos",
+		},
+		Provisioner: "foo-provisioner",
+		Parameters: map[string]string{
+			storageos.ReservedLabelTopologyAware: "true",
+		},
+	}
+
+	testcases := []struct {
+		name         string
+		labels       map[string]string
+		storageClass *storagev1.StorageClass
+		wantLabels   map[string]string
+		wantErr      bool
+	}{
+		{
+			name:         "not requested",
+			storageClass: stosSC,
+		},
+		{
+			name: "pvc label",
+			labels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "true",
+			},
+			storageClass: stosSC,
+			wantLabels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "true",
+				storageos.ReservedLabelTopologyKey:   storageos.LabelFailureDomain,
+			},
+		},
+		{
+			name:         "storageclass parameter",
+			storageClass: topologySC,
+			wantLabels: map[string]string{
+				storageos.ReservedLabelTopologyKey: storageos.LabelFailureDomain,
+			},
+		},
+		{
+			name: "pvc label disables storageclass parameter",
+			labels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "false",
+			},
+			storageClass: topologySC,
+			wantLabels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "false",
+			},
+		},
+		{
+			name: "pvc topology key",
+			labels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "true",
+				storageos.ReservedLabelTopologyKey:   corev1.LabelHostname,
+			},
+			storageClass: stosSC,
+			wantLabels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "true",
+				storageos.ReservedLabelTopologyKey:   corev1.LabelHostname,
+			},
+		},
+		{
+			name:         "storageclass topology key",
+			storageClass: topologyKeySC,
+		},
+		{
+			name: "invalid topology-aware value",
+			labels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "maybe",
+			},
+			storageClass: stosSC,
+			wantLabels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "maybe",
+			},
+			wantErr: true,
+		},
+		{
+			name:         "foreign pvc",
+			storageClass: notStosSC,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			setter := KeySetter{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(stosSC, topologySC, topologyKeySC, notStosSC).Build(),
+				log:    ctrl.Log,
+			}
+
+			scName := tc.storageClass.Name
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pvc1",
+					Namespace: "default",
+					Labels:    tc.labels,
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &scName,
+				},
+			}
+
+			err := setter.MutatePVC(context.Background(), pvc, pvc.Namespace)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("MutatePVC() error = %v, wantErr %t", err, tc.wantErr)
+			}
+			if len(pvc.GetLabels()) > 0 || len(tc.wantLabels) > 0 {
+				if !reflect.DeepEqual(pvc.GetLabels(), tc.wantLabels) {
+					t.Errorf("MutatePVC() labels = %v, want %v", pvc.GetLabels(), tc.wantLabels)
+				}
+			}
+		})
+	}
+}
+
+func TestMutatePVCUpdate(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	stosSC := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "stos"},
+		Provisioner: provisioner.DriverName,
+	}
+	topologyAware := map[string]string{storageos.ReservedLabelTopologyAware: "true"}
+
+	testcases := []struct {
+		name         string
+		storageClass string
+		bound        bool
+		wantLabels   map[string]string
+	}{
+		{
+			name:         "enabled before provisioning",
+			storageClass: stosSC.Name,
+			wantLabels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "true",
+				storageos.ReservedLabelTopologyKey:   storageos.LabelFailureDomain,
+			},
+		},
+		{
+			name:         "enabled after provisioning",
+			storageClass: stosSC.Name,
+			bound:        true,
+			wantLabels:   topologyAware,
+		},
+		{
+			name:         "missing storageclass",
+			storageClass: "missing",
+			wantLabels:   topologyAware,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			setter := NewKeySetter(fake.NewClientBuilder().WithScheme(scheme).WithObjects(stosSC).Build())
+
+			scName := tc.storageClass
+			old := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "pvc1", Namespace: "default"},
+				Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &scName},
+			}
+			if tc.bound {
+				old.Spec.VolumeName = "pv1"
+			}
+			pvc := old.DeepCopy()
+			pvc.Labels = map[string]string{storageos.ReservedLabelTopologyAware: "true"}
+
+			if err := setter.MutatePVCUpdate(context.Background(), old, pvc, pvc.Namespace); err != nil {
+				t.Fatalf("MutatePVCUpdate() error = %v", err)
+			}
+			if !reflect.DeepEqual(pvc.GetLabels(), tc.wantLabels) {
+				t.Errorf("MutatePVCUpdate() labels = %v, want %v", pvc.GetLabels(), tc.wantLabels)
+			}
+		})
+	}
+}
@@ -86,7 +86,7 @@ func SetupPVCKeygenTest(ctx context.Context, addMutator bool, isStorageOS bool,
 
 		if addMutator {
 			pvcMutator := pvcmutator.NewController(compositeClient, decoder, []pvcmutator.Mutator{
-				encryption.NewKeySetter(compositeClient, labels.Default()),
+				encryption.NewKeySetter(compositeClient, nil, mgr.GetEventRecorderFor("storageos-api-manager"), labels.Default()),
 			})
 
 			mgr.GetWebhookServer().Register(webhookMutatePVCsPath, &webhook.Admission{Handler: pvcMutator})
@@ -96,7 +96,7 @@ func SetupPVCLabelSyncTest(ctx context.Context, sc storagev1.StorageClass, isSto
 			gcInterval = time.Hour
 		}
 
-		controller := pvclabel.NewReconciler(api, mgr.GetClient(), defaultSyncDelay, gcInterval)
+		controller := pvclabel.NewReconciler(api, mgr.GetClient(), defaultSyncDelay, gcInterval, mgr.GetEventRecorderFor("storageos-api-manager"), nil)
 		err = controller.SetupWithManager(mgr, defaultWorkers)
 		Expect(err).NotTo(HaveOccurred(), "failed to setup controller")
 
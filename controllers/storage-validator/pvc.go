@@ -0,0 +1,108 @@
+package storagevalidator
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/go-multierror"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+// PVCValidator rejects StorageOS PVCs with reserved labels that can't be
+// applied to the volume, or that change labels that are fixed once the volume
+// has been created.
+type PVCValidator struct {
+	client.Client
+	decoder *admission.Decoder
+	log     logr.Logger
+}
+
+// Check if the Handler interface is implemented.
+var _ admission.Handler = &PVCValidator{}
+
+// +kubebuilder:webhook:path=/validate-pvcs,mutating=false,failurePolicy=ignore,sideEffects=None,groups="",resources=persistentvolumeclaims,verbs=create;update,versions=v1,name=pvc-validator.storageos.com,admissionReviewVersions=v1
+
+// NewPVCValidator returns a new PVC validating admission controller.
+func NewPVCValidator(k8s client.Client, decoder *admission.Decoder) *PVCValidator {
+	return &PVCValidator{
+		Client:  k8s,
+		decoder: decoder,
+		log:     ctrl.Log.WithName("pvc-validator"),
+	}
+}
+
+// Handle handles an admission request and validates the reserved labels of the
+// pvc object in the request.
+//
+// On create, all reserved labels are validated.  On update, only reserved
+// labels that were added or changed are validated, so that PVCs created with
+// invalid labels before the webhook was enabled can still be updated (e.g. to
+// remove finalizers).  Labels that are only read when the volume is
+// provisioned can't be changed once the pvc is bound.
+func (v *PVCValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := v.decoder.Decode(req, pvc); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	var old *corev1.PersistentVolumeClaim
+	if req.Operation == admissionv1.Update {
+		old = &corev1.PersistentVolumeClaim{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	log := v.log.WithValues("pvc", client.ObjectKey{Name: req.Name, Namespace: req.Namespace}.String(), "operation", req.Operation)
+
+	// PVCs are allowed to refer to StorageClasses that don't exist yet, so
+	// they are not validated if the StorageClass can't be found.
+	isStorageOS, err := provisioner.IsStorageOSPVC(v.Client, pvc)
+	if err != nil {
+		log.V(4).Info("unable to determine pvc provisioner", "error", err.Error())
+	}
+	if !isStorageOS {
+		log.V(4).Info("pvc not provisioned by StorageOS, skipping")
+		return admission.Allowed("")
+	}
+
+	if err := validatePVCLabels(old, pvc); err != nil {
+		log.Info("rejected pvc with invalid storageos labels", "reason", err.Error())
+		return admission.Denied("invalid storageos labels: " + err.Error())
+	}
+	return admission.Allowed("")
+}
+
+// validatePVCLabels returns an error listing each invalid reserved label on
+// the pvc.  If old is set, only added or changed labels are validated.  Once
+// the pvc is bound, changes to immutable labels are also rejected.
+func validatePVCLabels(old, pvc *corev1.PersistentVolumeClaim) error {
+	if old == nil {
+		return storageos.ValidateVolumeLabels(pvc.GetLabels())
+	}
+
+	var errs = &multierror.Error{ErrorFormat: storageos.ListErrors}
+	changed := make(map[string]string)
+	for k, v := range pvc.GetLabels() {
+		if prev, ok := old.GetLabels()[k]; !ok || prev != v {
+			changed[k] = v
+		}
+	}
+	if err := storageos.ValidateVolumeLabels(changed); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if provisioner.IsBound(old) {
+		if err := storageos.ValidateVolumeLabelUpdate(old.GetLabels(), pvc.GetLabels()); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,181 @@
+package storagevalidator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+func newDecoder(t *testing.T) (*runtime.Scheme, *admission.Decoder) {
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scheme, decoder
+}
+
+func rawObject(t *testing.T, obj runtime.Object) runtime.RawExtension {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+func genPVC(storageClassName string, labels map[string]string, annotations map[string]string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{Kind: "PersistentVolumeClaim", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pvc1",
+			Namespace:   "default",
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{StorageClassName: &storageClassName},
+	}
+}
+
+func bound(pvc *corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaim {
+	pvc.Spec.VolumeName = "pv1"
+	return pvc
+}
+
+func TestPVCValidatorHandle(t *testing.T) {
+	t.Parallel()
+
+	stosSC := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "stos"}, Provisioner: provisioner.DriverName}
+	notStosSC := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "non-stos"}, Provisioner: "foo-provisioner"}
+	stosProvisioned := map[string]string{provisioner.PVCProvisionerAnnotationKey: provisioner.DriverName}
+
+	tests := []struct {
+		name    string
+		old     *corev1.PersistentVolumeClaim
+		pvc     *corev1.PersistentVolumeClaim
+		allowed bool
+	}{
+		{
+			name:    "create valid labels",
+			pvc:     genPVC("stos", map[string]string{"foo": "bar", storageos.ReservedLabelReplicas: "2", storageos.ReservedLabelFailureMode: storageos.FailureModeSoft}, nil),
+			allowed: true,
+		},
+		{
+			name: "create invalid replicas",
+			pvc:  genPVC("stos", map[string]string{storageos.ReservedLabelReplicas: "abc"}, nil),
+		},
+		{
+			name: "create invalid failure mode",
+			pvc:  genPVC("stos", map[string]string{storageos.ReservedLabelFailureMode: "bogus"}, nil),
+		},
+		{
+			name: "create unknown reserved label",
+			pvc:  genPVC("stos", map[string]string{storageos.ReservedLabelPrefix + "foo": "bar"}, nil),
+		},
+		{
+			name:    "create non-storageos pvc",
+			pvc:     genPVC("non-stos", map[string]string{storageos.ReservedLabelReplicas: "abc"}, nil),
+			allowed: true,
+		},
+		{
+			name:    "create with missing storageclass",
+			pvc:     genPVC("missing", map[string]string{storageos.ReservedLabelReplicas: "abc"}, nil),
+			allowed: true,
+		},
+		{
+			name:    "update replicas",
+			old:     genPVC("stos", map[string]string{storageos.ReservedLabelReplicas: "1"}, stosProvisioned),
+			pvc:     genPVC("stos", map[string]string{storageos.ReservedLabelReplicas: "2"}, stosProvisioned),
+			allowed: true,
+		},
+		{
+			name: "update invalid replicas",
+			old:  genPVC("stos", map[string]string{storageos.ReservedLabelReplicas: "1"}, stosProvisioned),
+			pvc:  genPVC("stos", map[string]string{storageos.ReservedLabelReplicas: "7"}, stosProvisioned),
+		},
+		{
+			name:    "update with unchanged invalid label",
+			old:     genPVC("stos", map[string]string{storageos.ReservedLabelReplicas: "abc"}, stosProvisioned),
+			pvc:     genPVC("stos", map[string]string{storageos.ReservedLabelReplicas: "abc", "foo": "bar"}, stosProvisioned),
+			allowed: true,
+		},
+		{
+			name: "update nocache",
+			old:  bound(genPVC("stos", map[string]string{storageos.ReservedLabelNoCache: "false"}, stosProvisioned)),
+			pvc:  bound(genPVC("stos", map[string]string{storageos.ReservedLabelNoCache: "true"}, stosProvisioned)),
+		},
+		{
+			name: "add nocache",
+			old:  bound(genPVC("stos", nil, stosProvisioned)),
+			pvc:  bound(genPVC("stos", map[string]string{storageos.ReservedLabelNoCache: "true"}, stosProvisioned)),
+		},
+		{
+			name:    "add nocache before provisioning",
+			old:     genPVC("stos", nil, nil),
+			pvc:     genPVC("stos", map[string]string{storageos.ReservedLabelNoCache: "true"}, nil),
+			allowed: true,
+		},
+		{
+			name:    "remove default nocache",
+			old:     bound(genPVC("stos", map[string]string{storageos.ReservedLabelNoCache: "false"}, stosProvisioned)),
+			pvc:     bound(genPVC("stos", nil, stosProvisioned)),
+			allowed: true,
+		},
+		{
+			name:    "update provisioned pvc with deleted storageclass",
+			old:     genPVC("deleted", map[string]string{storageos.ReservedLabelReplicas: "1"}, stosProvisioned),
+			pvc:     genPVC("deleted", map[string]string{storageos.ReservedLabelReplicas: "2"}, stosProvisioned),
+			allowed: true,
+		},
+		{
+			name: "update provisioned pvc with deleted storageclass invalid",
+			old:  genPVC("deleted", nil, stosProvisioned),
+			pvc:  genPVC("deleted", map[string]string{storageos.ReservedLabelFailureMode: "bogus"}, stosProvisioned),
+		},
+		{
+			name:    "update non-storageos pvc",
+			old:     genPVC("stos", nil, map[string]string{provisioner.PVCProvisionerAnnotationKey: "foo-provisioner"}),
+			pvc:     genPVC("stos", map[string]string{storageos.ReservedLabelNoCache: "true"}, map[string]string{provisioner.PVCProvisionerAnnotationKey: "foo-provisioner"}),
+			allowed: true,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scheme, decoder := newDecoder(t)
+			k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(stosSC, notStosSC).Build()
+
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Name:      tt.pvc.Name,
+				Namespace: tt.pvc.Namespace,
+				Object:    rawObject(t, tt.pvc),
+			}}
+			if tt.old != nil {
+				req.Operation = admissionv1.Update
+				req.OldObject = rawObject(t, tt.old)
+			}
+
+			resp := NewPVCValidator(k8s, decoder).Handle(context.Background(), req)
+			if resp.Allowed != tt.allowed {
+				t.Errorf("Handle() allowed = %t, want %t (%v)", resp.Allowed, tt.allowed, resp.Result)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package storagevalidator
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	storagev1 "k8s.io/api/storage/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+// StorageClassValidator rejects StorageOS StorageClasses with parameters that
+// set reserved volume labels to invalid values.
+type StorageClassValidator struct {
+	decoder *admission.Decoder
+	log     logr.Logger
+}
+
+// Check if the Handler interface is implemented.
+var _ admission.Handler = &StorageClassValidator{}
+
+// +kubebuilder:webhook:path=/validate-storageclasses,mutating=false,failurePolicy=ignore,sideEffects=None,groups=storage.k8s.io,resources=storageclasses,verbs=create;update,versions=v1,name=storageclass-validator.storageos.com,admissionReviewVersions=v1
+
+// NewStorageClassValidator returns a new StorageClass validating admission
+// controller.
+func NewStorageClassValidator(decoder *admission.Decoder) *StorageClassValidator {
+	return &StorageClassValidator{
+		decoder: decoder,
+		log:     ctrl.Log.WithName("storageclass-validator"),
+	}
+}
+
+// Handle handles an admission request and validates the reserved parameters
+// of the StorageClass object in the request.
+func (v *StorageClassValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	sc := &storagev1.StorageClass{}
+	if err := v.decoder.Decode(req, sc); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	log := v.log.WithValues("storageclass", client.ObjectKey{Name: req.Name}.String(), "operation", req.Operation)
+
+	if !provisioner.IsProvisionedStorageClass(sc, provisioner.DriverName) {
+		log.V(4).Info("storageclass not provisioned by StorageOS, skipping")
+		return admission.Allowed("")
+	}
+
+	if err := validateStorageClassParams(sc); err != nil {
+		log.Info("rejected storageclass with invalid storageos parameters", "reason", err.Error())
+		return admission.Denied("invalid storageos parameters: " + err.Error())
+	}
+	return admission.Allowed("")
+}
+
+// validateStorageClassParams returns an error listing each StorageClass
+// parameter that sets a reserved volume label to an invalid value.
+//
+// StorageClass parameters are passed to the volume as labels when it is
+// created.  Other reserved parameters may be used to configure the provisioner
+// rather than the volume, so only parameters in the reserved volume label
+// registry are validated.
+func validateStorageClassParams(sc *storagev1.StorageClass) error {
+	params := make(map[string]string)
+	for k, v := range provisioner.StorageClassReservedParams(sc) {
+		if _, ok := storageos.LookupVolumeLabel(k); ok {
+			params[k] = v
+		}
+	}
+	return storageos.ValidateVolumeLabels(params)
+}
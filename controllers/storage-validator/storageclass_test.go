@@ -0,0 +1,88 @@
+package storagevalidator
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/storageos/api-manager/internal/pkg/provisioner"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+)
+
+func TestStorageClassValidatorHandle(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		provisioner string
+		params      map[string]string
+		allowed     bool
+	}{
+		{
+			name:        "valid parameters",
+			provisioner: provisioner.DriverName,
+			params: map[string]string{
+				"csi.storage.k8s.io/fstype":        "ext4",
+				storageos.ReservedLabelReplicas:    "1",
+				storageos.ReservedLabelFailureMode: "2",
+				storageos.ReservedLabelEncryption:  "true",
+			},
+			allowed: true,
+		},
+		{
+			name:        "invalid replicas",
+			provisioner: provisioner.DriverName,
+			params:      map[string]string{storageos.ReservedLabelReplicas: "abc"},
+		},
+		{
+			name:        "invalid failure mode",
+			provisioner: provisioner.DriverName,
+			params:      map[string]string{storageos.ReservedLabelFailureMode: "bogus"},
+		},
+		{
+			name:        "invalid nocache",
+			provisioner: provisioner.DriverName,
+			params:      map[string]string{storageos.ReservedLabelNoCache: "maybe"},
+		},
+		{
+			name:        "other reserved parameter",
+			provisioner: provisioner.DriverName,
+			params:      map[string]string{storageos.ReservedLabelPrefix + "foo": "bar"},
+			allowed:     true,
+		},
+		{
+			name:        "non-storageos storageclass",
+			provisioner: "foo-provisioner",
+			params:      map[string]string{storageos.ReservedLabelReplicas: "abc"},
+			allowed:     true,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, decoder := newDecoder(t)
+			sc := &storagev1.StorageClass{
+				TypeMeta:    metav1.TypeMeta{Kind: "StorageClass", APIVersion: "storage.k8s.io/v1"},
+				ObjectMeta:  metav1.ObjectMeta{Name: "sc1"},
+				Provisioner: tt.provisioner,
+				Parameters:  tt.params,
+			}
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Name:      sc.Name,
+				Object:    rawObject(t, sc),
+			}}
+
+			resp := NewStorageClassValidator(decoder).Handle(context.Background(), req)
+			if resp.Allowed != tt.allowed {
+				t.Errorf("Handle() allowed = %t, want %t (%v)", resp.Allowed, tt.allowed, resp.Result)
+			}
+		})
+	}
+}
@@ -24,6 +24,10 @@ type testMutator struct {
 	error bool
 }
 
+func (m testMutator) Name() string {
+	return "test"
+}
+
 func (m testMutator) MutatePod(ctx context.Context, obj *corev1.Pod, namespace string) error {
 	if m.error {
 		return errors.New("error")
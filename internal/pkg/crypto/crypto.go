@@ -5,7 +5,10 @@ import (
 	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"io"
 
@@ -125,3 +128,104 @@ func CheckHMAC(message, messageMAC, key []byte) (bool, error) {
 	}
 	return hmac.Equal(messageMAC, expectedMAC), nil
 }
+
+// EncryptGCM returns a cipher text by encrypting plaintext with the given key
+// using AES-256 GCM authenticated encryption.  The additional data is
+// authenticated but not encrypted, and must be passed to DecryptGCM.  The
+// random nonce is prepended to the cipher text.
+func EncryptGCM(plaintext, key, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// DecryptGCM returns a plaintext by decrypting and authenticating a AES-256 GCM
+// encrypted ciphertext using the given key and additional data.
+func DecryptGCM(ciphertext, key, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce := ciphertext[:gcm.NonceSize()]
+	return gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], additionalData)
+}
+
+// EncryptOAEP encrypts a short plaintext, such as a data encryption key, to the
+// RSA public key using RSA-OAEP with SHA-256.  The label must be passed to
+// DecryptOAEP.
+func EncryptOAEP(plaintext []byte, pub *rsa.PublicKey, label []byte) ([]byte, error) {
+	return rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, plaintext, label)
+}
+
+// DecryptOAEP decrypts a ciphertext encrypted with EncryptOAEP using the RSA
+// private key.
+func DecryptOAEP(ciphertext []byte, priv *rsa.PrivateKey, label []byte) ([]byte, error) {
+	return rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, ciphertext, label)
+}
+
+// ParseRSAPublicKey parses a PEM-encoded RSA public key, in either PKIX
+// ("PUBLIC KEY") or PKCS #1 ("RSA PUBLIC KEY") form.
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("public key is not an RSA key")
+		}
+		return pub, nil
+	default:
+		return nil, errors.New("unsupported public key type " + block.Type)
+	}
+}
+
+// ParseRSAPrivateKey parses a PEM-encoded RSA private key, in either PKCS #8
+// ("PRIVATE KEY") or PKCS #1 ("RSA PRIVATE KEY") form.
+func ParseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("private key is not an RSA key")
+		}
+		return priv, nil
+	default:
+		return nil, errors.New("unsupported private key type " + block.Type)
+	}
+}
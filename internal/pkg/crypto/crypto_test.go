@@ -2,6 +2,10 @@ package crypto
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"reflect"
 	"testing"
@@ -155,3 +159,78 @@ func TestDecrypt(t *testing.T) {
 		})
 	}
 }
+
+func TestGCM(t *testing.T) {
+	key, err := GenerateUserKey()
+	expect(t, err, nil)
+
+	vmk, err := GenerateVMK()
+	expect(t, err, nil)
+
+	aad := []byte("additional data")
+
+	ciphertext, err := EncryptGCM(vmk, key, aad)
+	expect(t, err, nil)
+
+	plaintext, err := DecryptGCM(ciphertext, key, aad)
+	expect(t, err, nil)
+	if !bytes.Equal(vmk, plaintext) {
+		t.Errorf("expected the decrypted plaintext to be equal to the original:\n\t(GOT): %v\n\t(WNT): %v", plaintext, vmk)
+	}
+
+	// Decryption must fail if the additional data doesn't match.
+	if _, err := DecryptGCM(ciphertext, key, []byte("other data")); err == nil {
+		t.Error("expected error with mismatched additional data, but got none")
+	}
+
+	// Decryption must fail if the ciphertext has been modified.
+	ciphertext[len(ciphertext)-1] ^= 0xff
+	if _, err := DecryptGCM(ciphertext, key, aad); err == nil {
+		t.Error("expected error with modified ciphertext, but got none")
+	}
+
+	// Decryption must fail if the ciphertext is too short to contain a nonce.
+	if _, err := DecryptGCM(make([]byte, 4), key, aad); err == nil {
+		t.Error("expected error with short ciphertext, but got none")
+	}
+}
+
+func TestOAEP(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Round trip the keys through PEM.
+	pubDER, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ParseRSAPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	if err != nil {
+		t.Fatalf("ParseRSAPublicKey() unexpected error: %v", err)
+	}
+	priv2, err := ParseRSAPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}))
+	if err != nil {
+		t.Fatalf("ParseRSAPrivateKey() unexpected error: %v", err)
+	}
+
+	key, err := GenerateUserKey()
+	expect(t, err, nil)
+
+	ciphertext, err := EncryptOAEP(key, pub, []byte("label"))
+	expect(t, err, nil)
+
+	plaintext, err := DecryptOAEP(ciphertext, priv2, []byte("label"))
+	expect(t, err, nil)
+	if !bytes.Equal(plaintext, key) {
+		t.Error("decrypted plaintext does not match")
+	}
+
+	if _, err := DecryptOAEP(ciphertext, priv2, []byte("other")); err == nil {
+		t.Error("expected error decrypting with a different label")
+	}
+	if _, err := ParseRSAPublicKey([]byte("not pem")); err == nil {
+		t.Error("expected error parsing invalid public key")
+	}
+}
@@ -0,0 +1,98 @@
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Filter selects which Kubernetes labels are synced to StorageOS.
+//
+// A label is selected if it matches an include rule, or no include rules have
+// been set, and it does not match an exclude rule.  A nil Filter selects all
+// labels.
+type Filter struct {
+	IncludePrefixes []string
+	ExcludePrefixes []string
+	Include         *regexp.Regexp
+	Exclude         *regexp.Regexp
+}
+
+// NewFilter returns a new label filter.  Prefixes are given as comma-separated
+// lists and regular expressions are matched against the label key.  Empty
+// values are ignored.
+func NewFilter(includePrefixes, excludePrefixes, includeRegex, excludeRegex string) (*Filter, error) {
+	f := &Filter{
+		IncludePrefixes: SplitList(includePrefixes),
+		ExcludePrefixes: SplitList(excludePrefixes),
+	}
+	var err error
+	if includeRegex != "" {
+		if f.Include, err = regexp.Compile(includeRegex); err != nil {
+			return nil, fmt.Errorf("invalid label include regex: %w", err)
+		}
+	}
+	if excludeRegex != "" {
+		if f.Exclude, err = regexp.Compile(excludeRegex); err != nil {
+			return nil, fmt.Errorf("invalid label exclude regex: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// SplitList splits a comma-separated list, removing whitespace and empty
+// values.
+func SplitList(s string) []string {
+	var ret []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// Match returns true if the label key is selected by the filter.
+func (f *Filter) Match(key string) bool {
+	if f == nil {
+		return true
+	}
+	included := len(f.IncludePrefixes) == 0 && f.Include == nil
+	for _, p := range f.IncludePrefixes {
+		if strings.HasPrefix(key, p) {
+			included = true
+			break
+		}
+	}
+	if !included && f.Include != nil && f.Include.MatchString(key) {
+		included = true
+	}
+	if !included {
+		return false
+	}
+	for _, p := range f.ExcludePrefixes {
+		if strings.HasPrefix(key, p) {
+			return false
+		}
+	}
+	if f.Exclude != nil && f.Exclude.MatchString(key) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the labels selected by the filter.  Labels that exempt returns
+// true for are always selected, so that labels with special meaning can't be
+// filtered out.  exempt may be nil.
+func (f *Filter) Apply(labels map[string]string, exempt func(key string) bool) map[string]string {
+	if f == nil || labels == nil {
+		return labels
+	}
+	ret := make(map[string]string)
+	for k, v := range labels {
+		if (exempt != nil && exempt(k)) || f.Match(k) {
+			ret[k] = v
+		}
+	}
+	return ret
+}
@@ -0,0 +1,135 @@
+package labels
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		includeRegex string
+		excludeRegex string
+		wantErr      bool
+	}{
+		{name: "no regex"},
+		{name: "valid regex", includeRegex: "^topology\\.", excludeRegex: "zone$"},
+		{name: "invalid include regex", includeRegex: "(", wantErr: true},
+		{name: "invalid exclude regex", excludeRegex: "[", wantErr: true},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := NewFilter("", "", tt.includeRegex, tt.excludeRegex); (err != nil) != tt.wantErr {
+				t.Errorf("NewFilter() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	t.Parallel()
+
+	in := map[string]string{
+		"app":                           "db",
+		"kubernetes.io/hostname":        "node1",
+		"topology.kubernetes.io/zone":   "a",
+		"topology.kubernetes.io/region": "eu",
+		"storageos.com/replicas":        "1",
+	}
+	reserved := func(key string) bool {
+		return strings.HasPrefix(key, "storageos.com/")
+	}
+
+	tests := []struct {
+		name            string
+		includePrefixes string
+		excludePrefixes string
+		includeRegex    string
+		excludeRegex    string
+		want            map[string]string
+	}{
+		{
+			name: "no rules",
+			want: in,
+		},
+		{
+			name:            "include prefix",
+			includePrefixes: "topology.kubernetes.io/",
+			want: map[string]string{
+				"topology.kubernetes.io/zone":   "a",
+				"topology.kubernetes.io/region": "eu",
+				"storageos.com/replicas":        "1",
+			},
+		},
+		{
+			name:            "exclude prefix",
+			excludePrefixes: "kubernetes.io/, topology.kubernetes.io/",
+			want: map[string]string{
+				"app":                    "db",
+				"storageos.com/replicas": "1",
+			},
+		},
+		{
+			name:            "include and exclude",
+			includePrefixes: "topology.kubernetes.io/",
+			excludeRegex:    "region$",
+			want: map[string]string{
+				"topology.kubernetes.io/zone": "a",
+				"storageos.com/replicas":      "1",
+			},
+		},
+		{
+			name:            "include prefix or regex",
+			includePrefixes: "kubernetes.io/",
+			includeRegex:    "^app$",
+			want: map[string]string{
+				"app":                    "db",
+				"kubernetes.io/hostname": "node1",
+				"storageos.com/replicas": "1",
+			},
+		},
+		{
+			name:            "exempt labels not filtered",
+			excludePrefixes: "storageos.com/",
+			want: map[string]string{
+				"app":                           "db",
+				"kubernetes.io/hostname":        "node1",
+				"topology.kubernetes.io/zone":   "a",
+				"topology.kubernetes.io/region": "eu",
+				"storageos.com/replicas":        "1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := NewFilter(tt.includePrefixes, tt.excludePrefixes, tt.includeRegex, tt.excludeRegex)
+			if err != nil {
+				t.Fatalf("NewFilter() unexpected error: %v", err)
+			}
+			if got := f.Apply(in, reserved); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter.Apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilFilter(t *testing.T) {
+	t.Parallel()
+
+	var f *Filter
+	in := map[string]string{"foo": "bar"}
+	if !f.Match("foo") {
+		t.Error("nil Filter.Match() = false, want true")
+	}
+	if got := f.Apply(in, nil); !reflect.DeepEqual(got, in) {
+		t.Errorf("nil Filter.Apply() = %v, want %v", got, in)
+	}
+}
@@ -0,0 +1,74 @@
+package mutation
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// DecisionApplied is the decision label value when a mutator changed the
+	// object.
+	DecisionApplied = "applied"
+
+	// DecisionSkipped is the decision label value when a mutator ran but left
+	// the object unchanged.
+	DecisionSkipped = "skipped"
+
+	// DecisionError is the decision label value when a mutator returned an
+	// error, rejecting the request.
+	DecisionError = "error"
+)
+
+// DecisionMetric counts mutator decisions.
+type DecisionMetric interface {
+	Increment(webhook string, mutator string, decision string)
+}
+
+var (
+	// Decisions counts the decisions made by admission mutators, by webhook,
+	// mutator and decision.
+	Decisions DecisionMetric = &decisionAdapter{m: decisionsCounter}
+
+	// registerMetricsOnce keeps track of metrics registration.
+	registerMetricsOnce sync.Once
+)
+
+var (
+	decisionsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "storageos_admission_mutator_decisions_total",
+			Help: "Number of admission mutator decisions, partitioned by webhook, mutator and decision.",
+		},
+		[]string{"webhook", "mutator", "decision"},
+	)
+)
+
+// RegisterMetrics ensures that the package metrics are registered.
+func RegisterMetrics() {
+	registerMetricsOnce.Do(func() {
+		metrics.Registry.MustRegister(decisionsCounter)
+	})
+}
+
+// Decision returns the decision label value for a mutator that returned err,
+// and changed the object if changed is true.
+func Decision(changed bool, err error) string {
+	switch {
+	case err != nil:
+		return DecisionError
+	case changed:
+		return DecisionApplied
+	default:
+		return DecisionSkipped
+	}
+}
+
+type decisionAdapter struct {
+	m *prometheus.CounterVec
+}
+
+func (d *decisionAdapter) Increment(webhook string, mutator string, decision string) {
+	d.m.WithLabelValues(webhook, mutator, decision).Inc()
+}
@@ -0,0 +1,48 @@
+package mutation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecision(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		changed bool
+		err     error
+		want    string
+	}{
+		{
+			name: "unchanged",
+			want: DecisionSkipped,
+		},
+		{
+			name:    "changed",
+			changed: true,
+			want:    DecisionApplied,
+		},
+		{
+			name: "error",
+			err:  errors.New("rejected"),
+			want: DecisionError,
+		},
+		{
+			name:    "changed with error",
+			changed: true,
+			err:     errors.New("rejected"),
+			want:    DecisionError,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := Decision(tt.changed, tt.err); got != tt.want {
+				t.Errorf("Decision() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
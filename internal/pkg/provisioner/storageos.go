@@ -94,6 +94,30 @@ func IsProvisionedPVC(k8s client.Client, pvc corev1.PersistentVolumeClaim, names
 	return IsProvisionedStorageClass(sc, provisioners...), nil
 }
 
+// IsStorageOSPVC returns true if the PVC has been or will be provisioned by
+// StorageOS.
+//
+// The provisioner annotation is used once set, as the StorageClass may have
+// been deleted since the volume was provisioned.  Otherwise the StorageClass
+// of the PVC is checked.
+func IsStorageOSPVC(k8s client.Client, pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	if _, ok := pvc.GetAnnotations()[PVCProvisionerAnnotationKey]; ok {
+		return HasStorageOSAnnotation(pvc), nil
+	}
+	sc, err := StorageClassForPVC(k8s, pvc)
+	if err != nil {
+		return false, err
+	}
+	return IsProvisionedStorageClass(sc, DriverName), nil
+}
+
+// IsBound returns true if the PVC has been bound to a PersistentVolume.  Once
+// bound, the volume has been provisioned and settings that are only read at
+// create time can no longer be changed.
+func IsBound(pvc *corev1.PersistentVolumeClaim) bool {
+	return pvc.Spec.VolumeName != ""
+}
+
 // IsProvisionedStorageClass returns true if the StorageClass has one of the given provisioners.
 func IsProvisionedStorageClass(sc *storagev1.StorageClass, provisioners ...string) bool {
 	// Check if the StorageClass provisioner matches with any of the provided
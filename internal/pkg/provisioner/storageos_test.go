@@ -334,6 +334,70 @@ func TestIsProvisionedStorageClass(t *testing.T) {
 	}
 }
 
+func TestIsStorageOSPVC(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := kscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	stosSC := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, Provisioner: DriverName}
+	fooSC := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "slow"}, Provisioner: "foo-provisioner"}
+
+	withProvisioner := func(pvc corev1.PersistentVolumeClaim, provisioner string) corev1.PersistentVolumeClaim {
+		pvc.Annotations[PVCProvisionerAnnotationKey] = provisioner
+		return pvc
+	}
+
+	tests := []struct {
+		name    string
+		pvc     corev1.PersistentVolumeClaim
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "storageos storageclass",
+			pvc:  createPVC("pv1", "default", stosSC.Name, false),
+			want: true,
+		},
+		{
+			name: "other storageclass",
+			pvc:  createPVC("pv1", "default", fooSC.Name, false),
+		},
+		{
+			name:    "missing storageclass",
+			pvc:     createPVC("pv1", "default", "deleted", false),
+			wantErr: true,
+		},
+		{
+			name: "storageos provisioner with missing storageclass",
+			pvc:  withProvisioner(createPVC("pv1", "default", "deleted", false), DriverName),
+			want: true,
+		},
+		{
+			name: "other provisioner with storageos storageclass",
+			pvc:  withProvisioner(createPVC("pv1", "default", stosSC.Name, false), "foo-provisioner"),
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			k8s := fake.NewClientBuilder().WithScheme(scheme).WithObjects(stosSC, fooSC).Build()
+
+			got, err := IsStorageOSPVC(k8s, &tt.pvc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("IsStorageOSPVC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsStorageOSPVC() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
 // createPVC creates and returns a PVC object.
 func createPVC(name, namespace, storageClassName string, betaAnnotation bool) corev1.PersistentVolumeClaim {
 	pvc := corev1.PersistentVolumeClaim{
@@ -0,0 +1,24 @@
+// Package request carries admission request metadata in a context, so that it
+// can be recorded by code that does not have access to the request.
+package request
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// uidKey is the context key for the admission request UID.
+type uidKey struct{}
+
+// WithUID returns a copy of the context with the admission request UID set.
+func WithUID(ctx context.Context, uid types.UID) context.Context {
+	return context.WithValue(ctx, uidKey{}, uid)
+}
+
+// UID returns the admission request UID set in the context, or an empty UID if
+// not set.
+func UID(ctx context.Context) types.UID {
+	uid, _ := ctx.Value(uidKey{}).(types.UID)
+	return uid
+}
@@ -35,6 +35,8 @@ type ControlPlane interface {
 	RefreshJwt(ctx context.Context) (api.UserSession, *http.Response, error)
 	AuthenticateUser(ctx context.Context, authUserData api.AuthUserData) (api.UserSession, *http.Response, error)
 	ListNamespaces(ctx context.Context) ([]api.Namespace, *http.Response, error)
+	CreateNamespace(ctx context.Context, createNamespaceData api.CreateNamespaceData) (api.Namespace, *http.Response, error)
+	UpdateNamespace(ctx context.Context, id string, updateNamespaceData api.UpdateNamespaceData, localVarOptionals *api.UpdateNamespaceOpts) (api.Namespace, *http.Response, error)
 	DeleteNamespace(ctx context.Context, id string, version string, localVarOptionals *api.DeleteNamespaceOpts) (*http.Response, error)
 	ListNodes(ctx context.Context) ([]api.Node, *http.Response, error)
 	UpdateNode(ctx context.Context, id string, updateNodeData api.UpdateNodeData) (api.Node, *http.Response, error)
@@ -42,9 +44,17 @@ type ControlPlane interface {
 	SetComputeOnly(ctx context.Context, id string, setComputeOnlyNodeData api.SetComputeOnlyNodeData, localVarOptionals *api.SetComputeOnlyOpts) (api.Node, *http.Response, error)
 	ListVolumes(ctx context.Context, namespaceID string) ([]api.Volume, *http.Response, error)
 	GetVolume(ctx context.Context, namespaceID string, id string) (api.Volume, *http.Response, error)
+	DeleteVolume(ctx context.Context, namespaceID string, id string, version string, localVarOptionals *api.DeleteVolumeOpts) (*http.Response, error)
 	UpdateVolume(ctx context.Context, namespaceID string, id string, updateVolumeData api.UpdateVolumeData, localVarOptionals *api.UpdateVolumeOpts) (api.Volume, *http.Response, error)
 	SetReplicas(ctx context.Context, namespaceID string, id string, setReplicasRequest api.SetReplicasRequest, localVarOptionals *api.SetReplicasOpts) (api.AcceptedMessage, *http.Response, error)
 	SetFailureMode(ctx context.Context, namespaceID string, id string, setFailureModeRequest api.SetFailureModeRequest, localVarOptionals *api.SetFailureModeOpts) (api.Volume, *http.Response, error)
+	ResizeVolume(ctx context.Context, namespaceID string, id string, resizeVolumeRequest api.ResizeVolumeRequest, localVarOptionals *api.ResizeVolumeOpts) (api.Volume, *http.Response, error)
+	ListPolicyGroups(ctx context.Context) ([]api.PolicyGroup, *http.Response, error)
+	CreatePolicyGroup(ctx context.Context, createPolicyGroupData api.CreatePolicyGroupData) (api.PolicyGroup, *http.Response, error)
+	UpdatePolicyGroup(ctx context.Context, id string, updatePolicyGroupData api.UpdatePolicyGroupData, localVarOptionals *api.UpdatePolicyGroupOpts) (api.PolicyGroup, *http.Response, error)
+	DeletePolicyGroup(ctx context.Context, id string, version string, localVarOptionals *api.DeletePolicyGroupOpts) (*http.Response, error)
+	ListUsers(ctx context.Context) ([]api.User, *http.Response, error)
+	UpdateUser(ctx context.Context, id string, updateUserData api.UpdateUserData, localVarOptionals *api.UpdateUserOpts) (api.User, *http.Response, error)
 	UpdateNFSVolumeMountEndpoint(ctx context.Context, namespaceID string, id string, nfsVolumeMountEndpoint api.NfsVolumeMountEndpoint, localVarOptionals *api.UpdateNFSVolumeMountEndpointOpts) (*http.Response, error)
 }
 
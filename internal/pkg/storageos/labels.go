@@ -2,7 +2,13 @@ package storageos
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const (
@@ -35,6 +41,33 @@ const (
 	// should be encrypted.
 	ReservedLabelEncryption = ReservedLabelPrefix + "encryption"
 
+	// ReservedLabelTopologyAware is the PVC label used to enable topology-aware
+	// placement of the volume's replicas.
+	ReservedLabelTopologyAware = ReservedLabelPrefix + "topology-aware"
+
+	// ReservedLabelTopologyKey is the PVC label used to set the node label that
+	// defines the failure domains used by topology-aware placement.
+	ReservedLabelTopologyKey = ReservedLabelPrefix + "topology-key"
+
+	// LabelFailureDomain is the node label set to the node's failure domain,
+	// derived from the Kubernetes zone and region topology labels.  It is the
+	// default topology key for topology-aware placement.  It is not a reserved
+	// label, so it can be set on nodes with the unreserved labels.
+	LabelFailureDomain = "topology.storageos.com/failure-domain"
+
+	// ReservedLabelProtectNode can be set on Kubernetes nodes to stop the
+	// StorageOS node from being decommissioned.  It is used by the api-manager
+	// and is not applied to the StorageOS node.  LabelNodeProtected is set on
+	// the StorageOS node instead.
+	ReservedLabelProtectNode = ReservedLabelPrefix + "protect-node"
+
+	// LabelNodeProtected is set on StorageOS nodes while the Kubernetes node
+	// is protected, so that the protection is kept once the Kubernetes node has
+	// been deleted.  It is not a reserved label, so it can be set on nodes with
+	// the unreserved labels.  It is managed by the api-manager and is kept when
+	// unreserved labels are applied.
+	LabelNodeProtected = "node.storageos.com/protected"
+
 	// ReservedLabelFencing can be set on Pods to indicate that the Pod should
 	// be deleted if it is running on a node that StorageOS believes no longer
 	// has access to its storage.
@@ -51,8 +84,46 @@ const (
 	// ReservedLabelK8sPVName is set by the csi-provisioner at create time. It's
 	// treated as a reserved label by StorageOS and can't be modified.
 	ReservedLabelK8sPVName = "csi.storage.k8s.io/pv/name"
+
+	// MaxReplicas is the maximum number of volume replicas that can be set
+	// with the replicas label.
+	MaxReplicas = 6
 )
 
+// VolumeLabelHandling describes how label sync handles a reserved volume label.
+type VolumeLabelHandling int
+
+const (
+	// VolumeLabelApplied labels are applied to the volume with a dedicated API
+	// call whenever they change.
+	VolumeLabelApplied VolumeLabelHandling = iota
+
+	// VolumeLabelImmutable labels are set when the volume is created, and
+	// can't be changed afterwards.
+	VolumeLabelImmutable
+
+	// VolumeLabelInformational labels are used when the volume is created.
+	// They are validated, but changes are not applied to existing volumes.
+	VolumeLabelInformational
+
+	// VolumeLabelIgnored labels are managed elsewhere and are neither
+	// validated nor applied.
+	VolumeLabelIgnored
+)
+
+// VolumeLabel describes a reserved label that may be set on a volume.
+type VolumeLabel struct {
+	// Handling determines how the label is applied to the volume.
+	Handling VolumeLabelHandling
+
+	// Validate returns an error if the label value is invalid.  If nil, any
+	// value is accepted.
+	Validate func(value string) error
+
+	// Default is the value the volume has when the label is not set.
+	Default string
+}
+
 var (
 	// ErrReservedLabelUnknown indicates that a label with the reserved prefix
 	// was provided, but not recognized.
@@ -65,8 +136,180 @@ var (
 	// ErrReservedLabelFixed can be used to indicate that a label can't be
 	// modified once set during object creation.
 	ErrReservedLabelFixed = errors.New("behaviour can't be changed after creation")
+
+	// ErrReservedLabelValue indicates that a reserved label was recognized,
+	// but its value is invalid.
+	ErrReservedLabelValue = errors.New("invalid reserved label value")
+
+	// ErrTopologyLabelValue indicates that a label used for topology-aware
+	// placement has an invalid value.
+	ErrTopologyLabelValue = errors.New("invalid topology label value")
 )
 
+// volumeLabels is the registry of reserved labels that can be set on volumes,
+// indexed by label key.
+var volumeLabels = map[string]VolumeLabel{
+	ReservedLabelReplicas:        {Handling: VolumeLabelApplied, Validate: validateReplicas, Default: "0"},
+	ReservedLabelFailureMode:     {Handling: VolumeLabelApplied, Validate: validateFailureMode},
+	ReservedLabelNoCache:         {Handling: VolumeLabelImmutable, Validate: validateBool, Default: "false"},
+	ReservedLabelNoCompress:      {Handling: VolumeLabelImmutable, Validate: validateBool, Default: "false"},
+	ReservedLabelEncryption:      {Handling: VolumeLabelImmutable, Validate: validateBool, Default: "false"},
+	ReservedLabelTopologyAware:   {Handling: VolumeLabelInformational, Validate: validateBool, Default: "false"},
+	ReservedLabelTopologyKey:     {Handling: VolumeLabelInformational, Validate: validateTopologyKey},
+	ReservedLabelK8sPVCNamespace: {Handling: VolumeLabelIgnored},
+	ReservedLabelK8sPVCName:      {Handling: VolumeLabelIgnored},
+	ReservedLabelK8sPVName:       {Handling: VolumeLabelIgnored},
+}
+
+// LookupVolumeLabel returns the registry entry for a reserved volume label.
+// ok is false if the key is not a reserved label that can be set on volumes.
+func LookupVolumeLabel(key string) (label VolumeLabel, ok bool) {
+	label, ok = volumeLabels[key]
+	return label, ok
+}
+
+// ValidateVolumeLabel returns an error if the reserved label can't be set on a
+// volume, or if its value is invalid.  Unreserved labels are always valid.
+func ValidateVolumeLabel(key string, value string) error {
+	if !IsReservedLabel(key) {
+		return nil
+	}
+	label, ok := LookupVolumeLabel(key)
+	switch {
+	case !ok && (key == ReservedLabelComputeOnly || key == ReservedLabelFencing):
+		return fmt.Errorf("%s: %w", key, ErrReservedLabelInvalid)
+	case !ok:
+		return fmt.Errorf("%s: %w", key, ErrReservedLabelUnknown)
+	case label.Validate == nil:
+		return nil
+	}
+	if err := label.Validate(value); err != nil {
+		return fmt.Errorf("%s: %w: %v", key, ErrReservedLabelValue, err)
+	}
+	return nil
+}
+
+// ValidateVolumeLabels returns an error listing each reserved label that can't
+// be set on a volume or has an invalid value.
+func ValidateVolumeLabels(labels map[string]string) error {
+	var errs = &multierror.Error{ErrorFormat: ListErrors}
+	for k, v := range labels {
+		if err := ValidateVolumeLabel(k, v); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// ValidateVolumeLabelUpdate returns an error listing each immutable label that
+// differs between the old and new labels.  Removing an immutable label is
+// allowed if it had the default value.
+func ValidateVolumeLabelUpdate(oldLabels map[string]string, newLabels map[string]string) error {
+	var errs = &multierror.Error{ErrorFormat: ListErrors}
+	for k, label := range volumeLabels {
+		if label.Handling != VolumeLabelImmutable {
+			continue
+		}
+		if !label.Equal(oldLabels[k], newLabels[k]) {
+			errs = multierror.Append(errs, fmt.Errorf("%s: %w", k, ErrReservedLabelFixed))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// Equal returns true if the label values are equivalent.  Unset values are
+// treated as the default.
+func (l VolumeLabel) Equal(a, b string) bool {
+	if a == "" {
+		a = l.Default
+	}
+	if b == "" {
+		b = l.Default
+	}
+	if a == b {
+		return true
+	}
+	// Compare boolean values in any format accepted by ParseBool.
+	ab, errA := strconv.ParseBool(a)
+	bb, errB := strconv.ParseBool(b)
+	return errA == nil && errB == nil && ab == bb
+}
+
+// VolumeLabelsInSync returns true if the labels on the volume already match
+// the desired labels, so that applying them with EnsureVolumeLabels would not
+// change the volume.
+//
+// Only labels that label sync manages are compared: unreserved labels and
+// reserved labels that are applied after creation.  Immutable, informational
+// and ignored reserved labels can't be changed by a sync, so are not compared.
+func VolumeLabelsInSync(current, desired map[string]string) bool {
+	for k, label := range volumeLabels {
+		if label.Handling != VolumeLabelApplied {
+			continue
+		}
+		if !label.Equal(current[k], desired[k]) {
+			return false
+		}
+	}
+	unreserved := func(labels map[string]string) map[string]string {
+		ret := make(map[string]string)
+		for k, v := range labels {
+			if !IsReservedLabel(k) {
+				ret[k] = v
+			}
+		}
+		return ret
+	}
+	return reflect.DeepEqual(unreserved(current), unreserved(desired))
+}
+
+// validateBool returns an error if the value is not a boolean.
+func validateBool(value string) error {
+	_, err := strconv.ParseBool(value)
+	return err
+}
+
+// validateReplicas returns an error if the value is not a valid number of
+// replicas.
+func validateReplicas(value string) error {
+	replicas, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	if replicas > MaxReplicas {
+		return fmt.Errorf("must be between 0 and %d", MaxReplicas)
+	}
+	return nil
+}
+
+// validateFailureMode returns an error if the value is not a failure mode
+// intent or threshold.
+func validateFailureMode(value string) error {
+	_, _, err := ParseFailureMode(value)
+	return err
+}
+
+// validateTopologyKey returns an error if the value is not a valid node label
+// key.
+func validateTopologyKey(value string) error {
+	if errs := validation.IsQualifiedName(value); len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// ValidateFailureDomain returns an error if the value can't be used as the
+// failure domain node label.
+func ValidateFailureDomain(value string) error {
+	if value == "" {
+		return fmt.Errorf("%s: %w: must not be empty", LabelFailureDomain, ErrTopologyLabelValue)
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		return fmt.Errorf("%s: %w: %s", LabelFailureDomain, ErrTopologyLabelValue, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
 // IsReservedLabel returns true if the key is a StorageOS reserved label name.
 // It does not validate whether the key is valid.
 func IsReservedLabel(key string) bool {
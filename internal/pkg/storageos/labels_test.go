@@ -1,6 +1,9 @@
 package storageos
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestIsReservedLabel(t *testing.T) {
 	testcases := []struct {
@@ -44,3 +47,193 @@ func TestIsReservedLabel(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateVolumeLabel(t *testing.T) {
+	testcases := []struct {
+		key     string
+		value   string
+		wantErr error
+	}{
+		{key: "foo", value: "anything"},
+		{key: ReservedLabelReplicas, value: "2"},
+		{key: ReservedLabelReplicas, value: "abc", wantErr: ErrReservedLabelValue},
+		{key: ReservedLabelReplicas, value: "7", wantErr: ErrReservedLabelValue},
+		{key: ReservedLabelFailureMode, value: FailureModeSoft},
+		{key: ReservedLabelFailureMode, value: "3"},
+		{key: ReservedLabelFailureMode, value: "bogus", wantErr: ErrReservedLabelValue},
+		{key: ReservedLabelNoCache, value: "true"},
+		{key: ReservedLabelNoCompress, value: "yes", wantErr: ErrReservedLabelValue},
+		{key: ReservedLabelEncryption, value: "false"},
+		{key: ReservedLabelTopologyAware, value: "true"},
+		{key: ReservedLabelTopologyKey, value: "topology.kubernetes.io/zone"},
+		{key: ReservedLabelTopologyKey, value: LabelFailureDomain},
+		{key: ReservedLabelTopologyKey, value: "not a label", wantErr: ErrReservedLabelValue},
+		{key: ReservedLabelK8sPVCName, value: "pvc1"},
+		{key: ReservedLabelComputeOnly, value: "true", wantErr: ErrReservedLabelInvalid},
+		{key: ReservedLabelPrefix + "foo", value: "true", wantErr: ErrReservedLabelUnknown},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.key+"="+tc.value, func(t *testing.T) {
+			err := ValidateVolumeLabel(tc.key, tc.value)
+			if tc.wantErr == nil && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateVolumeLabelUpdate(t *testing.T) {
+	testcases := []struct {
+		name      string
+		oldLabels map[string]string
+		newLabels map[string]string
+		wantErr   bool
+	}{
+		{
+			name:      "no labels",
+			oldLabels: nil,
+			newLabels: nil,
+		},
+		{
+			name:      "applied label changed",
+			oldLabels: map[string]string{ReservedLabelReplicas: "1"},
+			newLabels: map[string]string{ReservedLabelReplicas: "2"},
+		},
+		{
+			name:      "informational label changed",
+			oldLabels: map[string]string{ReservedLabelTopologyAware: "false"},
+			newLabels: map[string]string{ReservedLabelTopologyAware: "true"},
+		},
+		{
+			name:      "immutable label unchanged",
+			oldLabels: map[string]string{ReservedLabelNoCache: "true"},
+			newLabels: map[string]string{ReservedLabelNoCache: "true", "foo": "bar"},
+		},
+		{
+			name:      "immutable label set to default",
+			oldLabels: nil,
+			newLabels: map[string]string{ReservedLabelNoCompress: "false"},
+		},
+		{
+			name:      "immutable label equivalent value",
+			oldLabels: map[string]string{ReservedLabelEncryption: "true"},
+			newLabels: map[string]string{ReservedLabelEncryption: "1"},
+		},
+		{
+			name:      "immutable label added",
+			oldLabels: nil,
+			newLabels: map[string]string{ReservedLabelNoCache: "true"},
+			wantErr:   true,
+		},
+		{
+			name:      "immutable label removed",
+			oldLabels: map[string]string{ReservedLabelEncryption: "true"},
+			newLabels: nil,
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateVolumeLabelUpdate(tc.oldLabels, tc.newLabels)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("error = %v, wantErr %t", err, tc.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrReservedLabelFixed) {
+				t.Errorf("error = %v, want %v", err, ErrReservedLabelFixed)
+			}
+		})
+	}
+}
+
+func TestVolumeLabelsInSync(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		current map[string]string
+		desired map[string]string
+		want    bool
+	}{
+		{
+			name: "both empty",
+			want: true,
+		},
+		{
+			name:    "unreserved labels match",
+			current: map[string]string{"foo": "bar"},
+			desired: map[string]string{"foo": "bar"},
+			want:    true,
+		},
+		{
+			name:    "unreserved label changed",
+			current: map[string]string{"foo": "bar"},
+			desired: map[string]string{"foo": "baz"},
+		},
+		{
+			name:    "unreserved label removed",
+			current: map[string]string{"foo": "bar"},
+			desired: map[string]string{},
+		},
+		{
+			name:    "replicas match",
+			current: map[string]string{ReservedLabelReplicas: "1"},
+			desired: map[string]string{ReservedLabelReplicas: "1"},
+			want:    true,
+		},
+		{
+			name:    "replicas changed",
+			current: map[string]string{ReservedLabelReplicas: "1"},
+			desired: map[string]string{ReservedLabelReplicas: "2"},
+		},
+		{
+			name:    "replicas default",
+			current: map[string]string{ReservedLabelReplicas: "0"},
+			desired: nil,
+			want:    true,
+		},
+		{
+			name:    "failure mode changed",
+			current: map[string]string{ReservedLabelFailureMode: FailureModeHard},
+			desired: map[string]string{ReservedLabelFailureMode: FailureModeSoft},
+		},
+		{
+			name: "provisioner labels ignored",
+			current: map[string]string{
+				"foo":                        "bar",
+				ReservedLabelK8sPVCName:      "pvc1",
+				ReservedLabelK8sPVCNamespace: "default",
+				ReservedLabelK8sPVName:       "pv1",
+			},
+			desired: map[string]string{"foo": "bar"},
+			want:    true,
+		},
+		{
+			name:    "immutable label differs",
+			current: map[string]string{ReservedLabelNoCache: "true"},
+			desired: map[string]string{ReservedLabelNoCache: "false"},
+			want:    true,
+		},
+		{
+			name:    "informational label differs",
+			current: nil,
+			desired: map[string]string{ReservedLabelTopologyAware: "true"},
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := VolumeLabelsInSync(tt.current, tt.desired); got != tt.want {
+				t.Errorf("VolumeLabelsInSync() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -54,18 +56,35 @@ func (m MockObject) IsHealthy() bool {
 	return m.Healthy
 }
 
+// MockPolicyGroup is a policy group stored by the MockClient.
+type MockPolicyGroup struct {
+	ID    string
+	Rules []PolicyRule
+	Users []string
+}
+
 // MockClient provides a test interface to the StorageOS api.
 type MockClient struct {
+	policyGroups             map[string]MockPolicyGroup
+	users                    map[string]bool
 	sharedvols               map[string]*SharedVolume
 	namespaces               map[client.ObjectKey]Object
 	nodes                    map[client.ObjectKey]Object
 	volumes                  map[client.ObjectKey]Object
+	volumeSizes              map[client.ObjectKey]uint64
+	replicaStatuses          map[client.ObjectKey]*ReplicaStatus
+	nodeVolumes              map[client.ObjectKey][]NodeVolume
+	computeOnly              map[client.ObjectKey]bool
+	replicas                 map[client.ObjectKey]uint64
 	nodeLabels               map[string]string
 	mu                       sync.RWMutex
 	DeleteNamespaceCallCount map[client.ObjectKey]int
 	DeleteNodeCallCount      map[client.ObjectKey]int
+	DeleteVolumeCallCount    map[client.ObjectKey]int
 	ListNamespacesErr        error
 	DeleteNamespaceErr       error
+	NamespaceObjectsErr      error
+	EnsureNamespaceErr       error
 	GetNodeErr               error
 	NodeObjectsErr           error
 	ListNodesErr             error
@@ -73,23 +92,42 @@ type MockClient struct {
 	EnsureNodeLabelsErr      error
 	GetNodeLabelsErr         error
 	GetVolumeErr             error
+	NamespaceVolumesErr      error
+	DeleteVolumeErr          error
 	VolumeObjectsErr         error
 	EnsureVolumeLabelsErr    error
+	EnsureVolumeSizeErr      error
+	GetReplicaStatusErr      error
+	NodeVolumesErr           error
+	EnsureComputeOnlyErr     error
+	EnsureNodeProtectedErr   error
+	EnsureReplicasErr        error
 	SharedVolsErr            error
 	SharedVolErr             error
 	SetEndpointErr           error
+	PolicyGroupNamesErr      error
+	EnsurePolicyGroupErr     error
+	DeletePolicyGroupErr     error
 }
 
 // NewMockClient returns an initialized MockClient.
 func NewMockClient() *MockClient {
 	return &MockClient{
+		policyGroups:             make(map[string]MockPolicyGroup),
+		users:                    make(map[string]bool),
 		sharedvols:               make(map[string]*SharedVolume),
 		namespaces:               make(map[client.ObjectKey]Object),
 		nodes:                    make(map[client.ObjectKey]Object),
 		volumes:                  make(map[client.ObjectKey]Object),
+		volumeSizes:              make(map[client.ObjectKey]uint64),
+		replicaStatuses:          make(map[client.ObjectKey]*ReplicaStatus),
+		nodeVolumes:              make(map[client.ObjectKey][]NodeVolume),
+		computeOnly:              make(map[client.ObjectKey]bool),
+		replicas:                 make(map[client.ObjectKey]uint64),
 		nodeLabels:               make(map[string]string),
 		DeleteNamespaceCallCount: make(map[client.ObjectKey]int),
 		DeleteNodeCallCount:      make(map[client.ObjectKey]int),
+		DeleteVolumeCallCount:    make(map[client.ObjectKey]int),
 		mu:                       sync.RWMutex{},
 	}
 }
@@ -126,6 +164,32 @@ func (c *MockClient) NamespaceExists(key client.ObjectKey) bool {
 	return false
 }
 
+// NamespaceObjects returns a map of namespace objects, indexed on object key.
+func (c *MockClient) NamespaceObjects(ctx context.Context) (map[client.ObjectKey]Object, error) {
+	if c.NamespaceObjectsErr != nil {
+		return nil, c.NamespaceObjectsErr
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ret := make(map[client.ObjectKey]Object)
+	for k, v := range c.namespaces {
+		ret[k] = v
+	}
+	return ret, nil
+}
+
+// EnsureNamespace creates the namespace if it does not exist, and sets its
+// labels.
+func (c *MockClient) EnsureNamespace(ctx context.Context, key client.ObjectKey, labels map[string]string) error {
+	if c.EnsureNamespaceErr != nil {
+		return c.EnsureNamespaceErr
+	}
+	c.mu.Lock()
+	c.namespaces[key] = MockObject{Name: key.Name, Labels: labels}
+	c.mu.Unlock()
+	return nil
+}
+
 // DeleteNamespace removes a namespace from the StorageOS cluster.
 func (c *MockClient) DeleteNamespace(ctx context.Context, key client.ObjectKey) error {
 	c.DeleteNamespaceCallCount[key]++
@@ -165,7 +229,7 @@ func (c *MockClient) ListNodes(ctx context.Context) ([]client.Object, error) {
 			health = storageosv1.NodeHealthOffline
 		}
 		ret = append(ret, &storageosv1.Node{
-			ObjectMeta: metav1.ObjectMeta{Name: node.GetName()},
+			ObjectMeta: metav1.ObjectMeta{Name: node.GetName(), Labels: node.GetLabels()},
 			Status: storageosv1.NodeStatus{
 				Health: health,
 			},
@@ -233,6 +297,98 @@ func (c *MockClient) EnsureNodeLabels(ctx context.Context, key client.ObjectKey,
 	return errors.ErrorOrNil()
 }
 
+// EnsureComputeOnly sets the compute-only behaviour of the node.
+func (c *MockClient) EnsureComputeOnly(ctx context.Context, key client.ObjectKey, enabled bool) error {
+	if c.EnsureComputeOnlyErr != nil {
+		return c.EnsureComputeOnlyErr
+	}
+	if !c.NodeExists(key) {
+		return ErrNodeNotFound
+	}
+	c.mu.Lock()
+	c.computeOnly[key] = enabled
+	c.mu.Unlock()
+	return nil
+}
+
+// EnsureNodeProtected sets or removes the protection label on the node.
+func (c *MockClient) EnsureNodeProtected(ctx context.Context, key client.ObjectKey, protected bool) error {
+	if c.EnsureNodeProtectedErr != nil {
+		return c.EnsureNodeProtectedErr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[key]
+	if !ok {
+		return ErrNodeNotFound
+	}
+	labels := make(map[string]string)
+	for k, v := range n.GetLabels() {
+		if k != LabelNodeProtected {
+			labels[k] = v
+		}
+	}
+	if protected {
+		labels[LabelNodeProtected] = "true"
+	}
+	c.nodes[key] = MockObject{
+		ID:        n.GetID(),
+		Name:      n.GetName(),
+		Namespace: n.GetNamespace(),
+		Labels:    labels,
+		Healthy:   n.IsHealthy(),
+	}
+	return nil
+}
+
+// IsComputeOnly returns true if compute-only was enabled on the node with
+// EnsureComputeOnly.
+func (c *MockClient) IsComputeOnly(key client.ObjectKey) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.computeOnly[key]
+}
+
+// NodeVolumes returns the volumes set on the node with SetNodeVolumes.
+func (c *MockClient) NodeVolumes(ctx context.Context, key client.ObjectKey) ([]NodeVolume, error) {
+	if c.NodeVolumesErr != nil {
+		return nil, c.NodeVolumesErr
+	}
+	if !c.NodeExists(key) {
+		return nil, ErrNodeNotFound
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.nodeVolumes[key], nil
+}
+
+// SetNodeVolumes sets the volumes deployed on the node.
+func (c *MockClient) SetNodeVolumes(key client.ObjectKey, volumes []NodeVolume) {
+	c.mu.Lock()
+	c.nodeVolumes[key] = volumes
+	c.mu.Unlock()
+}
+
+// EnsureReplicas sets the number of replicas for the volume.
+func (c *MockClient) EnsureReplicas(ctx context.Context, key client.ObjectKey, desired uint64) error {
+	if c.EnsureReplicasErr != nil {
+		return c.EnsureReplicasErr
+	}
+	c.mu.Lock()
+	c.replicas[key] = desired
+	c.mu.Unlock()
+	return nil
+}
+
+// GetReplicas returns the number of replicas set for the volume with
+// EnsureReplicas.
+func (c *MockClient) GetReplicas(key client.ObjectKey) (uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	desired, ok := c.replicas[key]
+	return desired, ok
+}
+
 // GetNodeLabels retrieves the set of labels.
 func (c *MockClient) GetNodeLabels(key client.ObjectKey) (map[string]string, error) {
 	if c.GetNodeLabelsErr != nil {
@@ -301,6 +457,48 @@ func (c *MockClient) GetVolume(ctx context.Context, key client.ObjectKey) (Objec
 	return obj, nil
 }
 
+// NamespaceVolumes returns the volume objects in the namespace.
+func (c *MockClient) NamespaceVolumes(ctx context.Context, key client.ObjectKey) ([]Object, error) {
+	if c.NamespaceVolumesErr != nil {
+		return nil, c.NamespaceVolumesErr
+	}
+	if !c.NamespaceExists(key) {
+		return nil, ErrNamespaceNotFound
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ret := []Object{}
+	for k, obj := range c.volumes {
+		if k.Namespace == key.Name {
+			ret = append(ret, obj)
+		}
+	}
+	return ret, nil
+}
+
+// DeleteVolume removes a volume.
+func (c *MockClient) DeleteVolume(ctx context.Context, key client.ObjectKey) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.DeleteVolumeCallCount[key]++
+	if c.DeleteVolumeErr != nil {
+		return c.DeleteVolumeErr
+	}
+	if _, ok := c.volumes[key]; !ok {
+		return ErrVolumeNotFound
+	}
+	delete(c.volumes, key)
+	return nil
+}
+
+// VolumeExists returns true if the volume exists.
+func (c *MockClient) VolumeExists(key client.ObjectKey) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.volumes[key]
+	return ok
+}
+
 // VolumeObjects returns a map of volume objects, indexed on object key.
 func (c *MockClient) VolumeObjects(ctx context.Context) (map[client.ObjectKey]Object, error) {
 	if c.ListNodesErr != nil {
@@ -320,32 +518,113 @@ func (c *MockClient) EnsureVolumeLabels(ctx context.Context, key client.ObjectKe
 	var errors *multierror.Error
 	var newLabels = make(map[string]string)
 
-	for k, v := range labels {
-		switch {
-		case !IsReservedLabel(k):
-			newLabels[k] = v
-		case k == ReservedLabelReplicas || k == ReservedLabelFailureMode:
-			newLabels[k] = v
-		default:
-			errors = multierror.Append(errors, ErrReservedLabelUnknown)
-		}
-	}
-
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	n, ok := c.volumes[key]
 	if !ok {
 		return ErrVolumeNotFound
 	}
+
+	var replicas uint64
+	for k, v := range labels {
+		if !IsReservedLabel(k) {
+			newLabels[k] = v
+			continue
+		}
+		label, ok := LookupVolumeLabel(k)
+		switch {
+		case !ok:
+			errors = multierror.Append(errors, ValidateVolumeLabel(k, v))
+		case label.Handling == VolumeLabelApplied:
+			newLabels[k] = v
+			if k == ReservedLabelReplicas {
+				replicas, _ = strconv.ParseUint(v, 10, 64)
+			}
+		case label.Handling == VolumeLabelImmutable:
+			current := n.GetLabels()[k]
+			if !label.Equal(current, v) {
+				errors = multierror.Append(errors, ErrReservedLabelFixed)
+			}
+			if current != "" {
+				newLabels[k] = current
+			}
+		}
+	}
 	c.volumes[key] = &MockObject{
 		ID:        n.GetID(),
 		Name:      n.GetName(),
 		Namespace: n.GetNamespace(),
 		Labels:    newLabels,
 	}
+	// As with the api client, the replica count is always set, reverting to
+	// the default if the label is not set.
+	c.replicas[key] = replicas
 	return errors.ErrorOrNil()
 }
 
+// EnsureVolumeSize resizes the StorageOS volume if smaller than desired.
+func (c *MockClient) EnsureVolumeSize(ctx context.Context, key client.ObjectKey, desired uint64) (bool, error) {
+	if c.EnsureVolumeSizeErr != nil {
+		return false, c.EnsureVolumeSizeErr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.volumes[key]; !ok {
+		return false, ErrVolumeNotFound
+	}
+	if desired <= c.volumeSizes[key] {
+		return false, nil
+	}
+	c.volumeSizes[key] = desired
+	return true, nil
+}
+
+// GetVolumeSize returns the size in bytes of the volume.
+func (c *MockClient) GetVolumeSize(key client.ObjectKey) (uint64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.volumes[key]; !ok {
+		return 0, ErrVolumeNotFound
+	}
+	return c.volumeSizes[key], nil
+}
+
+// GetReplicaStatus returns the replica status set with SetReplicaStatus.  If
+// not set, the desired number of replicas are all reported as ready.
+func (c *MockClient) GetReplicaStatus(ctx context.Context, key client.ObjectKey) (*ReplicaStatus, error) {
+	if c.GetReplicaStatusErr != nil {
+		return nil, c.GetReplicaStatusErr
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	vol, ok := c.volumes[key]
+	if !ok {
+		return nil, ErrVolumeNotFound
+	}
+	if status, ok := c.replicaStatuses[key]; ok {
+		return status, nil
+	}
+	status := &ReplicaStatus{}
+	if v, ok := vol.GetLabels()[ReservedLabelReplicas]; ok {
+		desired, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		status.Desired = desired
+	}
+	for i := 0; i < status.Desired; i++ {
+		status.Replicas = append(status.Replicas, ReplicaProgress{ID: strconv.Itoa(i), Health: ReplicaHealthReady})
+	}
+	return status, nil
+}
+
+// SetReplicaStatus sets the replica status returned for the volume.
+func (c *MockClient) SetReplicaStatus(key client.ObjectKey, status *ReplicaStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.replicaStatuses[key] = status
+}
+
 // ListSharedVolumes returns a list of active shared volumes.
 func (c *MockClient) ListSharedVolumes(ctx context.Context) (SharedVolumeList, error) {
 	if c.SharedVolsErr != nil {
@@ -408,24 +687,115 @@ func (c *MockClient) Delete(id string, namespace string) {
 // Reset the shared volume list.
 func (c *MockClient) Reset() {
 	c.mu.Lock()
+	c.policyGroups = make(map[string]MockPolicyGroup)
+	c.users = make(map[string]bool)
 	c.sharedvols = make(map[string]*SharedVolume)
 	c.namespaces = make(map[client.ObjectKey]Object)
 	c.nodes = make(map[client.ObjectKey]Object)
 	c.nodeLabels = make(map[string]string)
+	c.nodeVolumes = make(map[client.ObjectKey][]NodeVolume)
+	c.computeOnly = make(map[client.ObjectKey]bool)
+	c.replicas = make(map[client.ObjectKey]uint64)
 	c.DeleteNamespaceCallCount = make(map[client.ObjectKey]int)
 	c.DeleteNodeCallCount = make(map[client.ObjectKey]int)
+	c.DeleteVolumeCallCount = make(map[client.ObjectKey]int)
 	c.ListNamespacesErr = nil
 	c.DeleteNamespaceErr = nil
+	c.NamespaceObjectsErr = nil
+	c.EnsureNamespaceErr = nil
 	c.ListNodesErr = nil
 	c.DeleteNodeErr = nil
+	c.NamespaceVolumesErr = nil
+	c.DeleteVolumeErr = nil
 	c.EnsureNodeLabelsErr = nil
 	c.GetNodeLabelsErr = nil
+	c.NodeVolumesErr = nil
+	c.EnsureComputeOnlyErr = nil
+	c.EnsureNodeProtectedErr = nil
+	c.EnsureReplicasErr = nil
 	c.SharedVolErr = nil
 	c.SharedVolsErr = nil
 	c.SetEndpointErr = nil
+	c.PolicyGroupNamesErr = nil
+	c.EnsurePolicyGroupErr = nil
+	c.DeletePolicyGroupErr = nil
 	c.mu.Unlock()
 }
 
+// AddUser adds a user to the StorageOS cluster.
+func (c *MockClient) AddUser(username string) {
+	c.mu.Lock()
+	c.users[username] = true
+	c.mu.Unlock()
+}
+
+// PolicyGroupNames returns the names of all policy groups.
+func (c *MockClient) PolicyGroupNames(ctx context.Context) ([]string, error) {
+	if c.PolicyGroupNamesErr != nil {
+		return nil, c.PolicyGroupNamesErr
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := []string{}
+	for name := range c.policyGroups {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// EnsurePolicyGroup creates or updates the policy group.  The namespaces in the
+// rules must exist.  Users that don't exist are not added.
+func (c *MockClient) EnsurePolicyGroup(ctx context.Context, name string, rules []PolicyRule, users []string) (string, error) {
+	if c.EnsurePolicyGroupErr != nil {
+		return "", c.EnsurePolicyGroupErr
+	}
+	for _, rule := range rules {
+		if !c.NamespaceExists(client.ObjectKey{Name: rule.Namespace}) {
+			return "", errors.Wrap(ErrNamespaceNotFound, rule.Namespace)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	group, ok := c.policyGroups[name]
+	if !ok {
+		group.ID = randomString(32)
+	}
+	group.Rules = rules
+	group.Users = nil
+	var errs = &multierror.Error{ErrorFormat: ListErrors}
+	for _, username := range users {
+		if !c.users[username] {
+			errs = multierror.Append(errs, errors.Wrap(ErrUserNotFound, username))
+			continue
+		}
+		group.Users = append(group.Users, username)
+	}
+	c.policyGroups[name] = group
+	return group.ID, errs.ErrorOrNil()
+}
+
+// DeletePolicyGroup removes the policy group.
+func (c *MockClient) DeletePolicyGroup(ctx context.Context, name string) error {
+	if c.DeletePolicyGroupErr != nil {
+		return c.DeletePolicyGroupErr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.policyGroups[name]; !ok {
+		return ErrPolicyGroupNotFound
+	}
+	delete(c.policyGroups, name)
+	return nil
+}
+
+// GetPolicyGroup returns the policy group, and false if it does not exist.
+func (c *MockClient) GetPolicyGroup(name string) (MockPolicyGroup, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	group, ok := c.policyGroups[name]
+	return group, ok
+}
+
 // RandomVol returns a randomly generated shared volume.  Always uses default
 // namespace since it will always exist.
 func (c *MockClient) RandomVol() *SharedVolume {
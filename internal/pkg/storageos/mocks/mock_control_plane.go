@@ -52,6 +52,38 @@ func (mr *MockControlPlaneMockRecorder) AuthenticateUser(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateUser", reflect.TypeOf((*MockControlPlane)(nil).AuthenticateUser), arg0, arg1)
 }
 
+// CreateNamespace mocks base method.
+func (m *MockControlPlane) CreateNamespace(arg0 context.Context, arg1 api.CreateNamespaceData) (api.Namespace, *http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateNamespace", arg0, arg1)
+	ret0, _ := ret[0].(api.Namespace)
+	ret1, _ := ret[1].(*http.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateNamespace indicates an expected call of CreateNamespace.
+func (mr *MockControlPlaneMockRecorder) CreateNamespace(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNamespace", reflect.TypeOf((*MockControlPlane)(nil).CreateNamespace), arg0, arg1)
+}
+
+// CreatePolicyGroup mocks base method.
+func (m *MockControlPlane) CreatePolicyGroup(arg0 context.Context, arg1 api.CreatePolicyGroupData) (api.PolicyGroup, *http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePolicyGroup", arg0, arg1)
+	ret0, _ := ret[0].(api.PolicyGroup)
+	ret1, _ := ret[1].(*http.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreatePolicyGroup indicates an expected call of CreatePolicyGroup.
+func (mr *MockControlPlaneMockRecorder) CreatePolicyGroup(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePolicyGroup", reflect.TypeOf((*MockControlPlane)(nil).CreatePolicyGroup), arg0, arg1)
+}
+
 // DeleteNamespace mocks base method.
 func (m *MockControlPlane) DeleteNamespace(arg0 context.Context, arg1, arg2 string, arg3 *api.DeleteNamespaceOpts) (*http.Response, error) {
 	m.ctrl.T.Helper()
@@ -82,6 +114,36 @@ func (mr *MockControlPlaneMockRecorder) DeleteNode(arg0, arg1, arg2, arg3 interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteNode", reflect.TypeOf((*MockControlPlane)(nil).DeleteNode), arg0, arg1, arg2, arg3)
 }
 
+// DeletePolicyGroup mocks base method.
+func (m *MockControlPlane) DeletePolicyGroup(arg0 context.Context, arg1, arg2 string, arg3 *api.DeletePolicyGroupOpts) (*http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePolicyGroup", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*http.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeletePolicyGroup indicates an expected call of DeletePolicyGroup.
+func (mr *MockControlPlaneMockRecorder) DeletePolicyGroup(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePolicyGroup", reflect.TypeOf((*MockControlPlane)(nil).DeletePolicyGroup), arg0, arg1, arg2, arg3)
+}
+
+// DeleteVolume mocks base method.
+func (m *MockControlPlane) DeleteVolume(arg0 context.Context, arg1, arg2, arg3 string, arg4 *api.DeleteVolumeOpts) (*http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteVolume", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*http.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteVolume indicates an expected call of DeleteVolume.
+func (mr *MockControlPlaneMockRecorder) DeleteVolume(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteVolume", reflect.TypeOf((*MockControlPlane)(nil).DeleteVolume), arg0, arg1, arg2, arg3, arg4)
+}
+
 // GetVolume mocks base method.
 func (m *MockControlPlane) GetVolume(arg0 context.Context, arg1, arg2 string) (api.Volume, *http.Response, error) {
 	m.ctrl.T.Helper()
@@ -130,6 +192,38 @@ func (mr *MockControlPlaneMockRecorder) ListNodes(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListNodes", reflect.TypeOf((*MockControlPlane)(nil).ListNodes), arg0)
 }
 
+// ListPolicyGroups mocks base method.
+func (m *MockControlPlane) ListPolicyGroups(arg0 context.Context) ([]api.PolicyGroup, *http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPolicyGroups", arg0)
+	ret0, _ := ret[0].([]api.PolicyGroup)
+	ret1, _ := ret[1].(*http.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListPolicyGroups indicates an expected call of ListPolicyGroups.
+func (mr *MockControlPlaneMockRecorder) ListPolicyGroups(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPolicyGroups", reflect.TypeOf((*MockControlPlane)(nil).ListPolicyGroups), arg0)
+}
+
+// ListUsers mocks base method.
+func (m *MockControlPlane) ListUsers(arg0 context.Context) ([]api.User, *http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", arg0)
+	ret0, _ := ret[0].([]api.User)
+	ret1, _ := ret[1].(*http.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockControlPlaneMockRecorder) ListUsers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockControlPlane)(nil).ListUsers), arg0)
+}
+
 // ListVolumes mocks base method.
 func (m *MockControlPlane) ListVolumes(arg0 context.Context, arg1 string) ([]api.Volume, *http.Response, error) {
 	m.ctrl.T.Helper()
@@ -162,6 +256,22 @@ func (mr *MockControlPlaneMockRecorder) RefreshJwt(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshJwt", reflect.TypeOf((*MockControlPlane)(nil).RefreshJwt), arg0)
 }
 
+// ResizeVolume mocks base method.
+func (m *MockControlPlane) ResizeVolume(arg0 context.Context, arg1, arg2 string, arg3 api.ResizeVolumeRequest, arg4 *api.ResizeVolumeOpts) (api.Volume, *http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResizeVolume", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(api.Volume)
+	ret1, _ := ret[1].(*http.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ResizeVolume indicates an expected call of ResizeVolume.
+func (mr *MockControlPlaneMockRecorder) ResizeVolume(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResizeVolume", reflect.TypeOf((*MockControlPlane)(nil).ResizeVolume), arg0, arg1, arg2, arg3, arg4)
+}
+
 // SetComputeOnly mocks base method.
 func (m *MockControlPlane) SetComputeOnly(arg0 context.Context, arg1 string, arg2 api.SetComputeOnlyNodeData, arg3 *api.SetComputeOnlyOpts) (api.Node, *http.Response, error) {
 	m.ctrl.T.Helper()
@@ -225,6 +335,22 @@ func (mr *MockControlPlaneMockRecorder) UpdateNFSVolumeMountEndpoint(arg0, arg1,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateNFSVolumeMountEndpoint", reflect.TypeOf((*MockControlPlane)(nil).UpdateNFSVolumeMountEndpoint), arg0, arg1, arg2, arg3, arg4)
 }
 
+// UpdateNamespace mocks base method.
+func (m *MockControlPlane) UpdateNamespace(arg0 context.Context, arg1 string, arg2 api.UpdateNamespaceData, arg3 *api.UpdateNamespaceOpts) (api.Namespace, *http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateNamespace", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(api.Namespace)
+	ret1, _ := ret[1].(*http.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UpdateNamespace indicates an expected call of UpdateNamespace.
+func (mr *MockControlPlaneMockRecorder) UpdateNamespace(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateNamespace", reflect.TypeOf((*MockControlPlane)(nil).UpdateNamespace), arg0, arg1, arg2, arg3)
+}
+
 // UpdateNode mocks base method.
 func (m *MockControlPlane) UpdateNode(arg0 context.Context, arg1 string, arg2 api.UpdateNodeData) (api.Node, *http.Response, error) {
 	m.ctrl.T.Helper()
@@ -241,6 +367,38 @@ func (mr *MockControlPlaneMockRecorder) UpdateNode(arg0, arg1, arg2 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateNode", reflect.TypeOf((*MockControlPlane)(nil).UpdateNode), arg0, arg1, arg2)
 }
 
+// UpdatePolicyGroup mocks base method.
+func (m *MockControlPlane) UpdatePolicyGroup(arg0 context.Context, arg1 string, arg2 api.UpdatePolicyGroupData, arg3 *api.UpdatePolicyGroupOpts) (api.PolicyGroup, *http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePolicyGroup", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(api.PolicyGroup)
+	ret1, _ := ret[1].(*http.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UpdatePolicyGroup indicates an expected call of UpdatePolicyGroup.
+func (mr *MockControlPlaneMockRecorder) UpdatePolicyGroup(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePolicyGroup", reflect.TypeOf((*MockControlPlane)(nil).UpdatePolicyGroup), arg0, arg1, arg2, arg3)
+}
+
+// UpdateUser mocks base method.
+func (m *MockControlPlane) UpdateUser(arg0 context.Context, arg1 string, arg2 api.UpdateUserData, arg3 *api.UpdateUserOpts) (api.User, *http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(api.User)
+	ret1, _ := ret[1].(*http.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockControlPlaneMockRecorder) UpdateUser(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockControlPlane)(nil).UpdateUser), arg0, arg1, arg2, arg3)
+}
+
 // UpdateVolume mocks base method.
 func (m *MockControlPlane) UpdateVolume(arg0 context.Context, arg1, arg2 string, arg3 api.UpdateVolumeData, arg4 *api.UpdateVolumeOpts) (api.Volume, *http.Response, error) {
 	m.ctrl.T.Helper()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"reflect"
 	"time"
 
 	"github.com/storageos/api-manager/internal/pkg/storageos/metrics"
@@ -46,6 +47,97 @@ func (c *Client) ListNamespaces(ctx context.Context) ([]Object, error) {
 	return objects, nil
 }
 
+// NamespaceObjects returns a map of namespace objects, indexed on object key.
+func (c *Client) NamespaceObjects(ctx context.Context) (map[client.ObjectKey]Object, error) {
+	funcName := "namespace_objects"
+	start := time.Now()
+	defer func() {
+		metrics.Latency.Observe(funcName, time.Since(start))
+	}()
+	observeErr := func(e error) error {
+		metrics.Errors.Increment(funcName, e)
+		return e
+	}
+
+	ctx = c.AddToken(ctx)
+
+	namespaces, resp, err := c.api.ListNamespaces(ctx)
+	if err != nil {
+		return nil, observeErr(api.MapAPIError(err, resp))
+	}
+	objects := make(map[client.ObjectKey]Object)
+	for _, ns := range namespaces {
+		objects[client.ObjectKey{Name: ns.GetName()}] = ns
+	}
+	return objects, nil
+}
+
+// EnsureNamespace creates the StorageOS namespace if it does not exist, and
+// applies the labels to it if they have changed.  Existing labels will be
+// overwritten.
+//
+// StorageOS reserved labels can't be set on namespaces, so any in the set of
+// labels are ignored and reserved labels already on the namespace are kept.
+func (c *Client) EnsureNamespace(ctx context.Context, key client.ObjectKey, labels map[string]string) error {
+	funcName := "ensure_namespace"
+	start := time.Now()
+	defer func() {
+		metrics.Latency.Observe(funcName, time.Since(start))
+	}()
+	observeErr := func(e error) error {
+		metrics.Errors.Increment(funcName, e)
+		return e
+	}
+
+	ctx = c.AddToken(ctx)
+
+	// Copy desired labels.  Ignore any reserved labels.
+	var applyLabels = make(map[string]string)
+	for k, v := range labels {
+		if !IsReservedLabel(k) {
+			applyLabels[k] = v
+		}
+	}
+
+	ns, err := c.getNamespace(ctx, key)
+	if err == ErrNamespaceNotFound {
+		if _, resp, err := c.api.CreateNamespace(ctx, api.CreateNamespaceData{Name: key.Name, Labels: applyLabels}); err != nil {
+			return observeErr(api.MapAPIError(err, resp))
+		}
+		return observeErr(nil)
+	}
+	if err != nil {
+		return observeErr(err)
+	}
+
+	// Re-apply reserved labels.
+	for k, v := range ns.Labels {
+		if IsReservedLabel(k) {
+			applyLabels[k] = v
+		}
+	}
+
+	// Skip update if both current and desired are empty or nil.  DeepEqual will
+	// not match empty with nil, but len treats them the same.
+	if len(ns.Labels) == 0 && len(applyLabels) == 0 {
+		return observeErr(nil)
+	}
+
+	// Skip update if unchanged.
+	if reflect.DeepEqual(ns.Labels, applyLabels) {
+		return observeErr(nil)
+	}
+
+	if _, resp, err := c.api.UpdateNamespace(ctx, ns.Id, api.UpdateNamespaceData{Labels: applyLabels, Version: ns.Version}, nil); err != nil {
+		err = observeErr(api.MapAPIError(err, resp))
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return ErrNamespaceNotFound
+		}
+		return err
+	}
+	return observeErr(nil)
+}
+
 // DeleteNamespace removes a namespace from the StorageOS cluster.  Delete will fail if
 // pre-requisites are not met (i.e. namespace has volumes).
 func (c *Client) DeleteNamespace(ctx context.Context, key client.ObjectKey) error {
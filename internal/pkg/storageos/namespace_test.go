@@ -0,0 +1,107 @@
+package storageos_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+	"github.com/storageos/api-manager/internal/pkg/storageos/mocks"
+	api "github.com/storageos/go-api/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestClient_EnsureNamespace(t *testing.T) {
+	errAPI := errors.New("api failed")
+
+	tests := []struct {
+		name       string
+		key        client.ObjectKey
+		labels     map[string]string
+		current    map[string]string
+		wantCreate *api.CreateNamespaceData
+		wantUpdate *api.UpdateNamespaceData
+		resp       *http.Response
+		err        error
+		wantErr    error
+	}{
+		{
+			name:       "create namespace",
+			key:        client.ObjectKey{Name: "new"},
+			labels:     map[string]string{"team": "a", storageos.ReservedLabelReplicas: "1"},
+			wantCreate: &api.CreateNamespaceData{Name: "new", Labels: map[string]string{"team": "a"}},
+		},
+		{
+			name:       "create namespace failed",
+			key:        client.ObjectKey{Name: "new"},
+			wantCreate: &api.CreateNamespaceData{Name: "new", Labels: map[string]string{}},
+			err:        errAPI,
+			wantErr:    errAPI,
+		},
+		{
+			name:    "labels unchanged",
+			key:     client.ObjectKey{Name: "ns1"},
+			labels:  map[string]string{"team": "a"},
+			current: map[string]string{"team": "a"},
+		},
+		{
+			name: "no labels",
+			key:  client.ObjectKey{Name: "ns1"},
+		},
+		{
+			name:       "update labels",
+			key:        client.ObjectKey{Name: "ns1"},
+			labels:     map[string]string{"team": "b"},
+			current:    map[string]string{"team": "a", "old": "label"},
+			wantUpdate: &api.UpdateNamespaceData{Labels: map[string]string{"team": "b"}, Version: "v1"},
+		},
+		{
+			name:       "keep reserved labels",
+			key:        client.ObjectKey{Name: "ns1"},
+			labels:     map[string]string{"team": "b", storageos.ReservedLabelReplicas: "2"},
+			current:    map[string]string{"team": "a", storageos.ReservedLabelReplicas: "1"},
+			wantUpdate: &api.UpdateNamespaceData{Labels: map[string]string{"team": "b", storageos.ReservedLabelReplicas: "1"}, Version: "v1"},
+		},
+		{
+			name:       "deleted concurrently",
+			key:        client.ObjectKey{Name: "ns1"},
+			labels:     map[string]string{"team": "b"},
+			wantUpdate: &api.UpdateNamespaceData{Labels: map[string]string{"team": "b"}, Version: "v1"},
+			resp:       &http.Response{StatusCode: http.StatusNotFound},
+			err:        errAPI,
+			wantErr:    storageos.ErrNamespaceNotFound,
+		},
+		{
+			name:       "update failed",
+			key:        client.ObjectKey{Name: "ns1"},
+			labels:     map[string]string{"team": "b"},
+			wantUpdate: &api.UpdateNamespaceData{Labels: map[string]string{"team": "b"}, Version: "v1"},
+			err:        errAPI,
+			wantErr:    errAPI,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockCP := mocks.NewMockControlPlane(mockCtrl)
+
+			c := storageos.NewTestAPIClient(mockCP)
+
+			mockCP.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{{Id: "ns1-id", Name: "ns1", Labels: tt.current, Version: "v1"}}, nil, nil).AnyTimes()
+			if tt.wantCreate != nil {
+				mockCP.EXPECT().CreateNamespace(gomock.Any(), *tt.wantCreate).Return(api.Namespace{}, tt.resp, tt.err).Times(1)
+			}
+			if tt.wantUpdate != nil {
+				mockCP.EXPECT().UpdateNamespace(gomock.Any(), "ns1-id", *tt.wantUpdate, nil).Return(api.Namespace{}, tt.resp, tt.err).Times(1)
+			}
+
+			if err := c.EnsureNamespace(context.Background(), tt.key, tt.labels); err != tt.wantErr {
+				t.Errorf("Client.EnsureNamespace() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -20,7 +20,8 @@ import (
 // ("storageos.com/") will need to be processed separately as most have
 // individual API endpoints to ensure that they are applied atomically.
 //
-// Unreserved labels are copied as a blob and are not evaluated.
+// Unreserved labels are copied as a blob and are not evaluated, except for the
+// failure domain label which must have a valid value.
 func (c *Client) EnsureNodeLabels(ctx context.Context, key client.ObjectKey, labels map[string]string) error {
 	var unreservedLabels = make(map[string]string)
 	var computeOnly = false
@@ -29,18 +30,30 @@ func (c *Client) EnsureNodeLabels(ctx context.Context, key client.ObjectKey, lab
 
 	for k, v := range labels {
 		switch {
+		case k == LabelFailureDomain:
+			// Don't apply an invalid failure domain, as topology-aware
+			// placement would treat it as a separate domain.
+			if err := ValidateFailureDomain(v); err != nil {
+				errs = multierror.Append(errs, err)
+				continue
+			}
+			unreservedLabels[k] = v
 		case !IsReservedLabel(k):
 			unreservedLabels[k] = v
 		case k == ReservedLabelNoCache ||
 			k == ReservedLabelNoCompress ||
 			k == ReservedLabelFailureMode ||
-			k == ReservedLabelReplicas:
+			k == ReservedLabelReplicas ||
+			k == ReservedLabelTopologyAware ||
+			k == ReservedLabelTopologyKey:
 			errs = multierror.Append(errs, errors.Wrap(ErrReservedLabelInvalid, k))
 		case k == ReservedLabelComputeOnly:
 			computeOnly, err = strconv.ParseBool(v)
 			if err != nil {
 				errs = multierror.Append(errs, errors.Wrap(err, k))
 			}
+		case k == ReservedLabelProtectNode:
+			// Handled by the node delete controller.
 		default:
 			errs = multierror.Append(errs, errors.Wrap(ErrReservedLabelUnknown, k))
 		}
@@ -85,18 +98,20 @@ func (c *Client) EnsureUnreservedNodeLabels(ctx context.Context, key client.Obje
 		return observeErr(err)
 	}
 
-	// Copy desired labels.  Ignore any reserved labels.
+	// Copy desired labels.  Ignore any reserved labels and the protection
+	// label, which is set with EnsureNodeProtected.
 	var applyLabels = make(map[string]string)
 	for k, v := range labels {
-		if !IsReservedLabel(k) {
+		if !IsReservedLabel(k) && k != LabelNodeProtected {
 			applyLabels[k] = v
 		}
 	}
 
 	// Re-apply reserved labels (must have the same reserved labels & values as
-	// current node or the update will fail validation).
+	// current node or the update will fail validation), and keep the
+	// protection label.
 	for k, v := range node.Labels {
-		if IsReservedLabel(k) {
+		if IsReservedLabel(k) || k == LabelNodeProtected {
 			applyLabels[k] = v
 		}
 	}
@@ -112,12 +127,60 @@ func (c *Client) EnsureUnreservedNodeLabels(ctx context.Context, key client.Obje
 		return observeErr(nil)
 	}
 
-	if _, resp, err := c.api.UpdateNode(ctx, node.Id, api.UpdateNodeData{Labels: labels, Version: node.Version}); err != nil {
+	if _, resp, err := c.api.UpdateNode(ctx, node.Id, api.UpdateNodeData{Labels: applyLabels, Version: node.Version}); err != nil {
+		return observeErr(api.MapAPIError(err, resp))
+	}
+	return observeErr(nil)
+}
+
+// EnsureNodeProtected sets or removes the protection label on the StorageOS
+// node.  All other labels are unchanged.
+func (c *Client) EnsureNodeProtected(ctx context.Context, key client.ObjectKey, protected bool) error {
+	funcName := "ensure_node_protected"
+	start := time.Now()
+	defer func() {
+		metrics.Latency.Observe(funcName, time.Since(start))
+	}()
+	observeErr := func(e error) error {
+		metrics.Errors.Increment(funcName, e)
+		return e
+	}
+
+	ctx = c.AddToken(ctx)
+
+	node, err := c.getNodeByKey(ctx, key)
+	if err != nil {
+		return observeErr(err)
+	}
+
+	// No change required.
+	if NodeProtected(node.Labels) == protected {
+		return observeErr(nil)
+	}
+
+	var applyLabels = make(map[string]string)
+	for k, v := range node.Labels {
+		if k != LabelNodeProtected {
+			applyLabels[k] = v
+		}
+	}
+	if protected {
+		applyLabels[LabelNodeProtected] = strconv.FormatBool(protected)
+	}
+
+	if _, resp, err := c.api.UpdateNode(ctx, node.Id, api.UpdateNodeData{Labels: applyLabels, Version: node.Version}); err != nil {
 		return observeErr(api.MapAPIError(err, resp))
 	}
 	return observeErr(nil)
 }
 
+// NodeProtected returns true if the StorageOS node labels have the protection
+// label set.
+func NodeProtected(labels map[string]string) bool {
+	protected, _ := strconv.ParseBool(labels[LabelNodeProtected])
+	return protected
+}
+
 // EnsureComputeOnly ensures that the compute-only behaviour has been applied to
 // the StorageOS node.
 func (c *Client) EnsureComputeOnly(ctx context.Context, key client.ObjectKey, enabled bool) error {
@@ -61,6 +61,66 @@ func TestClient_EnsureNodeLabels(t *testing.T) {
 				m.EXPECT().UpdateNode(gomock.Any(), id, updateData).Return(api.Node{}, nil, nil).Times(1)
 			},
 		},
+		{
+			name: "add failure domain label",
+			labels: map[string]string{
+				storageos.LabelFailureDomain: "eu-west-1a",
+			},
+			prepare: func(name string, m *mocks.MockControlPlane) {
+				id := uuid.New().String()
+				node := api.Node{
+					Id:   id,
+					Name: name,
+				}
+				updateData := api.UpdateNodeData{
+					Labels: map[string]string{
+						storageos.LabelFailureDomain: "eu-west-1a",
+					},
+				}
+
+				m.EXPECT().ListNodes(gomock.Any()).Return([]api.Node{node}, nil, nil).Times(2)
+				m.EXPECT().UpdateNode(gomock.Any(), id, updateData).Return(api.Node{}, nil, nil).Times(1)
+			},
+		},
+		{
+			name: "invalid failure domain label",
+			labels: map[string]string{
+				"foo":                        "bar",
+				storageos.LabelFailureDomain: "not/valid",
+			},
+			prepare: func(name string, m *mocks.MockControlPlane) {
+				id := uuid.New().String()
+				node := api.Node{
+					Id:   id,
+					Name: name,
+				}
+				updateData := api.UpdateNodeData{
+					Labels: map[string]string{
+						"foo": "bar",
+					},
+				}
+
+				m.EXPECT().ListNodes(gomock.Any()).Return([]api.Node{node}, nil, nil).Times(2)
+				m.EXPECT().UpdateNode(gomock.Any(), id, updateData).Return(api.Node{}, nil, nil).Times(1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "topology-aware label on node",
+			labels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "true",
+			},
+			prepare: func(name string, m *mocks.MockControlPlane) {
+				id := uuid.New().String()
+				node := api.Node{
+					Id:   id,
+					Name: name,
+				}
+
+				m.EXPECT().ListNodes(gomock.Any()).Return([]api.Node{node}, nil, nil).Times(2)
+			},
+			wantErr: true,
+		},
 		{
 			name: "add computeonly label",
 			labels: map[string]string{
@@ -138,6 +198,21 @@ func TestClient_EnsureNodeLabels(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "protect-node label not applied",
+			labels: map[string]string{
+				storageos.ReservedLabelProtectNode: "true",
+			},
+			prepare: func(name string, m *mocks.MockControlPlane) {
+				id := uuid.New().String()
+				node := api.Node{
+					Id:   id,
+					Name: name,
+				}
+
+				m.EXPECT().ListNodes(gomock.Any()).Return([]api.Node{node}, nil, nil).Times(2)
+			},
+		},
 		{
 			name: "add mixed labels",
 			labels: map[string]string{
@@ -349,6 +424,32 @@ func TestClient_EnsureUnreservedNodeLabels(t *testing.T) {
 				m.EXPECT().UpdateNode(gomock.Any(), nodeId, updateData).Return(api.Node{}, nil, nil).Times(1)
 			},
 		},
+		{
+			name: "keep protection label",
+			labels: map[string]string{
+				"foo": "baz",
+			},
+			prepare: func(name string, m *mocks.MockControlPlane) {
+				nodeId := uuid.New().String()
+				node := api.Node{
+					Id:   nodeId,
+					Name: name,
+					Labels: map[string]string{
+						"foo":                        "bar",
+						storageos.LabelNodeProtected: "true",
+					},
+				}
+				updateData := api.UpdateNodeData{
+					Labels: map[string]string{
+						"foo":                        "baz",
+						storageos.LabelNodeProtected: "true",
+					},
+				}
+
+				m.EXPECT().ListNodes(gomock.Any()).Return([]api.Node{node}, nil, nil).Times(1)
+				m.EXPECT().UpdateNode(gomock.Any(), nodeId, updateData).Return(api.Node{}, nil, nil).Times(1)
+			},
+		},
 		// Restricted label changes are handled by other Ensure functions.  Just
 		// check no updates are made and no errors when changes are passed.
 		{
@@ -438,3 +539,60 @@ func TestClient_EnsureUnreservedNodeLabels(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_EnsureNodeProtected(t *testing.T) {
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		protected bool
+		want      map[string]string
+	}{
+		{
+			name:      "protect",
+			labels:    map[string]string{"foo": "bar", storageos.ReservedLabelComputeOnly: "true"},
+			protected: true,
+			want:      map[string]string{"foo": "bar", storageos.ReservedLabelComputeOnly: "true", storageos.LabelNodeProtected: "true"},
+		},
+		{
+			name:      "unprotect",
+			labels:    map[string]string{"foo": "bar", storageos.LabelNodeProtected: "true"},
+			protected: false,
+			want:      map[string]string{"foo": "bar"},
+		},
+		{
+			name:      "already protected",
+			labels:    map[string]string{storageos.LabelNodeProtected: "true"},
+			protected: true,
+		},
+		{
+			name:      "not protected",
+			labels:    map[string]string{"foo": "bar"},
+			protected: false,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockCP := mocks.NewMockControlPlane(mockCtrl)
+
+			c := storageos.NewTestAPIClient(mockCP)
+
+			nodeId := uuid.New().String()
+			node := api.Node{
+				Id:     nodeId,
+				Name:   "testnode",
+				Labels: tt.labels,
+			}
+			mockCP.EXPECT().ListNodes(gomock.Any()).Return([]api.Node{node}, nil, nil).Times(1)
+			if tt.want != nil {
+				mockCP.EXPECT().UpdateNode(gomock.Any(), nodeId, api.UpdateNodeData{Labels: tt.want}).Return(api.Node{}, nil, nil).Times(1)
+			}
+
+			if err := c.EnsureNodeProtected(context.Background(), client.ObjectKey{Name: node.Name}, tt.protected); err != nil {
+				t.Errorf("Client.EnsureNodeProtected() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package storageos
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	api "github.com/storageos/go-api/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos/metrics"
+)
+
+// NodeVolume is a volume with its master or a replica deployed on a node.
+type NodeVolume struct {
+	// Key is the volume name and namespace name.
+	Key client.ObjectKey
+
+	// PVC is the Kubernetes PVC the volume was provisioned for.  It is empty
+	// if the volume was not created by the CSI provisioner.
+	PVC client.ObjectKey
+
+	// Master is true if the volume master is deployed on the node.
+	Master bool
+
+	// Replica is true if a volume replica is deployed on the node.
+	Replica bool
+
+	// Replicas is the number of replicas requested for the volume.
+	Replicas int
+
+	// ReadyElsewhere is the number of healthy copies of the volume, master
+	// and replicas, deployed on other nodes.
+	ReadyElsewhere int
+
+	// ReadyReplicasElsewhere is the number of in sync replicas, not counting
+	// the master, deployed on other nodes.
+	ReadyReplicasElsewhere int
+}
+
+// NodeVolumes returns the volumes that have their master or a replica deployed
+// on the StorageOS node matching the key.
+func (c *Client) NodeVolumes(ctx context.Context, key client.ObjectKey) ([]NodeVolume, error) {
+	funcName := "node_volumes"
+	start := time.Now()
+	defer func() {
+		metrics.Latency.Observe(funcName, time.Since(start))
+	}()
+	observeErr := func(e error) error {
+		metrics.Errors.Increment(funcName, e)
+		return e
+	}
+
+	ctx = c.AddToken(ctx)
+
+	node, err := c.getNodeByKey(ctx, key)
+	if err != nil {
+		return nil, observeErr(err)
+	}
+	namespaces, err := c.ListNamespaces(ctx)
+	if err != nil {
+		return nil, observeErr(err)
+	}
+
+	var ret []NodeVolume
+	for _, ns := range namespaces {
+		volumes, resp, err := c.api.ListVolumes(ctx, ns.GetID())
+		if err != nil {
+			return nil, observeErr(api.MapAPIError(err, resp))
+		}
+		for _, vol := range volumes {
+			if nv, ok := nodeVolume(vol, ns.GetName(), node.Id); ok {
+				ret = append(ret, nv)
+			}
+		}
+	}
+	return ret, observeErr(nil)
+}
+
+// nodeVolume returns the NodeVolume for the volume, and whether the volume has
+// a deployment on the node.
+func nodeVolume(vol api.Volume, namespace string, nodeID string) (NodeVolume, bool) {
+	nv := NodeVolume{
+		Key: client.ObjectKey{Name: vol.Name, Namespace: namespace},
+		PVC: client.ObjectKey{
+			Name:      vol.Labels[ReservedLabelK8sPVCName],
+			Namespace: vol.Labels[ReservedLabelK8sPVCNamespace],
+		},
+	}
+	// An invalid replicas label is treated as no replicas, as that is what
+	// label sync would apply.
+	nv.Replicas, _ = strconv.Atoi(vol.Labels[ReservedLabelReplicas])
+
+	switch {
+	case vol.Master.NodeID == nodeID:
+		nv.Master = true
+	case vol.Master.Health == api.MASTERHEALTH_ONLINE:
+		nv.ReadyElsewhere++
+	}
+	if vol.Replicas != nil {
+		for _, r := range *vol.Replicas {
+			switch {
+			case r.NodeID == nodeID:
+				nv.Replica = true
+			case r.Health == api.REPLICAHEALTH_READY:
+				nv.ReadyElsewhere++
+				nv.ReadyReplicasElsewhere++
+			}
+		}
+	}
+	return nv, nv.Master || nv.Replica
+}
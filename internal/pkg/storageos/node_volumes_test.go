@@ -0,0 +1,97 @@
+package storageos_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+	"github.com/storageos/api-manager/internal/pkg/storageos/mocks"
+	api "github.com/storageos/go-api/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestClient_NodeVolumes(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockCP := mocks.NewMockControlPlane(mockCtrl)
+
+	c := storageos.NewTestAPIClient(mockCP)
+
+	pvcLabels := func(name string) map[string]string {
+		return map[string]string{
+			storageos.ReservedLabelK8sPVCName:      name,
+			storageos.ReservedLabelK8sPVCNamespace: "default",
+			storageos.ReservedLabelReplicas:        "1",
+		}
+	}
+	replicas := func(r ...api.ReplicaDeploymentInfo) *[]api.ReplicaDeploymentInfo {
+		return &r
+	}
+
+	nodes := []api.Node{{Id: "node1-id", Name: "node1"}, {Id: "node2-id", Name: "node2"}}
+	namespaces := []api.Namespace{{Id: "ns1-id", Name: "default"}}
+	volumes := []api.Volume{
+		{
+			Name:     "master-on-node",
+			Labels:   pvcLabels("pvc1"),
+			Master:   api.MasterDeploymentInfo{NodeID: "node1-id", Health: api.MASTERHEALTH_ONLINE},
+			Replicas: replicas(api.ReplicaDeploymentInfo{NodeID: "node2-id", Health: api.REPLICAHEALTH_READY}),
+		},
+		{
+			Name:     "replica-on-node",
+			Labels:   pvcLabels("pvc2"),
+			Master:   api.MasterDeploymentInfo{NodeID: "node2-id", Health: api.MASTERHEALTH_ONLINE},
+			Replicas: replicas(api.ReplicaDeploymentInfo{NodeID: "node1-id", Health: api.REPLICAHEALTH_READY}),
+		},
+		{
+			Name:   "other-node",
+			Labels: pvcLabels("pvc3"),
+			Master: api.MasterDeploymentInfo{NodeID: "node2-id", Health: api.MASTERHEALTH_ONLINE},
+		},
+	}
+
+	mockCP.EXPECT().ListNodes(gomock.Any()).Return(nodes, nil, nil).Times(1)
+	mockCP.EXPECT().ListNamespaces(gomock.Any()).Return(namespaces, nil, nil).Times(1)
+	mockCP.EXPECT().ListVolumes(gomock.Any(), "ns1-id").Return(volumes, nil, nil).Times(1)
+
+	got, err := c.NodeVolumes(context.Background(), client.ObjectKey{Name: "node1"})
+	if err != nil {
+		t.Fatalf("Client.NodeVolumes() unexpected error: %v", err)
+	}
+	want := []storageos.NodeVolume{
+		{
+			Key:                    client.ObjectKey{Name: "master-on-node", Namespace: "default"},
+			PVC:                    client.ObjectKey{Name: "pvc1", Namespace: "default"},
+			Master:                 true,
+			Replicas:               1,
+			ReadyElsewhere:         1,
+			ReadyReplicasElsewhere: 1,
+		},
+		{
+			Key:            client.ObjectKey{Name: "replica-on-node", Namespace: "default"},
+			PVC:            client.ObjectKey{Name: "pvc2", Namespace: "default"},
+			Replica:        true,
+			Replicas:       1,
+			ReadyElsewhere: 1,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Client.NodeVolumes() = %+v, want %+v", got, want)
+	}
+}
+
+func TestClient_NodeVolumesNodeNotFound(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockCP := mocks.NewMockControlPlane(mockCtrl)
+
+	c := storageos.NewTestAPIClient(mockCP)
+
+	mockCP.EXPECT().ListNodes(gomock.Any()).Return([]api.Node{}, nil, nil).Times(1)
+
+	if _, err := c.NodeVolumes(context.Background(), client.ObjectKey{Name: "node1"}); err != storageos.ErrNodeNotFound {
+		t.Errorf("Client.NodeVolumes() error = %v, want %v", err, storageos.ErrNodeNotFound)
+	}
+}
@@ -0,0 +1,293 @@
+package storageos
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	api "github.com/storageos/go-api/v2"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos/metrics"
+)
+
+// PolicyResourceAll is the policy resource type that grants access to all
+// resource types in a namespace.
+const PolicyResourceAll = "*"
+
+var (
+	// ErrPolicyGroupNotFound is returned if a policy group was provided but it
+	// was not found.
+	ErrPolicyGroupNotFound = errors.New("policy group not found")
+
+	// ErrUserNotFound is returned if a user was provided but it was not found.
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// PolicyRule grants access to a resource type in a StorageOS namespace.
+type PolicyRule struct {
+	// Namespace is the name of the namespace.
+	Namespace string
+
+	// ResourceType is the type of resource that access is granted to.  All
+	// resource types are granted if empty.
+	ResourceType string
+
+	// ReadOnly disallows requests that would modify the resources.
+	ReadOnly bool
+}
+
+// PolicyGroupNames returns the names of all StorageOS policy groups.
+func (c *Client) PolicyGroupNames(ctx context.Context) ([]string, error) {
+	funcName := "policy_group_names"
+	start := time.Now()
+	defer func() {
+		metrics.Latency.Observe(funcName, time.Since(start))
+	}()
+	observeErr := func(e error) error {
+		metrics.Errors.Increment(funcName, e)
+		return e
+	}
+
+	ctx = c.AddToken(ctx)
+
+	groups, resp, err := c.api.ListPolicyGroups(ctx)
+	if err != nil {
+		return nil, observeErr(api.MapAPIError(err, resp))
+	}
+	names := []string{}
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names, nil
+}
+
+// EnsurePolicyGroup creates the StorageOS policy group if it does not exist,
+// and applies the rules to it if they have changed.  The users are then made
+// members of the policy group, and any other users removed from it.  The
+// policy group ID is returned.
+//
+// The namespaces in the rules must exist in StorageOS, otherwise
+// ErrNamespaceNotFound is returned without changing the policy group.  Users
+// that don't exist are skipped, and ErrUserNotFound is returned once the
+// remaining users have been updated.
+func (c *Client) EnsurePolicyGroup(ctx context.Context, name string, rules []PolicyRule, users []string) (string, error) {
+	funcName := "ensure_policy_group"
+	start := time.Now()
+	defer func() {
+		metrics.Latency.Observe(funcName, time.Since(start))
+	}()
+	observeErr := func(e error) error {
+		metrics.Errors.Increment(funcName, e)
+		return e
+	}
+
+	ctx = c.AddToken(ctx)
+
+	specs, err := c.policySpecs(ctx, rules)
+	if err != nil {
+		return "", observeErr(err)
+	}
+
+	group, err := c.getPolicyGroup(ctx, name)
+	switch {
+	case err == ErrPolicyGroupNotFound:
+		createSpecs := []api.PoliciesSpecs{}
+		for _, spec := range specs {
+			createSpecs = append(createSpecs, api.PoliciesSpecs(spec))
+		}
+		created, resp, err := c.api.CreatePolicyGroup(ctx, api.CreatePolicyGroupData{Name: name, Specs: &createSpecs})
+		if err != nil {
+			return "", observeErr(api.MapAPIError(err, resp))
+		}
+		group = &created
+	case err != nil:
+		return "", observeErr(err)
+	case !policySpecsEqual(currentSpecs(group), specs):
+		updated, resp, err := c.api.UpdatePolicyGroup(ctx, group.Id, api.UpdatePolicyGroupData{Specs: &specs, Version: group.Version}, nil)
+		if err != nil {
+			err = observeErr(api.MapAPIError(err, resp))
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return "", ErrPolicyGroupNotFound
+			}
+			return "", err
+		}
+		group = &updated
+	}
+
+	if err := c.ensurePolicyGroupMembers(ctx, group.Id, users); err != nil {
+		return group.Id, observeErr(err)
+	}
+	return group.Id, observeErr(nil)
+}
+
+// DeletePolicyGroup removes all users from the StorageOS policy group, then
+// deletes it.
+func (c *Client) DeletePolicyGroup(ctx context.Context, name string) error {
+	funcName := "delete_policy_group"
+	start := time.Now()
+	defer func() {
+		metrics.Latency.Observe(funcName, time.Since(start))
+	}()
+	observeErr := func(e error) error {
+		metrics.Errors.Increment(funcName, e)
+		return e
+	}
+
+	ctx = c.AddToken(ctx)
+
+	group, err := c.getPolicyGroup(ctx, name)
+	if err != nil {
+		return observeErr(err)
+	}
+	if err := c.ensurePolicyGroupMembers(ctx, group.Id, nil); err != nil {
+		return observeErr(err)
+	}
+
+	resp, err := c.api.DeletePolicyGroup(ctx, group.Id, group.Version, nil)
+	if err != nil {
+		err = observeErr(api.MapAPIError(err, resp))
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return ErrPolicyGroupNotFound
+		}
+		return err
+	}
+	return observeErr(nil)
+}
+
+// getPolicyGroup returns the StorageOS policy group matching the name, if any.
+func (c *Client) getPolicyGroup(ctx context.Context, name string) (*api.PolicyGroup, error) {
+	groups, resp, err := c.api.ListPolicyGroups(ctx)
+	if err != nil {
+		return nil, api.MapAPIError(err, resp)
+	}
+	for _, group := range groups {
+		if group.Name == name {
+			return &group, nil
+		}
+	}
+	return nil, ErrPolicyGroupNotFound
+}
+
+// policySpecs converts the rules to StorageOS policy specs, looking up the
+// namespace IDs.
+func (c *Client) policySpecs(ctx context.Context, rules []PolicyRule) ([]api.PoliciesIdSpecs, error) {
+	if len(rules) == 0 {
+		return []api.PoliciesIdSpecs{}, nil
+	}
+	namespaces, resp, err := c.api.ListNamespaces(ctx)
+	if err != nil {
+		return nil, api.MapAPIError(err, resp)
+	}
+	ids := make(map[string]string)
+	for _, ns := range namespaces {
+		ids[ns.Name] = ns.Id
+	}
+
+	specs := []api.PoliciesIdSpecs{}
+	for _, rule := range rules {
+		id, ok := ids[rule.Namespace]
+		if !ok {
+			return nil, errors.Wrap(ErrNamespaceNotFound, rule.Namespace)
+		}
+		resourceType := rule.ResourceType
+		if resourceType == "" {
+			resourceType = PolicyResourceAll
+		}
+		specs = append(specs, api.PoliciesIdSpecs{NamespaceID: id, ResourceType: resourceType, ReadOnly: rule.ReadOnly})
+	}
+	return specs, nil
+}
+
+// ensurePolicyGroupMembers adds the policy group to the users, and removes it
+// from all other users.
+func (c *Client) ensurePolicyGroupMembers(ctx context.Context, groupID string, usernames []string) error {
+	var errs = &multierror.Error{ErrorFormat: ListErrors}
+
+	users, resp, err := c.api.ListUsers(ctx)
+	if err != nil {
+		return api.MapAPIError(err, resp)
+	}
+
+	desired := make(map[string]bool)
+	for _, username := range usernames {
+		desired[username] = true
+	}
+
+	found := make(map[string]bool)
+	for _, user := range users {
+		found[user.Username] = true
+
+		var groups []string
+		if user.Groups != nil {
+			groups = *user.Groups
+		}
+		isMember := false
+		for _, g := range groups {
+			if g == groupID {
+				isMember = true
+				break
+			}
+		}
+		if isMember == desired[user.Username] {
+			continue
+		}
+
+		update := []string{}
+		for _, g := range groups {
+			if g != groupID {
+				update = append(update, g)
+			}
+		}
+		if !isMember {
+			update = append(update, groupID)
+		}
+
+		// IsAdmin must be set, otherwise it will be removed from the user.
+		if _, resp, err := c.api.UpdateUser(ctx, user.Id, api.UpdateUserData{IsAdmin: user.IsAdmin, Groups: &update, Version: user.Version}, nil); err != nil {
+			errs = multierror.Append(errs, errors.Wrap(api.MapAPIError(err, resp), user.Username))
+		}
+	}
+
+	for _, username := range usernames {
+		if !found[username] {
+			errs = multierror.Append(errs, errors.Wrap(ErrUserNotFound, username))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// currentSpecs returns the policy specs set on the policy group.
+func currentSpecs(group *api.PolicyGroup) []api.PoliciesIdSpecs {
+	if group.Specs == nil {
+		return nil
+	}
+	return *group.Specs
+}
+
+// policySpecsEqual returns true if both sets of policy specs are the same,
+// ignoring order.
+func policySpecsEqual(a, b []api.PoliciesIdSpecs) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return reflect.DeepEqual(sortedSpecs(a), sortedSpecs(b))
+}
+
+// sortedSpecs returns a sorted copy of the policy specs.
+func sortedSpecs(specs []api.PoliciesIdSpecs) []api.PoliciesIdSpecs {
+	sorted := append([]api.PoliciesIdSpecs{}, specs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].NamespaceID != sorted[j].NamespaceID {
+			return sorted[i].NamespaceID < sorted[j].NamespaceID
+		}
+		if sorted[i].ResourceType != sorted[j].ResourceType {
+			return sorted[i].ResourceType < sorted[j].ResourceType
+		}
+		return !sorted[i].ReadOnly && sorted[j].ReadOnly
+	})
+	return sorted
+}
@@ -0,0 +1,215 @@
+package storageos_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+	"github.com/storageos/api-manager/internal/pkg/storageos/mocks"
+	api "github.com/storageos/go-api/v2"
+)
+
+func specs(s ...api.PoliciesIdSpecs) *[]api.PoliciesIdSpecs {
+	if s == nil {
+		s = []api.PoliciesIdSpecs{}
+	}
+	return &s
+}
+
+func groups(g ...string) *[]string {
+	if g == nil {
+		g = []string{}
+	}
+	return &g
+}
+
+func TestClient_EnsurePolicyGroup(t *testing.T) {
+	errAPI := errors.New("api failed")
+
+	ns1All := api.PoliciesIdSpecs{NamespaceID: "ns1-id", ResourceType: storageos.PolicyResourceAll}
+	ns2VolRO := api.PoliciesIdSpecs{NamespaceID: "ns2-id", ResourceType: "volume", ReadOnly: true}
+
+	tests := []struct {
+		name        string
+		rules       []storageos.PolicyRule
+		users       []string
+		current     *api.PolicyGroup
+		currentUser []api.User
+		createErr   error
+		wantCreate  *api.CreatePolicyGroupData
+		wantUpdate  *api.UpdatePolicyGroupData
+		wantUsers   map[string]api.UpdateUserData
+		wantID      string
+		wantErr     error
+	}{
+		{
+			name:  "create policy group",
+			rules: []storageos.PolicyRule{{Namespace: "ns1"}, {Namespace: "ns2", ResourceType: "volume", ReadOnly: true}},
+			users: []string{"alice"},
+			currentUser: []api.User{
+				{Id: "alice-id", Username: "alice", Groups: groups("other-id"), Version: "u1"},
+				{Id: "bob-id", Username: "bob", Version: "u1"},
+			},
+			wantCreate: &api.CreatePolicyGroupData{Name: "group", Specs: &[]api.PoliciesSpecs{
+				{NamespaceID: "ns1-id", ResourceType: storageos.PolicyResourceAll},
+				{NamespaceID: "ns2-id", ResourceType: "volume", ReadOnly: true},
+			}},
+			wantUsers: map[string]api.UpdateUserData{
+				"alice-id": {Groups: groups("other-id", "new-id"), Version: "u1"},
+			},
+			wantID: "new-id",
+		},
+		{
+			name:       "create policy group failed",
+			wantCreate: &api.CreatePolicyGroupData{Name: "group", Specs: &[]api.PoliciesSpecs{}},
+			createErr:  errAPI,
+			wantErr:    errAPI,
+		},
+		{
+			name:    "namespace not found",
+			rules:   []storageos.PolicyRule{{Namespace: "ns1"}, {Namespace: "missing"}},
+			wantErr: storageos.ErrNamespaceNotFound,
+		},
+		{
+			name:    "policy group unchanged",
+			rules:   []storageos.PolicyRule{{Namespace: "ns2", ResourceType: "volume", ReadOnly: true}, {Namespace: "ns1"}},
+			users:   []string{"alice"},
+			current: &api.PolicyGroup{Id: "group-id", Name: "group", Specs: specs(ns1All, ns2VolRO), Version: "v1"},
+			currentUser: []api.User{
+				{Id: "alice-id", Username: "alice", Groups: groups("group-id"), Version: "u1"},
+			},
+			wantID: "group-id",
+		},
+		{
+			name:       "update rules",
+			rules:      []storageos.PolicyRule{{Namespace: "ns1"}},
+			current:    &api.PolicyGroup{Id: "group-id", Name: "group", Specs: specs(ns1All, ns2VolRO), Version: "v1"},
+			wantUpdate: &api.UpdatePolicyGroupData{Specs: specs(ns1All), Version: "v1"},
+			wantID:     "group-id",
+		},
+		{
+			name:    "update members",
+			users:   []string{"bob"},
+			current: &api.PolicyGroup{Id: "group-id", Name: "group", Version: "v1"},
+			currentUser: []api.User{
+				{Id: "alice-id", Username: "alice", Groups: groups("other-id", "group-id"), Version: "u1"},
+				{Id: "bob-id", Username: "bob", IsAdmin: true, Version: "u2"},
+			},
+			wantUsers: map[string]api.UpdateUserData{
+				"alice-id": {Groups: groups("other-id"), Version: "u1"},
+				"bob-id":   {IsAdmin: true, Groups: groups("group-id"), Version: "u2"},
+			},
+			wantID: "group-id",
+		},
+		{
+			name:    "user not found",
+			users:   []string{"alice", "carol"},
+			current: &api.PolicyGroup{Id: "group-id", Name: "group", Version: "v1"},
+			currentUser: []api.User{
+				{Id: "alice-id", Username: "alice", Version: "u1"},
+			},
+			wantUsers: map[string]api.UpdateUserData{
+				"alice-id": {Groups: groups("group-id"), Version: "u1"},
+			},
+			wantID:  "group-id",
+			wantErr: storageos.ErrUserNotFound,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockCP := mocks.NewMockControlPlane(mockCtrl)
+
+			c := storageos.NewTestAPIClient(mockCP)
+
+			mockCP.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{{Id: "ns1-id", Name: "ns1"}, {Id: "ns2-id", Name: "ns2"}}, nil, nil).AnyTimes()
+			var current []api.PolicyGroup
+			if tt.current != nil {
+				current = append(current, *tt.current)
+			}
+			mockCP.EXPECT().ListPolicyGroups(gomock.Any()).Return(current, nil, nil).AnyTimes()
+			mockCP.EXPECT().ListUsers(gomock.Any()).Return(tt.currentUser, nil, nil).AnyTimes()
+			if tt.wantCreate != nil {
+				mockCP.EXPECT().CreatePolicyGroup(gomock.Any(), *tt.wantCreate).Return(api.PolicyGroup{Id: "new-id", Name: "group"}, nil, tt.createErr).Times(1)
+			}
+			if tt.wantUpdate != nil {
+				mockCP.EXPECT().UpdatePolicyGroup(gomock.Any(), "group-id", *tt.wantUpdate, nil).Return(*tt.current, nil, nil).Times(1)
+			}
+			for id, data := range tt.wantUsers {
+				mockCP.EXPECT().UpdateUser(gomock.Any(), id, data, nil).Return(api.User{}, nil, nil).Times(1)
+			}
+
+			id, err := c.EnsurePolicyGroup(context.Background(), "group", tt.rules, tt.users)
+			if !errors.Is(err, tt.wantErr) || (err != nil && tt.wantErr == nil) {
+				t.Errorf("Client.EnsurePolicyGroup() error = %v, want %v", err, tt.wantErr)
+			}
+			if id != tt.wantID {
+				t.Errorf("Client.EnsurePolicyGroup() id = %q, want %q", id, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestClient_DeletePolicyGroup(t *testing.T) {
+	errDelete := errors.New("delete failed")
+
+	tests := []struct {
+		name    string
+		group   string
+		resp    *http.Response
+		err     error
+		wantErr error
+	}{
+		{
+			name:  "deleted",
+			group: "group",
+		},
+		{
+			name:    "policy group not found",
+			group:   "missing",
+			wantErr: storageos.ErrPolicyGroupNotFound,
+		},
+		{
+			name:    "deleted concurrently",
+			group:   "group",
+			resp:    &http.Response{StatusCode: http.StatusNotFound},
+			err:     errDelete,
+			wantErr: storageos.ErrPolicyGroupNotFound,
+		},
+		{
+			name:    "other error",
+			group:   "group",
+			err:     errDelete,
+			wantErr: errDelete,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockCP := mocks.NewMockControlPlane(mockCtrl)
+
+			c := storageos.NewTestAPIClient(mockCP)
+
+			mockCP.EXPECT().ListPolicyGroups(gomock.Any()).Return([]api.PolicyGroup{{Id: "group-id", Name: "group", Version: "v1"}}, nil, nil).AnyTimes()
+			mockCP.EXPECT().ListUsers(gomock.Any()).Return([]api.User{
+				{Id: "alice-id", Username: "alice", Groups: groups("group-id"), Version: "u1"},
+				{Id: "bob-id", Username: "bob", Version: "u1"},
+			}, nil, nil).AnyTimes()
+			if tt.group == "group" {
+				mockCP.EXPECT().UpdateUser(gomock.Any(), "alice-id", api.UpdateUserData{Groups: groups(), Version: "u1"}, nil).Return(api.User{}, nil, nil).Times(1)
+				mockCP.EXPECT().DeletePolicyGroup(gomock.Any(), "group-id", "v1", nil).Return(tt.resp, tt.err).Times(1)
+			}
+
+			if err := c.DeletePolicyGroup(context.Background(), tt.group); err != tt.wantErr {
+				t.Errorf("Client.DeletePolicyGroup() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
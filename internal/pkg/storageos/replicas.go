@@ -0,0 +1,120 @@
+package storageos
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos/metrics"
+	api "github.com/storageos/go-api/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReplicaHealthReady is the health of a replica that is in sync with the
+// master.
+const ReplicaHealthReady = string(api.REPLICAHEALTH_READY)
+
+// ReplicaHealthFailed is the health of a replica that has failed.
+const ReplicaHealthFailed = string(api.REPLICAHEALTH_FAILED)
+
+// DecommissionNodeAnnotationKey is the PVC annotation set to the name of the
+// node being decommissioned while the volume has an extra replica.
+const DecommissionNodeAnnotationKey = "storageos.com/decommission-node"
+
+// DecommissionReplicasAnnotationKey is the PVC annotation set to the number of
+// replicas the volume had before an extra replica was added.
+const DecommissionReplicasAnnotationKey = "storageos.com/decommission-replicas"
+
+// DecommissionState returns the name of the node being decommissioned that
+// added an extra replica to the PVC's volume, and the original replica count.
+// Returns false if the PVC does not have a valid decommission state.
+func DecommissionState(obj client.Object) (string, int, bool) {
+	node, ok := obj.GetAnnotations()[DecommissionNodeAnnotationKey]
+	if !ok {
+		return "", 0, false
+	}
+	original, err := strconv.Atoi(obj.GetAnnotations()[DecommissionReplicasAnnotationKey])
+	if err != nil {
+		return "", 0, false
+	}
+	return node, original, true
+}
+
+// ReplicaStatus is the deployment state of a volume's replicas.
+type ReplicaStatus struct {
+	// Desired is the number of replicas requested for the volume.
+	Desired int `json:"desired"`
+
+	// Replicas is the state of each replica currently deployed.
+	Replicas []ReplicaProgress `json:"replicas,omitempty"`
+}
+
+// ReplicaProgress is the health and sync progress of a single replica.
+type ReplicaProgress struct {
+	ID                        string `json:"id"`
+	NodeID                    string `json:"nodeID,omitempty"`
+	Health                    string `json:"health"`
+	BytesRemaining            uint64 `json:"bytesRemaining,omitempty"`
+	ThroughputBytes           uint64 `json:"throughputBytes,omitempty"`
+	EstimatedSecondsRemaining uint64 `json:"estimatedSecondsRemaining,omitempty"`
+}
+
+// Ready returns the number of replicas that are in sync.
+func (s ReplicaStatus) Ready() int {
+	var ready int
+	for _, r := range s.Replicas {
+		if r.Health == ReplicaHealthReady {
+			ready++
+		}
+	}
+	return ready
+}
+
+// InSync returns true if the desired number of replicas have been deployed
+// and are all in sync.
+func (s ReplicaStatus) InSync() bool {
+	return len(s.Replicas) == s.Desired && s.Ready() == s.Desired
+}
+
+// GetReplicaStatus returns the health and sync progress of the replicas of the
+// StorageOS volume matching the key.
+func (c *Client) GetReplicaStatus(ctx context.Context, key client.ObjectKey) (*ReplicaStatus, error) {
+	funcName := "get_replica_status"
+	start := time.Now()
+	defer func() {
+		metrics.Latency.Observe(funcName, time.Since(start))
+	}()
+	observeErr := func(e error) error {
+		metrics.Errors.Increment(funcName, e)
+		return e
+	}
+
+	ctx = c.AddToken(ctx)
+
+	vol, err := c.getVolume(ctx, key)
+	if err != nil {
+		return nil, observeErr(err)
+	}
+
+	status := &ReplicaStatus{}
+	if v, ok := vol.Labels[ReservedLabelReplicas]; ok {
+		desired, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, observeErr(err)
+		}
+		status.Desired = desired
+	}
+	if vol.Replicas != nil {
+		for _, r := range *vol.Replicas {
+			status.Replicas = append(status.Replicas, ReplicaProgress{
+				ID:                        r.Id,
+				NodeID:                    r.NodeID,
+				Health:                    string(r.Health),
+				BytesRemaining:            r.SyncProgress.BytesRemaining,
+				ThroughputBytes:           r.SyncProgress.ThroughputBytes,
+				EstimatedSecondsRemaining: r.SyncProgress.EstimatedSecondsRemaining,
+			})
+		}
+	}
+	return status, observeErr(nil)
+}
@@ -0,0 +1,115 @@
+package storageos_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+	"github.com/storageos/api-manager/internal/pkg/storageos/mocks"
+	api "github.com/storageos/go-api/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestClient_GetReplicaStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		labels     map[string]string
+		replicas   *[]api.ReplicaDeploymentInfo
+		want       *storageos.ReplicaStatus
+		wantInSync bool
+		wantErr    bool
+	}{
+		{
+			name:       "no replicas",
+			want:       &storageos.ReplicaStatus{},
+			wantInSync: true,
+		},
+		{
+			name:   "replicas ready",
+			labels: map[string]string{storageos.ReservedLabelReplicas: "1"},
+			replicas: &[]api.ReplicaDeploymentInfo{
+				{Id: "r1", NodeID: "n1", Health: api.REPLICAHEALTH_READY},
+			},
+			want: &storageos.ReplicaStatus{
+				Desired:  1,
+				Replicas: []storageos.ReplicaProgress{{ID: "r1", NodeID: "n1", Health: storageos.ReplicaHealthReady}},
+			},
+			wantInSync: true,
+		},
+		{
+			name:   "replica syncing",
+			labels: map[string]string{storageos.ReservedLabelReplicas: "2"},
+			replicas: &[]api.ReplicaDeploymentInfo{
+				{Id: "r1", NodeID: "n1", Health: api.REPLICAHEALTH_READY},
+				{Id: "r2", NodeID: "n2", Health: api.REPLICAHEALTH_SYNCING, SyncProgress: api.SyncProgress{BytesRemaining: 1024, ThroughputBytes: 512, EstimatedSecondsRemaining: 2}},
+			},
+			want: &storageos.ReplicaStatus{
+				Desired: 2,
+				Replicas: []storageos.ReplicaProgress{
+					{ID: "r1", NodeID: "n1", Health: storageos.ReplicaHealthReady},
+					{ID: "r2", NodeID: "n2", Health: "syncing", BytesRemaining: 1024, ThroughputBytes: 512, EstimatedSecondsRemaining: 2},
+				},
+			},
+		},
+		{
+			name:   "replica not yet deployed",
+			labels: map[string]string{storageos.ReservedLabelReplicas: "2"},
+			replicas: &[]api.ReplicaDeploymentInfo{
+				{Id: "r1", NodeID: "n1", Health: api.REPLICAHEALTH_READY},
+			},
+			want: &storageos.ReplicaStatus{
+				Desired:  2,
+				Replicas: []storageos.ReplicaProgress{{ID: "r1", NodeID: "n1", Health: storageos.ReplicaHealthReady}},
+			},
+		},
+		{
+			name:    "invalid replicas label",
+			labels:  map[string]string{storageos.ReservedLabelReplicas: "many"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockCP := mocks.NewMockControlPlane(mockCtrl)
+
+			c := storageos.NewTestAPIClient(mockCP)
+
+			key := client.ObjectKey{Name: "testpvc", Namespace: "testns"}
+			nsId := uuid.New().String()
+			ns := api.Namespace{
+				Id:   nsId,
+				Name: key.Namespace,
+			}
+			vol := api.Volume{
+				Id:          uuid.New().String(),
+				NamespaceID: nsId,
+				Name:        key.Name,
+				Labels:      tt.labels,
+				Replicas:    tt.replicas,
+			}
+
+			mockCP.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{ns}, nil, nil).Times(1)
+			mockCP.EXPECT().ListVolumes(gomock.Any(), nsId).Return([]api.Volume{vol}, nil, nil).Times(1)
+
+			got, err := c.GetReplicaStatus(context.Background(), key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.GetReplicaStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Client.GetReplicaStatus() = %+v, want %+v", got, tt.want)
+			}
+			if got.InSync() != tt.wantInSync {
+				t.Errorf("ReplicaStatus.InSync() = %t, want %t", got.InSync(), tt.wantInSync)
+			}
+		})
+	}
+}
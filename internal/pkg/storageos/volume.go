@@ -3,6 +3,7 @@ package storageos
 import (
 	"context"
 	"errors"
+	"net/http"
 	"time"
 
 	"github.com/storageos/api-manager/internal/pkg/storageos/metrics"
@@ -13,10 +14,15 @@ import (
 var (
 	// ErrVolumeNotFound is returned if a volume was provided but it was not found.
 	ErrVolumeNotFound = errors.New("volume not found")
+
+	// ErrVolumeInUse is returned when a volume can't be deleted because
+	// StorageOS detects that it is still in use.
+	ErrVolumeInUse = errors.New("volume still in use")
 )
 
 // VolumeObjects returns a map of volume objects, indexed on object key for
-// efficient lookups.
+// efficient lookups.  The key uses the namespace name, not the ID, so that it
+// matches the keys used for single volume lookups.
 func (c *Client) VolumeObjects(ctx context.Context) (map[client.ObjectKey]Object, error) {
 	funcName := "volume_objects"
 	start := time.Now()
@@ -35,8 +41,10 @@ func (c *Client) VolumeObjects(ctx context.Context) (map[client.ObjectKey]Object
 		return nil, observeErr(err)
 	}
 	objects := make(map[client.ObjectKey]Object)
-	for _, vol := range volumes {
-		objects[ObjectKeyFromObject(vol)] = vol
+	for namespace, nsVols := range volumes {
+		for _, vol := range nsVols {
+			objects[client.ObjectKey{Name: vol.Name, Namespace: namespace}] = vol
+		}
 	}
 
 	return objects, nil
@@ -61,30 +69,102 @@ func (c *Client) ListVolumes(ctx context.Context) ([]Object, error) {
 		return nil, observeErr(err)
 	}
 	objects := []Object{}
-	for _, vol := range volumes {
-		objects = append(objects, vol)
+	for _, nsVols := range volumes {
+		for _, vol := range nsVols {
+			objects = append(objects, vol)
+		}
 	}
 
 	return objects, nil
 }
 
-// getVolumes returns all StorageOS volumes.
-func (c *Client) getVolumes(ctx context.Context) ([]api.Volume, error) {
-	var volumes []api.Volume
+// getVolumes returns all StorageOS volumes, indexed on namespace name.
+// Volumes only reference their namespace by ID, so the name is taken from the
+// namespace list.
+func (c *Client) getVolumes(ctx context.Context) (map[string][]api.Volume, error) {
 	namespaces, err := c.ListNamespaces(ctx)
 	if err != nil {
 		return nil, err
 	}
+	volumes := make(map[string][]api.Volume)
 	for _, ns := range namespaces {
 		nsVols, resp, err := c.api.ListVolumes(ctx, ns.GetID())
 		if err != nil {
 			return nil, api.MapAPIError(err, resp)
 		}
-		volumes = append(volumes, nsVols...)
+		volumes[ns.GetName()] = nsVols
 	}
 	return volumes, nil
 }
 
+// NamespaceVolumes returns the StorageOS volume objects in the namespace
+// matching the key.
+func (c *Client) NamespaceVolumes(ctx context.Context, key client.ObjectKey) ([]Object, error) {
+	funcName := "namespace_volumes"
+	start := time.Now()
+	defer func() {
+		metrics.Latency.Observe(funcName, time.Since(start))
+	}()
+	observeErr := func(e error) error {
+		metrics.Errors.Increment(funcName, e)
+		return e
+	}
+
+	ctx = c.AddToken(ctx)
+
+	ns, err := c.getNamespace(ctx, key)
+	if err != nil {
+		return nil, observeErr(err)
+	}
+	volumes, resp, err := c.api.ListVolumes(ctx, ns.Id)
+	if err != nil {
+		return nil, observeErr(api.MapAPIError(err, resp))
+	}
+	objects := []Object{}
+	for _, vol := range volumes {
+		objects = append(objects, vol)
+	}
+	return objects, observeErr(nil)
+}
+
+// DeleteVolume removes a volume from the StorageOS cluster.  Delete will fail
+// if the volume is still in use.
+func (c *Client) DeleteVolume(ctx context.Context, key client.ObjectKey) error {
+	funcName := "delete_volume"
+	start := time.Now()
+	defer func() {
+		metrics.Latency.Observe(funcName, time.Since(start))
+	}()
+	observeErr := func(e error) error {
+		metrics.Errors.Increment(funcName, e)
+		return e
+	}
+
+	ctx = c.AddToken(ctx)
+
+	vol, err := c.getVolume(ctx, key)
+	if err != nil {
+		return observeErr(err)
+	}
+
+	resp, err := c.api.DeleteVolume(ctx, vol.NamespaceID, vol.Id, vol.Version, nil)
+	if err != nil {
+		err = observeErr(api.MapAPIError(err, resp))
+		if resp == nil {
+			return err
+		}
+		switch resp.StatusCode {
+		case http.StatusConflict:
+			return ErrVolumeInUse
+		case http.StatusNotFound:
+			return ErrVolumeNotFound
+		default:
+			return err
+		}
+	}
+	return observeErr(nil)
+}
+
 // GetVolume returns the StorageOS volume object matching the key.
 func (c *Client) GetVolume(ctx context.Context, key client.ObjectKey) (Object, error) {
 	funcName := "get_volume"
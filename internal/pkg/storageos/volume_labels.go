@@ -29,26 +29,42 @@ var (
 //
 // Labels prefixed with the StorageOS reserved label indicator
 // ("storageos.com/") will need to be processed separately as most have
-// individual API endpoints to ensure that they are applied atomically.
+// individual API endpoints to ensure that they are applied atomically.  How
+// each reserved label is handled is determined by the reserved volume label
+// registry.  Immutable labels are compared with the volume and an error
+// returned if they differ.  Informational labels are validated but not applied,
+// and ignored labels are skipped.
 //
 // Unreserved labels are copied as a blob and are not evaluated.
 func (c *Client) EnsureVolumeLabels(ctx context.Context, key client.ObjectKey, labels map[string]string) error {
 	var unreservedLabels = make(map[string]string)
+	var immutableLabels = make(map[string]string)
 	var replicas uint64
 	var failureMode string
 	var err error
 	var errs = &multierror.Error{ErrorFormat: ListErrors}
 
 	for k, v := range labels {
-		switch {
-		case !IsReservedLabel(k):
+		if !IsReservedLabel(k) {
 			unreservedLabels[k] = v
-		case k == ReservedLabelComputeOnly:
-			// Don't attempt reserved labels that don't apply to volumes.
-			errs = multierror.Append(errs, errors.Wrap(ErrReservedLabelInvalid, k))
-		case k == ReservedLabelNoCache || k == ReservedLabelNoCompress:
-			// Don't attempt reserved labels that can't be modifed after creation.
-			errs = multierror.Append(errs, errors.Wrap(ErrReservedLabelFixed, k))
+			continue
+		}
+		label, ok := LookupVolumeLabel(k)
+		if !ok {
+			// Don't attempt reserved labels that are unknown or don't apply to
+			// volumes.
+			errs = multierror.Append(errs, ValidateVolumeLabel(k, v))
+			continue
+		}
+		switch {
+		case label.Handling == VolumeLabelImmutable:
+			immutableLabels[k] = v
+		case label.Handling == VolumeLabelInformational:
+			// Not applied, but report invalid values such as a malformed
+			// topology key.
+			if err := ValidateVolumeLabel(k, v); err != nil {
+				errs = multierror.Append(errs, err)
+			}
 		case k == ReservedLabelFailureMode:
 			failureMode = v
 		case k == ReservedLabelReplicas:
@@ -56,8 +72,13 @@ func (c *Client) EnsureVolumeLabels(ctx context.Context, key client.ObjectKey, l
 			if err != nil {
 				errs = multierror.Append(errs, errors.Wrap(err, k))
 			}
-		default:
-			errs = multierror.Append(errs, errors.Wrap(ErrReservedLabelUnknown, k))
+		}
+	}
+
+	// Check that immutable labels have not been changed since creation.
+	if len(immutableLabels) > 0 {
+		if err := c.checkImmutableVolumeLabels(ctx, key, immutableLabels); err != nil && err != ErrVolumeNotFound {
+			errs = multierror.Append(errs, err)
 		}
 	}
 
@@ -78,6 +99,26 @@ func (c *Client) EnsureVolumeLabels(ctx context.Context, key client.ObjectKey, l
 	return errs.ErrorOrNil()
 }
 
+// checkImmutableVolumeLabels returns an error wrapping ErrReservedLabelFixed for
+// each immutable label that differs from the value set on the volume.
+func (c *Client) checkImmutableVolumeLabels(ctx context.Context, key client.ObjectKey, labels map[string]string) error {
+	ctx = c.AddToken(ctx)
+
+	vol, err := c.getVolume(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	var errs = &multierror.Error{ErrorFormat: ListErrors}
+	for k, v := range labels {
+		label, _ := LookupVolumeLabel(k)
+		if !label.Equal(vol.Labels[k], v) {
+			errs = multierror.Append(errs, errors.Wrap(ErrReservedLabelFixed, k))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
 // EnsureUnreservedVolumeLabels applies a set of labels to the StorageOS volume
 // if different. Existing labels will be overwritten.  Any reserved labels
 // will be ignored.
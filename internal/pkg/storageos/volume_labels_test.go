@@ -470,8 +470,8 @@ func TestClient_EnsureVolumeLabels(t *testing.T) {
 					Name:        key.Name,
 				}
 
-				m.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{ns}, nil, nil).Times(3)
-				m.EXPECT().ListVolumes(gomock.Any(), nsId).Return([]api.Volume{vol}, nil, nil).Times(3)
+				m.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{ns}, nil, nil).Times(4)
+				m.EXPECT().ListVolumes(gomock.Any(), nsId).Return([]api.Volume{vol}, nil, nil).Times(4)
 			},
 			wantErr: true,
 		},
@@ -493,6 +493,102 @@ func TestClient_EnsureVolumeLabels(t *testing.T) {
 					Name:        key.Name,
 				}
 
+				m.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{ns}, nil, nil).Times(4)
+				m.EXPECT().ListVolumes(gomock.Any(), nsId).Return([]api.Volume{vol}, nil, nil).Times(4)
+			},
+			wantErr: true,
+		},
+		{
+			name: "unchanged immutable labels",
+			labels: map[string]string{
+				storageos.ReservedLabelNoCache:    "true",
+				storageos.ReservedLabelEncryption: "true",
+			},
+			prepare: func(key client.ObjectKey, m *mocks.MockControlPlane) {
+				nsId := uuid.New().String()
+				volId := uuid.New().String()
+				ns := api.Namespace{
+					Id:   nsId,
+					Name: key.Namespace,
+				}
+				vol := api.Volume{
+					Id:          volId,
+					NamespaceID: nsId,
+					Name:        key.Name,
+					Labels: map[string]string{
+						storageos.ReservedLabelNoCache:    "true",
+						storageos.ReservedLabelEncryption: "true",
+					},
+				}
+
+				m.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{ns}, nil, nil).Times(4)
+				m.EXPECT().ListVolumes(gomock.Any(), nsId).Return([]api.Volume{vol}, nil, nil).Times(4)
+			},
+		},
+		{
+			name: "immutable label set to default",
+			labels: map[string]string{
+				storageos.ReservedLabelNoCompress: "false",
+			},
+			prepare: func(key client.ObjectKey, m *mocks.MockControlPlane) {
+				nsId := uuid.New().String()
+				volId := uuid.New().String()
+				ns := api.Namespace{
+					Id:   nsId,
+					Name: key.Namespace,
+				}
+				vol := api.Volume{
+					Id:          volId,
+					NamespaceID: nsId,
+					Name:        key.Name,
+				}
+
+				m.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{ns}, nil, nil).Times(4)
+				m.EXPECT().ListVolumes(gomock.Any(), nsId).Return([]api.Volume{vol}, nil, nil).Times(4)
+			},
+		},
+		{
+			name: "informational labels",
+			labels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "true",
+				storageos.ReservedLabelTopologyKey:   "topology.kubernetes.io/zone",
+			},
+			prepare: func(key client.ObjectKey, m *mocks.MockControlPlane) {
+				nsId := uuid.New().String()
+				volId := uuid.New().String()
+				ns := api.Namespace{
+					Id:   nsId,
+					Name: key.Namespace,
+				}
+				vol := api.Volume{
+					Id:          volId,
+					NamespaceID: nsId,
+					Name:        key.Name,
+				}
+
+				m.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{ns}, nil, nil).Times(3)
+				m.EXPECT().ListVolumes(gomock.Any(), nsId).Return([]api.Volume{vol}, nil, nil).Times(3)
+			},
+		},
+		{
+			name: "invalid topology key",
+			labels: map[string]string{
+				storageos.ReservedLabelTopologyAware: "true",
+				storageos.ReservedLabelTopologyKey:   "not a label",
+			},
+			prepare: func(key client.ObjectKey, m *mocks.MockControlPlane) {
+				nsId := uuid.New().String()
+				volId := uuid.New().String()
+				ns := api.Namespace{
+					Id:   nsId,
+					Name: key.Namespace,
+				}
+				vol := api.Volume{
+					Id:          volId,
+					NamespaceID: nsId,
+					Name:        key.Name,
+				}
+
 				m.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{ns}, nil, nil).Times(3)
 				m.EXPECT().ListVolumes(gomock.Any(), nsId).Return([]api.Volume{vol}, nil, nil).Times(3)
 			},
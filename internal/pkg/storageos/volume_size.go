@@ -0,0 +1,47 @@
+package storageos
+
+import (
+	"context"
+	"time"
+
+	"github.com/storageos/api-manager/internal/pkg/storageos/metrics"
+	api "github.com/storageos/go-api/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// EnsureVolumeSize ensures that the StorageOS volume is at least the desired
+// size in bytes, resizing it if needed.  resized is true if a resize was
+// requested.
+//
+// Volumes can't be shrunk, so nothing is done if the volume is already at
+// least the desired size.  Volumes may be provisioned larger than requested,
+// for example when the request is rounded up.
+func (c *Client) EnsureVolumeSize(ctx context.Context, key client.ObjectKey, desired uint64) (resized bool, err error) {
+	funcName := "ensure_volume_size"
+	start := time.Now()
+	defer func() {
+		metrics.Latency.Observe(funcName, time.Since(start))
+	}()
+	observeErr := func(e error) error {
+		metrics.Errors.Increment(funcName, e)
+		return e
+	}
+
+	ctx = c.AddToken(ctx)
+
+	vol, err := c.getVolume(ctx, key)
+	if err != nil {
+		return false, observeErr(err)
+	}
+
+	// No change required.
+	if desired <= vol.SizeBytes {
+		return false, observeErr(nil)
+	}
+
+	// Apply update.
+	if _, resp, err := c.api.ResizeVolume(ctx, vol.NamespaceID, vol.Id, api.ResizeVolumeRequest{SizeBytes: desired, Version: vol.Version}, nil); err != nil {
+		return false, observeErr(api.MapAPIError(err, resp))
+	}
+	return true, observeErr(nil)
+}
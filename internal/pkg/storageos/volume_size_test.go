@@ -0,0 +1,99 @@
+package storageos_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+	"github.com/storageos/api-manager/internal/pkg/storageos/mocks"
+	api "github.com/storageos/go-api/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestClient_EnsureVolumeSize(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+
+	tests := []struct {
+		name        string
+		currentSize uint64
+		desired     uint64
+		resizeErr   error
+		wantResized bool
+		wantErr     error
+	}{
+		{
+			name:        "unchanged",
+			currentSize: 5 * gib,
+			desired:     5 * gib,
+		},
+		{
+			name:        "expand",
+			currentSize: 5 * gib,
+			desired:     10 * gib,
+			wantResized: true,
+		},
+		{
+			name:        "larger than desired",
+			currentSize: 10 * gib,
+			desired:     5 * gib,
+		},
+		{
+			name:        "rounded up",
+			currentSize: gib,
+			desired:     1000 * 1000 * 1000,
+		},
+		{
+			name:        "resize error",
+			currentSize: 5 * gib,
+			desired:     10 * gib,
+			resizeErr:   errors.New("insufficient capacity"),
+			wantErr:     errors.New("insufficient capacity"),
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockCP := mocks.NewMockControlPlane(mockCtrl)
+
+			c := storageos.NewTestAPIClient(mockCP)
+
+			key := client.ObjectKey{Name: "testpvc", Namespace: "testns"}
+			nsId := uuid.New().String()
+			volId := uuid.New().String()
+			ns := api.Namespace{
+				Id:   nsId,
+				Name: key.Namespace,
+			}
+			vol := api.Volume{
+				Id:          volId,
+				NamespaceID: nsId,
+				Name:        key.Name,
+				SizeBytes:   tt.currentSize,
+				Version:     "1",
+			}
+
+			mockCP.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{ns}, nil, nil).Times(1)
+			mockCP.EXPECT().ListVolumes(gomock.Any(), nsId).Return([]api.Volume{vol}, nil, nil).Times(1)
+			if tt.desired > tt.currentSize {
+				req := api.ResizeVolumeRequest{SizeBytes: tt.desired, Version: vol.Version}
+				mockCP.EXPECT().ResizeVolume(gomock.Any(), nsId, volId, req, nil).Return(api.Volume{}, nil, tt.resizeErr).Times(1)
+			}
+
+			resized, err := c.EnsureVolumeSize(context.Background(), key, tt.desired)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Client.EnsureVolumeSize() unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && err == nil {
+				t.Fatalf("Client.EnsureVolumeSize() expected error %v, got none", tt.wantErr)
+			}
+			if resized != tt.wantResized {
+				t.Errorf("Client.EnsureVolumeSize() resized = %t, want %t", resized, tt.wantResized)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package storageos_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/storageos/api-manager/internal/pkg/storageos"
+	"github.com/storageos/api-manager/internal/pkg/storageos/mocks"
+	api "github.com/storageos/go-api/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestClient_VolumeObjects(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockCP := mocks.NewMockControlPlane(mockCtrl)
+
+	c := storageos.NewTestAPIClient(mockCP)
+
+	namespaces := []api.Namespace{
+		{Id: "ns1-id", Name: "ns1"},
+		{Id: "ns2-id", Name: "ns2"},
+	}
+	mockCP.EXPECT().ListNamespaces(gomock.Any()).Return(namespaces, nil, nil).Times(1)
+	mockCP.EXPECT().ListVolumes(gomock.Any(), "ns1-id").Return([]api.Volume{{Id: "vol1-id", Name: "pv1", NamespaceID: "ns1-id"}}, nil, nil).Times(1)
+	mockCP.EXPECT().ListVolumes(gomock.Any(), "ns2-id").Return([]api.Volume{{Id: "vol2-id", Name: "pv1", NamespaceID: "ns2-id"}}, nil, nil).Times(1)
+
+	got, err := c.VolumeObjects(context.Background())
+	if err != nil {
+		t.Fatalf("Client.VolumeObjects() unexpected error: %v", err)
+	}
+
+	// Volumes must be keyed on namespace name, not ID.
+	want := map[client.ObjectKey]string{
+		{Name: "pv1", Namespace: "ns1"}: "vol1-id",
+		{Name: "pv1", Namespace: "ns2"}: "vol2-id",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Client.VolumeObjects() returned %d volumes, want %d", len(got), len(want))
+	}
+	for key, id := range want {
+		vol, ok := got[key]
+		if !ok {
+			t.Errorf("Client.VolumeObjects() missing volume %s", key)
+			continue
+		}
+		if vol.GetID() != id {
+			t.Errorf("Client.VolumeObjects() volume %s id = %s, want %s", key, vol.GetID(), id)
+		}
+	}
+}
+
+func TestClient_NamespaceVolumes(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockCP := mocks.NewMockControlPlane(mockCtrl)
+
+	c := storageos.NewTestAPIClient(mockCP)
+
+	namespaces := []api.Namespace{
+		{Id: "ns1-id", Name: "ns1"},
+		{Id: "ns2-id", Name: "ns2"},
+	}
+	mockCP.EXPECT().ListNamespaces(gomock.Any()).Return(namespaces, nil, nil).Times(2)
+	mockCP.EXPECT().ListVolumes(gomock.Any(), "ns2-id").Return([]api.Volume{{Id: "vol1-id", Name: "pv1", NamespaceID: "ns2-id"}}, nil, nil).Times(1)
+
+	got, err := c.NamespaceVolumes(context.Background(), client.ObjectKey{Name: "ns2"})
+	if err != nil {
+		t.Fatalf("Client.NamespaceVolumes() unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].GetID() != "vol1-id" {
+		t.Errorf("Client.NamespaceVolumes() = %v, want vol1-id", got)
+	}
+
+	if _, err := c.NamespaceVolumes(context.Background(), client.ObjectKey{Name: "ns3"}); err != storageos.ErrNamespaceNotFound {
+		t.Errorf("Client.NamespaceVolumes() error = %v, want %v", err, storageos.ErrNamespaceNotFound)
+	}
+}
+
+func TestClient_DeleteVolume(t *testing.T) {
+	errDelete := errors.New("delete failed")
+
+	tests := []struct {
+		name    string
+		key     client.ObjectKey
+		resp    *http.Response
+		err     error
+		wantErr error
+	}{
+		{
+			name: "deleted",
+			key:  client.ObjectKey{Name: "pv1", Namespace: "ns1"},
+		},
+		{
+			name:    "volume not found",
+			key:     client.ObjectKey{Name: "pv2", Namespace: "ns1"},
+			wantErr: storageos.ErrVolumeNotFound,
+		},
+		{
+			name:    "namespace not found",
+			key:     client.ObjectKey{Name: "pv1", Namespace: "ns2"},
+			wantErr: storageos.ErrNamespaceNotFound,
+		},
+		{
+			name:    "in use",
+			key:     client.ObjectKey{Name: "pv1", Namespace: "ns1"},
+			resp:    &http.Response{StatusCode: http.StatusConflict},
+			err:     errDelete,
+			wantErr: storageos.ErrVolumeInUse,
+		},
+		{
+			name:    "deleted concurrently",
+			key:     client.ObjectKey{Name: "pv1", Namespace: "ns1"},
+			resp:    &http.Response{StatusCode: http.StatusNotFound},
+			err:     errDelete,
+			wantErr: storageos.ErrVolumeNotFound,
+		},
+		{
+			name:    "other error",
+			key:     client.ObjectKey{Name: "pv1", Namespace: "ns1"},
+			err:     errDelete,
+			wantErr: errDelete,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockCP := mocks.NewMockControlPlane(mockCtrl)
+
+			c := storageos.NewTestAPIClient(mockCP)
+
+			mockCP.EXPECT().ListNamespaces(gomock.Any()).Return([]api.Namespace{{Id: "ns1-id", Name: "ns1"}}, nil, nil).AnyTimes()
+			mockCP.EXPECT().ListVolumes(gomock.Any(), "ns1-id").Return([]api.Volume{{Id: "vol1-id", Name: "pv1", NamespaceID: "ns1-id", Version: "v1"}}, nil, nil).AnyTimes()
+			if tt.key.Name == "pv1" && tt.key.Namespace == "ns1" {
+				mockCP.EXPECT().DeleteVolume(gomock.Any(), "ns1-id", "vol1-id", "v1", nil).Return(tt.resp, tt.err).Times(1)
+			}
+
+			if err := c.DeleteVolume(context.Background(), tt.key); err != tt.wantErr {
+				t.Errorf("Client.DeleteVolume() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
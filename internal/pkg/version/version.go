@@ -0,0 +1,7 @@
+// Package version records the api-manager build version.
+package version
+
+// Version is the api-manager version.  It is set at build time with:
+//
+//   -ldflags "-X github.com/storageos/api-manager/internal/pkg/version.Version=v1.2.3"
+var Version = "devel"
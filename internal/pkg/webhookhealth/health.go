@@ -0,0 +1,56 @@
+// Package webhookhealth provides readiness checks for the webhook server.
+package webhookhealth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// ErrCertNotValid is returned when the webhook certificate is not valid at the
+// current time.
+var ErrCertNotValid = errors.New("webhook certificate not valid")
+
+// CertChecker returns a readiness check that fails unless the webhook serving
+// certificate and key in certDir can be loaded, and the certificate is
+// currently valid.
+func CertChecker(certDir, certName, keyName string) healthz.Checker {
+	return func(_ *http.Request) error {
+		pair, err := tls.LoadX509KeyPair(filepath.Join(certDir, certName), filepath.Join(certDir, keyName))
+		if err != nil {
+			return fmt.Errorf("failed to load webhook certificate: %w", err)
+		}
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("failed to parse webhook certificate: %w", err)
+		}
+		now := time.Now()
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			return fmt.Errorf("%w: valid from %s to %s", ErrCertNotValid, cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+		}
+		return nil
+	}
+}
+
+// ServerChecker returns a readiness check that fails unless the webhook server
+// accepts TLS connections on addr within timeout.
+//
+// The certificate presented by the server is not verified, it is checked by
+// CertChecker.
+func ServerChecker(addr string, timeout time.Duration) healthz.Checker {
+	return func(_ *http.Request) error {
+		dialer := &net.Dialer{Timeout: timeout}
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return fmt.Errorf("webhook server not serving: %w", err)
+		}
+		return conn.Close()
+	}
+}
@@ -0,0 +1,126 @@
+package webhookhealth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate and key valid between notBefore
+// and notAfter to dir.
+func writeCert(t *testing.T, dir string, notBefore, notAfter time.Time) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "storageos-webhook"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(filepath.Join(dir, "tls.crt"), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "tls.key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertChecker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		notBefore time.Time
+		notAfter  time.Time
+		noCert    bool
+		wantErr   bool
+		wantErrIs error
+	}{
+		{
+			name:      "valid",
+			notBefore: now.Add(-time.Hour),
+			notAfter:  now.Add(time.Hour),
+		},
+		{
+			name:      "expired",
+			notBefore: now.Add(-2 * time.Hour),
+			notAfter:  now.Add(-time.Hour),
+			wantErr:   true,
+			wantErrIs: ErrCertNotValid,
+		},
+		{
+			name:      "not yet valid",
+			notBefore: now.Add(time.Hour),
+			notAfter:  now.Add(2 * time.Hour),
+			wantErr:   true,
+			wantErrIs: ErrCertNotValid,
+		},
+		{
+			name:    "missing",
+			noCert:  true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		var tt = tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir, err := ioutil.TempDir("", "webhookhealth")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			if !tt.noCert {
+				writeCert(t, dir, tt.notBefore, tt.notAfter)
+			}
+
+			err = CertChecker(dir, "tls.crt", "tls.key")(nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CertChecker() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+				t.Errorf("CertChecker() error = %v, want %v", err, tt.wantErrIs)
+			}
+		})
+	}
+}
+
+func TestServerChecker(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	addr := srv.Listener.Addr().String()
+
+	if err := ServerChecker(addr, time.Second)(nil); err != nil {
+		t.Errorf("ServerChecker() on running server error = %v", err)
+	}
+
+	srv.Close()
+
+	if err := ServerChecker(addr, time.Second)(nil); err == nil {
+		t.Error("ServerChecker() on stopped server expected error")
+	}
+}
@@ -28,7 +28,10 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
 	"time"
 
 	cclient "github.com/darkowlzz/operator-toolkit/client/composite"
@@ -36,31 +39,42 @@ import (
 	"github.com/darkowlzz/operator-toolkit/webhook/cert"
 	"go.uber.org/zap/zapcore"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	storageosv1 "github.com/storageos/api-manager/api/v1"
 	"github.com/storageos/api-manager/controllers/fencer"
+	keybackup "github.com/storageos/api-manager/controllers/key-backup"
+	keyrotation "github.com/storageos/api-manager/controllers/key-rotation"
 	nsdelete "github.com/storageos/api-manager/controllers/namespace-delete"
+	nssync "github.com/storageos/api-manager/controllers/namespace-sync"
 	nodedelete "github.com/storageos/api-manager/controllers/node-delete"
 	nodelabel "github.com/storageos/api-manager/controllers/node-label"
 	podmutator "github.com/storageos/api-manager/controllers/pod-mutator"
 	"github.com/storageos/api-manager/controllers/pod-mutator/scheduler"
+	policygroup "github.com/storageos/api-manager/controllers/policy-group"
 	pvclabel "github.com/storageos/api-manager/controllers/pvc-label"
 	pvcmutator "github.com/storageos/api-manager/controllers/pvc-mutator"
 	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption"
+	"github.com/storageos/api-manager/controllers/pvc-mutator/encryption/keys"
 	"github.com/storageos/api-manager/controllers/pvc-mutator/storageclass"
+	"github.com/storageos/api-manager/controllers/pvc-mutator/topology"
+	storagevalidator "github.com/storageos/api-manager/controllers/storage-validator"
 	"github.com/storageos/api-manager/internal/controllers/sharedvolume"
 	"github.com/storageos/api-manager/internal/pkg/cluster"
 	"github.com/storageos/api-manager/internal/pkg/labels"
 	"github.com/storageos/api-manager/internal/pkg/storageos"
 	apimetrics "github.com/storageos/api-manager/internal/pkg/storageos/metrics"
+	"github.com/storageos/api-manager/internal/pkg/version"
+	"github.com/storageos/api-manager/internal/pkg/webhookhealth"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -70,6 +84,18 @@ const (
 	EventSourceName = "storageos-api-manager"
 
 	oneYear = 365 * 24 * time.Hour
+
+	// webhookPort is the port that the webhook server listens on.
+	webhookPort = 9443
+
+	// webhookCertName and webhookKeyName are the names of the webhook serving
+	// certificate and key files in the webhook certificate directory.
+	webhookCertName = "tls.crt"
+	webhookKeyName  = "tls.key"
+
+	// webhookReadyTimeout is the maximum time the readiness check waits for
+	// the webhook server to accept a connection.
+	webhookReadyTimeout = time.Second
 )
 
 var (
@@ -84,9 +110,16 @@ func init() {
 }
 
 func main() {
+	// Run key backup subcommands instead of the manager.
+	if len(os.Args) > 1 && keybackup.IsCommand(os.Args[1]) {
+		runKeyBackupCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	var loggerOpts zap.Options
 	var namespace string
 	var metricsAddr string
+	var healthProbeAddr string
 	var enableLeaderElection bool
 	var schedulerName string
 	var webhookServiceName string
@@ -96,6 +129,9 @@ func main() {
 	var webhookConfigMutatingName string
 	var webhookMutatePodsPath string
 	var webhookMutatePVCsPath string
+	var webhookConfigValidatingName string
+	var webhookValidatePVCsPath string
+	var webhookValidateStorageClassesPath string
 	var webhookCertValidity time.Duration
 	var webhookCertRefreshInterval time.Duration
 	var apiSecretPath string
@@ -112,21 +148,63 @@ func main() {
 	var gcNodeDeleteInterval time.Duration
 	var resyncNodeLabelInterval time.Duration
 	var resyncPVCLabelInterval time.Duration
+	var resyncNamespaceSyncInterval time.Duration
+	var gcPolicyGroupInterval time.Duration
 	var gcNamespaceDeleteDelay time.Duration
 	var gcNodeDeleteDelay time.Duration
 	var resyncNodeLabelDelay time.Duration
 	var resyncPVCLabelDelay time.Duration
+	var resyncNamespaceSyncDelay time.Duration
+	var gcPolicyGroupDelay time.Duration
 	var nsDeleteWorkers int
+	var nsDeleteCascade bool
+	var nsDeleteCascadeRetention time.Duration
+	var nsDeleteCascadeDryRun bool
+	var nsDeleteCascadeProtected string
+	var nsSyncWorkers int
+	var policyGroupWorkers int
 	var nodeDeleteWorkers int
+	var nodeDeleteEvacuate bool
+	var nodeDeleteGracePeriod time.Duration
+	var nodeDeleteBlockedThreshold time.Duration
 	var nodeLabelSyncWorkers int
+	var nodeLabelCordonUnschedulable bool
+	var nodeLabelCordonTaints string
+	var nodeStatusSyncInterval time.Duration
+	var labelSyncIncludePrefixes string
+	var labelSyncExcludePrefixes string
+	var labelSyncIncludeRegex string
+	var labelSyncExcludeRegex string
 	var nodeFencerWorkers int
 	var nodeFencerRetryInterval time.Duration
 	var nodeFencerTimeout time.Duration
 	var pvcLabelSyncWorkers int
+	var nsKeyRotationWorkers int
+	var nsKeyRotationInterval time.Duration
+	var kekProviderName string
+	var kekLocalPath string
+	var kekTransitAddress string
+	var kekTransitMount string
+	var kekTransitKey string
+	var kekTransitTokenPath string
+	var keyBackupInterval time.Duration
+	var keyBackupPublicKeyPath string
+	var keyBackupPath string
+	var keyBackupSecretName string
+	var keyBackupSecretNamespace string
 	var enablePVCLabelSync bool
 	var enableNodeLabelSync bool
+	var enableNodeStatusSync bool
+	var enableNamespaceSync bool
+	var enablePolicyGroupSync bool
+	var enablePodSchedulerMutator bool
+	var enablePVCEncryptionMutator bool
+	var enablePVCStorageClassMutator bool
+	var enablePVCTopologyMutator bool
+	var enableEncryptionKeyRotation bool
 
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&healthProbeAddr, "health-probe-addr", ":8081", "The address the health and readiness probe endpoints bind to.  Set to 0 to disable.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -139,6 +217,9 @@ func main() {
 	flag.StringVar(&webhookConfigMutatingName, "webhook-config-mutating", "storageos-mutating-webhook", "Name of the mutating webhook configuration.")
 	flag.StringVar(&webhookMutatePodsPath, "webhook-mutate-pods-path", "/mutate-pods", "URL path of the Pod mutating webhook.")
 	flag.StringVar(&webhookMutatePVCsPath, "webhook-mutate-pvcs-path", "/mutate-pvcs", "URL path of the PVC mutating webhook.")
+	flag.StringVar(&webhookConfigValidatingName, "webhook-config-validating", "storageos-validating-webhook", "Name of the validating webhook configuration.  Validation is disabled if unset or if the configuration does not exist on startup.")
+	flag.StringVar(&webhookValidatePVCsPath, "webhook-validate-pvcs-path", "/validate-pvcs", "URL path of the PVC validating webhook.")
+	flag.StringVar(&webhookValidateStorageClassesPath, "webhook-validate-storageclasses-path", "/validate-storageclasses", "URL path of the StorageClass validating webhook.")
 	flag.DurationVar(&webhookCertValidity, "webhook-cert-validity", oneYear, "Validity of webhook certificate.")
 	flag.DurationVar(&webhookCertRefreshInterval, "webhook-cert-refresh-interval", 30*time.Minute, "Frequency of webhook certificate refresh.")
 	flag.StringVar(&apiSecretPath, "api-secret-path", "/etc/storageos/secrets/api", "Path where the StorageOS api secret is mounted.  The secret must have \"username\" and \"password\" set.")
@@ -155,19 +236,60 @@ func main() {
 	flag.DurationVar(&gcNodeDeleteInterval, "node-delete-gc-interval", 1*time.Hour, "Frequency of node garbage collection.")
 	flag.DurationVar(&resyncNodeLabelInterval, "node-label-resync-interval", 1*time.Hour, "Frequency of node label resync.")
 	flag.DurationVar(&resyncPVCLabelInterval, "pvc-label-resync-interval", 1*time.Hour, "Frequency of PVC label resync.")
+	flag.DurationVar(&resyncNamespaceSyncInterval, "namespace-sync-resync-interval", 1*time.Hour, "Frequency of namespace sync resync.")
+	flag.DurationVar(&gcPolicyGroupInterval, "policy-group-gc-interval", 1*time.Hour, "Frequency of policy group garbage collection.")
 	flag.DurationVar(&gcNamespaceDeleteDelay, "namespace-delete-gc-delay", 20*time.Second, "Startup delay of initial namespace garbage collection.")
 	flag.DurationVar(&gcNodeDeleteDelay, "node-delete-gc-delay", 30*time.Second, "Startup delay of initial node garbage collection.")
+	flag.BoolVar(&nodeDeleteEvacuate, "node-delete-evacuate", true, "Move volumes off nodes before deleting them from StorageOS.")
+	flag.DurationVar(&nodeDeleteGracePeriod, "node-delete-grace-period", 0, "Minimum time a Kubernetes node must be absent before the StorageOS node is deleted.")
+	flag.DurationVar(&nodeDeleteBlockedThreshold, "node-delete-blocked-threshold", 10*time.Minute, "Time a node delete can be blocked before a warning event is recorded.")
 	flag.DurationVar(&resyncNodeLabelDelay, "node-label-resync-delay", 10*time.Second, "Startup delay of initial node label resync.")
 	flag.DurationVar(&resyncPVCLabelDelay, "pvc-label-resync-delay", 5*time.Second, "Startup delay of initial PVC label resync.")
+	flag.DurationVar(&resyncNamespaceSyncDelay, "namespace-sync-resync-delay", 15*time.Second, "Startup delay of initial namespace sync resync.")
+	flag.DurationVar(&gcPolicyGroupDelay, "policy-group-gc-delay", 20*time.Second, "Startup delay of initial policy group garbage collection.")
 	flag.IntVar(&nodeFencerWorkers, "node-fencer-workers", 5, "Maximum concurrent node fencing operations.")
 	flag.DurationVar(&nodeFencerRetryInterval, "node-fencer-retry-interval", 5*time.Second, "Frequency of fencing retries on failure.")
 	flag.DurationVar(&nodeFencerTimeout, "node-fencer-timeout", 25*time.Second, "Maximum time to wait for fencing to complete.")
 	flag.IntVar(&nodeDeleteWorkers, "node-delete-workers", 5, "Maximum concurrent node delete operations.")
 	flag.IntVar(&nsDeleteWorkers, "namespace-delete-workers", 5, "Maximum concurrent namespace delete operations.")
+	flag.BoolVar(&nsDeleteCascade, "namespace-delete-cascade", false, "Delete volumes without a PV from StorageOS namespaces that have been deleted in Kubernetes.")
+	flag.DurationVar(&nsDeleteCascadeRetention, "namespace-delete-cascade-retention", 24*time.Hour, "Time a Kubernetes namespace must have been deleted before its remaining StorageOS volumes are deleted.  Measured from when the api-manager first finds the namespace deleted, and restarts if the api-manager restarts.")
+	flag.BoolVar(&nsDeleteCascadeDryRun, "namespace-delete-cascade-dry-run", false, "Log the volumes that would be deleted by namespace cascade delete, without deleting them.")
+	flag.StringVar(&nsDeleteCascadeProtected, "namespace-delete-cascade-protected", "", "Comma-separated list of namespaces that never have their volumes deleted by namespace cascade delete.")
+	flag.IntVar(&nsSyncWorkers, "namespace-sync-workers", 5, "Maximum concurrent namespace sync operations.")
+	flag.IntVar(&policyGroupWorkers, "policy-group-workers", 5, "Maximum concurrent policy group sync operations.")
 	flag.IntVar(&nodeLabelSyncWorkers, "node-label-sync-workers", 5, "Maximum concurrent node label sync operations.")
+	flag.BoolVar(&nodeLabelCordonUnschedulable, "node-label-cordon-unschedulable", false, "Mark cordoned nodes as compute-only in StorageOS.")
+	flag.StringVar(&nodeLabelCordonTaints, "node-label-cordon-taints", "", "Comma-separated list of taints, as key or key:Effect, that mark nodes as compute-only in StorageOS.")
+	flag.DurationVar(&nodeStatusSyncInterval, "node-status-sync-interval", time.Minute, "Frequency of StorageOS node status sync to Kubernetes nodes.")
+	flag.StringVar(&labelSyncIncludePrefixes, "label-sync-include-prefixes", "", "Comma-separated list of label key prefixes to sync from Kubernetes nodes and PVCs to StorageOS.  All labels are synced if no include prefixes or regex are set.")
+	flag.StringVar(&labelSyncExcludePrefixes, "label-sync-exclude-prefixes", "", "Comma-separated list of label key prefixes not to sync from Kubernetes nodes and PVCs to StorageOS.")
+	flag.StringVar(&labelSyncIncludeRegex, "label-sync-include-regex", "", "Regular expression matching label keys to sync from Kubernetes nodes and PVCs to StorageOS.")
+	flag.StringVar(&labelSyncExcludeRegex, "label-sync-exclude-regex", "", "Regular expression matching label keys not to sync from Kubernetes nodes and PVCs to StorageOS.")
 	flag.IntVar(&pvcLabelSyncWorkers, "pvc-label-sync-workers", 5, "Maximum concurrent PVC label sync operations.")
+	flag.IntVar(&nsKeyRotationWorkers, "namespace-key-rotation-workers", 1, "Maximum concurrent namespace key rotation operations.")
+	flag.DurationVar(&nsKeyRotationInterval, "namespace-key-rotation-interval", 0, "Frequency of namespace encryption key rotation.  Set to 0 to only rotate on request.")
+	flag.StringVar(&kekProviderName, "encryption-kek-provider", "", "Key encryption key provider used to wrap namespace encryption keys, either \"local\" or \"transit\".  Namespace keys are stored unwrapped if unset.")
+	flag.StringVar(&kekLocalPath, "encryption-kek-local-path", "/etc/storageos/secrets/kek/key", "Path of the hex-encoded key encryption key used by the local provider.")
+	flag.StringVar(&kekTransitAddress, "encryption-kek-transit-address", "", "Address of the transit key encryption key service.")
+	flag.StringVar(&kekTransitMount, "encryption-kek-transit-mount", keys.DefaultTransitMount, "Mount path of the transit key encryption key service.")
+	flag.StringVar(&kekTransitKey, "encryption-kek-transit-key", "", "Name of the transit key used to wrap namespace encryption keys.")
+	flag.StringVar(&kekTransitTokenPath, "encryption-kek-transit-token-path", "/etc/storageos/secrets/kek/token", "Path of the token used to authenticate with the transit key encryption key service.")
+	flag.DurationVar(&keyBackupInterval, "encryption-key-backup-interval", 0, "Frequency of encryption key backups.  Set to 0 to disable.")
+	flag.StringVar(&keyBackupPublicKeyPath, "encryption-key-backup-public-key-path", "/etc/storageos/secrets/key-backup/public-key", "Path of the PEM-encoded RSA public key that encryption key backups are encrypted to.")
+	flag.StringVar(&keyBackupPath, "encryption-key-backup-path", "", "Path of the file to write encryption key backups to.")
+	flag.StringVar(&keyBackupSecretName, "encryption-key-backup-secret-name", keybackup.DefaultSecretName, "Name of the secret to write encryption key backups to.")
+	flag.StringVar(&keyBackupSecretNamespace, "encryption-key-backup-secret-namespace", "", "Namespace of the secret to write encryption key backups to.  Backups are not written to a secret if unset.")
 	flag.BoolVar(&enablePVCLabelSync, "enable-pvc-label-sync", true, "Enable pvc label sync controller.")
 	flag.BoolVar(&enableNodeLabelSync, "enable-node-label-sync", true, "Enable node label sync controller.")
+	flag.BoolVar(&enableNamespaceSync, "enable-namespace-sync", true, "Enable namespace sync controller.")
+	flag.BoolVar(&enablePolicyGroupSync, "enable-policy-group-sync", false, "Enable StoragePolicyGroup sync controller.  Requires the StoragePolicyGroup CRD to be installed.")
+	flag.BoolVar(&enablePodSchedulerMutator, "enable-pod-scheduler-mutator", true, "Enable the Pod mutator that sets the scheduler of Pods with StorageOS volumes.")
+	flag.BoolVar(&enablePVCEncryptionMutator, "enable-pvc-encryption-mutator", true, "Enable the PVC mutator that sets encryption keys on PVCs with encryption enabled.")
+	flag.BoolVar(&enablePVCStorageClassMutator, "enable-pvc-storageclass-mutator", true, "Enable the PVC mutator that sets the StorageClass UID annotation.")
+	flag.BoolVar(&enablePVCTopologyMutator, "enable-pvc-topology-mutator", true, "Enable the PVC mutator that sets the topology key on PVCs with topology-aware placement.  Requires node label sync.")
+	flag.BoolVar(&enableEncryptionKeyRotation, "enable-encryption-key-rotation", true, "Enable namespace encryption key rotation controller.")
+	flag.BoolVar(&enableNodeStatusSync, "enable-node-status-sync", false, "Enable sync of StorageOS node health, capacity and compute-only status to Kubernetes node labels and annotations.")
 
 	loggerOpts.BindFlags(flag.CommandLine)
 	flag.Parse()
@@ -188,6 +310,18 @@ func main() {
 	}
 	defer telemetryShutdown()
 
+	// Configure the key encryption key provider for namespace keys.
+	var kek keys.KEKProvider
+	switch kekProviderName {
+	case "":
+	case keys.LocalKEKProviderName:
+		kek = keys.NewLocalKEKProvider(kekLocalPath)
+	case keys.TransitKEKProviderName:
+		kek = keys.NewTransitKEKProvider(kekTransitAddress, kekTransitMount, kekTransitKey, kekTransitTokenPath, storageos.HTTPTimeout)
+	default:
+		fatal(fmt.Errorf("unknown provider %q", kekProviderName), "invalid key encryption key provider")
+	}
+
 	// Block startup until there is a working StorageOS API connection.  Unless
 	// we loop here, we'll get a number of failures on cold cluster start as it
 	// takes longer for the api to be ready than the api-manager to start.
@@ -213,11 +347,12 @@ func main() {
 
 	// Only attempt to grab leader lock once we have an API connection.
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "storageos-api-manager-lease",
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: healthProbeAddr,
+		Port:                   webhookPort,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "storageos-api-manager-lease",
 	})
 	if err != nil {
 		fatal(err, "unable to start manager")
@@ -252,8 +387,19 @@ func main() {
 		webhookServiceNamespace = namespace
 	}
 
+	// The certificate manager writes the webhook serving certificate to the
+	// directory that the webhook server reads it from.  Set it explicitly so
+	// the readiness check can inspect it.
+	webhookCertDir := filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
+	mgr.GetWebhookServer().CertDir = webhookCertDir
+	mgr.GetWebhookServer().CertName = webhookCertName
+	mgr.GetWebhookServer().KeyName = webhookKeyName
+
 	// Configure the certificate manager.
 	certOpts := cert.Options{
+		CertDir:             webhookCertDir,
+		CertName:            webhookCertName,
+		KeyName:             webhookKeyName,
 		CertValidity:        webhookCertValidity,
 		CertRefreshInterval: webhookCertRefreshInterval,
 		Service: &admissionregistrationv1.ServiceReference{
@@ -264,6 +410,21 @@ func main() {
 		SecretRef:                 &types.NamespacedName{Name: webhookSecretName, Namespace: webhookSecretNamespace},
 		MutatingWebhookConfigRefs: []types.NamespacedName{{Name: webhookConfigMutatingName}},
 	}
+	// The validating webhook configuration is created by the cluster-operator,
+	// and may not exist when running with an older version.  Only manage its
+	// certificate if it exists, otherwise certificate provisioning would fail.
+	if webhookConfigValidatingName != "" {
+		key := types.NamespacedName{Name: webhookConfigValidatingName}
+		err := uncachedClient.Get(context.Background(), key, &admissionregistrationv1.ValidatingWebhookConfiguration{})
+		switch {
+		case err == nil:
+			certOpts.ValidatingWebhookConfigRefs = []types.NamespacedName{key}
+		case apierrors.IsNotFound(err):
+			setupLog.Info("validating webhook configuration not found, validation disabled", "name", webhookConfigValidatingName)
+		default:
+			fatal(err, "unable to get validating webhook configuration")
+		}
+	}
 	// Create certificate manager without manager to start the provisioning
 	// immediately.
 	// NOTE: Certificate Manager implements nonLeaderElectionRunnable interface
@@ -302,26 +463,74 @@ func main() {
 		fatal(err, "failed to register shared volume reconciler")
 	}
 
+	labelSyncFilter, err := labels.NewFilter(labelSyncIncludePrefixes, labelSyncExcludePrefixes, labelSyncIncludeRegex, labelSyncExcludeRegex)
+	if err != nil {
+		fatal(err, "invalid label sync filter")
+	}
 	if enablePVCLabelSync {
 		setupLog.Info("starting pvc label sync controller ")
-		if err := pvclabel.NewReconciler(api, mgr.GetClient(), resyncPVCLabelDelay, resyncPVCLabelInterval).SetupWithManager(mgr, pvcLabelSyncWorkers); err != nil {
+		if err := pvclabel.NewReconciler(api, mgr.GetClient(), resyncPVCLabelDelay, resyncPVCLabelInterval, mgr.GetEventRecorderFor(EventSourceName), labelSyncFilter).SetupWithManager(mgr, pvcLabelSyncWorkers); err != nil {
 			fatal(err, "failed to register pvc label reconciler")
 		}
 	}
 	if enableNodeLabelSync {
 		setupLog.Info("starting node label sync controller ")
-		if err := nodelabel.NewReconciler(api, mgr.GetClient(), resyncNodeLabelDelay, resyncNodeLabelInterval).SetupWithManager(mgr, nodeLabelSyncWorkers); err != nil {
+		cordon := nodelabel.CordonPolicy{
+			Unschedulable: nodeLabelCordonUnschedulable,
+			Taints:        nodelabel.ParseCordonTaints(nodeLabelCordonTaints),
+		}
+		if err := nodelabel.NewReconciler(api, mgr.GetClient(), resyncNodeLabelDelay, resyncNodeLabelInterval, cordon, labelSyncFilter).SetupWithManager(mgr, nodeLabelSyncWorkers); err != nil {
 			fatal(err, "failed to register node label reconciler")
 		}
 	}
+	if enableNodeStatusSync {
+		setupLog.Info("starting node status sync")
+		if err := nodelabel.NewStatusSyncer(api, mgr.GetClient(), nodeStatusSyncInterval).SetupWithManager(mgr); err != nil {
+			fatal(err, "failed to register node status sync")
+		}
+	}
 	setupLog.Info("starting node delete controller")
-	if err := nodedelete.NewReconciler(api, mgr.GetClient(), gcNodeDeleteDelay, gcNodeDeleteInterval).SetupWithManager(mgr, nodeDeleteWorkers); err != nil {
+	if err := nodedelete.NewReconciler(api, mgr.GetClient(), gcNodeDeleteDelay, gcNodeDeleteInterval, mgr.GetEventRecorderFor(EventSourceName), nodeDeleteEvacuate, nodeDeleteGracePeriod, nodeDeleteBlockedThreshold).SetupWithManager(mgr, nodeDeleteWorkers); err != nil {
 		fatal(err, "failed to register node delete reconciler")
 	}
 	setupLog.Info("starting namespace delete controller")
-	if err := nsdelete.NewReconciler(api, mgr.GetClient(), gcNamespaceDeleteDelay, gcNamespaceDeleteInterval).SetupWithManager(mgr, nsDeleteWorkers); err != nil {
+	cascade := nsdelete.CascadePolicy{
+		Enabled:   nsDeleteCascade,
+		Retention: nsDeleteCascadeRetention,
+		DryRun:    nsDeleteCascadeDryRun,
+		Protected: nsdelete.ParseProtectedNamespaces(nsDeleteCascadeProtected),
+	}
+	if err := nsdelete.NewReconciler(api, mgr.GetClient(), gcNamespaceDeleteDelay, gcNamespaceDeleteInterval, cascade).SetupWithManager(mgr, nsDeleteWorkers); err != nil {
 		fatal(err, "failed to register namespace delete reconciler")
 	}
+	if enableNamespaceSync {
+		setupLog.Info("starting namespace sync controller")
+		if err := nssync.NewReconciler(api, mgr.GetClient(), resyncNamespaceSyncDelay, resyncNamespaceSyncInterval).SetupWithManager(mgr, nsSyncWorkers); err != nil {
+			fatal(err, "failed to register namespace sync reconciler")
+		}
+	}
+	if enablePolicyGroupSync {
+		setupLog.Info("starting policy group sync controller")
+		if err := policygroup.NewReconciler(api, mgr.GetClient(), gcPolicyGroupDelay, gcPolicyGroupInterval).SetupWithManager(mgr, policyGroupWorkers); err != nil {
+			fatal(err, "failed to register policy group sync reconciler")
+		}
+	}
+	if enableEncryptionKeyRotation {
+		setupLog.Info("starting namespace key rotation controller")
+		if err := keyrotation.NewReconciler(mgr.GetClient(), keys.New(compositeClient, kek), nsKeyRotationInterval).SetupWithManager(mgr, nsKeyRotationWorkers); err != nil {
+			fatal(err, "failed to register namespace key rotation reconciler")
+		}
+	}
+	if keyBackupInterval > 0 {
+		var keyBackupSecretRef *client.ObjectKey
+		if keyBackupSecretNamespace != "" {
+			keyBackupSecretRef = &client.ObjectKey{Name: keyBackupSecretName, Namespace: keyBackupSecretNamespace}
+		}
+		setupLog.Info("starting encryption key backup")
+		if err := keybackup.NewExporter(compositeClient, keys.New(compositeClient, kek), keyBackupPublicKeyPath, keyBackupPath, keyBackupSecretRef, labels.Default(), keyBackupInterval).SetupWithManager(mgr); err != nil {
+			fatal(err, "failed to register encryption key backup")
+		}
+	}
 	setupLog.Info("starting node fencing controller")
 	if err := fencer.NewReconciler(api, apiReset, mgr.GetClient(), nodePollInterval, nodeExpiryInterval).SetupWithManager(ctx, mgr, nodeFencerWorkers, nodeFencerRetryInterval, nodeFencerTimeout); err != nil {
 		fatal(err, "failed to register node fencing reconciler")
@@ -334,24 +543,71 @@ func main() {
 		os.Exit(1)
 	}
 
-	podMutator := podmutator.NewController(compositeClient, decoder, []podmutator.Mutator{
-		scheduler.NewPodSchedulerSetter(compositeClient, schedulerName),
-	})
+	// The webhooks are always registered so that requests from the webhook
+	// configurations created by the cluster-operator are answered.  Disabled
+	// mutators are not run.
+	podMutators := []podmutator.Mutator{}
+	if enablePodSchedulerMutator {
+		podMutators = append(podMutators, scheduler.NewPodSchedulerSetter(compositeClient, schedulerName))
+	}
+	podMutator := podmutator.NewController(compositeClient, decoder, podMutators)
 	mgr.GetWebhookServer().Register(webhookMutatePodsPath, &webhook.Admission{Handler: podMutator})
 
-	pvcMutator := pvcmutator.NewController(compositeClient, decoder, []pvcmutator.Mutator{
-		encryption.NewKeySetter(compositeClient, labels.Default()),
-		storageclass.NewAnnotationSetter(compositeClient),
-	})
+	pvcMutators := []pvcmutator.Mutator{}
+	if enablePVCEncryptionMutator {
+		pvcMutators = append(pvcMutators, encryption.NewKeySetter(compositeClient, kek, mgr.GetEventRecorderFor(EventSourceName), labels.Default()))
+	}
+	if enablePVCStorageClassMutator {
+		pvcMutators = append(pvcMutators, storageclass.NewAnnotationSetter(compositeClient))
+	}
+	if enablePVCTopologyMutator {
+		// The failure domain topology key is only set on nodes by node label
+		// sync.
+		if enableNodeLabelSync {
+			pvcMutators = append(pvcMutators, topology.NewKeySetter(compositeClient))
+		} else {
+			setupLog.Info("pvc topology mutator disabled, it requires -enable-node-label-sync")
+		}
+	}
+	pvcMutator := pvcmutator.NewController(compositeClient, decoder, pvcMutators)
 	mgr.GetWebhookServer().Register(webhookMutatePVCsPath, &webhook.Admission{Handler: pvcMutator})
 
-	setupLog.Info("starting manager")
+	mgr.GetWebhookServer().Register(webhookValidatePVCsPath, &webhook.Admission{Handler: storagevalidator.NewPVCValidator(compositeClient, decoder)})
+	mgr.GetWebhookServer().Register(webhookValidateStorageClassesPath, &webhook.Admission{Handler: storagevalidator.NewStorageClassValidator(decoder)})
+
+	// Report ready once the webhook server is serving a valid certificate.
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		fatal(err, "failed to add health check")
+	}
+	if err := mgr.AddReadyzCheck("webhook-cert", webhookhealth.CertChecker(webhookCertDir, webhookCertName, webhookKeyName)); err != nil {
+		fatal(err, "failed to add webhook certificate readiness check")
+	}
+	webhookAddr := net.JoinHostPort("localhost", strconv.Itoa(webhookPort))
+	if err := mgr.AddReadyzCheck("webhook-server", webhookhealth.ServerChecker(webhookAddr, webhookReadyTimeout)); err != nil {
+		fatal(err, "failed to add webhook server readiness check")
+	}
+
+	setupLog.Info("starting manager", "version", version.Version)
 	if err := mgr.Start(ctx); err != nil {
 		fatal(err, "failed to start manager")
 	}
 	setupLog.Info("shutdown complete")
 }
 
+// runKeyBackupCommand runs a key backup subcommand against the cluster in the
+// current kubeconfig context, then exits.
+func runKeyBackupCommand(name string, args []string) {
+	ctrl.SetLogger(zap.New())
+	k8s, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		fatal(err, "failed to create client")
+	}
+	if err := keybackup.RunCommand(ctrl.SetupSignalHandler(), k8s, name, args, os.Stdin, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
 func fatal(err error, msg string) {
 	setupLog.Error(err, msg)
 	os.Exit(1)